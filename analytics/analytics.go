@@ -0,0 +1,343 @@
+// Package analytics measures how well a Manifold user forecasts, from the bets they have
+// placed on markets that have since resolved.
+//
+// Each bet is read as a forecast of the probability the market moved to: a user who bets a
+// market up to 70% is taken to believe it is at least that likely. A user's forecast for a
+// market, or for an answer of a multiple choice market, is the probability after the last
+// bet they placed before it resolved, so every market counts once however often it was
+// traded.
+//
+// Markets resolved to CANCEL are left out of the scores, and of the profit.
+package analytics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/jonnyspicer/mango"
+)
+
+// Report holds a user's forecasting scores, calibration and profit.
+type Report struct {
+	Username string `json:"username,omitempty"`
+	// Forecasts is the number of resolved markets and answers the user forecast.
+	Forecasts int `json:"forecasts"`
+	// Brier is the mean squared error of the forecasts, from 0 at best to 1 at worst.
+	Brier float64 `json:"brier"`
+	// LogScore is the mean log probability given to the outcome, from 0 at best.
+	LogScore    float64          `json:"logScore"`
+	Calibration []CalibrationBin `json:"calibration"`
+	// Markets is the number of resolved markets the user bet on, and Profit what they made
+	// on them.
+	Markets int           `json:"markets"`
+	Profit  float64       `json:"profit"`
+	Groups  []GroupProfit `json:"groups"`
+	// Skipped holds the markets that couldn't be fetched, such as deleted or private
+	// markets, whose bets are left out of the report.
+	Skipped []SkippedMarket `json:"skipped,omitempty"`
+}
+
+// SkippedMarket is a market left out of a [Report] because it couldn't be fetched.
+type SkippedMarket struct {
+	Id    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// CalibrationBin holds the forecasts whose probability was in [Lower, Upper). For a well
+// calibrated user, Realised is close to Predicted in every bin.
+type CalibrationBin struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+	Count int     `json:"count"`
+	// Predicted is the mean forecast probability, and Realised the mean outcome, where YES
+	// is 1, NO is 0 and MKT is the resolution probability.
+	Predicted float64 `json:"predicted"`
+	Realised  float64 `json:"realised"`
+}
+
+// GroupProfit holds the profit made on resolved markets in a group. The profit on a market
+// in several groups is split evenly between them, so the profits of every group add up to
+// the user's total. Markets that are not in any group have an empty Slug.
+type GroupProfit struct {
+	Slug     string  `json:"slug"`
+	Markets  int     `json:"markets"`
+	Invested float64 `json:"invested"`
+	Profit   float64 `json:"profit"`
+}
+
+type config struct {
+	bins int
+	opts *mango.BatchOptions
+}
+
+// Option configures how a [Report] is built.
+type Option func(*config)
+
+// WithBins sets the number of calibration bins, which defaults to 10.
+func WithBins(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.bins = n
+		}
+	}
+}
+
+// WithBatchOptions sets the options used to fetch the markets the user has bet on.
+func WithBatchOptions(opts *mango.BatchOptions) Option {
+	return func(c *config) {
+		c.opts = opts
+	}
+}
+
+// Analyze fetches every bet placed by the user with the given username, and the markets
+// they were placed on, and builds a [Report] from them.
+//
+// Markets that can't be fetched are left out and listed in [Report.Skipped], so a deleted
+// or private market doesn't stop the rest being analyzed. An error is only returned if
+// none of the markets could be fetched.
+func Analyze(mc *mango.Client, username string, opts ...Option) (*Report, error) {
+	c := newConfig(opts)
+
+	var bets []mango.Bet
+	err := mc.PageBets(mango.GetBetsRequest{Username: username}, func(page []mango.Bet) error {
+		bets = append(bets, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting bets: %w", err)
+	}
+
+	var ids []string
+	for _, b := range bets {
+		ids = append(ids, b.ContractId)
+	}
+
+	markets, errs := mc.GetMarketsByIDs(ids, c.opts)
+
+	var skipped []SkippedMarket
+	for id, err := range errs {
+		skipped = append(skipped, SkippedMarket{Id: id, Error: err.Error()})
+	}
+	sort.Slice(skipped, func(i, j int) bool { return skipped[i].Id < skipped[j].Id })
+
+	if len(markets) == 0 && len(skipped) > 0 {
+		var all []error
+		for _, s := range skipped {
+			all = append(all, fmt.Errorf("error getting market %v: %v", s.Id, s.Error))
+		}
+		return nil, errors.Join(all...)
+	}
+
+	r := Compute(bets, markets, opts...)
+	r.Username = username
+	r.Skipped = skipped
+
+	return r, nil
+}
+
+// Compute builds a [Report] from bets and the markets they were placed on, keyed by id.
+// Bets on markets that are missing or unresolved are ignored.
+func Compute(bets []mango.Bet, markets map[string]mango.FullMarket, opts ...Option) *Report {
+	c := newConfig(opts)
+
+	type key struct{ market, answer string }
+
+	// the last bet on each market or answer is the user's forecast
+	latest := map[key]mango.Bet{}
+	byMarket := map[string][]mango.Bet{}
+	for _, b := range bets {
+		m, ok := markets[b.ContractId]
+		if !ok || !m.IsResolved || b.IsAnte {
+			continue
+		}
+		byMarket[m.Id] = append(byMarket[m.Id], b)
+
+		// redemptions and unfilled limit orders don't say anything new about the user's belief
+		if b.IsRedemption || (b.ProbBefore == 0 && b.ProbAfter == 0) {
+			continue
+		}
+		if m.ResolutionTime != 0 && b.CreatedTime > m.ResolutionTime {
+			continue
+		}
+		k := key{m.Id, b.AnswerId}
+		if prev, ok := latest[k]; !ok || b.CreatedTime >= prev.CreatedTime {
+			latest[k] = b
+		}
+	}
+
+	r := &Report{Calibration: make([]CalibrationBin, c.bins)}
+	for i := range r.Calibration {
+		r.Calibration[i].Lower = float64(i) / float64(c.bins)
+		r.Calibration[i].Upper = float64(i+1) / float64(c.bins)
+	}
+
+	// iterate in a fixed order so that floating point sums are reproducible
+	keys := make([]key, 0, len(latest))
+	for k := range latest {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].market != keys[j].market {
+			return keys[i].market < keys[j].market
+		}
+		return keys[i].answer < keys[j].answer
+	})
+
+	for _, k := range keys {
+		v, ok := outcome(markets[k.market], k.answer)
+		if !ok {
+			continue
+		}
+		p := latest[k].ProbAfter
+
+		r.Forecasts++
+		r.Brier += (p - v) * (p - v)
+		r.LogScore += logScore(p, v)
+
+		bin := &r.Calibration[min(int(p*float64(c.bins)), c.bins-1)]
+		bin.Count++
+		bin.Predicted += p
+		bin.Realised += v
+	}
+
+	if r.Forecasts > 0 {
+		r.Brier /= float64(r.Forecasts)
+		r.LogScore /= float64(r.Forecasts)
+	}
+	for i := range r.Calibration {
+		if n := r.Calibration[i].Count; n > 0 {
+			r.Calibration[i].Predicted /= float64(n)
+			r.Calibration[i].Realised /= float64(n)
+		}
+	}
+
+	ids := make([]string, 0, len(byMarket))
+	for id := range byMarket {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	groups := map[string]*GroupProfit{}
+	for _, id := range ids {
+		m := markets[id]
+		if m.Resolution == "CANCEL" {
+			continue
+		}
+
+		var invested, profit float64
+		for _, b := range byMarket[id] {
+			v, ok := outcome(m, b.AnswerId)
+			if !ok {
+				continue
+			}
+			if b.Amount > 0 {
+				invested += b.Amount
+			}
+			if b.Outcome == "NO" {
+				v = 1 - v
+			}
+			profit += b.Shares*v - b.Amount
+		}
+
+		r.Markets++
+		r.Profit += profit
+
+		slugs := m.GroupSlugs
+		if len(slugs) == 0 {
+			slugs = []string{""}
+		}
+		for _, s := range slugs {
+			g, ok := groups[s]
+			if !ok {
+				g = &GroupProfit{Slug: s}
+				groups[s] = g
+			}
+			g.Markets++
+			g.Invested += invested / float64(len(slugs))
+			g.Profit += profit / float64(len(slugs))
+		}
+	}
+
+	r.Groups = make([]GroupProfit, 0, len(groups))
+	for _, g := range groups {
+		r.Groups = append(r.Groups, *g)
+	}
+	sort.Slice(r.Groups, func(i, j int) bool {
+		if r.Groups[i].Profit != r.Groups[j].Profit {
+			return r.Groups[i].Profit > r.Groups[j].Profit
+		}
+		return r.Groups[i].Slug < r.Groups[j].Slug
+	})
+
+	return r
+}
+
+// WriteJSON writes the report to w as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{bins: 10}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// outcome returns the value a YES share in a market, or in one of its answers, paid out
+// when the market resolved. False is returned if the market was cancelled or the
+// resolution is unknown.
+func outcome(m mango.FullMarket, answerId string) (float64, bool) {
+	resolution, prob := m.Resolution, m.ResolutionProbability
+
+	if answerId != "" {
+		var a *mango.Answer
+		for i := range m.Answers {
+			if m.Answers[i].Id == answerId {
+				a = &m.Answers[i]
+			}
+		}
+		if a == nil {
+			return 0, false
+		}
+
+		switch {
+		case a.Resolution != "":
+			resolution, prob = a.Resolution, a.ResolutionProbability
+		case m.Resolution == a.Id:
+			// older multiple choice markets resolve to the id of the winning answer
+			resolution = "YES"
+		case m.Resolution != "" && m.Resolution != "CANCEL" && m.Resolution != "MKT":
+			resolution = "NO"
+		default:
+			return 0, false
+		}
+	}
+
+	switch resolution {
+	case "YES":
+		return 1, true
+	case "NO":
+		return 0, true
+	case "MKT":
+		return prob, true
+	default:
+		return 0, false
+	}
+}
+
+// logScore returns the log probability a forecast of p gave to an outcome of v. Forecasts are
+// kept away from 0 and 1 so that a single confident miss doesn't make the score infinite.
+func logScore(p, v float64) float64 {
+	const eps = 0.001
+	p = math.Min(math.Max(p, eps), 1-eps)
+
+	return v*math.Log(p) + (1-v)*math.Log(1-p)
+}
@@ -0,0 +1,127 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/jonnyspicer/mango"
+	"github.com/jonnyspicer/mango/mangotest"
+)
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func testMarkets() map[string]mango.FullMarket {
+	return map[string]mango.FullMarket{
+		"yes":    {Id: "yes", IsResolved: true, Resolution: "YES", ResolutionTime: 100, GroupSlugs: []string{"sports"}},
+		"no":     {Id: "no", IsResolved: true, Resolution: "NO", ResolutionTime: 100, GroupSlugs: []string{"sports", "politics"}},
+		"mkt":    {Id: "mkt", IsResolved: true, Resolution: "MKT", ResolutionProbability: 0.5, ResolutionTime: 100},
+		"cancel": {Id: "cancel", IsResolved: true, Resolution: "CANCEL", ResolutionTime: 100},
+		"open":   {Id: "open"},
+		"multi": {Id: "multi", IsResolved: true, Resolution: "MKT", ResolutionTime: 100, Answers: []mango.Answer{
+			{Id: "a", Resolution: "YES"},
+			{Id: "b", Resolution: "NO"},
+		}},
+	}
+}
+
+func TestCompute(t *testing.T) {
+	bets := []mango.Bet{
+		// the later bet is the forecast
+		{ContractId: "yes", Outcome: "YES", Amount: 10, Shares: 20, ProbBefore: 0.5, ProbAfter: 0.6, CreatedTime: 1},
+		{ContractId: "yes", Outcome: "YES", Amount: 10, Shares: 12, ProbBefore: 0.6, ProbAfter: 0.8, CreatedTime: 2},
+		// bets after resolution aren't forecasts
+		{ContractId: "yes", Outcome: "NO", ProbBefore: 0.8, ProbAfter: 0.1, CreatedTime: 200},
+		{ContractId: "no", Outcome: "NO", Amount: 10, Shares: 14, ProbBefore: 0.4, ProbAfter: 0.3, CreatedTime: 1},
+		{ContractId: "mkt", Outcome: "YES", Amount: 10, Shares: 16, ProbBefore: 0.5, ProbAfter: 0.7, CreatedTime: 1},
+		{ContractId: "cancel", Outcome: "YES", Amount: 10, Shares: 16, ProbBefore: 0.5, ProbAfter: 0.7, CreatedTime: 1},
+		{ContractId: "open", Outcome: "YES", Amount: 10, Shares: 16, ProbBefore: 0.5, ProbAfter: 0.7, CreatedTime: 1},
+		{ContractId: "multi", AnswerId: "a", Outcome: "YES", Amount: 5, Shares: 10, ProbBefore: 0.4, ProbAfter: 0.45, CreatedTime: 1},
+		{ContractId: "multi", AnswerId: "b", Outcome: "NO", Amount: 5, Shares: 8, ProbBefore: 0.4, ProbAfter: 0.35, CreatedTime: 1},
+		{ContractId: "missing", Outcome: "YES", Amount: 10, ProbBefore: 0.5, ProbAfter: 0.7, CreatedTime: 1},
+	}
+
+	r := Compute(bets, testMarkets(), WithBins(5))
+
+	if r.Forecasts != 5 {
+		t.Fatalf("expected 5 forecasts, got %d", r.Forecasts)
+	}
+
+	brier := (0.04 + 0.09 + 0.04 + 0.3025 + 0.1225) / 5
+	if !approx(r.Brier, brier) {
+		t.Errorf("expected brier %v, got %v", brier, r.Brier)
+	}
+	logs := (math.Log(0.8) + math.Log(0.7) + 0.5*math.Log(0.7) + 0.5*math.Log(0.3) + math.Log(0.45) + math.Log(0.65)) / 5
+	if !approx(r.LogScore, logs) {
+		t.Errorf("expected log score %v, got %v", logs, r.LogScore)
+	}
+
+	// 0.3 and 0.35 fall in the 20-40% bin
+	b := r.Calibration[1]
+	if b.Count != 2 || !approx(b.Predicted, 0.325) || !approx(b.Realised, 0) {
+		t.Errorf("unexpected bin %+v", b)
+	}
+	if len(r.Calibration) != 5 || r.Calibration[4].Count != 1 || r.Calibration[0].Count != 0 {
+		t.Errorf("unexpected calibration %+v", r.Calibration)
+	}
+
+	// yes: 32-20, no: 14-10, mkt: 8-10, multi: 10-5 + 8-5
+	if r.Markets != 4 || !approx(r.Profit, 12+4-2+8) {
+		t.Errorf("expected 4 markets and M22 profit, got %d and %v", r.Markets, r.Profit)
+	}
+
+	want := []GroupProfit{
+		{Slug: "sports", Markets: 2, Invested: 25, Profit: 14},
+		{Slug: "", Markets: 2, Invested: 20, Profit: 6},
+		{Slug: "politics", Markets: 1, Invested: 5, Profit: 2},
+	}
+	if len(r.Groups) != len(want) {
+		t.Fatalf("unexpected groups %+v", r.Groups)
+	}
+	for i := range want {
+		g := r.Groups[i]
+		if g.Slug != want[i].Slug || g.Markets != want[i].Markets || !approx(g.Invested, want[i].Invested) || !approx(g.Profit, want[i].Profit) {
+			t.Errorf("group %d: got %+v, want %+v", i, g, want[i])
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	srv := mangotest.NewServer()
+	defer srv.Close()
+
+	srv.AddMarket(mango.FullMarket{Id: "m1", IsResolved: true, Resolution: "YES"})
+	srv.AddMarket(mango.FullMarket{Id: "m2", IsResolved: true, Resolution: "NO"})
+	srv.AddBet(mango.Bet{ContractId: "m1", UserUsername: "alice", Outcome: "YES", Amount: 10, Shares: 15, ProbBefore: 0.6, ProbAfter: 0.7})
+	srv.AddBet(mango.Bet{ContractId: "m2", UserUsername: "alice", Outcome: "YES", Amount: 10, Shares: 20, ProbBefore: 0.4, ProbAfter: 0.5})
+	srv.AddBet(mango.Bet{ContractId: "m2", UserUsername: "bob", Outcome: "NO", Amount: 10, Shares: 20, ProbBefore: 0.5, ProbAfter: 0.4})
+
+	r, err := Analyze(srv.Client(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Username != "alice" || r.Forecasts != 2 || !approx(r.Brier, (0.09+0.25)/2) || !approx(r.Profit, -5) {
+		t.Errorf("unexpected report %+v", r)
+	}
+
+	// a market that can't be fetched is skipped, and the rest are still analyzed
+	srv.AddBet(mango.Bet{ContractId: "deleted", UserUsername: "alice", Outcome: "YES", Amount: 10, ProbBefore: 0.5, ProbAfter: 0.6})
+	r, err = Analyze(srv.Client(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Forecasts != 2 || !approx(r.Profit, -5) || len(r.Skipped) != 1 || r.Skipped[0].Id != "deleted" || r.Skipped[0].Error == "" {
+		t.Errorf("expected the deleted market to be skipped, got %+v", r)
+	}
+
+	// but if no market can be fetched, there's nothing to report
+	if _, err := Analyze(srv.Client(), "carol"); err != nil {
+		t.Errorf("expected no error for a user without bets, got %v", err)
+	}
+	srv.AddBet(mango.Bet{ContractId: "deleted", UserUsername: "carol", Amount: 10})
+	if _, err := Analyze(srv.Client(), "carol"); err == nil {
+		t.Error("expected an error when every market is missing")
+	}
+}
@@ -0,0 +1,141 @@
+package analytics
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strings"
+	"text/tabwriter"
+)
+
+// barWidth is the width of the calibration bars drawn by [Report.WriteText].
+const barWidth = 40
+
+// WriteText writes a plain text summary of the report to w, with a calibration chart, the
+// profit made in each group, and any markets that were skipped.
+//
+// Each row of the chart is a calibration bin. The bar shows the realised frequency, and the
+// | marks the mean predicted probability, so for a well calibrated user the bar ends close
+// to the mark.
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	if r.Username != "" {
+		fmt.Fprintf(tw, "user\t%v\n", r.Username)
+	}
+	fmt.Fprintf(tw, "forecasts\t%d\n", r.Forecasts)
+	fmt.Fprintf(tw, "brier score\t%.4f\n", r.Brier)
+	fmt.Fprintf(tw, "log score\t%.4f\n", r.LogScore)
+	fmt.Fprintf(tw, "markets\t%d\n", r.Markets)
+	fmt.Fprintf(tw, "profit\tM%.2f\n", r.Profit)
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "bin\tforecasts\tpredicted\trealised\t")
+	for _, b := range r.Calibration {
+		if b.Count == 0 {
+			fmt.Fprintf(tw, "%v\t0\t\t\t\n", binLabel(b))
+			continue
+		}
+		fmt.Fprintf(tw, "%v\t%d\t%.1f%%\t%.1f%%\t%v\n", binLabel(b), b.Count, b.Predicted*100, b.Realised*100, bar(b))
+	}
+
+	if len(r.Groups) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "group\tmarkets\tinvested\tprofit")
+		for _, g := range r.Groups {
+			slug := g.Slug
+			if slug == "" {
+				slug = "(none)"
+			}
+			fmt.Fprintf(tw, "%v\t%d\tM%.2f\tM%.2f\n", slug, g.Markets, g.Invested, g.Profit)
+		}
+	}
+
+	if len(r.Skipped) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "skipped market\terror")
+		for _, s := range r.Skipped {
+			fmt.Fprintf(tw, "%v\t%v\n", s.Id, s.Error)
+		}
+	}
+
+	return tw.Flush()
+}
+
+func binLabel(b CalibrationBin) string {
+	return fmt.Sprintf("%.0f-%.0f%%", b.Lower*100, b.Upper*100)
+}
+
+func bar(b CalibrationBin) string {
+	cells := []rune(strings.Repeat("█", int(math.Round(b.Realised*barWidth))) + strings.Repeat(" ", barWidth+1))[:barWidth+1]
+	cells[min(int(math.Round(b.Predicted*barWidth)), barWidth)] = '|'
+
+	return strings.TrimRight(string(cells), " ")
+}
+
+// WriteSVG writes the calibration curve of the report to w as an SVG image. Each bin with
+// forecasts in it is drawn as a point at its mean predicted probability and realised
+// frequency, sized by the number of forecasts, and the diagonal shows perfect calibration.
+func (r *Report) WriteSVG(w io.Writer) error {
+	const (
+		size   = 400
+		margin = 50
+		plot   = size - 2*margin
+	)
+
+	x := func(p float64) float64 { return margin + p*plot }
+	y := func(p float64) float64 { return size - margin - p*plot }
+
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n", size, size, size, size)
+	fmt.Fprintf(&sb, `<rect width="%d" height="%d" fill="white"/>`+"\n", size, size)
+
+	title := "Calibration"
+	if r.Username != "" {
+		title += " for " + r.Username
+	}
+	fmt.Fprintf(&sb, `<text x="%d" y="%d" text-anchor="middle" font-size="14">%v</text>`+"\n", size/2, margin/2, html.EscapeString(title))
+
+	// axes, grid and labels
+	for i := 0; i <= 10; i++ {
+		p := float64(i) / 10
+		fmt.Fprintf(&sb, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#eee"/>`+"\n", x(p), y(0), x(p), y(1))
+		fmt.Fprintf(&sb, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#eee"/>`+"\n", x(0), y(p), x(1), y(p))
+		if i%2 == 0 {
+			fmt.Fprintf(&sb, `<text x="%.1f" y="%.1f" text-anchor="middle">%d%%</text>`+"\n", x(p), y(0)+16, i*10)
+			fmt.Fprintf(&sb, `<text x="%.1f" y="%.1f" text-anchor="end">%d%%</text>`+"\n", x(0)-6, y(p)+4, i*10)
+		}
+	}
+	fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="black"/>`+"\n", margin, margin, plot, plot)
+	fmt.Fprintf(&sb, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#999" stroke-dasharray="4"/>`+"\n", x(0), y(0), x(1), y(1))
+	fmt.Fprintf(&sb, `<text x="%d" y="%d" text-anchor="middle">predicted</text>`+"\n", size/2, size-10)
+	fmt.Fprintf(&sb, `<text x="%d" y="%d" text-anchor="middle" transform="rotate(-90 %d %d)">realised</text>`+"\n", 14, size/2, 14, size/2)
+
+	// the calibration curve
+	var points []string
+	largest := 0
+	for _, b := range r.Calibration {
+		if b.Count > 0 {
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x(b.Predicted), y(b.Realised)))
+		}
+		largest = max(largest, b.Count)
+	}
+	if len(points) > 1 {
+		fmt.Fprintf(&sb, `<polyline points="%v" fill="none" stroke="steelblue" stroke-width="2"/>`+"\n", strings.Join(points, " "))
+	}
+	for _, b := range r.Calibration {
+		if b.Count == 0 {
+			continue
+		}
+		radius := 3 + 9*math.Sqrt(float64(b.Count)/float64(largest))
+		fmt.Fprintf(&sb, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="steelblue" fill-opacity="0.7"><title>%v: %d forecasts, %.1f%% realised</title></circle>`+"\n",
+			x(b.Predicted), y(b.Realised), radius, binLabel(b), b.Count, b.Realised*100)
+	}
+
+	sb.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
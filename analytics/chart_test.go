@@ -0,0 +1,86 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func testReport() *Report {
+	return &Report{
+		Username:  "alice",
+		Forecasts: 3,
+		Brier:     0.12,
+		LogScore:  -0.4,
+		Calibration: []CalibrationBin{
+			{Lower: 0, Upper: 0.5, Count: 1, Predicted: 0.25, Realised: 0},
+			{Lower: 0.5, Upper: 1, Count: 2, Predicted: 0.75, Realised: 1},
+		},
+		Markets: 3,
+		Profit:  12.5,
+		Groups:  []GroupProfit{{Slug: "", Markets: 3, Invested: 30, Profit: 12.5}},
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport().WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"brier score  0.1200", "profit       M12.50", "50-100%", "(none)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%v", want, out)
+		}
+	}
+
+	// the bar for the second bin is full, with the predicted mark three quarters along
+	want := strings.Repeat("█", 30) + "|" + strings.Repeat("█", 9)
+	if !strings.Contains(out, want) {
+		t.Errorf("expected a full bar, got:\n%v", out)
+	}
+}
+
+func TestWriteSVG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport().WriteSVG(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	circles := 0
+	dec := xml.NewDecoder(&buf)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid SVG: %v", err)
+		}
+		if el, ok := tok.(xml.StartElement); ok && el.Name.Local == "circle" {
+			circles++
+		}
+	}
+	if circles != 2 {
+		t.Errorf("expected a point for each bin, got %d", circles)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport().WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var r Report
+	if err := json.Unmarshal(buf.Bytes(), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Username != "alice" || len(r.Calibration) != 2 || r.Groups[0].Profit != 12.5 {
+		t.Errorf("unexpected round trip %+v", r)
+	}
+}
@@ -15,7 +15,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strconv"
 )
@@ -78,6 +77,11 @@ func (mc *Client) GetBets(gbr GetBetsRequest) (*[]Bet, error) {
 // optional parameters:
 //   - [GetCommentsRequest.ContractId]
 //   - [GetCommentsRequest.ContractSlug]
+//   - [GetCommentsRequest.UserId]
+//   - [GetCommentsRequest.Limit] - Optional. Manifold's default is 1000.
+//   - [GetCommentsRequest.Page] - Optional. The page of Limit comments to return, counting from 0.
+//
+// One of the contract ID, contract slug or user ID must be given.
 //
 // If there is an error making the request, then nil and an error
 // will be returned.
@@ -86,13 +90,24 @@ func (mc *Client) GetBets(gbr GetBetsRequest) (*[]Bet, error) {
 //
 // [the Manifold API docs for GET /v0/comments]: https://docs.manifold.markets/api#get-v0comments
 func (mc *Client) GetComments(gcr GetCommentsRequest) (*[]Comment, error) {
-	if gcr.ContractId == "" && gcr.ContractSlug == "" {
-		return nil, fmt.Errorf("either contractID or contractSlug must be specified")
+	if gcr.ContractId == "" && gcr.ContractSlug == "" && gcr.UserId == "" {
+		return nil, fmt.Errorf("one of contractID, contractSlug or userId must be specified")
+	}
+
+	var limit, page string
+	if gcr.Limit > 0 {
+		limit = strconv.FormatInt(gcr.Limit, 10)
+	}
+	if gcr.Page > 0 {
+		page = strconv.FormatInt(gcr.Page, 10)
 	}
 
 	resp, err := mc.getRequest(requestURL(mc.url, getComments, "", "",
 		"contractId", gcr.ContractId,
 		"contractSlug", gcr.ContractSlug,
+		"userId", gcr.UserId,
+		"limit", limit,
+		"page", page,
 	))
 	if err != nil {
 		return nil, fmt.Errorf("error making http request: %v", err)
@@ -161,6 +176,38 @@ func (mc *Client) GetGroups(userId *string) (*[]Group, error) {
 	return parseResponse(resp, []Group{})
 }
 
+// GetGroupMembers returns the members of a group, which for a topic are the users who
+// follow it.
+//
+// If there is an error making the request, then nil and an error
+// will be returned.
+//
+// This endpoint isn't documented by Manifold.
+func (mc *Client) GetGroupMembers(id string) (*[]GroupMember, error) {
+	resp, err := mc.getRequest(requestURL(mc.url, getGroupByID, id, membersSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("error making http request: %v", err)
+	}
+
+	return parseResponse(resp, []GroupMember{})
+}
+
+// GetRelatedGroups returns the groups directly above and below a group in Manifold's
+// topic hierarchy. Use [Client.TopicTree] to follow the hierarchy further.
+//
+// If there is an error making the request, then nil and an error
+// will be returned.
+//
+// This endpoint isn't documented by Manifold.
+func (mc *Client) GetRelatedGroups(id string) (*RelatedGroups, error) {
+	resp, err := mc.getRequest(requestURL(mc.url, getGroupByID, id, groupsSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("error making http request: %v", err)
+	}
+
+	return parseResponse(resp, RelatedGroups{})
+}
+
 // GetMarketByID returns a [FullMarket] by its unique id.
 //
 // If there is an error making the request, then nil and an error
@@ -199,6 +246,8 @@ func (mc *Client) GetMarketBySlug(slug string) (*FullMarket, error) {
 // optional parameters:
 //   - [GetMarketsRequest.Before] - the ID of the market before which the list will start.
 //   - [GetMarketsRequest.Limit] - the maximum and default limit is 1000.
+//   - [GetMarketsRequest.Sort] - one of "created-time" (the default), "updated-time", "last-bet-time" or "last-comment-time".
+//   - [GetMarketsRequest.Order] - "desc" (the default) or "asc".
 //
 // If there is an error making the request, then nil and an error
 // will be returned.
@@ -216,6 +265,7 @@ func (mc *Client) GetMarkets(gmr GetMarketsRequest) (*[]LiteMarket, error) {
 		"",
 		"",
 		"limit", strconv.FormatInt(gmr.Limit, 10), "before", gmr.Before,
+		"sort", gmr.Sort, "order", gmr.Order,
 	))
 	if err != nil {
 		return nil, fmt.Errorf("error making http request: %v", err)
@@ -225,6 +275,8 @@ func (mc *Client) GetMarkets(gmr GetMarketsRequest) (*[]LiteMarket, error) {
 }
 
 // GetMarketsForGroup returns a slice of [LiteMarket] and an error. It takes a group ID to retrieve the markets for.
+// Manifold limits how many markets it returns, so use [Client.PageGroupMarkets], which takes the group's slug rather than
+// its ID, to fetch every market in a large group.
 //
 // If there is an error making the request, then nil and an error
 // will be returned.
@@ -235,7 +287,7 @@ func (mc *Client) GetMarkets(gmr GetMarketsRequest) (*[]LiteMarket, error) {
 func (mc *Client) GetMarketsForGroup(id string) (*[]LiteMarket, error) {
 	resp, err := mc.getRequest(requestURL(mc.url, getGroupByID, id, marketsSuffix))
 	if err != nil {
-		log.Printf("error making http request: %v", err)
+		return nil, fmt.Errorf("error making http request: %v", err)
 	}
 
 	return parseResponse(resp, []LiteMarket{})
@@ -716,9 +768,31 @@ func (mc *Client) CloseMarket(marketId string, ct *int64) error {
 //
 // [the Manifold API docs for POST /v0/market/marketId/group]: https://docs.manifold.markets/api#post-v0marketmarketidgroup
 func (mc *Client) AddMarketToGroup(marketId, gi string) error {
+	if err := mc.setMarketGroup(marketId, gi, false); err != nil {
+		return fmt.Errorf("adding market to group failed: %w", err)
+	}
+	return nil
+}
+
+// RemoveMarketFromGroup removes a given market from a given group.
+//
+// If there is an error making the request, then an error will be returned.
+//
+// See [the Manifold API docs for POST /v0/market/marketId/group] for more details.
+//
+// [the Manifold API docs for POST /v0/market/marketId/group]: https://docs.manifold.markets/api#post-v0marketmarketidgroup
+func (mc *Client) RemoveMarketFromGroup(marketId, gi string) error {
+	if err := mc.setMarketGroup(marketId, gi, true); err != nil {
+		return fmt.Errorf("removing market from group failed: %w", err)
+	}
+	return nil
+}
+
+func (mc *Client) setMarketGroup(marketId, gi string, remove bool) error {
 	g := struct {
 		GroupId string `json:"groupId,omitempty"`
-	}{gi}
+		Remove  bool   `json:"remove,omitempty"`
+	}{gi, remove}
 
 	jsonBody, err := json.Marshal(g)
 	if err != nil {
@@ -741,7 +815,60 @@ func (mc *Client) AddMarketToGroup(marketId, gi string) error {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("adding market to group failed with status %d: %s", resp.StatusCode, readErrorBody(resp))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, readErrorBody(resp))
+	}
+
+	return nil
+}
+
+// FollowGroup makes the authenticated user follow a group, also known as a topic, so its
+// markets show up in their feed.
+//
+// If there is an error making the request, then an error will be returned.
+//
+// This endpoint isn't documented by Manifold.
+func (mc *Client) FollowGroup(groupId string) error {
+	if err := mc.followGroup(groupId, true); err != nil {
+		return fmt.Errorf("following group failed: %w", err)
+	}
+	return nil
+}
+
+// UnfollowGroup makes the authenticated user stop following a group.
+//
+// If there is an error making the request, then an error will be returned.
+//
+// This endpoint isn't documented by Manifold.
+func (mc *Client) UnfollowGroup(groupId string) error {
+	if err := mc.followGroup(groupId, false); err != nil {
+		return fmt.Errorf("unfollowing group failed: %w", err)
+	}
+	return nil
+}
+
+func (mc *Client) followGroup(groupId string, follow bool) error {
+	g := struct {
+		GroupId string `json:"groupId"`
+		Follow  bool   `json:"follow"`
+	}{groupId, follow}
+
+	jsonBody, err := json.Marshal(g)
+	if err != nil {
+		return fmt.Errorf("error marshalling request body: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, requestURL(mc.url, postFollowTopic, "", ""), bytes.NewReader(jsonBody))
+	if err != nil {
+		return fmt.Errorf("error creating http request: %v", err)
+	}
+
+	resp, err := mc.doRequest(req)
+	if err != nil {
+		return fmt.Errorf("error making http request: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, readErrorBody(resp))
 	}
 
 	return nil
@@ -791,6 +918,8 @@ func (mc *Client) ResolveMarket(marketId string, rmr ResolveMarketRequest) error
 // SellShares creates a new market. It takes a [SellSharesRequest] which has the following parameters:
 //   - [SellSharesRequest.Outcome] - Optional. One of "YES" or "NO". If omitted and only one kind of shares are held, sells those. TODO: make this an enum
 //   - [SellSharesRequest.Shares] - Optional. If omitted, all shares held will be sold.
+//   - [SellSharesRequest.FractionalShares] - Optional. A number of shares that isn't whole, sent in place of Shares.
+//   - [SellSharesRequest.AnswerId] - Optional. The answer to sell shares in, for multiple choice markets.
 //
 // If there is an error making the request, then an error will be returned.
 //
@@ -825,11 +954,13 @@ func (mc *Client) SellShares(marketId string, ssr SellSharesRequest) error {
 	return nil
 }
 
-// PostComment makes a new bet on a market. It takes a [PostCommentRequest] which has the following parameters:
-//   - [PostCommentRequest.ContractId] - Required.
+// PostComment posts a new comment on a market. It takes a [PostCommentRequest] which has the following parameters:
+//   - [PostCommentRequest.ContractId] - Optional. Defaults to marketId.
 //   - [PostCommentRequest.Content] - Optional. A plaintext string.
 //   - [PostCommentRequest.Html] - Optional.
 //   - [PostCommentRequest.Markdown] - Optional.
+//   - [PostCommentRequest.Doc] - Optional. A rich text document, sent in place of Content.
+//   - [PostCommentRequest.ReplyToCommentId] - Optional. The comment to reply to.
 //
 // If there is an error making the request, then an error will be returned.
 //
@@ -837,6 +968,10 @@ func (mc *Client) SellShares(marketId string, ssr SellSharesRequest) error {
 //
 // [the Manifold API docs for POST /v0/comment]: https://docs.manifold.markets/api#post-v0comment
 func (mc *Client) PostComment(marketId string, pcr PostCommentRequest) error {
+	if pcr.ContractId == "" {
+		pcr.ContractId = marketId
+	}
+
 	jsonBody, err := json.Marshal(pcr)
 	if err != nil {
 		return fmt.Errorf("error making http request: %v", err)
@@ -864,6 +999,56 @@ func (mc *Client) PostComment(marketId string, pcr PostCommentRequest) error {
 	return nil
 }
 
+// ReplyToComment posts a comment replying to another comment, on the same market. It takes
+// the parent [Comment] and a [PostCommentRequest] with the reply, whose
+// [PostCommentRequest.ContractId] and [PostCommentRequest.ReplyToCommentId] are set from
+// the parent.
+//
+// If there is an error making the request, then an error will be returned.
+//
+// See [the Manifold API docs for POST /v0/comment] for more details.
+//
+// [the Manifold API docs for POST /v0/comment]: https://docs.manifold.markets/api#post-v0comment
+func (mc *Client) ReplyToComment(parent Comment, pcr PostCommentRequest) error {
+	pcr.ContractId = parent.ContractId
+	pcr.ReplyToCommentId = parent.Id
+	return mc.PostComment(parent.ContractId, pcr)
+}
+
+// React reacts to a comment or market. It takes a [ReactRequest] which has the following parameters:
+//   - [ReactRequest.ContentId] - Required. The id of the comment or market.
+//   - [ReactRequest.ContentType] - Required. Either "comment" or "contract".
+//   - [ReactRequest.ReactionType] - Optional. Either "like", the default, or "dislike".
+//   - [ReactRequest.Remove] - Optional. Takes the reaction away instead of adding it.
+//
+// If there is an error making the request, then an error will be returned.
+//
+// See [the Manifold API docs for POST /v0/react] for more details.
+//
+// [the Manifold API docs for POST /v0/react]: https://docs.manifold.markets/api#post-v0react
+func (mc *Client) React(rr ReactRequest) error {
+	jsonBody, err := json.Marshal(rr)
+	if err != nil {
+		return fmt.Errorf("error marshalling request body: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, requestURL(mc.url, postReact, "", ""), bytes.NewReader(jsonBody))
+	if err != nil {
+		return fmt.Errorf("error creating http request: %v", err)
+	}
+
+	resp, err := mc.doRequest(req)
+	if err != nil {
+		return fmt.Errorf("error making http request: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("reacting failed with status %d: %s", resp.StatusCode, readErrorBody(resp))
+	}
+
+	return nil
+}
+
 // PostMultiBet places multiple YES bets on answers in a multiple choice market.
 func (mc *Client) PostMultiBet(req PostMultiBetRequest) error {
 	jsonBody, err := json.Marshal(req)
@@ -1083,14 +1268,15 @@ func parseResponse[S any](r *http.Response, s S) (*S, error) {
 }
 
 func (mc *Client) doRequest(req *http.Request) (*http.Response, error) {
-	if mc.key == "" {
-		return nil, fmt.Errorf("no API key found")
+	key, err := mc.creds.APIKey()
+	if err != nil {
+		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Key %v", mc.key))
+	req.Header.Set("Authorization", fmt.Sprintf("Key %v", key))
 
-	return mc.client.Do(req)
+	return mc.send(req)
 }
 
 // getRequest makes an authenticated GET request to the given URL.
@@ -1101,7 +1287,12 @@ func (mc *Client) getRequest(url string) (*http.Response, error) {
 		return nil, fmt.Errorf("error creating http request: %v", err)
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Key %v", mc.key))
+	// most GET endpoints don't need authentication, so carry on without a key if there isn't one
+	if key, err := mc.creds.APIKey(); err == nil {
+		req.Header.Set("Authorization", fmt.Sprintf("Key %v", key))
+	} else {
+		mc.logger.Debug("sending request without API key", "error", err)
+	}
 
-	return mc.client.Do(req)
+	return mc.send(req)
 }
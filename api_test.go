@@ -446,6 +446,24 @@ func TestSellShares(t *testing.T) {
 	}
 }
 
+func TestSellSharesFractional(t *testing.T) {
+	for _, tt := range []struct {
+		ssr  SellSharesRequest
+		want string
+	}{
+		{SellSharesRequest{Outcome: "YES", Shares: 10}, `{"outcome":"YES","shares":10}`},
+		{SellSharesRequest{Outcome: "NO", Shares: 10, FractionalShares: 12.5, AnswerId: "a1"}, `{"outcome":"NO","answerId":"a1","shares":12.5}`},
+	} {
+		b, err := json.Marshal(tt.ssr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("expected %v, got %s", tt.want, b)
+		}
+	}
+}
+
 func TestPostComment(t *testing.T) {
 	pcr := PostCommentRequest{
 		ContractId: "123contractid",
@@ -957,3 +975,27 @@ func TestGetLeagues(t *testing.T) {
 		t.Errorf("unexpected result: %+v", result)
 	}
 }
+
+func TestRemoveMarketFromGroup(t *testing.T) {
+	var receivedBody []byte
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	mc := ClientInstance(server.Client(), &server.URL, &testKey)
+	defer mc.Destroy()
+
+	err := mc.RemoveMarketFromGroup("123marketid", "456groupid")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if string(receivedBody) != `{"groupId":"456groupid","remove":true}` {
+		t.Errorf("unexpected body %s", receivedBody)
+	}
+}
@@ -0,0 +1,113 @@
+package arb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jonnyspicer/mango"
+	"github.com/jonnyspicer/mango/internal/slicesx"
+)
+
+// ErrNotProfitable is returned by [Scanner.Execute] when prices have moved and an
+// opportunity no longer makes the scanner's minimum profit.
+var ErrNotProfitable = errors.New("opportunity is no longer profitable")
+
+// Execution represents the outcome of trading an [Opportunity].
+type Execution struct {
+	// Opportunity is the opportunity as it was traded, sized at the prices just before trading.
+	Opportunity Opportunity `json:"opportunity"`
+	// Bets are the bets placed, one for each leg that was traded.
+	Bets []mango.Bet `json:"bets"`
+	// RolledBack is true if a leg failed, and the shares bought by the others were sold again.
+	RolledBack bool `json:"rolledBack"`
+}
+
+// Execute trades an opportunity found by [Scanner.Scan].
+//
+// Prices will have moved since the scan, so the trade is sized again with the latest
+// markets first, and [ErrNotProfitable] is returned if it no longer makes the scanner's
+// minimum profit. Each leg is then bet on in turn. Manifold can't place bets on several
+// markets at once, so if a bet fails, or buys fewer shares than planned by more than the
+// scanner's slippage, the shares bought by the legs already placed are sold again, and the
+// failure is returned along with any errors selling them.
+//
+// Selling shares back costs fees, and the price may have moved in the meantime, so a
+// rolled back trade will usually lose a little mana.
+func (s *Scanner) Execute(ctx context.Context, o Opportunity) (*Execution, error) {
+	var ids []string
+	outcomes := make([]bool, len(o.Legs))
+	for i, leg := range o.Legs {
+		ids = append(ids, leg.MarketId)
+		outcomes[i] = leg.Outcome == "YES"
+	}
+
+	ids = slicesx.Dedupe(ids)
+	markets, errs := s.mc.GetMarketsByIDs(ids, nil)
+	if len(errs) > 0 {
+		// in the order of the legs, so the error is the same every time
+		var all []error
+		for _, id := range ids {
+			if err, ok := errs[id]; ok {
+				all = append(all, fmt.Errorf("error getting market %v: %w", id, err))
+			}
+		}
+		return nil, errors.Join(all...)
+	}
+
+	fresh, err := s.size(o.Link, outcomes, markets)
+	if err != nil {
+		return nil, err
+	}
+	fresh.Edge = o.Edge
+	if fresh.Profit < s.minProfit {
+		return nil, fmt.Errorf("%w: expected profit of %.2f", ErrNotProfitable, fresh.Profit)
+	}
+
+	ex := &Execution{Opportunity: *fresh}
+	for _, leg := range fresh.Legs {
+		if err := ctx.Err(); err != nil {
+			return ex, s.rollback(ex, err)
+		}
+
+		bet, err := s.mc.PostBet(mango.PostBetRequest{
+			Amount:     leg.Amount,
+			ContractId: leg.MarketId,
+			Outcome:    leg.Outcome,
+			AnswerId:   leg.AnswerId,
+		})
+		if err != nil {
+			return ex, s.rollback(ex, fmt.Errorf("error betting on %v: %w", leg.Ref, err))
+		}
+		ex.Bets = append(ex.Bets, *bet)
+		s.logger.Info("placed bet", "market", leg.Ref, "outcome", leg.Outcome, "amount", bet.Amount, "shares", bet.Shares)
+
+		if bet.Shares < leg.Shares*(1-s.slippage) {
+			return ex, s.rollback(ex, fmt.Errorf("bet on %v bought %.2f shares, expected %.2f", leg.Ref, bet.Shares, leg.Shares))
+		}
+	}
+
+	return ex, nil
+}
+
+// rollback sells the shares bought by every bet of an execution, most recent first, and
+// returns cause joined with any errors selling them.
+func (s *Scanner) rollback(ex *Execution, cause error) error {
+	s.logger.Warn("rolling back trade", "error", cause)
+
+	errs := []error{cause}
+	for i := len(ex.Bets) - 1; i >= 0; i-- {
+		bet := ex.Bets[i]
+		err := s.mc.SellShares(bet.ContractId, mango.SellSharesRequest{
+			Outcome:          bet.Outcome,
+			FractionalShares: bet.Shares,
+			AnswerId:         bet.AnswerId,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error selling shares in %v: %w", bet.ContractId, err))
+		}
+	}
+	ex.RolledBack = true
+
+	return errors.Join(errs...)
+}
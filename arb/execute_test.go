@@ -0,0 +1,131 @@
+package arb
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+	"github.com/jonnyspicer/mango/mangotest"
+)
+
+func TestExecute(t *testing.T) {
+	srv := mangotest.NewServer()
+	defer srv.Close()
+
+	srv.AddMarket(market("a", "a", 0.3))
+	srv.AddMarket(market("b", "b", 0.6))
+
+	s := NewScanner(srv.Client())
+	link := Link{Kind: Duplicate, Markets: []Ref{{MarketId: "a"}, {MarketId: "b"}}}
+
+	opps, err := s.Check(context.Background(), link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opps) != 1 {
+		t.Fatalf("expected one opportunity, got %+v", opps)
+	}
+
+	ex, err := s.Execute(context.Background(), opps[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ex.Bets) != 2 || ex.RolledBack {
+		t.Fatalf("expected two bets, got %+v", ex)
+	}
+
+	spent := 1000 - srv.Me().Balance
+	if math.Abs(spent-ex.Opportunity.Cost) > 1e-6 {
+		t.Errorf("expected to spend M%v, spent M%v", ex.Opportunity.Cost, spent)
+	}
+	for _, b := range ex.Bets {
+		if b.Shares < ex.Opportunity.Payout-1e-3 {
+			t.Errorf("expected at least %v shares, got %+v", ex.Opportunity.Payout, b)
+		}
+	}
+
+	// having traded, the markets are priced consistently and there is nothing left to do
+	if _, err := s.Execute(context.Background(), opps[0]); !errors.Is(err, ErrNotProfitable) {
+		t.Errorf("expected ErrNotProfitable, got %v", err)
+	}
+}
+
+func TestExecuteRollback(t *testing.T) {
+	srv := mangotest.NewServer()
+	defer srv.Close()
+
+	srv.AddMarket(market("a", "a", 0.3))
+	srv.AddMarket(market("b", "b", 0.6))
+
+	s := NewScanner(srv.Client(), WithMaxCost(50))
+	link := Link{Kind: Duplicate, Markets: []Ref{{MarketId: "a"}, {MarketId: "b"}}}
+
+	opps, err := s.Check(context.Background(), link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opps) != 1 {
+		t.Fatalf("expected one opportunity, got %+v", opps)
+	}
+
+	// the second leg can't be bet on, so the first has to be sold again
+	srv.UpdateMarket("b", func(m *mango.FullMarket) {
+		m.CloseTime = time.Now().Add(-time.Minute).UnixMilli()
+	})
+
+	ex, err := s.Execute(context.Background(), opps[0])
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if ex == nil || !ex.RolledBack || len(ex.Bets) != 1 {
+		t.Fatalf("expected one bet to be rolled back, got %+v", ex)
+	}
+
+	held := 0.0
+	for _, b := range srv.Bets() {
+		if b.ContractId == "a" {
+			held += b.Shares
+		}
+	}
+	if math.Abs(held) > 1e-6 {
+		t.Errorf("expected no shares left in a, got %v", held)
+	}
+
+	// selling back costs fees, but only a little
+	if lost := 1000 - srv.Me().Balance; lost <= 0 || lost > 5 {
+		t.Errorf("expected to lose a little mana rolling back, lost M%v", lost)
+	}
+
+	if m, _ := srv.Market("a"); math.Abs(m.Probability-0.3) > 0.01 {
+		t.Errorf("expected a to be back near 0.3, got %v", m.Probability)
+	}
+}
+
+func TestExecuteMissingMarkets(t *testing.T) {
+	srv := mangotest.NewServer()
+	defer srv.Close()
+
+	srv.AddMarket(market("a", "a", 0.3))
+
+	s := NewScanner(srv.Client())
+	o := Opportunity{
+		Link: Link{Kind: Exclusive, Markets: []Ref{{MarketId: "c"}, {MarketId: "a"}, {MarketId: "b"}}},
+		Legs: []Leg{{Ref: Ref{MarketId: "c"}, Outcome: "NO"}, {Ref: Ref{MarketId: "a"}, Outcome: "NO"}, {Ref: Ref{MarketId: "b"}, Outcome: "NO"}},
+	}
+
+	// every market that couldn't be fetched is reported, in the order of the legs
+	_, err := s.Execute(context.Background(), o)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if want := "error getting market c: "; !strings.HasPrefix(err.Error(), want) || !strings.Contains(err.Error(), "\nerror getting market b: ") {
+		t.Errorf("expected errors for c and then b, got %v", err)
+	}
+	if len(srv.Bets()) != 0 {
+		t.Errorf("expected no bets, got %+v", srv.Bets())
+	}
+}
@@ -0,0 +1,396 @@
+package arb
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jonnyspicer/mango"
+)
+
+// maxLegs is the most markets a [Link] can hold. Every combination of outcomes is
+// considered when looking for a trade, so this keeps scans quick.
+const maxLegs = 12
+
+// Ref refers to a binary market, or to one answer of a multiple choice market.
+type Ref struct {
+	MarketId string `json:"marketId"`
+	AnswerId string `json:"answerId,omitempty"`
+}
+
+// String returns the market id, followed by a slash and the answer id if there is one.
+func (r Ref) String() string {
+	if r.AnswerId == "" {
+		return r.MarketId
+	}
+	return r.MarketId + "/" + r.AnswerId
+}
+
+// Kind is a logical relationship between markets.
+type Kind string
+
+const (
+	// Duplicate markets ask the same question, so they all resolve the same way.
+	Duplicate Kind = "duplicate"
+	// Implies is a chain of markets where each one resolving YES means the next one will
+	// too, so their probabilities should rise along the chain. For example, "Will it reach
+	// 100?" implies "Will it reach 50?".
+	Implies Kind = "implies"
+	// Exclusive markets can't both resolve YES, so their probabilities should add up to
+	// no more than 100%.
+	Exclusive Kind = "exclusive"
+	// Partition markets are exclusive, and one of them must resolve YES, so their
+	// probabilities should add up to exactly 100%.
+	Partition Kind = "partition"
+)
+
+// Link represents markets whose prices are tied together by a relationship.
+type Link struct {
+	Kind    Kind   `json:"kind"`
+	Markets []Ref  `json:"markets"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// validate returns an error if the link can't be scanned.
+func (l Link) validate() error {
+	switch l.Kind {
+	case Duplicate, Implies, Exclusive, Partition:
+	default:
+		return fmt.Errorf("unknown link kind %q", l.Kind)
+	}
+	if len(l.Markets) < 2 || len(l.Markets) > maxLegs {
+		return fmt.Errorf("a link must have between 2 and %d markets, got %d", maxLegs, len(l.Markets))
+	}
+
+	seen := map[Ref]bool{}
+	for _, r := range l.Markets {
+		if seen[r] {
+			return fmt.Errorf("market %v is in the link twice", r)
+		}
+		seen[r] = true
+	}
+
+	return nil
+}
+
+// worlds returns every way the linked markets could resolve, with true for YES.
+func (l Link) worlds() [][]bool {
+	n := len(l.Markets)
+	all := func(v bool) []bool {
+		w := make([]bool, n)
+		for i := range w {
+			w[i] = v
+		}
+		return w
+	}
+
+	var out [][]bool
+	switch l.Kind {
+	case Duplicate:
+		out = append(out, all(true), all(false))
+	case Implies:
+		// once one market in the chain resolves YES, so do all those after it
+		for i := 0; i <= n; i++ {
+			w := all(false)
+			for j := i; j < n; j++ {
+				w[j] = true
+			}
+			out = append(out, w)
+		}
+	case Exclusive, Partition:
+		if l.Kind == Exclusive {
+			out = append(out, all(false))
+		}
+		for i := 0; i < n; i++ {
+			w := all(false)
+			w[i] = true
+			out = append(out, w)
+		}
+	}
+
+	return out
+}
+
+// A Linker finds links between markets.
+type Linker func(markets []mango.FullMarket) []Link
+
+// Links returns a [Linker] that always returns the given links, for relationships that are
+// known in advance. The linked markets don't have to be among those searched for.
+func Links(links ...Link) Linker {
+	return func([]mango.FullMarket) []Link {
+		return links
+	}
+}
+
+// Duplicates returns a [Linker] that links open binary markets whose questions are near
+// duplicates: they contain the same numbers and the same number of negations, such as
+// "not" or "won't", and at least threshold of their other words, as a fraction of the
+// words in either, are shared. A threshold of 0.8 works well.
+func Duplicates(threshold float64) Linker {
+	return func(markets []mango.FullMarket) []Link {
+		binary := openBinary(markets)
+
+		var links []Link
+		for i := range binary {
+			a := words(binary[i].Question)
+			for j := i + 1; j < len(binary); j++ {
+				b := words(binary[j].Question)
+				if !sameNumbers(a, b) || negations(a) != negations(b) || similarity(a, b) < threshold {
+					continue
+				}
+				links = append(links, Link{
+					Kind:    Duplicate,
+					Markets: []Ref{{MarketId: binary[i].Id}, {MarketId: binary[j].Id}},
+					Reason:  fmt.Sprintf("%q and %q ask the same question", binary[i].Question, binary[j].Question),
+				})
+			}
+		}
+		return links
+	}
+}
+
+// Ladders returns a [Linker] that links open binary markets whose questions are the same
+// apart from a single number, and which ask whether something will be above or below it,
+// or happen by a date. "Will it reach 100?" implies "Will it reach 50?", and "Will it
+// happen by 2025?" implies "Will it happen by 2030?". Negated questions run the other way:
+// "Will it not reach 50?" implies "Will it not reach 100?". Pairs where only one of the
+// questions is negated aren't linked.
+func Ladders() Linker {
+	return func(markets []mango.FullMarket) []Link {
+		binary := openBinary(markets)
+
+		var links []Link
+		for i := range binary {
+			a := words(binary[i].Question)
+			for j := i + 1; j < len(binary); j++ {
+				b := words(binary[j].Question)
+
+				x, y, ok := differingNumber(a, b)
+				if !ok || negations(a) != negations(b) {
+					continue
+				}
+
+				var higherImplies bool
+				switch direction(a) {
+				case up:
+					higherImplies = true
+				case down:
+					higherImplies = false
+				default:
+					continue
+				}
+				if negations(a)%2 == 1 {
+					higherImplies = !higherImplies
+				}
+
+				first, second := binary[i], binary[j]
+				if (x > y) != higherImplies {
+					first, second = second, first
+				}
+				links = append(links, Link{
+					Kind:    Implies,
+					Markets: []Ref{{MarketId: first.Id}, {MarketId: second.Id}},
+					Reason:  fmt.Sprintf("%q implies %q", first.Question, second.Question),
+				})
+			}
+		}
+		return links
+	}
+}
+
+// Answers returns a [Linker] that links the answers of open multiple choice markets whose
+// answers sum to one, as exactly one of them will resolve YES.
+//
+// Manifold keeps these answers adding up to 100% itself, so they are rarely far out of line.
+// Each answer is also simulated as its own pool, ignoring that rebalancing, so check the
+// bets actually placed when trading them.
+func Answers() Linker {
+	return func(markets []mango.FullMarket) []Link {
+		var links []Link
+		for _, m := range markets {
+			if m.IsResolved || !m.ShouldAnswersSumToOne || len(m.Answers) < 2 || len(m.Answers) > maxLegs {
+				continue
+			}
+
+			l := Link{Kind: Partition, Reason: fmt.Sprintf("the answers to %q sum to one", m.Question)}
+			for _, a := range m.Answers {
+				l.Markets = append(l.Markets, Ref{MarketId: m.Id, AnswerId: a.Id})
+			}
+			links = append(links, l)
+		}
+		return links
+	}
+}
+
+func openBinary(markets []mango.FullMarket) []mango.FullMarket {
+	var out []mango.FullMarket
+	for _, m := range markets {
+		if m.OutcomeType == mango.Binary && !m.IsResolved {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+var (
+	wordPattern = regexp.MustCompile(`[a-z]+|\$?[0-9][0-9,]*(?:\.[0-9]+)?[%k]?`)
+
+	stopWords = map[string]bool{
+		"a": true, "an": true, "the": true, "will": true, "be": true, "is": true, "of": true,
+		"in": true, "on": true, "at": true, "to": true, "for": true, "and": true, "or": true,
+	}
+
+	// negationWords turn a question into its opposite. Contractions like "won't" are split
+	// into "won" and "t".
+	negationWords = map[string]bool{
+		"not": true, "no": true, "never": true, "t": true, "without": true,
+		"fail": true, "fails": true, "failed": true,
+	}
+
+	upWords   = []string{"above", "over", "exceed", "exceeds", "more", "greater", "least", "higher", "reach", "reaches", "hit", "hits"}
+	downWords = []string{"below", "under", "less", "fewer", "lower", "most", "by", "before"}
+)
+
+// words returns the words and numbers of a question, in order, without stop words.
+func words(question string) []string {
+	var out []string
+	for _, w := range wordPattern.FindAllString(strings.ToLower(question), -1) {
+		if !stopWords[w] {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// number returns the value of a word if it is a number.
+func number(w string) (float64, bool) {
+	w = strings.TrimPrefix(w, "$")
+	w = strings.ReplaceAll(w, ",", "")
+
+	scale := 1.0
+	switch {
+	case strings.HasSuffix(w, "%"):
+		w = strings.TrimSuffix(w, "%")
+	case strings.HasSuffix(w, "k"):
+		w, scale = strings.TrimSuffix(w, "k"), 1000
+	}
+
+	f, err := strconv.ParseFloat(w, 64)
+	return f * scale, err == nil
+}
+
+// sameNumbers reports whether two questions contain the same numbers.
+func sameNumbers(a, b []string) bool {
+	var x, y []float64
+	for _, w := range a {
+		if f, ok := number(w); ok {
+			x = append(x, f)
+		}
+	}
+	for _, w := range b {
+		if f, ok := number(w); ok {
+			y = append(y, f)
+		}
+	}
+
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// negations returns the number of words in a question that negate it.
+func negations(words []string) int {
+	n := 0
+	for _, w := range words {
+		if negationWords[w] {
+			n++
+		}
+	}
+	return n
+}
+
+// similarity returns the number of distinct words two questions share, as a fraction of
+// the distinct words in either.
+func similarity(a, b []string) float64 {
+	set := map[string]int{}
+	for _, w := range a {
+		set[w] |= 1
+	}
+	for _, w := range b {
+		set[w] |= 2
+	}
+
+	shared := 0
+	for _, v := range set {
+		if v == 3 {
+			shared++
+		}
+	}
+	if len(set) == 0 {
+		return 0
+	}
+	return float64(shared) / float64(len(set))
+}
+
+// differingNumber returns the numbers that differ between two questions, if they are the
+// same apart from one number.
+func differingNumber(a, b []string) (float64, float64, bool) {
+	if len(a) != len(b) {
+		return 0, 0, false
+	}
+
+	var x, y float64
+	found := false
+	for i := range a {
+		if a[i] == b[i] {
+			continue
+		}
+		fa, okA := number(a[i])
+		fb, okB := number(b[i])
+		if !okA || !okB || found || fa == fb {
+			return 0, 0, false
+		}
+		x, y, found = fa, fb, true
+	}
+
+	return x, y, found
+}
+
+type trend int
+
+const (
+	none trend = iota
+	up
+	down
+)
+
+// direction returns whether a question asks if something will be above a number or
+// below it. Questions that ask about both, or neither, have no direction.
+func direction(question []string) trend {
+	has := func(list []string) bool {
+		for _, w := range question {
+			for _, l := range list {
+				if w == l {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	switch isUp, isDown := has(upWords), has(downWords); {
+	case isUp && !isDown:
+		return up
+	case isDown && !isUp:
+		return down
+	default:
+		return none
+	}
+}
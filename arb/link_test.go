@@ -0,0 +1,115 @@
+package arb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jonnyspicer/mango"
+)
+
+func TestWorlds(t *testing.T) {
+	refs := []Ref{{MarketId: "a"}, {MarketId: "b"}, {MarketId: "c"}}
+
+	tests := []struct {
+		kind Kind
+		want [][]bool
+	}{
+		{Duplicate, [][]bool{{true, true, true}, {false, false, false}}},
+		{Implies, [][]bool{{true, true, true}, {false, true, true}, {false, false, true}, {false, false, false}}},
+		{Exclusive, [][]bool{{false, false, false}, {true, false, false}, {false, true, false}, {false, false, true}}},
+		{Partition, [][]bool{{true, false, false}, {false, true, false}, {false, false, true}}},
+	}
+
+	for _, tt := range tests {
+		if got := (Link{Kind: tt.kind, Markets: refs}).worlds(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.kind, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Link{Kind: Duplicate, Markets: []Ref{{MarketId: "a"}, {MarketId: "b"}}}
+	if err := valid.validate(); err != nil {
+		t.Errorf("expected a valid link, got %v", err)
+	}
+
+	invalid := []Link{
+		{Kind: "related", Markets: valid.Markets},
+		{Kind: Duplicate, Markets: []Ref{{MarketId: "a"}}},
+		{Kind: Duplicate, Markets: []Ref{{MarketId: "a"}, {MarketId: "a"}}},
+	}
+	for _, l := range invalid {
+		if err := l.validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", l)
+		}
+	}
+}
+
+func binary(id, question string) mango.FullMarket {
+	return mango.FullMarket{Id: id, Question: question, OutcomeType: mango.Binary}
+}
+
+func TestDuplicates(t *testing.T) {
+	markets := []mango.FullMarket{
+		binary("a", "Will Bitcoin be above $100k at the end of 2025?"),
+		binary("b", "Will bitcoin be above $100k at the end of 2025"),
+		binary("c", "Will Bitcoin be above $120k at the end of 2025?"),
+		binary("d", "Will Ethereum flip Bitcoin in 2025?"),
+		binary("e", "Will Biden win the 2024 election?"),
+		binary("f", "Will Biden not win the 2024 election?"),
+		binary("g", "Biden won't win the 2024 election?"),
+	}
+
+	links := Duplicates(0.8)(markets)
+	if len(links) != 1 {
+		t.Fatalf("expected one link, got %+v", links)
+	}
+	if want := []Ref{{MarketId: "a"}, {MarketId: "b"}}; links[0].Kind != Duplicate || !reflect.DeepEqual(links[0].Markets, want) {
+		t.Errorf("expected a and b to be linked, got %+v", links[0])
+	}
+}
+
+func TestLadders(t *testing.T) {
+	markets := []mango.FullMarket{
+		binary("a", "Will Bitcoin be above $100k at the end of 2025?"),
+		binary("b", "Will Bitcoin be above $120k at the end of 2025?"),
+		binary("c", "Will GPT-5 be released by 2026?"),
+		binary("d", "Will GPT-5 be released by 2025?"),
+		binary("e", "Will Bitcoin be $100k at the end of 2025?"),
+		binary("f", "Will Bitcoin not be above $100k at the end of 2025?"),
+		binary("g", "Will Bitcoin not be above $120k at the end of 2025?"),
+		binary("h", "Will Tesla be above $500 in 2025?"),
+		binary("i", "Will Tesla not be above $600 in 2025?"),
+	}
+
+	links := Ladders()(markets)
+	want := []Link{
+		{Kind: Implies, Markets: []Ref{{MarketId: "b"}, {MarketId: "a"}}},
+		{Kind: Implies, Markets: []Ref{{MarketId: "d"}, {MarketId: "c"}}},
+		{Kind: Implies, Markets: []Ref{{MarketId: "f"}, {MarketId: "g"}}},
+	}
+	if len(links) != len(want) {
+		t.Fatalf("expected %d links, got %+v", len(want), links)
+	}
+	for i := range want {
+		if links[i].Kind != want[i].Kind || !reflect.DeepEqual(links[i].Markets, want[i].Markets) {
+			t.Errorf("expected %+v, got %+v", want[i], links[i])
+		}
+	}
+}
+
+func TestAnswers(t *testing.T) {
+	markets := []mango.FullMarket{
+		{Id: "a", Question: "Who will win?", ShouldAnswersSumToOne: true, Answers: []mango.Answer{{Id: "x"}, {Id: "y"}}},
+		{Id: "b", Question: "Which will happen?", Answers: []mango.Answer{{Id: "x"}, {Id: "y"}}},
+		{Id: "c", Question: "Who won?", ShouldAnswersSumToOne: true, IsResolved: true, Answers: []mango.Answer{{Id: "x"}, {Id: "y"}}},
+	}
+
+	links := Answers()(markets)
+	if len(links) != 1 {
+		t.Fatalf("expected one link, got %+v", links)
+	}
+	if want := []Ref{{"a", "x"}, {"a", "y"}}; links[0].Kind != Partition || !reflect.DeepEqual(links[0].Markets, want) {
+		t.Errorf("expected the answers of a to be linked, got %+v", links[0])
+	}
+}
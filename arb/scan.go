@@ -0,0 +1,465 @@
+// Package arb looks for markets on Manifold that are priced inconsistently with each
+// other, and trades them for a profit that doesn't depend on how they resolve.
+//
+// Markets are tied together by a [Link], such as two markets asking the same question, or
+// one market implying another. Links are found by [Linker] functions, either from rules
+// known in advance or heuristics over the markets a search returns. A [Scanner] checks
+// the prices of every linked market and, for each link that is out of line, works out the
+// set of bets that pays out the same or more whatever happens and costs less than that
+// payout, taking price impact and fees into account:
+//
+//	s := arb.NewScanner(mc, arb.WithLinkers(arb.Duplicates(0.8), arb.Ladders()), arb.WithMaxCost(100))
+//
+//	opps, err := s.Scan(ctx, mango.SearchMarketsRequest{Term: "bitcoin"})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	for _, o := range opps {
+//		if _, err := s.Execute(ctx, o); err != nil {
+//			log.Print(err)
+//		}
+//	}
+package arb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"sort"
+
+	"github.com/jonnyspicer/mango"
+	"github.com/jonnyspicer/mango/internal/optimize"
+	"github.com/jonnyspicer/mango/internal/slicesx"
+)
+
+// Leg represents one bet of an [Opportunity].
+type Leg struct {
+	Ref
+	Outcome string `json:"outcome"`
+	// Prob is the probability of the market, or answer, when it was scanned.
+	Prob float64 `json:"prob"`
+	// Amount is the mana to bet, which is expected to buy Shares and move the market to ProbAfter.
+	Amount    float64 `json:"amount"`
+	Shares    float64 `json:"shares"`
+	ProbAfter float64 `json:"probAfter"`
+}
+
+// Opportunity represents a set of bets on linked markets that makes a profit however they
+// resolve.
+type Opportunity struct {
+	Link Link  `json:"link"`
+	Legs []Leg `json:"legs"`
+	// Edge is the guaranteed profit per share at the scanned prices, before price impact and fees.
+	Edge float64 `json:"edge"`
+	// Cost is the mana spent on every leg, and Payout the least the legs will pay out
+	// between them, whatever happens.
+	Cost   float64 `json:"cost"`
+	Payout float64 `json:"payout"`
+	Profit float64 `json:"profit"`
+}
+
+// Scanner finds and trades arbitrage opportunities.
+type Scanner struct {
+	mc        *mango.Client
+	linkers   []Linker
+	minEdge   float64
+	minProfit float64
+	maxCost   float64
+	slippage  float64
+	logger    *slog.Logger
+}
+
+// Option configures a [Scanner].
+type Option func(*Scanner)
+
+// WithLinkers adds linkers that the scanner uses to find links between markets.
+func WithLinkers(linkers ...Linker) Option {
+	return func(s *Scanner) {
+		s.linkers = append(s.linkers, linkers...)
+	}
+}
+
+// WithMinEdge sets the smallest guaranteed profit per share, at the scanned prices, that
+// is worth sizing a trade for. It defaults to 0.01.
+func WithMinEdge(edge float64) Option {
+	return func(s *Scanner) {
+		s.minEdge = edge
+	}
+}
+
+// WithMinProfit sets the smallest guaranteed profit, in mana, that an opportunity must
+// make once price impact and fees are taken into account. It defaults to 1.
+func WithMinProfit(profit float64) Option {
+	return func(s *Scanner) {
+		s.minProfit = profit
+	}
+}
+
+// WithMaxCost limits how much mana an opportunity can spend. By default there is no limit,
+// and opportunities are sized to make the most profit.
+func WithMaxCost(cost float64) Option {
+	return func(s *Scanner) {
+		s.maxCost = cost
+	}
+}
+
+// WithSlippage sets how many fewer shares than planned, as a fraction, a bet can buy
+// before [Scanner.Execute] rolls the trade back. It defaults to 0.02.
+func WithSlippage(slippage float64) Option {
+	return func(s *Scanner) {
+		s.slippage = slippage
+	}
+}
+
+// WithLogger sets the logger the scanner reports progress to.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Scanner) {
+		s.logger = logger
+	}
+}
+
+// NewScanner returns a scanner that uses mc to search for markets, check their prices and trade.
+func NewScanner(mc *mango.Client, opts ...Option) *Scanner {
+	s := &Scanner{
+		mc:        mc,
+		minEdge:   0.01,
+		minProfit: 1,
+		slippage:  0.02,
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Scan searches for markets with each query, links them with the scanner's linkers and
+// returns the opportunities found among the links, most profitable first.
+//
+// Links whose markets can't be fetched, or aren't CPMM markets, are skipped.
+func (s *Scanner) Scan(ctx context.Context, queries ...mango.SearchMarketsRequest) ([]Opportunity, error) {
+	var candidates []mango.FullMarket
+	seen := map[string]bool{}
+	for _, q := range queries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		found, err := s.mc.SearchMarkets(q)
+		if err != nil {
+			return nil, fmt.Errorf("error searching markets: %w", err)
+		}
+		for _, m := range *found {
+			if !seen[m.Id] {
+				seen[m.Id] = true
+				candidates = append(candidates, m)
+			}
+		}
+	}
+
+	var links []Link
+	for _, linker := range s.linkers {
+		for _, l := range linker(candidates) {
+			if err := l.validate(); err != nil {
+				s.logger.Warn("skipping invalid link", "link", l, "error", err)
+				continue
+			}
+			links = append(links, l)
+		}
+	}
+	s.logger.Debug("linked markets", "markets", len(candidates), "links", len(links))
+
+	return s.Check(ctx, links...)
+}
+
+// Check returns the opportunities among the given links, most profitable first, without
+// searching for markets.
+func (s *Scanner) Check(ctx context.Context, links ...Link) ([]Opportunity, error) {
+	var ids []string
+	for _, l := range links {
+		for _, r := range l.Markets {
+			ids = append(ids, r.MarketId)
+		}
+	}
+
+	probs, err := s.probs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// only markets with an edge at the current prices need their pools fetched
+	type candidate struct {
+		link     Link
+		outcomes []bool
+		edge     float64
+	}
+	var found []candidate
+	var poolIds []string
+	for _, l := range links {
+		p, ok := linkProbs(l, probs)
+		if !ok {
+			s.logger.Debug("skipping link without prices", "link", l)
+			continue
+		}
+
+		outcomes, edge := bestTrade(l, p)
+		if edge < s.minEdge {
+			continue
+		}
+
+		found = append(found, candidate{l, outcomes, edge})
+		for _, r := range l.Markets {
+			poolIds = append(poolIds, r.MarketId)
+		}
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	markets, errs := s.mc.GetMarketsByIDs(slicesx.Dedupe(poolIds), nil)
+	for id, err := range errs {
+		s.logger.Warn("error getting market", "market", id, "error", err)
+	}
+
+	var opps []Opportunity
+	for _, c := range found {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		o, err := s.size(c.link, c.outcomes, markets)
+		if err != nil {
+			s.logger.Debug("skipping link", "link", c.link, "error", err)
+			continue
+		}
+		o.Edge = c.edge
+
+		if o.Profit >= s.minProfit {
+			opps = append(opps, *o)
+		}
+	}
+
+	sort.SliceStable(opps, func(i, j int) bool { return opps[i].Profit > opps[j].Profit })
+
+	return opps, nil
+}
+
+// probs returns the probability of each market and answer, keyed by [Ref.String].
+func (s *Scanner) probs(ctx context.Context, ids []string) (map[string]float64, error) {
+	ids = slicesx.Dedupe(ids)
+	out := map[string]float64{}
+
+	for start := 0; start < len(ids); start += 100 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		chunk := ids[start:min(start+100, len(ids))]
+		probs, err := s.mc.GetMarketProbs(chunk)
+		if err != nil {
+			return nil, fmt.Errorf("error getting market probabilities: %w", err)
+		}
+
+		for id, mp := range *probs {
+			if len(mp.AnswerProbs) == 0 {
+				out[id] = mp.Prob
+			}
+			for a, p := range mp.AnswerProbs {
+				out[Ref{MarketId: id, AnswerId: a}.String()] = p
+			}
+		}
+	}
+
+	return out, nil
+}
+
+func linkProbs(l Link, probs map[string]float64) ([]float64, bool) {
+	out := make([]float64, len(l.Markets))
+	for i, r := range l.Markets {
+		p, ok := probs[r.String()]
+		if !ok {
+			return nil, false
+		}
+		out[i] = p
+	}
+	return out, true
+}
+
+// bestTrade returns the outcomes to buy in each of the link's markets, true for YES, that
+// give the most guaranteed profit per share at the given prices, and that profit.
+func bestTrade(l Link, probs []float64) ([]bool, float64) {
+	worlds := l.worlds()
+	n := len(l.Markets)
+
+	var best []bool
+	bestEdge := math.Inf(-1)
+	for combo := 0; combo < 1<<n; combo++ {
+		outcomes := make([]bool, n)
+		price := 0.0
+		for i := range outcomes {
+			outcomes[i] = combo&(1<<i) != 0
+			if outcomes[i] {
+				price += probs[i]
+			} else {
+				price += 1 - probs[i]
+			}
+		}
+
+		if edge := guaranteed(outcomes, worlds) - price; edge > bestEdge {
+			best, bestEdge = outcomes, edge
+		}
+	}
+
+	return best, bestEdge
+}
+
+// guaranteed returns the least that one share of each outcome pays out between them,
+// whichever of the worlds comes about.
+func guaranteed(outcomes []bool, worlds [][]bool) float64 {
+	least := math.Inf(1)
+	for _, w := range worlds {
+		paid := 0.0
+		for i, o := range outcomes {
+			if o == w[i] {
+				paid++
+			}
+		}
+		least = math.Min(least, paid)
+	}
+	return least
+}
+
+// size works out how many shares of each outcome to buy for the most guaranteed profit,
+// within the scanner's maximum cost.
+func (s *Scanner) size(l Link, outcomes []bool, markets map[string]mango.FullMarket) (*Opportunity, error) {
+	books := make([]book, len(l.Markets))
+	for i, r := range l.Markets {
+		m, ok := markets[r.MarketId]
+		if !ok {
+			return nil, fmt.Errorf("market %v wasn't found", r.MarketId)
+		}
+		b, err := newBook(m, r.AnswerId)
+		if err != nil {
+			return nil, err
+		}
+		books[i] = b
+	}
+
+	payout := guaranteed(outcomes, l.worlds())
+	outcome := func(i int) string {
+		if outcomes[i] {
+			return "YES"
+		}
+		return "NO"
+	}
+
+	cost := func(shares float64) float64 {
+		total := 0.0
+		for i, b := range books {
+			total += b.cost(outcome(i), shares)
+		}
+		return total
+	}
+	profit := func(shares float64) float64 {
+		return shares*payout - cost(shares)
+	}
+
+	// find a number of shares that is too many, either because it costs more than the
+	// maximum or because the price has moved so far that it makes a loss
+	limit := 1.0
+	for limit < 1e9 && profit(limit) > 0 && (s.maxCost == 0 || cost(limit) < s.maxCost) {
+		limit *= 2
+	}
+	if s.maxCost > 0 {
+		limit = bisect(0, limit, func(x float64) bool { return cost(x) <= s.maxCost })
+	}
+
+	shares := optimize.Maximise(0, limit, profit)
+
+	o := &Opportunity{Link: l, Payout: shares * payout}
+	for i, b := range books {
+		amount := b.cost(outcome(i), shares)
+		sim, err := mango.SimulateBet(b.pool, b.p, outcome(i), amount)
+		if err != nil {
+			return nil, err
+		}
+
+		o.Legs = append(o.Legs, Leg{
+			Ref:       l.Markets[i],
+			Outcome:   outcome(i),
+			Prob:      mango.CPMMProbability(b.pool, b.p),
+			Amount:    amount,
+			Shares:    sim.Shares,
+			ProbAfter: sim.ProbAfter,
+		})
+		o.Cost += amount
+	}
+	o.Profit = o.Payout - o.Cost
+
+	return o, nil
+}
+
+// book represents the CPMM pool that a leg trades against.
+type book struct {
+	pool mango.Pool
+	p    float64
+}
+
+func newBook(m mango.FullMarket, answerId string) (book, error) {
+	if answerId == "" {
+		if len(m.Pool) == 0 || m.P == 0 {
+			return book{}, fmt.Errorf("market %v is not a CPMM market", m.Id)
+		}
+		return book{m.Pool, m.P}, nil
+	}
+
+	for _, a := range m.Answers {
+		if a.Id == answerId {
+			if a.PoolYes <= 0 || a.PoolNo <= 0 {
+				return book{}, fmt.Errorf("answer %v of market %v has no pool", answerId, m.Id)
+			}
+			return book{mango.Pool{"YES": a.PoolYes, "NO": a.PoolNo}, 0.5}, nil
+		}
+	}
+
+	return book{}, fmt.Errorf("market %v has no answer %v", m.Id, answerId)
+}
+
+// cost returns the mana it takes to buy shares of outcome, including fees.
+func (b book) cost(outcome string, shares float64) float64 {
+	if shares <= 0 {
+		return 0
+	}
+
+	bought := func(amount float64) float64 {
+		sim, err := mango.SimulateBet(b.pool, b.p, outcome, amount)
+		if err != nil {
+			return 0
+		}
+		return sim.Shares
+	}
+
+	hi := shares
+	for bought(hi) < shares && hi < 1e12 {
+		hi *= 2
+	}
+
+	return bisect(0, hi, func(amount float64) bool { return bought(amount) < shares })
+}
+
+// bisect returns the boundary in [lo, hi] between where ok holds, below it, and where it
+// doesn't, above it.
+func bisect(lo, hi float64, ok func(float64) bool) float64 {
+	for i := 0; i < 100 && hi-lo > 1e-9; i++ {
+		mid := (lo + hi) / 2
+		if ok(mid) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return hi
+}
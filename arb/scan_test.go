@@ -0,0 +1,108 @@
+package arb
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/jonnyspicer/mango"
+	"github.com/jonnyspicer/mango/mangotest"
+)
+
+// market returns a binary market whose pool puts its probability at prob.
+func market(id, question string, prob float64) mango.FullMarket {
+	return mango.FullMarket{
+		Id:       id,
+		Question: question,
+		Pool:     mango.Pool{"YES": 1000 * (1 - prob), "NO": 1000 * prob},
+		P:        0.5,
+	}
+}
+
+func TestBestTrade(t *testing.T) {
+	dup := Link{Kind: Duplicate, Markets: []Ref{{MarketId: "a"}, {MarketId: "b"}}}
+	outcomes, edge := bestTrade(dup, []float64{0.3, 0.6})
+	if want := []bool{true, false}; outcomes[0] != want[0] || outcomes[1] != want[1] {
+		t.Errorf("expected to buy YES in a and NO in b, got %v", outcomes)
+	}
+	if math.Abs(edge-0.3) > 1e-9 {
+		t.Errorf("expected an edge of 0.3, got %v", edge)
+	}
+
+	// the more likely market implies the less likely one, so this is priced consistently
+	implies := Link{Kind: Implies, Markets: []Ref{{MarketId: "a"}, {MarketId: "b"}}}
+	if _, edge := bestTrade(implies, []float64{0.3, 0.6}); edge > 1e-9 {
+		t.Errorf("expected no edge, got %v", edge)
+	}
+
+	partition := Link{Kind: Partition, Markets: []Ref{{MarketId: "a"}, {MarketId: "b"}, {MarketId: "c"}}}
+	outcomes, edge = bestTrade(partition, []float64{0.5, 0.4, 0.3})
+	for i, o := range outcomes {
+		if o {
+			t.Errorf("expected to buy NO in every answer, got YES in %d", i)
+		}
+	}
+	if math.Abs(edge-0.2) > 1e-9 {
+		t.Errorf("expected an edge of 0.2, got %v", edge)
+	}
+}
+
+func TestScan(t *testing.T) {
+	srv := mangotest.NewServer()
+	defer srv.Close()
+
+	srv.AddMarket(market("a", "Will Bitcoin be above $100k at the end of 2025?", 0.3))
+	srv.AddMarket(market("b", "Will bitcoin be above $100k at the end of 2025", 0.6))
+	srv.AddMarket(market("c", "Will it rain in London tomorrow?", 0.5))
+
+	s := NewScanner(srv.Client(), WithLinkers(Duplicates(0.8)))
+	opps, err := s.Scan(context.Background(), mango.SearchMarketsRequest{Term: "bitcoin"}, mango.SearchMarketsRequest{Term: "rain"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opps) != 1 {
+		t.Fatalf("expected one opportunity, got %+v", opps)
+	}
+
+	o := opps[0]
+	legs := map[string]Leg{}
+	for _, leg := range o.Legs {
+		legs[leg.MarketId] = leg
+	}
+	if legs["a"].Outcome != "YES" || legs["b"].Outcome != "NO" {
+		t.Errorf("expected to buy YES in a and NO in b, got %+v", o.Legs)
+	}
+	if o.Profit <= 1 || math.Abs(o.Profit-(o.Payout-o.Cost)) > 1e-6 {
+		t.Errorf("expected a profit, got %+v", o)
+	}
+
+	// each leg must pay out at least the guaranteed payout on its own, whichever way the markets resolve
+	for _, leg := range o.Legs {
+		if leg.Shares < o.Payout-1e-3 {
+			t.Errorf("expected at least %v shares, got %+v", o.Payout, leg)
+		}
+	}
+	if legs["a"].ProbAfter <= 0.3 || legs["b"].ProbAfter >= 0.6 || legs["a"].ProbAfter > legs["b"].ProbAfter {
+		t.Errorf("expected the trade to move the markets towards but not past each other, got %+v", o.Legs)
+	}
+
+	capped, err := NewScanner(srv.Client(), WithLinkers(Duplicates(0.8)), WithMaxCost(10)).Scan(context.Background(), mango.SearchMarketsRequest{Term: "bitcoin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(capped) != 1 || capped[0].Cost > 10+1e-6 || capped[0].Profit >= o.Profit {
+		t.Errorf("expected a smaller opportunity costing at most M10, got %+v", capped)
+	}
+
+	// a consistent link, and a link to a market that doesn't exist, are skipped
+	opps, err = s.Check(context.Background(),
+		Link{Kind: Implies, Markets: []Ref{{MarketId: "a"}, {MarketId: "b"}}},
+		Link{Kind: Duplicate, Markets: []Ref{{MarketId: "a"}, {MarketId: "missing"}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opps) != 0 {
+		t.Errorf("expected no opportunities, got %+v", opps)
+	}
+}
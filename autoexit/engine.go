@@ -0,0 +1,332 @@
+// Package autoexit sells positions automatically when rules say so, giving Manifold the
+// stop-loss, take-profit and similar orders it doesn't have itself.
+//
+// Each [Rule] pairs a [Condition], such as [StopLoss], [TakeProfit] or [BeforeClose], with
+// the fraction of the position to sell when it is met. An [Engine] checks the rules against
+// the authenticated user's positions, either on a polling loop with [Engine.Run] or when
+// told to with [Engine.Evaluate], and sells with [mango.Client.SellShares]. Each rule fires
+// once for a position, until the position is closed, and every sale is recorded in an
+// audit trail:
+//
+//	e, err := autoexit.New(mc, []autoexit.Rule{
+//		{Name: "stop-loss", Sell: 1, When: autoexit.StopLoss(0.2)},
+//		{Name: "take-half", Sell: 0.5, When: autoexit.TakeProfit(100)},
+//		{Name: "flatten", Sell: 1, When: autoexit.BeforeClose(time.Hour)},
+//	}, autoexit.WithAuditLog("exits.jsonl"))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	e.Run(ctx, time.Minute)
+package autoexit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+)
+
+// Action records a rule firing for a position.
+type Action struct {
+	Time     time.Time `json:"time"`
+	Rule     string    `json:"rule"`
+	MarketId string    `json:"marketId"`
+	AnswerId string    `json:"answerId,omitempty"`
+	Question string    `json:"question"`
+	Reason   string    `json:"reason"`
+	// Outcome and Shares are what was sold, at probability Prob.
+	Outcome string  `json:"outcome"`
+	Shares  float64 `json:"shares"`
+	Prob    float64 `json:"prob"`
+	// DryRun is true if nothing was actually sold.
+	DryRun bool `json:"dryRun,omitempty"`
+	// Error is set if the sale failed, in which case the rule will fire again.
+	Error string `json:"error,omitempty"`
+}
+
+// key identifies the position an action was taken on, and the rule that took it.
+func (a Action) key() string {
+	return a.Rule + " " + a.MarketId + "/" + a.AnswerId
+}
+
+// Engine applies exit rules to the authenticated user's positions. An Engine isn't safe for concurrent use.
+type Engine struct {
+	mc      *mango.Client
+	rules   []Rule
+	logger  *slog.Logger
+	now     func() time.Time
+	dryRun  bool
+	path    string
+	me      string
+	actions []Action
+	fired   map[string]bool
+}
+
+// Option configures an [Engine].
+type Option func(*Engine)
+
+// WithLogger sets the logger the engine reports its actions to. By default nothing is logged.
+func WithLogger(l *slog.Logger) Option {
+	return func(e *Engine) {
+		e.logger = l
+	}
+}
+
+// WithClock replaces time.Now, which is useful for testing.
+func WithClock(now func() time.Time) Option {
+	return func(e *Engine) {
+		e.now = now
+	}
+}
+
+// WithDryRun makes the engine record the actions it would take without selling anything.
+func WithDryRun() Option {
+	return func(e *Engine) {
+		e.dryRun = true
+	}
+}
+
+// WithAuditLog appends every action to the file at path, one JSON object per line. Actions
+// already in the file are loaded when the engine is created, so that rules which have
+// already fired don't fire again after a restart.
+func WithAuditLog(path string) Option {
+	return func(e *Engine) {
+		e.path = path
+	}
+}
+
+// New returns an engine that applies the given rules.
+func New(mc *mango.Client, rules []Rule, opts ...Option) (*Engine, error) {
+	e := &Engine{
+		mc:     mc,
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		now:    time.Now,
+		fired:  map[string]bool{},
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	names := map[string]bool{}
+	for _, r := range rules {
+		switch {
+		case r.Name == "":
+			return nil, fmt.Errorf("rule must have a name")
+		case names[r.Name]:
+			return nil, fmt.Errorf("duplicate rule %q", r.Name)
+		case r.When == nil:
+			return nil, fmt.Errorf("rule %q must have a condition", r.Name)
+		case r.Sell <= 0 || r.Sell > 1:
+			return nil, fmt.Errorf("rule %q must sell between 0 and 1 of a position, got %v", r.Name, r.Sell)
+		}
+		names[r.Name] = true
+	}
+	e.rules = rules
+
+	if e.path != "" {
+		actions, err := LoadAuditLog(e.path)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range actions {
+			e.record(a)
+		}
+	}
+
+	return e, nil
+}
+
+// LoadAuditLog reads the actions in the audit log at path. A missing file has no actions.
+func LoadAuditLog(path string) ([]Action, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading audit log: %v", err)
+	}
+	defer f.Close()
+
+	var out []Action
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var a Action
+		if err := json.Unmarshal(scanner.Bytes(), &a); err != nil {
+			return nil, fmt.Errorf("error parsing audit log %v line %d: %v", path, line, err)
+		}
+		out = append(out, a)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading audit log: %v", err)
+	}
+
+	return out, nil
+}
+
+// Actions returns every action the engine has taken, oldest first, including those loaded
+// from its audit log.
+func (e *Engine) Actions() []Action {
+	return append([]Action(nil), e.actions...)
+}
+
+// Run calls [Engine.RunOnce] every interval until ctx is cancelled. Errors are logged rather than returned.
+func (e *Engine) Run(ctx context.Context, interval time.Duration) error {
+	for {
+		if _, err := e.RunOnce(ctx); err != nil {
+			e.logger.Error("auto-exit run failed", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// RunOnce fetches the authenticated user's positions and applies the rules to them,
+// returning the actions taken.
+func (e *Engine) RunOnce(ctx context.Context) ([]Action, error) {
+	if e.me == "" {
+		me, err := e.mc.GetAuthenticatedUser()
+		if err != nil {
+			return nil, fmt.Errorf("error getting authenticated user: %w", err)
+		}
+		e.me = me.Id
+	}
+
+	positions, err := e.mc.GetPositions(e.me)
+	if err != nil {
+		return nil, fmt.Errorf("error getting positions: %w", err)
+	}
+
+	return e.Evaluate(ctx, positions)
+}
+
+// Evaluate applies the rules to the given positions, returning the actions taken. Use it to
+// check positions as soon as something changes, such as when a market's probability moves,
+// rather than waiting for the next poll.
+//
+// Rules fire at most once for each position until it no longer holds shares. A rule that
+// fires is recorded even if selling fails, and tried again on the next evaluation. Errors
+// selling are returned together, after every position has been checked.
+func (e *Engine) Evaluate(ctx context.Context, positions []mango.Position) ([]Action, error) {
+	now := e.now()
+
+	var out []Action
+	var errs []error
+	for _, pos := range positions {
+		if err := ctx.Err(); err != nil {
+			return out, err
+		}
+
+		outcome, shares := held(pos)
+		if pos.Resolved() || shares < 1e-9 {
+			// a closed position rearms every rule, for whenever it is opened again
+			for _, r := range e.rules {
+				delete(e.fired, Action{Rule: r.Name, MarketId: pos.Market.Id, AnswerId: pos.AnswerId}.key())
+			}
+			continue
+		}
+
+		for _, r := range e.rules {
+			a := Action{Rule: r.Name, MarketId: pos.Market.Id, AnswerId: pos.AnswerId}
+			if !r.applies(pos.Market.Id) || e.fired[a.key()] {
+				continue
+			}
+
+			reason, ok := r.When(pos, now)
+			if !ok {
+				continue
+			}
+
+			a.Time = now
+			a.Question = pos.Market.Question
+			a.Reason = reason
+			a.Outcome = outcome
+			a.Shares = shares * r.Sell
+			a.Prob = pos.Prob()
+			a.DryRun = e.dryRun
+
+			if err := e.sell(a, r.Sell == 1); err != nil {
+				a.Error = err.Error()
+				errs = append(errs, fmt.Errorf("rule %q selling %v: %w", r.Name, pos.Key(), err))
+			}
+			if err := e.audit(a); err != nil {
+				errs = append(errs, err)
+			}
+			out = append(out, a)
+
+			// selling changes the position, so the other rules wait for the next evaluation
+			if a.Error == "" {
+				break
+			}
+		}
+	}
+
+	return out, errors.Join(errs...)
+}
+
+// sell sells the shares of an action. If all is true, every share is sold, so that none
+// are left over from rounding.
+func (e *Engine) sell(a Action, all bool) error {
+	e.logger.Info("selling shares", "rule", a.Rule, "market", a.MarketId, "answer", a.AnswerId, "outcome", a.Outcome, "shares", a.Shares, "reason", a.Reason, "dryRun", a.DryRun)
+	if a.DryRun {
+		return nil
+	}
+
+	ssr := mango.SellSharesRequest{Outcome: a.Outcome, FractionalShares: a.Shares, AnswerId: a.AnswerId}
+	if all {
+		ssr.FractionalShares = 0
+	}
+	return e.mc.SellShares(a.MarketId, ssr)
+}
+
+// audit records an action, and writes it to the audit log if there is one.
+func (e *Engine) audit(a Action) error {
+	e.record(a)
+	if e.path == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("error encoding action: %v", err)
+	}
+
+	f, err := os.OpenFile(e.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing audit log: %v", err)
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing audit log: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error writing audit log: %v", err)
+	}
+
+	return nil
+}
+
+// record adds an action to the engine's history, and marks its rule as fired for the
+// position if it succeeded. Dry runs only count as firing for engines that are dry runs too.
+func (e *Engine) record(a Action) {
+	e.actions = append(e.actions, a)
+	if a.Error == "" && (!a.DryRun || e.dryRun) {
+		e.fired[a.key()] = true
+	}
+}
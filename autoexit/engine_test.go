@@ -0,0 +1,198 @@
+package autoexit
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+	"github.com/jonnyspicer/mango/mangotest"
+)
+
+func newTestServer(t *testing.T) (*mangotest.Server, *mango.Client) {
+	t.Helper()
+
+	s := mangotest.NewServer()
+	t.Cleanup(s.Close)
+
+	s.AddMarket(mango.FullMarket{Id: "m1", Question: "Will it rain?", Pool: mango.Pool{"YES": 1000, "NO": 1000}, P: 0.5})
+
+	return s, s.Client()
+}
+
+// shares returns the shares of outcome the authenticated user holds in a market.
+func shares(s *mangotest.Server, marketId, outcome string) float64 {
+	total := 0.0
+	for _, b := range s.Bets() {
+		if b.ContractId == marketId && b.UserId == s.Me().Id && b.Outcome == outcome {
+			total += b.Shares
+		}
+	}
+	return total
+}
+
+func TestStopLossEngine(t *testing.T) {
+	s, mc := newTestServer(t)
+	audit := filepath.Join(t.TempDir(), "exits.jsonl")
+
+	rules := []Rule{{Name: "stop-loss", Sell: 1, When: StopLoss(0.2)}}
+	e, err := New(mc, rules, WithAuditLog(audit))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mc.PostBet(mango.PostBetRequest{ContractId: "m1", Outcome: "YES", Amount: 50}); err != nil {
+		t.Fatal(err)
+	}
+
+	actions, err := e.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 0 {
+		t.Fatalf("expected no actions yet, got %+v", actions)
+	}
+
+	// someone else pushes the market well below the price paid
+	if _, err := s.Trade("m1", "NO", 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	actions, err = e.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 || actions[0].Rule != "stop-loss" || actions[0].Outcome != "YES" || actions[0].Reason == "" {
+		t.Fatalf("expected the stop loss to fire, got %+v", actions)
+	}
+	if held := shares(s, "m1", "YES"); math.Abs(held) > 1e-6 {
+		t.Errorf("expected every share to be sold, %v left", held)
+	}
+
+	// the position is closed, so there is nothing more to do
+	if actions, err := e.RunOnce(context.Background()); err != nil || len(actions) != 0 {
+		t.Errorf("expected no more actions, got %+v, %v", actions, err)
+	}
+
+	logged, err := LoadAuditLog(audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logged) != 1 || logged[0].MarketId != "m1" || logged[0].Question != "Will it rain?" || logged[0].Shares <= 0 {
+		t.Errorf("expected the sale in the audit log, got %+v", logged)
+	}
+}
+
+func TestTakeProfitEngine(t *testing.T) {
+	s, mc := newTestServer(t)
+	audit := filepath.Join(t.TempDir(), "exits.jsonl")
+
+	rules := []Rule{{Name: "take-half", Sell: 0.5, When: TakeProfit(10)}}
+	e, err := New(mc, rules, WithAuditLog(audit))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mc.PostBet(mango.PostBetRequest{ContractId: "m1", Outcome: "YES", Amount: 50}); err != nil {
+		t.Fatal(err)
+	}
+	bought := shares(s, "m1", "YES")
+
+	if _, err := s.Trade("m1", "YES", 500); err != nil {
+		t.Fatal(err)
+	}
+
+	actions, err := e.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 || math.Abs(actions[0].Shares-bought/2) > 1e-6 {
+		t.Fatalf("expected half the position to be sold, got %+v", actions)
+	}
+	if held := shares(s, "m1", "YES"); math.Abs(held-bought/2) > 1e-6 {
+		t.Errorf("expected %v shares left, got %v", bought/2, held)
+	}
+
+	// the rule has fired for this position, even for a new engine reading the same audit log
+	if actions, err := e.RunOnce(context.Background()); err != nil || len(actions) != 0 {
+		t.Errorf("expected no more actions, got %+v, %v", actions, err)
+	}
+
+	restarted, err := New(mc, rules, WithAuditLog(audit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(restarted.Actions()); n != 1 {
+		t.Errorf("expected the action to be loaded from the audit log, got %d", n)
+	}
+	if actions, err := restarted.RunOnce(context.Background()); err != nil || len(actions) != 0 {
+		t.Errorf("expected no actions after restarting, got %+v, %v", actions, err)
+	}
+}
+
+func TestBeforeCloseEngine(t *testing.T) {
+	s, mc := newTestServer(t)
+
+	now := time.Now()
+	s.UpdateMarket("m1", func(m *mango.FullMarket) {
+		m.CloseTime = now.Add(2 * time.Hour).UnixMilli()
+	})
+	if _, err := mc.PostBet(mango.PostBetRequest{ContractId: "m1", Outcome: "NO", Amount: 50}); err != nil {
+		t.Fatal(err)
+	}
+
+	rules := []Rule{{Name: "flatten", Sell: 1, When: BeforeClose(time.Hour)}}
+
+	dry, err := New(mc, rules, WithDryRun(), WithClock(func() time.Time { return now.Add(90 * time.Minute) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions, err := dry.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 || !actions[0].DryRun || actions[0].Outcome != "NO" {
+		t.Fatalf("expected a dry run of flattening, got %+v", actions)
+	}
+	if held := shares(s, "m1", "NO"); held <= 0 {
+		t.Error("expected a dry run not to sell anything")
+	}
+	if actions, _ := dry.RunOnce(context.Background()); len(actions) != 0 {
+		t.Errorf("expected a dry run to fire only once, got %+v", actions)
+	}
+
+	e, err := New(mc, rules, WithClock(func() time.Time { return now.Add(30 * time.Minute) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actions, err := e.RunOnce(context.Background()); err != nil || len(actions) != 0 {
+		t.Fatalf("expected no actions more than an hour before close, got %+v, %v", actions, err)
+	}
+
+	e.now = func() time.Time { return now.Add(90 * time.Minute) }
+	if actions, err := e.RunOnce(context.Background()); err != nil || len(actions) != 1 {
+		t.Fatalf("expected the position to be flattened, got %+v, %v", actions, err)
+	}
+	if held := shares(s, "m1", "NO"); math.Abs(held) > 1e-6 {
+		t.Errorf("expected every share to be sold, %v left", held)
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, mc := newTestServer(t)
+
+	invalid := [][]Rule{
+		{{Sell: 1, When: StopLoss(0.1)}},
+		{{Name: "a", Sell: 1}},
+		{{Name: "a", Sell: 0, When: StopLoss(0.1)}},
+		{{Name: "a", Sell: 1.5, When: StopLoss(0.1)}},
+		{{Name: "a", Sell: 1, When: StopLoss(0.1)}, {Name: "a", Sell: 1, When: TakeProfit(1)}},
+	}
+	for _, rules := range invalid {
+		if _, err := New(mc, rules); err == nil {
+			t.Errorf("expected an error for %+v", rules)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package autoexit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+)
+
+// Rule sells some or all of a position when its condition is met.
+type Rule struct {
+	// Name identifies the rule in the audit trail, so it must be unique.
+	Name string
+	// Sell is the fraction of the position's shares to sell when the rule fires, between
+	// 0 and 1. 1 sells the whole position.
+	Sell float64
+	// When decides whether the rule fires for a position.
+	When Condition
+	// Markets are the IDs of the markets the rule applies to. If empty, it applies to every position.
+	Markets []string
+}
+
+// applies reports whether the rule applies to positions in the market with the given id.
+func (r Rule) applies(marketId string) bool {
+	if len(r.Markets) == 0 {
+		return true
+	}
+	for _, id := range r.Markets {
+		if id == marketId {
+			return true
+		}
+	}
+	return false
+}
+
+// Condition decides whether a rule fires for a position at the given time, and if it
+// does, returns the reason why.
+type Condition func(pos mango.Position, now time.Time) (reason string, ok bool)
+
+// StopLoss returns a [Condition] that is met once the probability has moved against the
+// position by at least points, such as 0.2 for 20 percentage points, from the average price
+// its shares were bought at.
+func StopLoss(points float64) Condition {
+	return func(pos mango.Position, _ time.Time) (string, bool) {
+		outcome, shares := held(pos)
+		if shares <= 0 || pos.Metric.Invested <= 0 {
+			return "", false
+		}
+
+		// the price of YES shares is the probability, and of NO shares the rest of it
+		paid := pos.Metric.Invested / shares
+		price := pos.Prob()
+		if outcome == "NO" {
+			price = 1 - price
+		}
+
+		if moved := paid - price; moved >= points-1e-9 {
+			return fmt.Sprintf("%v shares bought at %.0f%% are now worth %.0f%%, down %.0f points", outcome, paid*100, price*100, moved*100), true
+		}
+		return "", false
+	}
+}
+
+// TakeProfit returns a [Condition] that is met once the position's profit is at least
+// profit mana.
+func TakeProfit(profit float64) Condition {
+	return func(pos mango.Position, _ time.Time) (string, bool) {
+		if pos.Metric.Profit >= profit {
+			return fmt.Sprintf("profit of M%.2f is at least M%.2f", pos.Metric.Profit, profit), true
+		}
+		return "", false
+	}
+}
+
+// BeforeClose returns a [Condition] that is met within d of the market's close time,
+// while it can still be traded.
+func BeforeClose(d time.Duration) Condition {
+	return func(pos mango.Position, now time.Time) (string, bool) {
+		if pos.Market.CloseTime == 0 {
+			return "", false
+		}
+
+		close := time.UnixMilli(pos.Market.CloseTime)
+		if left := close.Sub(now); left > 0 && left <= d {
+			return fmt.Sprintf("market closes in %v", left.Round(time.Second)), true
+		}
+		return "", false
+	}
+}
+
+// held returns the outcome the position holds the most shares of, and how many.
+func held(pos mango.Position) (string, float64) {
+	yes, no := pos.Metric.TotalShares["YES"], pos.Metric.TotalShares["NO"]
+	if no > yes {
+		return "NO", no
+	}
+	return "YES", yes
+}
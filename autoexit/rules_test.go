@@ -0,0 +1,77 @@
+package autoexit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+)
+
+func position(outcome string, shares, invested, prob float64) mango.Position {
+	return mango.Position{
+		Market: mango.FullMarket{Id: "m1", Probability: prob},
+		Metric: mango.ContractMetric{
+			TotalShares: map[string]float64{outcome: shares},
+			Invested:    invested,
+			Profit:      shares*map[string]float64{"YES": prob, "NO": 1 - prob}[outcome] - invested,
+		},
+	}
+}
+
+func TestStopLoss(t *testing.T) {
+	stop := StopLoss(0.2)
+
+	tests := []struct {
+		name string
+		pos  mango.Position
+		want bool
+	}{
+		{"YES bought at 60% now 45%", position("YES", 100, 60, 0.45), false},
+		{"YES bought at 60% now 40%", position("YES", 100, 60, 0.4), true},
+		{"YES bought at 60% now 80%", position("YES", 100, 60, 0.8), false},
+		{"NO bought at 30% now 35%", position("NO", 100, 30, 0.35), false},
+		{"NO bought at 30% now 90%", position("NO", 100, 30, 0.9), true},
+		{"nothing invested", position("YES", 100, 0, 0.1), false},
+	}
+
+	for _, tt := range tests {
+		if _, got := stop(tt.pos, time.Now()); got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTakeProfit(t *testing.T) {
+	take := TakeProfit(20)
+
+	if _, ok := take(position("YES", 100, 50, 0.65), time.Now()); ok {
+		t.Error("expected a profit of 15 not to take profit")
+	}
+	if reason, ok := take(position("YES", 100, 50, 0.75), time.Now()); !ok || reason == "" {
+		t.Error("expected a profit of 25 to take profit")
+	}
+}
+
+func TestBeforeClose(t *testing.T) {
+	now := time.Now()
+	before := BeforeClose(time.Hour)
+
+	pos := position("YES", 100, 50, 0.5)
+	if _, ok := before(pos, now); ok {
+		t.Error("expected a market without a close time never to fire")
+	}
+
+	for _, tt := range []struct {
+		close time.Duration
+		want  bool
+	}{
+		{2 * time.Hour, false},
+		{30 * time.Minute, true},
+		{-time.Minute, false},
+	} {
+		pos.Market.CloseTime = now.Add(tt.close).UnixMilli()
+		if _, got := before(pos, now); got != tt.want {
+			t.Errorf("closing in %v: got %v, want %v", tt.close, got, tt.want)
+		}
+	}
+}
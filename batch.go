@@ -0,0 +1,94 @@
+package mango
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/jonnyspicer/mango/internal/slicesx"
+)
+
+const defaultBatchWorkers = 8
+
+var errNilResult = errors.New("no result returned")
+
+// BatchOptions represents the optional parameters that can be supplied to the
+// batch fetch helpers such as [Client.GetUsersByIDs].
+type BatchOptions struct {
+	// Workers is the maximum number of requests that will be in flight at once.
+	// Defaults to 8. Every request still goes through the client's rate limiter.
+	Workers int
+}
+
+// GetUsersByIDs fetches a [User] for each of the given ids concurrently.
+//
+// Duplicate and empty ids are ignored. It returns a map of the users that were found, keyed by id,
+// and a map of the errors encountered for the ids that could not be fetched.
+func (mc *Client) GetUsersByIDs(ids []string, opts *BatchOptions) (map[string]User, map[string]error) {
+	return batchFetch(ids, opts, mc.GetUserByID)
+}
+
+// GetMarketsByIDs fetches a [FullMarket] for each of the given ids concurrently.
+//
+// Duplicate and empty ids are ignored. It returns a map of the markets that were found, keyed by id,
+// and a map of the errors encountered for the ids that could not be fetched.
+func (mc *Client) GetMarketsByIDs(ids []string, opts *BatchOptions) (map[string]FullMarket, map[string]error) {
+	return batchFetch(ids, opts, mc.GetMarketByID)
+}
+
+// GetGroupsByIDs fetches a [Group] for each of the given ids concurrently.
+//
+// Duplicate and empty ids are ignored. It returns a map of the groups that were found, keyed by id,
+// and a map of the errors encountered for the ids that could not be fetched.
+func (mc *Client) GetGroupsByIDs(ids []string, opts *BatchOptions) (map[string]Group, map[string]error) {
+	return batchFetch(ids, opts, mc.GetGroupById)
+}
+
+// batchFetch calls fetch once for every unique key, using a bounded pool of workers.
+// A nil result without an error is reported as an error rather than stored.
+func batchFetch[T any](keys []string, opts *BatchOptions, fetch func(string) (*T, error)) (map[string]T, map[string]error) {
+	workers := defaultBatchWorkers
+	if opts != nil && opts.Workers > 0 {
+		workers = opts.Workers
+	}
+
+	unique := slicesx.Dedupe(keys)
+	if workers > len(unique) {
+		workers = len(unique)
+	}
+
+	results := make(map[string]T, len(unique))
+	errs := make(map[string]error)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan string)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range jobs {
+				v, err := fetch(key)
+
+				mu.Lock()
+				switch {
+				case err != nil:
+					errs[key] = err
+				case v == nil:
+					errs[key] = errNilResult
+				default:
+					results[key] = *v
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, key := range unique {
+		jobs <- key
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results, errs
+}
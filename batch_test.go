@@ -0,0 +1,145 @@
+package mango
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetUsersByIDs(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/v0/user/by-id/")
+
+		mu.Lock()
+		calls[id]++
+		mu.Unlock()
+
+		if id == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"user not found"}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(User{Id: id, Username: "user-" + id})
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	mc := ClientInstance(server.Client(), &server.URL, &testKey)
+	defer mc.Destroy()
+
+	users, errs := mc.GetUsersByIDs([]string{"a", "b", "a", "", "missing"}, &BatchOptions{Workers: 2})
+
+	if len(users) != 2 {
+		t.Fatalf("expected 2 users, got %d: %+v", len(users), users)
+	}
+	if users["a"].Username != "user-a" || users["b"].Username != "user-b" {
+		t.Errorf("unexpected users: %+v", users)
+	}
+
+	if len(errs) != 1 || errs["missing"] == nil {
+		t.Errorf("expected a single error for id 'missing', got %+v", errs)
+	}
+
+	for id, n := range calls {
+		if n != 1 {
+			t.Errorf("expected id %q to be fetched once, got %d", id, n)
+		}
+	}
+	if _, ok := calls[""]; ok {
+		t.Error("expected empty id to be skipped")
+	}
+}
+
+func TestBatchFetchWorkerLimit(t *testing.T) {
+	var inFlight, peak int32
+
+	fetch := func(id string) (*string, error) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return &id, nil
+	}
+
+	ids := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}
+	results, errs := batchFetch(ids, &BatchOptions{Workers: 3}, fetch)
+
+	if len(results) != len(ids) || len(errs) != 0 {
+		t.Fatalf("expected %d results and no errors, got %d results and %v", len(ids), len(results), errs)
+	}
+	if peak > 3 {
+		t.Errorf("expected at most 3 concurrent fetches, got %d", peak)
+	}
+}
+
+func TestBatchFetchNilResult(t *testing.T) {
+	fetch := func(id string) (*string, error) {
+		return nil, nil
+	}
+
+	results, errs := batchFetch([]string{"x"}, nil, fetch)
+
+	if len(results) != 0 {
+		t.Errorf("expected no results, got %v", results)
+	}
+	if errs["x"] == nil {
+		t.Error("expected an error for a nil result")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	rl := newRateLimiter(2, 100*time.Millisecond)
+	ctx := context.Background()
+
+	if d, _ := rl.wait(ctx); d != 0 {
+		t.Errorf("expected first request not to wait, waited %v", d)
+	}
+	if d, _ := rl.wait(ctx); d != 0 {
+		t.Errorf("expected second request not to wait, waited %v", d)
+	}
+	if d, _ := rl.wait(ctx); d <= 0 {
+		t.Error("expected third request to wait for a token")
+	}
+
+	var disabled *rateLimiter
+	if d, _ := disabled.wait(ctx); d != 0 {
+		t.Errorf("expected nil limiter not to wait, waited %v", d)
+	}
+}
+
+func TestRateLimiterCancelled(t *testing.T) {
+	rl := newRateLimiter(1, time.Hour)
+	rl.wait(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := rl.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait to end with the context, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("expected the wait to stop when the context was done")
+	}
+	if rl.tokens < 0 {
+		t.Errorf("expected the token to be given back, have %v tokens", rl.tokens)
+	}
+}
@@ -61,6 +61,7 @@ type Bet struct {
 	ProbBefore    float64 `json:"probBefore"`
 	LoanAmount    float64 `json:"loanAmount"`
 	ContractId    string  `json:"contractId"`
+	AnswerId      string  `json:"answerId,omitempty"`
 	UserUsername  string  `json:"userUsername"`
 	CreatedTime   int64   `json:"createdTime"`
 	UserAvatarUrl string  `json:"userAvatarUrl"`
@@ -73,6 +74,7 @@ type Bet struct {
 	IsFilled      bool    `json:"isFilled,omitempty"`
 	UserName      string  `json:"userName"`
 	OrderAmount   float64 `json:"orderAmount,omitempty"`
+	LimitProb     float64 `json:"limitProb,omitempty"`
 	IsChallenge   bool    `json:"isChallenge"`
 	ProbAfter     float64 `json:"probAfter"`
 }
@@ -0,0 +1,385 @@
+package mango
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Bounty represents a bountied question and the answers it has had.
+type Bounty struct {
+	Market FullMarket `json:"market"`
+	// Total is the mana put up for the bounty, and Remaining how much of it hasn't been
+	// awarded yet.
+	Total     float64 `json:"total"`
+	Remaining float64 `json:"remaining"`
+	// Answers are the comments that don't reply to another comment, from users other than
+	// the question's creator, oldest first.
+	Answers []Comment `json:"answers"`
+
+	comments map[string]Comment
+}
+
+// BountySplit represents a bounty to award across several comments, in proportion to
+// their weights.
+type BountySplit struct {
+	// Key identifies the split in a [BountyManager]'s record, so that awarding it again
+	// only pays the comments that haven't been paid yet. If empty, it is made from the
+	// market, amount and weights, so awarding the same split again is safe.
+	Key      string
+	MarketId string
+	// Amount is the whole mana to award, which is shared between the comments.
+	Amount int64
+	// Weights are each comment's share of the amount, keyed by comment ID. They don't
+	// need to add up to anything in particular.
+	Weights map[string]float64
+}
+
+func (s BountySplit) key() string {
+	if s.Key != "" {
+		return s.Key
+	}
+
+	ids := make([]string, 0, len(s.Weights))
+	for id := range s.Weights {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	parts := []string{s.MarketId, fmt.Sprint(s.Amount)}
+	for _, id := range ids {
+		parts = append(parts, fmt.Sprintf("%v:%v", id, s.Weights[id]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// BountyAward records the part of a [BountySplit] awarded to one comment.
+type BountyAward struct {
+	Key       string `json:"key"`
+	MarketId  string `json:"marketId"`
+	CommentId string `json:"commentId"`
+	UserId    string `json:"userId"`
+	Username  string `json:"username"`
+	Amount    int64  `json:"amount"`
+	// Time is when the award was made, in milliseconds since the epoch.
+	Time int64 `json:"time"`
+	// Paid is false if the award was being made when the record was last written, or
+	// hasn't been made yet.
+	Paid bool `json:"paid"`
+}
+
+func (a BountyAward) id() string {
+	return a.Key + "/" + a.CommentId
+}
+
+// BountyManager helps run the bountied questions created by the authenticated user: it
+// lists them with their answers and remaining bounty, and awards splits of the bounty
+// across several answers at once, keeping a record of what was paid. A BountyManager
+// isn't safe for concurrent use.
+type BountyManager struct {
+	mc     *Client
+	path   string
+	me     string
+	order  []string
+	awards map[string]BountyAward
+}
+
+// NewBountyManager returns a [BountyManager]. If record isn't empty, it is the path of a
+// file that every award is written to, one JSON object per line, and awards already in it
+// are loaded so that they aren't paid again.
+func NewBountyManager(mc *Client, record string) (*BountyManager, error) {
+	bm := &BountyManager{mc: mc, path: record, awards: map[string]BountyAward{}}
+	if record == "" {
+		return bm, nil
+	}
+
+	f, err := os.Open(record)
+	if errors.Is(err, fs.ErrNotExist) {
+		return bm, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading bounty record: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var a BountyAward
+		if err := json.Unmarshal(scanner.Bytes(), &a); err != nil {
+			return nil, fmt.Errorf("error parsing bounty record %v line %d: %v", record, line, err)
+		}
+		bm.remember(a)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading bounty record: %v", err)
+	}
+
+	return bm, nil
+}
+
+// Awards returns the awards that have been paid, oldest first, including those loaded
+// from the record.
+func (bm *BountyManager) Awards() []BountyAward {
+	var out []BountyAward
+	for _, id := range bm.order {
+		if a := bm.awards[id]; a.Paid {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// Bounties returns the bountied questions created by the authenticated user, newest first,
+// with their answers.
+func (bm *BountyManager) Bounties() ([]Bounty, error) {
+	if bm.me == "" {
+		me, err := bm.mc.GetAuthenticatedUser()
+		if err != nil {
+			return nil, fmt.Errorf("error getting authenticated user: %v", err)
+		}
+		bm.me = me.Id
+	}
+
+	var markets []FullMarket
+	req := SearchMarketsRequest{ContractType: string(BountiedQuestion), CreatorId: bm.me, Sort: "newest", Limit: 100}
+	for {
+		page, err := bm.mc.SearchMarkets(req)
+		if err != nil {
+			return nil, err
+		}
+		markets = append(markets, *page...)
+		if int64(len(*page)) < req.Limit {
+			break
+		}
+		req.Offset += int64(len(*page))
+	}
+
+	out := make([]Bounty, 0, len(markets))
+	for _, m := range markets {
+		b, err := bm.bounty(m)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *b)
+	}
+	return out, nil
+}
+
+// Bounty returns the bountied question with the given ID, with its answers.
+func (bm *BountyManager) Bounty(marketId string) (*Bounty, error) {
+	m, err := bm.mc.GetMarketByID(marketId)
+	if err != nil {
+		return nil, err
+	}
+	if m.OutcomeType != BountiedQuestion {
+		return nil, fmt.Errorf("market %v is not a bountied question", marketId)
+	}
+	return bm.bounty(*m)
+}
+
+func (bm *BountyManager) bounty(m FullMarket) (*Bounty, error) {
+	comments, err := bm.mc.GetComments(GetCommentsRequest{ContractId: m.Id})
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bounty{Market: m, Total: m.TotalBounty, Remaining: m.BountyLeft, Answers: []Comment{}, comments: map[string]Comment{}}
+	for _, c := range *comments {
+		b.comments[c.Id] = c
+		if c.ReplyToCommentId == "" && c.UserId != m.CreatorId {
+			b.Answers = append(b.Answers, c)
+		}
+	}
+	sort.SliceStable(b.Answers, func(i, j int) bool { return b.Answers[i].CreatedTime < b.Answers[j].CreatedTime })
+
+	return b, nil
+}
+
+// Award pays a bounty split, awarding each comment its share of the amount with
+// [Client.AwardBounty]. Shares are whole amounts of mana, rounded so that they add up to
+// the split's amount, and each must be at least M1.
+//
+// Before anything is paid, every comment must be on the question, and what is still to
+// be paid must not be more than the bounty left. Parts of the split already in the record
+// aren't paid again, and a part that was being paid when the record was last written
+// counts as paid if the comment's awarded bounty shows it. If an award fails, Award
+// stops and returns the error; awarding the same split again carries on from there.
+//
+// It returns an award for every comment in the split, in the order they are paid, even
+// if an error stops some of them being paid.
+func (bm *BountyManager) Award(split BountySplit) ([]BountyAward, error) {
+	if split.Amount < 1 {
+		return nil, fmt.Errorf("bounty split must award at least M1, got M%d", split.Amount)
+	}
+	if len(split.Weights) == 0 {
+		return nil, fmt.Errorf("bounty split must award at least one comment")
+	}
+	for id, w := range split.Weights {
+		if !(w > 0) || math.IsInf(w, 1) {
+			return nil, fmt.Errorf("comment %v must have a positive weight, got %v", id, w)
+		}
+	}
+
+	b, err := bm.Bounty(split.MarketId)
+	if err != nil {
+		return nil, err
+	}
+
+	// pay in the order the comments were made, so splits are paid the same way each time
+	var comments []Comment
+	for id := range split.Weights {
+		c, ok := b.comments[id]
+		if !ok {
+			return nil, fmt.Errorf("market %v has no comment %v", split.MarketId, id)
+		}
+		comments = append(comments, c)
+	}
+	sort.Slice(comments, func(i, j int) bool {
+		if comments[i].CreatedTime != comments[j].CreatedTime {
+			return comments[i].CreatedTime < comments[j].CreatedTime
+		}
+		return comments[i].Id < comments[j].Id
+	})
+
+	shares, err := splitBounty(split, comments)
+	if err != nil {
+		return nil, err
+	}
+
+	key := split.key()
+	awards := make([]BountyAward, len(comments))
+	var owed int64
+	for i, c := range comments {
+		a := BountyAward{Key: key, MarketId: split.MarketId, CommentId: c.Id, UserId: c.UserId, Username: c.UserUsername, Amount: shares[i]}
+		if prev, ok := bm.awards[a.id()]; ok {
+			a = prev
+			// an award that may have been made shows up in the comment's awarded bounty
+			if !a.Paid && c.BountyAwarded >= bm.paid(c.Id)+float64(a.Amount)-1e-9 {
+				a.Paid = true
+				if err := bm.write(a); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if !a.Paid {
+			owed += a.Amount
+		}
+		awards[i] = a
+	}
+
+	if float64(owed) > b.Remaining+1e-9 {
+		return nil, fmt.Errorf("bounty split needs M%d but only M%v of the bounty is left", owed, b.Remaining)
+	}
+
+	for i, a := range awards {
+		if a.Paid {
+			continue
+		}
+
+		a.Time = time.Now().UnixMilli()
+		awards[i] = a
+		if err := bm.write(a); err != nil {
+			return awards, err
+		}
+		if err := bm.mc.AwardBounty(a.MarketId, a.Amount, a.CommentId); err != nil {
+			return awards, fmt.Errorf("error awarding M%d to comment %v: %w", a.Amount, a.CommentId, err)
+		}
+		a.Paid = true
+		awards[i] = a
+		if err := bm.write(a); err != nil {
+			return awards, err
+		}
+	}
+
+	return awards, nil
+}
+
+// splitBounty shares the split's amount between the comments in proportion to their
+// weights, giving the mana left over from rounding down to the largest remainders.
+func splitBounty(split BountySplit, comments []Comment) ([]int64, error) {
+	var total float64
+	for _, c := range comments {
+		total += split.Weights[c.Id]
+	}
+
+	shares := make([]int64, len(comments))
+	remainders := make([]float64, len(comments))
+	left := split.Amount
+	for i, c := range comments {
+		exact := float64(split.Amount) * split.Weights[c.Id] / total
+		shares[i] = int64(math.Floor(exact))
+		remainders[i] = exact - float64(shares[i])
+		left -= shares[i]
+	}
+
+	order := make([]int, len(comments))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return remainders[order[i]] > remainders[order[j]] })
+	for _, i := range order[:min(left, int64(len(order)))] {
+		shares[i]++
+	}
+
+	for i, s := range shares {
+		if s < 1 {
+			return nil, fmt.Errorf("comment %v's share of M%d rounds down to nothing", comments[i].Id, split.Amount)
+		}
+	}
+	return shares, nil
+}
+
+// paid returns the total of the paid awards to a comment.
+func (bm *BountyManager) paid(commentId string) float64 {
+	var total float64
+	for _, a := range bm.awards {
+		if a.CommentId == commentId && a.Paid {
+			total += float64(a.Amount)
+		}
+	}
+	return total
+}
+
+func (bm *BountyManager) remember(a BountyAward) {
+	if _, ok := bm.awards[a.id()]; !ok {
+		bm.order = append(bm.order, a.id())
+	}
+	bm.awards[a.id()] = a
+}
+
+// write remembers an award, and appends it to the record if there is one.
+func (bm *BountyManager) write(a BountyAward) error {
+	bm.remember(a)
+	if bm.path == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("error encoding bounty award: %v", err)
+	}
+
+	f, err := os.OpenFile(bm.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing bounty record: %v", err)
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing bounty record: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error writing bounty record: %v", err)
+	}
+
+	return nil
+}
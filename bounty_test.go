@@ -0,0 +1,197 @@
+package mango
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type awardRequest struct {
+	Amount    int64  `json:"amount"`
+	CommentId string `json:"commentId"`
+}
+
+// bountyServer is a fake of the endpoints BountyManager uses, with one bountied question.
+type bountyServer struct {
+	market   FullMarket
+	comments []Comment
+	fail     map[string]bool // comments that awards to fail
+	awards   []awardRequest
+}
+
+func newBountyServer() *bountyServer {
+	return &bountyServer{
+		market: FullMarket{Id: "q1", CreatorId: "me", Question: "Best bread recipe?", OutcomeType: BountiedQuestion, TotalBounty: 200, BountyLeft: 200},
+		comments: []Comment{
+			{Id: "c3", ContractId: "q1", UserId: "u3", UserUsername: "carol", CreatedTime: 3},
+			{Id: "c2", ContractId: "q1", UserId: "me", CreatedTime: 2, ReplyToCommentId: "c1"},
+			{Id: "c1", ContractId: "q1", UserId: "u1", UserUsername: "alice", CreatedTime: 1},
+			{Id: "c0", ContractId: "q1", UserId: "me", CreatedTime: 0},
+		},
+	}
+}
+
+func (s *bountyServer) start(t *testing.T) *Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v0/"), "/")
+		switch path {
+		case "me":
+			json.NewEncoder(w).Encode(User{Id: "me"})
+		case "search-markets":
+			if q := r.URL.Query(); q.Get("creatorId") != "me" || q.Get("contractType") != "BOUNTIED_QUESTION" {
+				t.Errorf("unexpected search %v", r.URL)
+			}
+			json.NewEncoder(w).Encode([]FullMarket{s.market})
+		case "market/q1":
+			json.NewEncoder(w).Encode(s.market)
+		case "comments":
+			json.NewEncoder(w).Encode(s.comments)
+		case "market/q1/award-bounty":
+			var req awardRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if s.fail[req.CommentId] {
+				http.Error(w, `{"message":"failed"}`, http.StatusInternalServerError)
+				return
+			}
+			s.awards = append(s.awards, req)
+			s.market.BountyLeft -= float64(req.Amount)
+			for i := range s.comments {
+				if s.comments[i].Id == req.CommentId {
+					s.comments[i].BountyAwarded += float64(req.Amount)
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]string{})
+		default:
+			t.Errorf("unexpected path %v", r.URL.Path)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	mc := ClientInstance(server.Client(), &server.URL, &testKey)
+	t.Cleanup(mc.Destroy)
+	return mc
+}
+
+func TestBounties(t *testing.T) {
+	s := newBountyServer()
+	bm, err := NewBountyManager(s.start(t), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bs, err := bm.Bounties()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bs) != 1 || bs[0].Total != 200 || bs[0].Remaining != 200 {
+		t.Fatalf("unexpected bounties %+v", bs)
+	}
+
+	// the creator's comments and replies aren't answers
+	if as := bs[0].Answers; len(as) != 2 || as[0].Id != "c1" || as[1].Id != "c3" {
+		t.Errorf("unexpected answers %+v", as)
+	}
+}
+
+func TestBountyAward(t *testing.T) {
+	s := newBountyServer()
+	bm, err := NewBountyManager(s.start(t), filepath.Join(t.TempDir(), "bounties.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	split := BountySplit{MarketId: "q1", Amount: 100, Weights: map[string]float64{"c3": 1, "c1": 2}}
+	awards, err := bm.Award(split)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(awards) != 2 || awards[0].CommentId != "c1" || awards[0].Amount != 67 || awards[1].Amount != 33 || !awards[1].Paid {
+		t.Errorf("unexpected awards %+v", awards)
+	}
+	if awards[1].Username != "carol" {
+		t.Errorf("expected the award to name the commenter, got %+v", awards[1])
+	}
+	if s.market.BountyLeft != 100 {
+		t.Errorf("expected M100 of the bounty left, got %v", s.market.BountyLeft)
+	}
+
+	// awarding the same split again pays nothing
+	if _, err := bm.Award(split); err != nil || len(s.awards) != 2 {
+		t.Errorf("expected awarding again to pay nothing, got %v and %+v", err, s.awards)
+	}
+	if len(bm.Awards()) != 2 {
+		t.Errorf("expected 2 awards, got %+v", bm.Awards())
+	}
+
+	for _, bad := range []BountySplit{
+		{MarketId: "q1", Amount: 150, Weights: map[string]float64{"c1": 1}},
+		{MarketId: "q1", Amount: 10, Weights: map[string]float64{"c9": 1}},
+		{MarketId: "q1", Amount: 10, Weights: map[string]float64{"c1": -1}},
+		{MarketId: "q1", Amount: 1, Weights: map[string]float64{"c1": 1, "c3": 1}},
+	} {
+		if _, err := bm.Award(bad); err == nil {
+			t.Errorf("expected %+v to fail", bad)
+		}
+	}
+	if len(s.awards) != 2 {
+		t.Errorf("expected invalid splits to pay nothing, got %+v", s.awards)
+	}
+}
+
+func TestBountyAwardResume(t *testing.T) {
+	s := newBountyServer()
+	mc := s.start(t)
+	record := filepath.Join(t.TempDir(), "bounties.jsonl")
+
+	bm, err := NewBountyManager(mc, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	split := BountySplit{Key: "winners", MarketId: "q1", Amount: 50, Weights: map[string]float64{"c1": 1, "c3": 1}}
+	s.fail = map[string]bool{"c3": true}
+	if awards, err := bm.Award(split); err == nil || !awards[0].Paid || awards[1].Paid {
+		t.Fatalf("expected the second award to fail, got %v and %+v", err, awards)
+	}
+
+	// a new manager picks up from the record and only pays what's left
+	s.fail = nil
+	bm, err = NewBountyManager(mc, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bm.Award(split); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.awards) != 2 || s.awards[1].CommentId != "c3" || s.awards[1].Amount != 25 {
+		t.Errorf("expected only c3 to be paid again, got %+v", s.awards)
+	}
+
+	// an award that was made but not recorded as paid isn't paid again
+	s.comments[0].BountyAwarded += 10
+	s.market.BountyLeft -= 10
+	b, _ := json.Marshal(BountyAward{Key: "extra", MarketId: "q1", CommentId: "c3", Amount: 10})
+	f, err := os.OpenFile(record, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(append(b, '\n'))
+	f.Close()
+
+	bm, err = NewBountyManager(mc, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	awards, err := bm.Award(BountySplit{Key: "extra", MarketId: "q1", Amount: 10, Weights: map[string]float64{"c3": 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.awards) != 2 || !awards[0].Paid {
+		t.Errorf("expected the unrecorded award to count as paid, got %+v", s.awards)
+	}
+}
@@ -1,64 +1,102 @@
 package mango
 
 import (
-	"fmt"
-	"github.com/spf13/viper"
+	"context"
+	"log/slog"
 	"net/http"
-	"os"
 	"sync"
 	"time"
 )
 
 // Client represents the main Mango client, used to make requests to the Manifold API
 type Client struct {
-	client http.Client
-	key    string
-	url    string
+	client  http.Client
+	creds   CredentialProvider
+	url     string
+	limiter *rateLimiter
+	logger  *slog.Logger
+	before  []BeforeRequestHook
+	after   []AfterRequestHook
+	retries int
+	backoff time.Duration
+	ctx     context.Context
+}
+
+// ClientOption configures optional behaviour of a [Client] when it is created.
+type ClientOption func(*Client)
+
+// WithRateLimit limits the client to the given number of requests per period.
+//
+// By default the client allows 500 requests per minute, matching Manifold's
+// documented rate limit. Passing a non-positive number of requests disables
+// rate limiting entirely.
+func WithRateLimit(requests int, per time.Duration) ClientOption {
+	return func(mc *Client) {
+		mc.limiter = newRateLimiter(requests, per)
+	}
 }
 
 var lock = &sync.Mutex{}
 var mcInstance *Client // TODO: figure out whether this should really be a singleton or not
 
 // ClientInstance creates a singleton of the Mango Client.
-// It optionally takes a http.Client, base URL, and API key.
+// It optionally takes a http.Client, base URL, and API key, followed by any number of [ClientOption].
 //
 // If you don't specify a base URL, the default Manifold Markets domain will be used.
 //
-// If no API key is provided then you will need to specify a `MANIFOLD_API_KEY` in your .env file
+// If no API key or [CredentialProvider] is provided then you will need to specify a `MANIFOLD_API_KEY`
+// in your .env file, or as an environment variable.
 //
 // Just because you *can* specify an API key here doesn't mean that you *should*!
 // Please don't put your API key in code.
-func ClientInstance(client *http.Client, url, ak *string) *Client {
+func ClientInstance(client *http.Client, url, ak *string, opts ...ClientOption) *Client {
 	if mcInstance == nil {
 		lock.Lock()
 		defer lock.Unlock()
 		if mcInstance == nil {
-			if client == nil {
-				client = &http.Client{
-					Timeout: time.Second * 10,
-				}
-			}
-
-			if url == nil {
-				u := base
-				url = &u
-			}
-
-			if ak == nil {
-				a := apiKey()
-				ak = &a
-			}
-
-			mcInstance = &Client{
-				client: *client,
-				key:    *ak,
-				url:    *url,
-			}
+			mcInstance = NewClient(client, url, ak, opts...)
 		}
 	}
 	return mcInstance
 }
 
+// NewClient creates a new Mango Client. It takes the same parameters as [ClientInstance],
+// but returns a separate client every time it is called, which allows a program to act
+// as several Manifold accounts at once by giving each client its own [CredentialProvider].
+func NewClient(client *http.Client, url, ak *string, opts ...ClientOption) *Client {
+	if client == nil {
+		client = &http.Client{
+			Timeout: time.Second * 10,
+		}
+	}
+
+	if url == nil {
+		u := base
+		url = &u
+	}
+
+	mc := &Client{
+		client:  *client,
+		url:     *url,
+		limiter: newRateLimiter(defaultRateLimit, defaultRatePeriod),
+		logger:  slog.New(discardHandler{}),
+	}
+
+	if ak != nil {
+		mc.creds = StaticCredentials(*ak)
+	}
+
+	for _, opt := range opts {
+		opt(mc)
+	}
+
+	if mc.creds == nil {
+		mc.creds = defaultCredentials()
+	}
+
+	return mc
+}
+
 // DefaultClientInstance returns a singleton of the Mango Client using all default values.
 //
 // It will use a default http.Client, the primary Manifold domain as the base URL, and
@@ -67,6 +105,17 @@ func DefaultClientInstance() *Client {
 	return ClientInstance(nil, nil, nil)
 }
 
+// WithContext returns a copy of the client that makes its requests with ctx, so that they
+// are cancelled with it, and request hooks such as those of the otelmango package see the
+// trace it carries. The copy shares the client's credentials, rate limiter and hooks.
+//
+//	market, err := mc.WithContext(ctx).GetMarketByID(id)
+func (mc *Client) WithContext(ctx context.Context) *Client {
+	c := *mc
+	c.ctx = ctx
+	return &c
+}
+
 // Destroy destroys the current singleton of the Mango client.
 //
 // Useful for testing.
@@ -77,23 +126,3 @@ func (mc *Client) Destroy() {
 		mcInstance = nil
 	}
 }
-
-func apiKey() string {
-	v := viper.New()
-	v.SetConfigName(".env")
-	v.SetConfigType("env")
-	v.AddConfigPath(".")
-
-	err := v.ReadInConfig() // Find and read the config file
-	if err != nil {         // Handle errors reading the config file
-		fmt.Printf("error reading config file: %v\n", err)
-	}
-
-	if mak := v.GetString("MANIFOLD_API_KEY"); mak != "" {
-		return mak
-	}
-
-	fmt.Println("No value for MANIFOLD_API_KEY found in .env file, falling back to env vars")
-
-	return os.Getenv("MANIFOLD_API_KEY")
-}
@@ -0,0 +1,779 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+	"github.com/jonnyspicer/mango/analytics"
+	"github.com/jonnyspicer/mango/ledger"
+	"gopkg.in/yaml.v3"
+)
+
+func marketGet(a *app, args []string) error {
+	fs := a.flagSet("market get")
+	byID := fs.Bool("id", false, "treat the argument as a market ID rather than a slug")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	var m *mango.FullMarket
+	if *byID {
+		m, err = a.mc().GetMarketByID(args[0])
+	} else {
+		m, err = a.mc().GetMarketBySlug(args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	return a.print(m, func() table {
+		t := table{rows: [][]string{
+			{"id", m.Id},
+			{"question", m.Question},
+			{"type", string(m.OutcomeType)},
+			{"creator", m.CreatorUsername},
+			{"probability", formatProb(m.Probability)},
+			{"volume", formatMana(m.Volume)},
+			{"liquidity", formatMana(m.TotalLiquidity)},
+			{"closes", formatTime(m.CloseTime)},
+			{"resolved", strconv.FormatBool(m.IsResolved)},
+			{"url", m.Url},
+		}}
+		if m.IsResolved {
+			t.rows = append(t.rows, []string{"resolution", m.Resolution})
+		}
+		for _, ans := range m.Answers {
+			t.rows = append(t.rows, []string{"answer " + ans.Id, fmt.Sprintf("%v (%v)", ans.Text, formatProb(ans.Probability))})
+		}
+		return t
+	})
+}
+
+func marketCreate(a *app, args []string) error {
+	fs := a.flagSet("market create")
+	file := fs.String("f", "", "a YAML or JSON file describing the market")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if *file == "" || len(args) != 0 {
+		return errUsage
+	}
+
+	pmr, err := readMarketFile(*file)
+	if err != nil {
+		return err
+	}
+
+	if a.dryRun {
+		return a.printDryRun("CreateMarket", pmr)
+	}
+
+	id, err := a.mc().CreateMarket(*pmr)
+	if err != nil {
+		return err
+	}
+
+	return a.print(map[string]string{"id": *id}, func() table {
+		return table{rows: [][]string{{"id", *id}}}
+	})
+}
+
+func manifestPlan(a *app, args []string) error {
+	fs := a.flagSet("manifest plan")
+	file := fs.String("f", "", "a YAML or JSON manifest describing the markets")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if *file == "" || len(args) != 0 {
+		return errUsage
+	}
+
+	m, err := mango.LoadManifest(*file)
+	if err != nil {
+		return err
+	}
+
+	p, err := a.mc().PlanManifest(*m)
+	if err != nil {
+		return err
+	}
+
+	return a.printPlan(p)
+}
+
+func manifestApply(a *app, args []string) error {
+	fs := a.flagSet("manifest apply")
+	file := fs.String("f", "", "a YAML or JSON manifest describing the markets")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if *file == "" || len(args) != 0 {
+		return errUsage
+	}
+
+	m, err := mango.LoadManifest(*file)
+	if err != nil {
+		return err
+	}
+
+	p, err := a.mc().PlanManifest(*m)
+	if err != nil {
+		return err
+	}
+
+	if a.dryRun {
+		return a.printDryRun("ApplyManifest", p.ToApply())
+	}
+
+	// print the plan even if applying it fails part way, so it's clear what was created
+	applyErr := a.mc().ApplyPlan(p)
+	if err := a.printPlan(p); err != nil {
+		return err
+	}
+
+	return applyErr
+}
+
+func (a *app) printPlan(p *mango.ManifestPlan) error {
+	return a.print(p, func() table {
+		t := table{header: []string{"STATUS", "ID", "CLOSES", "QUESTION"}}
+		for _, pm := range p.Markets {
+			status := "create"
+			switch {
+			case pm.Exists && pm.Unfinished():
+				status = "unfinished"
+			case pm.Exists:
+				status = "exists"
+			case pm.Id != "":
+				status = "created"
+			}
+			t.rows = append(t.rows, []string{status, pm.Id, formatTime(pm.Request.CloseTime), pm.Request.Question})
+		}
+		return t
+	})
+}
+
+// readMarketFile reads a [mango.PostMarketRequest] from a YAML or JSON file.
+// Field names are the same as those used by the API, eg "outcomeType" and "closeTime".
+func readMarketFile(path string) (*mango.PostMarketRequest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// YAML is a superset of JSON, so decode generically and then use the
+	// request type's JSON tags to map the fields
+	var v any
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("error parsing %v: %v", path, err)
+	}
+
+	j, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %v: %v", path, err)
+	}
+
+	var pmr mango.PostMarketRequest
+	if err := json.Unmarshal(j, &pmr); err != nil {
+		return nil, fmt.Errorf("error parsing %v: %v", path, err)
+	}
+
+	if pmr.Question == "" || pmr.OutcomeType == "" {
+		return nil, fmt.Errorf("%v must set question and outcomeType", path)
+	}
+
+	return &pmr, nil
+}
+
+func marketResolve(a *app, args []string) error {
+	fs := a.flagSet("market resolve")
+	outcome := fs.String("outcome", "", `one of "YES", "NO", "MKT", "CANCEL" or an answer ID`)
+	prob := fs.Int64("prob", 0, "the probability to resolve to, for MKT resolutions")
+	value := fs.Float64("value", 0, "the value to resolve to, for numeric markets")
+	resolutions := fs.String("resolutions", "", "comma-separated answer:pct pairs, for multiple choice markets")
+	preview := fs.Bool("preview", false, "show what each trader would be paid instead of resolving")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 || *outcome == "" {
+		return errUsage
+	}
+
+	rmr := mango.ResolveMarketRequest{
+		Outcome:        *outcome,
+		ProbabilityInt: *prob,
+		Value:          *value,
+	}
+
+	if *resolutions != "" {
+		for _, r := range strings.Split(*resolutions, ",") {
+			answer, pct, ok := strings.Cut(r, ":")
+			ans, aerr := strconv.ParseInt(strings.TrimSpace(answer), 10, 64)
+			p, perr := strconv.ParseInt(strings.TrimSpace(pct), 10, 64)
+			if !ok || aerr != nil || perr != nil {
+				return fmt.Errorf("invalid resolution %q, expected answer:pct", r)
+			}
+			rmr.Resolutions = append(rmr.Resolutions, mango.Resolution{Answer: ans, Pct: p})
+		}
+	}
+
+	if *preview {
+		p, err := a.mc().PreviewResolution(args[0], rmr)
+		if err != nil {
+			return err
+		}
+
+		switch a.output {
+		case "json":
+			return a.writeJSON(p)
+		case "", "table":
+			return p.WriteText(a.stdout)
+		default:
+			return fmt.Errorf("unknown output format %q", a.output)
+		}
+	}
+
+	if a.dryRun {
+		return a.printDryRun("ResolveMarket", map[string]any{"marketId": args[0], "resolution": rmr})
+	}
+
+	if err := a.mc().ResolveMarket(args[0], rmr); err != nil {
+		return err
+	}
+
+	return a.print(map[string]string{"id": args[0], "outcome": *outcome}, func() table {
+		return table{rows: [][]string{{"resolved", args[0]}, {"outcome", *outcome}}}
+	})
+}
+
+func betPlace(a *app, args []string) error {
+	fs := a.flagSet("bet place")
+	market := fs.String("market", "", "the ID of the market to bet on")
+	outcome := fs.String("outcome", "YES", `either "YES" or "NO"`)
+	amount := fs.Float64("amount", 0, "the amount of mana to bet")
+	limit := fs.Float64("limit", 0, "place a limit order at this probability")
+	answer := fs.String("answer", "", "the answer to bet on, for multiple choice markets")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if *market == "" || *amount <= 0 || len(args) != 0 {
+		return errUsage
+	}
+
+	pbr := mango.PostBetRequest{
+		Amount:     *amount,
+		ContractId: *market,
+		Outcome:    strings.ToUpper(*outcome),
+		AnswerId:   *answer,
+	}
+	if *limit > 0 {
+		pbr.LimitProb = limit
+	}
+
+	if a.dryRun {
+		return a.printDryRun("PostBet", pbr)
+	}
+
+	bet, err := a.mc().PostBet(pbr)
+	if err != nil {
+		return err
+	}
+
+	return a.print(bet, func() table {
+		return table{rows: [][]string{
+			{"id", bet.Id},
+			{"outcome", bet.Outcome},
+			{"amount", formatMana(bet.Amount)},
+			{"shares", fmt.Sprintf("%.2f", bet.Shares)},
+			{"probability", formatProb(bet.ProbBefore) + " -> " + formatProb(bet.ProbAfter)},
+			{"filled", strconv.FormatBool(bet.IsFilled)},
+		}}
+	})
+}
+
+func betCancel(a *app, args []string) error {
+	fs := a.flagSet("bet cancel")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	if a.dryRun {
+		return a.printDryRun("CancelBet", map[string]string{"betId": args[0]})
+	}
+
+	if err := a.mc().CancelBet(args[0]); err != nil {
+		return err
+	}
+
+	return a.print(map[string]string{"cancelled": args[0]}, func() table {
+		return table{rows: [][]string{{"cancelled", args[0]}}}
+	})
+}
+
+func managramSend(a *app, args []string) error {
+	fs := a.flagSet("managram send")
+	to := fs.String("to", "", "comma-separated usernames or user IDs to send mana to")
+	amount := fs.Float64("amount", 0, "the amount of mana to send to each user")
+	message := fs.String("message", "", "a message to send with the mana")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if *to == "" || *amount <= 0 || len(args) != 0 {
+		return errUsage
+	}
+
+	var users []string
+	for _, u := range strings.Split(*to, ",") {
+		users = append(users, strings.TrimSpace(u))
+	}
+
+	// a dry run makes no requests, so the users are printed as given rather than looked up
+	if a.dryRun {
+		return a.printDryRun("SendManagram", mango.SendManagramRequest{ToIds: users, Amount: *amount, Message: *message})
+	}
+
+	var ids []string
+	for _, u := range users {
+		id, err := a.resolveUser(u)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+
+	smr := mango.SendManagramRequest{ToIds: ids, Amount: *amount, Message: *message}
+
+	if err := a.mc().SendManagram(smr); err != nil {
+		return err
+	}
+
+	return a.print(smr, func() table {
+		return table{rows: [][]string{
+			{"sent", formatMana(smr.Amount)},
+			{"to", strings.Join(smr.ToIds, ", ")},
+		}}
+	})
+}
+
+func managramBulk(a *app, args []string) error {
+	fs := a.flagSet("managram bulk")
+	file := fs.String("f", "", "a YAML or JSON file listing the payments, each with a username or userId, amount and message")
+	journal := fs.String("journal", "", "a file recording each payment, so that a run that fails part way through can be resumed")
+	batch := fs.Int("batch", 0, "the most users to pay with one managram (default 20)")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if *file == "" || len(args) != 0 {
+		return errUsage
+	}
+
+	payments, err := readPaymentsFile(*file)
+	if err != nil {
+		return err
+	}
+
+	results, err := a.mc().BulkManagram(payments, &mango.BulkManagramOptions{Journal: *journal, BatchSize: *batch, DryRun: a.dryRun})
+	if results == nil {
+		return err
+	}
+
+	if perr := a.print(results, func() table {
+		t := table{header: []string{"USER", "AMOUNT", "MESSAGE", "STATUS", "TXN"}}
+		for _, r := range results {
+			user := r.Username
+			if user == "" {
+				user = r.UserId
+			}
+			status := string(r.Status)
+			if r.Error != "" {
+				status += ": " + r.Error
+			}
+			var txn string
+			if r.Txn != nil {
+				txn = r.Txn.Id
+			}
+			t.rows = append(t.rows, []string{user, formatMana(r.Amount), r.Message, status, txn})
+		}
+		return t
+	}); perr != nil {
+		return perr
+	}
+
+	return err
+}
+
+// readPaymentsFile reads a list of payments from a YAML or JSON file.
+func readPaymentsFile(path string) ([]mango.Payment, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var v any
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("error parsing %v: %v", path, err)
+	}
+
+	j, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %v: %v", path, err)
+	}
+
+	var payments []mango.Payment
+	if err := json.Unmarshal(j, &payments); err != nil {
+		return nil, fmt.Errorf("error parsing %v: %v", path, err)
+	}
+	if len(payments) == 0 {
+		return nil, fmt.Errorf("%v has no payments", path)
+	}
+
+	return payments, nil
+}
+
+func bountyList(a *app, args []string) error {
+	fs := a.flagSet("bounty list")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	bm, err := mango.NewBountyManager(a.mc(), "")
+	if err != nil {
+		return err
+	}
+	bounties, err := bm.Bounties()
+	if err != nil {
+		return err
+	}
+
+	return a.print(bounties, func() table {
+		t := table{header: []string{"ID", "QUESTION", "BOUNTY", "LEFT", "ANSWERS"}}
+		for _, b := range bounties {
+			t.rows = append(t.rows, []string{
+				b.Market.Id,
+				b.Market.Question,
+				formatMana(b.Total),
+				formatMana(b.Remaining),
+				strconv.Itoa(len(b.Answers)),
+			})
+		}
+		return t
+	})
+}
+
+func bountyAward(a *app, args []string) error {
+	fs := a.flagSet("bounty award")
+	amount := fs.Int64("amount", 0, "the mana to share between the comments")
+	split := fs.String("split", "", "comma-separated comment IDs to award, each optionally with a weight, eg c1:2,c2:1")
+	record := fs.String("record", "", "a file recording each award, so that the same split is never paid twice")
+	key := fs.String("key", "", "identifies the split in the record, instead of its market, amount and weights")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 || *amount <= 0 || *split == "" {
+		return errUsage
+	}
+
+	bs := mango.BountySplit{Key: *key, MarketId: args[0], Amount: *amount, Weights: map[string]float64{}}
+	for _, part := range strings.Split(*split, ",") {
+		id, weight, ok := strings.Cut(strings.TrimSpace(part), ":")
+		w := 1.0
+		if ok {
+			if w, err = strconv.ParseFloat(weight, 64); err != nil {
+				return fmt.Errorf("invalid weight for comment %v: %v", id, err)
+			}
+		}
+		bs.Weights[id] += w
+	}
+
+	if a.dryRun {
+		return a.printDryRun("AwardBounty", bs)
+	}
+
+	bm, err := mango.NewBountyManager(a.mc(), *record)
+	if err != nil {
+		return err
+	}
+	awards, err := bm.Award(bs)
+	if awards == nil {
+		return err
+	}
+
+	if perr := a.print(awards, func() table {
+		t := table{header: []string{"COMMENT", "USER", "AMOUNT", "PAID"}}
+		for _, aw := range awards {
+			t.rows = append(t.rows, []string{aw.CommentId, aw.Username, formatMana(float64(aw.Amount)), strconv.FormatBool(aw.Paid)})
+		}
+		return t
+	}); perr != nil {
+		return perr
+	}
+
+	return err
+}
+
+// resolveUser returns the ID of the user with the given username, or u itself if
+// no such user exists, in which case it is assumed to already be an ID.
+func (a *app) resolveUser(u string) (string, error) {
+	if user, err := a.mc().GetUserByUsername(u); err == nil {
+		return user.Id, nil
+	}
+
+	user, err := a.mc().GetUserByID(u)
+	if err != nil {
+		return "", fmt.Errorf("no user found with username or ID %q", u)
+	}
+
+	return user.Id, nil
+}
+
+func txnsList(a *app, args []string) error {
+	fs := a.flagSet("txns list")
+	var req mango.GetTransactionsRequest
+	fs.StringVar(&req.Token, "token", "", `the token to list transactions for, eg "MANA"`)
+	fs.StringVar(&req.Category, "category", "", `the category of transaction, eg "MANA_PAYMENT"`)
+	fs.StringVar(&req.FromId, "from", "", "only list transactions from this user ID")
+	fs.StringVar(&req.ToId, "to", "", "only list transactions to this user ID")
+	fs.Int64Var(&req.Limit, "limit", 100, "the maximum number of transactions to list")
+	fs.Int64Var(&req.Offset, "offset", 0, "the number of transactions to skip")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	txns, err := a.mc().GetTransactions(req)
+	if err != nil {
+		return err
+	}
+
+	return a.print(txns, func() table {
+		t := table{header: []string{"ID", "TIME", "CATEGORY", "FROM", "TO", "AMOUNT", "TOKEN"}}
+		for _, txn := range *txns {
+			t.rows = append(t.rows, []string{
+				txn.Id,
+				formatTime(txn.CreatedTime),
+				txn.Category,
+				txn.FromId,
+				txn.ToId,
+				formatMana(txn.Amount),
+				txn.Token,
+			})
+		}
+		return t
+	})
+}
+
+func portfolio(a *app, args []string) error {
+	fs := a.flagSet("portfolio")
+	username := fs.String("user", "", "the username to show the portfolio of, instead of the authenticated user")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	var user *mango.User
+	if *username != "" {
+		user, err = a.mc().GetUserByUsername(*username)
+	} else {
+		user, err = a.mc().GetAuthenticatedUser()
+	}
+	if err != nil {
+		return err
+	}
+
+	p, err := a.mc().GetUserPortfolio(user.Id)
+	if err != nil {
+		return err
+	}
+
+	return a.print(p, func() table {
+		return table{rows: [][]string{
+			{"user", user.Username},
+			{"balance", formatMana(p.Balance)},
+			{"investment value", formatMana(p.InvestmentValue)},
+			{"net worth", formatMana(p.Balance + p.InvestmentValue)},
+			{"loans", formatMana(p.LoanTotal)},
+			{"profit", formatMana(p.Profit)},
+			{"daily profit", formatMana(p.DailyProfit)},
+		}}
+	})
+}
+
+func calibration(a *app, args []string) error {
+	fs := a.flagSet("calibration")
+	username := fs.String("user", "", "the username to analyse, instead of the authenticated user")
+	bins := fs.Int("bins", 10, "the number of calibration bins")
+	svg := fs.String("svg", "", "also write the calibration curve to this SVG file")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	if *username == "" {
+		user, err := a.mc().GetAuthenticatedUser()
+		if err != nil {
+			return err
+		}
+		*username = user.Username
+	}
+
+	r, err := analytics.Analyze(a.mc(), *username, analytics.WithBins(*bins))
+	if err != nil {
+		return err
+	}
+
+	if *svg != "" {
+		f, err := os.Create(*svg)
+		if err != nil {
+			return err
+		}
+		if err := r.WriteSVG(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	switch a.output {
+	case "json":
+		return r.WriteJSON(a.stdout)
+	case "", "table":
+		return r.WriteText(a.stdout)
+	default:
+		return fmt.Errorf("unknown output format %q", a.output)
+	}
+}
+
+func ledgerCommand(a *app, args []string) error {
+	fs := a.flagSet("ledger")
+	username := fs.String("user", "", "the username to reconcile, instead of the authenticated user")
+	tolerance := fs.Float64("tolerance", 0.01, "the largest difference between balances that isn't a discrepancy")
+	dir := fs.String("statements", "", "also write a CSV statement for each month and token to this directory")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	var user *mango.User
+	if *username != "" {
+		user, err = a.mc().GetUserByUsername(*username)
+	} else {
+		user, err = a.mc().GetAuthenticatedUser()
+	}
+	if err != nil {
+		return err
+	}
+
+	l, err := ledger.Build(a.mc(), user.Id)
+	if err != nil {
+		return err
+	}
+	live, err := a.mc().GetUserPortfolio(user.Id)
+	if err != nil {
+		return err
+	}
+
+	if *dir != "" {
+		if err := os.MkdirAll(*dir, 0o755); err != nil {
+			return err
+		}
+		statements := l.Statements(time.UTC)
+		for _, s := range statements {
+			f, err := os.Create(filepath.Join(*dir, fmt.Sprintf("%v-%v.csv", s.Month.Format("2006-01"), strings.ToLower(string(s.Token)))))
+			if err != nil {
+				return err
+			}
+			if err := s.WriteCSV(f); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(a.stderr, "wrote %d statements to %v\n", len(statements), *dir)
+	}
+
+	rs := l.Reconcile(*live, *tolerance)
+	if err := a.print(rs, func() table {
+		t := table{header: []string{"TOKEN", "LEDGER", "PORTFOLIO", "DIFFERENCE", "UNCLASSIFIED", "STATUS"}}
+		for _, r := range rs {
+			status := "ok"
+			if r.Discrepancy {
+				status = "DISCREPANCY"
+			}
+			t.rows = append(t.rows, []string{
+				string(r.Token),
+				fmt.Sprintf("%.2f", r.Ledger),
+				fmt.Sprintf("%.2f", r.Portfolio),
+				fmt.Sprintf("%.2f", r.Difference),
+				fmt.Sprintf("%.2f", r.Unclassified),
+				status,
+			})
+		}
+		return t
+	}); err != nil {
+		return err
+	}
+
+	for _, r := range rs {
+		if r.Discrepancy {
+			return fmt.Errorf("%v balance is out by %.2f", r.Token, r.Difference)
+		}
+	}
+	return nil
+}
+
+func formatMana(m float64) string {
+	return fmt.Sprintf("M%.2f", m)
+}
+
+func formatProb(p float64) string {
+	return fmt.Sprintf("%.1f%%", p*100)
+}
+
+func formatTime(ms int64) string {
+	if ms == 0 {
+		return ""
+	}
+	return time.UnixMilli(ms).UTC().Format(time.RFC3339)
+}
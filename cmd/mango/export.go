@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+	"github.com/jonnyspicer/mango/export"
+)
+
+// exportCommand returns a command that exports records with the given function.
+func exportCommand[T any](name string, run func(*mango.Client, export.Filter, export.Writer[T]) (int, error)) func(a *app, args []string) error {
+	return func(a *app, args []string) error {
+		fs := a.flagSet("export " + name)
+		format := fs.String("format", "", "csv, ndjson or parquet, by default taken from the output file's extension or ndjson")
+		out := fs.String("o", "", "the file to write to, instead of stdout")
+		user := fs.String("user", "", "only export records for this username or user ID")
+		market := fs.String("market", "", "only export records for this market ID")
+		from := fs.String("from", "", "only export records created at or after this time, as a date or RFC 3339 timestamp")
+		to := fs.String("to", "", "only export records created before this time, as a date or RFC 3339 timestamp")
+		args, err := parse(fs, args)
+		if err != nil {
+			return err
+		}
+		if len(args) != 0 {
+			return errUsage
+		}
+
+		f := export.NDJSON
+		if *format != "" {
+			if f, err = export.ParseFormat(*format); err != nil {
+				return err
+			}
+		} else if pf, ok := export.FormatForPath(*out); ok {
+			f = pf
+		}
+
+		filter := export.Filter{MarketId: *market}
+		if filter.From, err = parseTimeFlag(*from); err != nil {
+			return fmt.Errorf("invalid -from: %v", err)
+		}
+		if filter.To, err = parseTimeFlag(*to); err != nil {
+			return fmt.Errorf("invalid -to: %v", err)
+		}
+
+		if a.dryRun {
+			return a.printDryRun("export."+name, struct {
+				Format string        `json:"format"`
+				Output string        `json:"output,omitempty"`
+				User   string        `json:"user,omitempty"`
+				Filter export.Filter `json:"filter"`
+			}{string(f), *out, *user, filter})
+		}
+
+		if *user != "" {
+			if filter.UserId, err = a.resolveUser(*user); err != nil {
+				return err
+			}
+		}
+
+		var dst io.Writer = a.stdout
+		if *out != "" {
+			file, err := os.Create(*out)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			dst = file
+		}
+
+		w, err := export.NewWriter[T](dst, f)
+		if err != nil {
+			return err
+		}
+
+		n, err := run(a.mc(), filter, w)
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+
+		if *out != "" {
+			fmt.Fprintf(a.stderr, "exported %d %v to %v\n", n, name, *out)
+		}
+		return nil
+	}
+}
+
+// parseTimeFlag parses a date or an RFC 3339 timestamp. Dates are midnight UTC.
+func parseTimeFlag(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
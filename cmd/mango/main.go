@@ -0,0 +1,307 @@
+// Command mango is a command-line client for the Manifold Markets API.
+//
+// Usage:
+//
+//	mango [global flags] <command> <subcommand> [flags] [args]
+//
+// The commands are:
+//
+//	market get <slug|id>        show a market
+//	market create -f <file>     create a market from a YAML or JSON file
+//	market resolve <id>         resolve a market
+//	manifest plan -f <file>     show which of the markets in a manifest already exist
+//	manifest apply -f <file>    create or finish the markets in a manifest
+//	bet place                   place a bet
+//	bet cancel <id>             cancel a limit order
+//	managram send               send mana to other users
+//	managram bulk -f <file>     send different amounts of mana to many users
+//	bounty list                 list your bountied questions
+//	bounty award <id>           award a bounty across several comments
+//	txns list                   list transactions
+//	screen run <expr|name>      list the markets matching a filter expression or saved screen
+//	screen save <name> <expr>   save a filter expression as a screen
+//	screen list                 list saved screens
+//	screen delete <name>        delete a saved screen
+//	export bets                 export bets as CSV, NDJSON or Parquet
+//	export markets              export markets
+//	export comments             export a market's comments
+//	export txns                 export transactions
+//	export positions            export a user's positions
+//	portfolio                   show a user's portfolio
+//	calibration                 show a user's forecasting accuracy and calibration
+//	ledger                      reconcile a user's transactions against their balances
+//	tui <market>...             watch and trade markets interactively
+//
+// Global flags can be given before or after the command:
+//
+//	-profile name   use the API key for the named profile in the shared credentials file
+//	-output format  either "table" (the default) or "json"
+//	-dry-run        print the request a command would send instead of sending it
+//	-url url        the base URL of the Manifold API
+//
+// If no profile is given, the API key is read from `MANIFOLD_API_KEY` in a .env file in the
+// current directory or from the environment, and then from the "default" profile.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jonnyspicer/mango"
+	"github.com/jonnyspicer/mango/export"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// globals holds the flags shared by every command.
+type globals struct {
+	profile string
+	output  string
+	dryRun  bool
+	url     string
+}
+
+// app holds the state shared by every command.
+type app struct {
+	globals
+	stdout io.Writer
+	stderr io.Writer
+	client *mango.Client
+}
+
+type command struct {
+	name  string
+	usage string
+	run   func(a *app, args []string) error
+}
+
+var commands = map[string][]command{
+	"market": {
+		{"get", "get <slug|id>", marketGet},
+		{"create", "create -f <file>", marketCreate},
+		{"resolve", "resolve <id> -outcome <outcome> [-prob n] [-resolutions answer:pct,...] [-preview]", marketResolve},
+	},
+	"manifest": {
+		{"plan", "plan -f <file>", manifestPlan},
+		{"apply", "apply -f <file>", manifestApply},
+	},
+	"bet": {
+		{"place", "place -market <id> -outcome <YES|NO> -amount <n> [-limit prob] [-answer id]", betPlace},
+		{"cancel", "cancel <id>", betCancel},
+	},
+	"managram": {
+		{"send", "send -to <user,...> -amount <n> [-message text]", managramSend},
+		{"bulk", "bulk -f <file> [-journal file] [-batch n]", managramBulk},
+	},
+	"bounty": {
+		{"list", "list", bountyList},
+		{"award", "award <id> -amount <n> -split <comment[:weight],...> [-record file] [-key k]", bountyAward},
+	},
+	"txns": {
+		{"list", "list [-token t] [-category c] [-from id] [-to id] [-limit n]", txnsList},
+	},
+	"export": {
+		{"bets", "bets [-format f] [-o file] [-user u] [-market id] [-from t] [-to t]", exportCommand("bets", export.Bets)},
+		{"markets", "markets [-format f] [-o file] [-user creator] [-market id] [-from t] [-to t]", exportCommand("markets", export.Markets)},
+		{"comments", "comments -market id [-format f] [-o file] [-user u] [-from t] [-to t]", exportCommand("comments", export.Comments)},
+		{"txns", "txns [-format f] [-o file] [-user u] [-market id] [-from t] [-to t]", exportCommand("txns", export.Transactions)},
+		{"positions", "positions [-format f] [-o file] [-user u] [-market id] [-from t] [-to t]", exportCommand("positions", export.ContractMetrics)},
+	},
+	"screen": {
+		{"run", "run <expr|name> [-limit n] [-scan n] [-term t] [-filter f] [-sort s] [-type t] [-topic slug] [-creator id] [-screens file]", screenRun},
+		{"save", "save <name> <expr> [-description d] [-term t] [-filter f] [-sort s] [-type t] [-topic slug] [-creator id] [-screens file]", screenSave},
+		{"list", "list [-screens file]", screenList},
+		{"delete", "delete <name> [-screens file]", screenDelete},
+	},
+	"portfolio": {
+		{"", "[-user username]", portfolio},
+	},
+	"calibration": {
+		{"", "[-user username] [-bins n] [-svg file]", calibration},
+	},
+	"ledger": {
+		{"", "[-user username] [-tolerance n] [-statements dir]", ledgerCommand},
+	},
+	"tui": {
+		{"", "[-interval duration] <slug|id>...", runTUI},
+	},
+}
+
+var errUsage = errors.New("usage")
+
+func run(args []string, stdout, stderr io.Writer) int {
+	a := &app{stdout: stdout, stderr: stderr}
+
+	fs := a.flagSet("mango")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	args = fs.Args()
+
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+
+	group, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "mango: unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+
+	cmd := group[0]
+	rest := args[1:]
+	if cmd.name != "" {
+		if len(rest) == 0 {
+			usage(stderr)
+			return 2
+		}
+
+		found := false
+		for _, c := range group {
+			if c.name == rest[0] {
+				cmd, found = c, true
+				break
+			}
+		}
+		if !found {
+			fmt.Fprintf(stderr, "mango: unknown command %q\n", args[0]+" "+rest[0])
+			usage(stderr)
+			return 2
+		}
+		rest = rest[1:]
+	}
+
+	if err := cmd.run(a, rest); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(stderr, "usage: mango %v %v\n", args[0], cmd.usage)
+			return 2
+		}
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(stderr, "mango: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: mango [-profile name] [-output table|json] [-dry-run] <command> [flags] [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, name := range []string{"market", "manifest", "bet", "managram", "bounty", "txns", "screen", "export", "portfolio", "calibration", "ledger", "tui"} {
+		for _, c := range commands[name] {
+			fmt.Fprintf(w, "  %v %v\n", name, c.usage)
+		}
+	}
+}
+
+// flagSet returns a new flag set with the global flags registered on it.
+func (a *app) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+
+	fs.StringVar(&a.profile, "profile", a.profile, "use the API key for the named profile in the shared credentials file")
+	fs.StringVar(&a.output, "output", a.output, `output format, either "table" or "json"`)
+	fs.BoolVar(&a.dryRun, "dry-run", a.dryRun, "print the request that would be sent instead of sending it")
+	fs.StringVar(&a.url, "url", a.url, "the base URL of the Manifold API")
+
+	return fs
+}
+
+// parse parses flags that may appear before, between or after positional arguments,
+// and returns the positional arguments.
+func parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// mc returns the client used to talk to the API, creating it on first use.
+func (a *app) mc() *mango.Client {
+	if a.client != nil {
+		return a.client
+	}
+
+	var creds mango.CredentialProvider
+	if a.profile != "" {
+		creds = mango.ProfileCredentials{Profile: a.profile}
+	} else {
+		creds = mango.ChainCredentials{
+			mango.DotEnvCredentials{},
+			mango.EnvCredentials{},
+			mango.ProfileCredentials{},
+		}
+	}
+
+	var url *string
+	if a.url != "" {
+		url = &a.url
+	}
+
+	a.client = mango.NewClient(nil, url, nil, mango.WithCredentials(creds))
+
+	return a.client
+}
+
+// printDryRun prints the request that would be made by the named API call.
+func (a *app) printDryRun(call string, req any) error {
+	return a.writeJSON(struct {
+		DryRun  bool   `json:"dryRun"`
+		Call    string `json:"call"`
+		Request any    `json:"request"`
+	}{true, call, req})
+}
+
+func (a *app) writeJSON(v any) error {
+	enc := json.NewEncoder(a.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// table is a simple representation of tabular output.
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+// print writes v in the selected output format. The table is only built if it is needed.
+func (a *app) print(v any, tbl func() table) error {
+	switch a.output {
+	case "json":
+		return a.writeJSON(v)
+	case "", "table":
+		t := tbl()
+		tw := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+		if len(t.header) > 0 {
+			fmt.Fprintln(tw, strings.Join(t.header, "\t"))
+		}
+		for _, r := range t.rows {
+			fmt.Fprintln(tw, strings.Join(r, "\t"))
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q", a.output)
+	}
+}
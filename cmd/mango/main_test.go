@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jonnyspicer/mango"
+	"github.com/jonnyspicer/mango/mangotest"
+)
+
+// runCLI runs the command against the given server and returns its exit code and output.
+func runCLI(t *testing.T, server *httptest.Server, args ...string) (int, string, string) {
+	t.Helper()
+	t.Setenv("MANIFOLD_API_KEY", "test-api-key")
+
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"-url", server.URL}, args...), &stdout, &stderr)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func TestMarketGet(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v0/slug/will-it-rain" {
+			t.Errorf("unexpected path %v", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(mango.FullMarket{Id: "m1", Question: "Will it rain?", Probability: 0.25})
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	code, out, errOut := runCLI(t, server, "market", "get", "will-it-rain")
+	if code != 0 {
+		t.Fatalf("exit code %d: %v", code, errOut)
+	}
+	if !strings.Contains(out, "Will it rain?") || !strings.Contains(out, "25.0%") {
+		t.Errorf("unexpected table output: %v", out)
+	}
+
+	code, out, errOut = runCLI(t, server, "market", "get", "will-it-rain", "-output", "json")
+	if code != 0 {
+		t.Fatalf("exit code %d: %v", code, errOut)
+	}
+
+	var m mango.FullMarket
+	if err := json.Unmarshal([]byte(out), &m); err != nil {
+		t.Fatalf("expected JSON output, got %v: %v", out, err)
+	}
+	if m.Id != "m1" {
+		t.Errorf("expected market m1, got %+v", m)
+	}
+}
+
+func TestBetPlaceDryRun(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("expected no request to be made, got %v %v", r.Method, r.URL.Path)
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	code, out, errOut := runCLI(t, server, "-dry-run", "bet", "place", "-market", "m1", "-outcome", "no", "-amount", "25")
+	if code != 0 {
+		t.Fatalf("exit code %d: %v", code, errOut)
+	}
+
+	var got struct {
+		Call    string               `json:"call"`
+		Request mango.PostBetRequest `json:"request"`
+	}
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("expected JSON output, got %v: %v", out, err)
+	}
+	if got.Call != "PostBet" || got.Request.Outcome != "NO" || got.Request.Amount != 25 {
+		t.Errorf("unexpected dry run output: %+v", got)
+	}
+}
+
+func TestManagramSendDryRun(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("expected no request to be made, got %v %v", r.Method, r.URL.Path)
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	code, out, errOut := runCLI(t, server, "-dry-run", "managram", "send", "-to", "alice, bob", "-amount", "50")
+	if code != 0 {
+		t.Fatalf("exit code %d: %v", code, errOut)
+	}
+
+	var got struct {
+		Call    string                    `json:"call"`
+		Request mango.SendManagramRequest `json:"request"`
+	}
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("expected JSON output, got %v: %v", out, err)
+	}
+	if got.Call != "SendManagram" || strings.Join(got.Request.ToIds, ",") != "alice,bob" || got.Request.Amount != 50 {
+		t.Errorf("unexpected dry run output: %+v", got)
+	}
+}
+
+func TestMarketCreateFromYAML(t *testing.T) {
+	var received mango.PostMarketRequest
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		json.Unmarshal(b, &received)
+		w.Write([]byte(`{"id":"new-market"}`))
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "market.yaml")
+	manifest := `outcomeType: BINARY
+question: Will it rain tomorrow?
+initialProb: 40
+closeTime: 1704067199000
+`
+	if err := os.WriteFile(path, []byte(manifest), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	code, out, errOut := runCLI(t, server, "market", "create", "-f", path)
+	if code != 0 {
+		t.Fatalf("exit code %d: %v", code, errOut)
+	}
+	if !strings.Contains(out, "new-market") {
+		t.Errorf("expected new market id in output, got %v", out)
+	}
+
+	if received.Question != "Will it rain tomorrow?" || received.InitialProb != 40 || received.CloseTime != 1704067199000 {
+		t.Errorf("unexpected request: %+v", received)
+	}
+}
+
+func TestManifestApplyDryRun(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v0/me/":
+			json.NewEncoder(w).Encode(mango.User{Id: "creator"})
+		case "/v0/search-markets/":
+			var found []mango.FullMarket
+			if strings.Contains(r.URL.Query().Get("term"), "Monday") {
+				found = append(found, mango.FullMarket{Id: "m1", Question: "Will it rain on Monday?"})
+			}
+			json.NewEncoder(w).Encode(found)
+		default:
+			t.Errorf("unexpected request %v %v", r.Method, r.URL.Path)
+		}
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "manifest.yaml")
+	manifest := `defaults:
+  outcomeType: BINARY
+  closeTime: now + 7d
+markets:
+  - question: "Will it rain on {{.Day}}?"
+    each: [{Day: Monday}, {Day: Tuesday}]
+`
+	if err := os.WriteFile(path, []byte(manifest), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	code, out, errOut := runCLI(t, server, "manifest", "apply", "-f", path, "-dry-run")
+	if code != 0 {
+		t.Fatalf("exit code %d: %v", code, errOut)
+	}
+	if strings.Contains(out, "Monday") || !strings.Contains(out, "Will it rain on Tuesday?") {
+		t.Errorf("expected only Tuesday to be created, got %v", out)
+	}
+
+	code, out, errOut = runCLI(t, server, "manifest", "plan", "-f", path)
+	if code != 0 {
+		t.Fatalf("exit code %d: %v", code, errOut)
+	}
+	if !strings.Contains(out, "exists") || !strings.Contains(out, "create") {
+		t.Errorf("unexpected plan output %v", out)
+	}
+}
+
+func TestMarketResolvePreview(t *testing.T) {
+	s := mangotest.NewServer()
+	defer s.Close()
+
+	s.AddMarket(mango.FullMarket{Id: "m1", Question: "Will it rain?", CreatorId: s.Me().Id, Pool: mango.Pool{"YES": 100, "NO": 100}, P: 0.5})
+	if _, err := s.Client().PostBet(mango.PostBetRequest{ContractId: "m1", Outcome: "YES", Amount: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	code, out, errOut := runCLI(t, s.Server, "market", "resolve", "m1", "-outcome", "YES", "-preview")
+	if code != 0 {
+		t.Fatalf("exit code %d: %v", code, errOut)
+	}
+	if !strings.Contains(out, "Will it rain?") || !strings.Contains(out, "me") || !strings.Contains(out, "YES (100% YES)") {
+		t.Errorf("unexpected preview %v", out)
+	}
+
+	if m, _ := s.Market("m1"); m.IsResolved {
+		t.Error("expected previewing not to resolve the market")
+	}
+}
+
+func TestManagramBulk(t *testing.T) {
+	s := mangotest.NewServer()
+	defer s.Close()
+
+	s.AddUser(mango.User{Id: "u1", Username: "alice"})
+	s.AddUser(mango.User{Id: "u2", Username: "bob"})
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "payroll.yaml")
+	payments := `- username: alice
+  amount: 100
+  message: 1st place
+- userId: u2
+  amount: 50
+  message: 2nd place
+`
+	if err := os.WriteFile(path, []byte(payments), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	journal := filepath.Join(dir, "payroll.jsonl")
+
+	// sending mana needs the fake's own API key
+	t.Setenv("MANIFOLD_API_KEY", mangotest.APIKey)
+	for i := 0; i < 2; i++ {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"-url", s.URL, "managram", "bulk", "-f", path, "-journal", journal}, &stdout, &stderr); code != 0 {
+			t.Fatalf("exit code %d: %v", code, stderr.String())
+		}
+		if out := stdout.String(); !strings.Contains(out, "alice") || !strings.Contains(out, "sent") {
+			t.Errorf("unexpected output %v", out)
+		}
+	}
+
+	// the second run finds both payments in the journal and sends nothing
+	if b := s.Me().Balance; b != 850 {
+		t.Errorf("expected balance 850, got %v", b)
+	}
+}
+
+func TestBountyAward(t *testing.T) {
+	s := mangotest.NewServer()
+	defer s.Close()
+
+	s.AddUser(mango.User{Id: "u1", Username: "alice"})
+	s.AddUser(mango.User{Id: "u2", Username: "bob"})
+	s.AddMarket(mango.FullMarket{Id: "q1", Question: "Best bread recipe?", CreatorId: s.Me().Id, OutcomeType: mango.BountiedQuestion})
+	s.AddComment(mango.Comment{Id: "c1", ContractId: "q1", UserId: "u1", UserUsername: "alice"})
+	s.AddComment(mango.Comment{Id: "c2", ContractId: "q1", UserId: "u2", UserUsername: "bob"})
+	if err := s.Client().AddBounty("q1", 100); err != nil {
+		t.Fatal(err)
+	}
+
+	// awarding needs the fake's own API key
+	t.Setenv("MANIFOLD_API_KEY", mangotest.APIKey)
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-url", s.URL, "bounty", "award", "q1", "-amount", "90", "-split", "c1:2,c2"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %v", code, stderr.String())
+	}
+	if out := stdout.String(); !strings.Contains(out, "alice") || !strings.Contains(out, "M60.00") || !strings.Contains(out, "M30.00") {
+		t.Errorf("unexpected output %v", out)
+	}
+
+	if m, _ := s.Market("q1"); m.BountyLeft != 10 {
+		t.Errorf("expected M10 of the bounty left, got %v", m.BountyLeft)
+	}
+}
+
+func TestLedger(t *testing.T) {
+	s := mangotest.NewServer()
+	defer s.Close()
+
+	s.AddUser(mango.User{Id: "u1", Username: "alice"})
+	s.AddTxn(mango.Txn{FromId: "BANK", FromType: "BANK", ToId: s.Me().Id, ToType: "USER", Amount: 1000, Category: "SIGNUP_BONUS"})
+	if err := s.Client().SendManagram(mango.SendManagramRequest{ToIds: []string{"u1"}, Amount: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "statements")
+	code, out, errOut := runCLI(t, s.Server, "ledger", "-user", "me", "-statements", dir)
+	if code != 0 {
+		t.Fatalf("exit code %d: %v", code, errOut)
+	}
+	if !strings.Contains(out, "MANA") || !strings.Contains(out, "900.00") || strings.Contains(out, "DISCREPANCY") {
+		t.Errorf("unexpected output %v", out)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 || !strings.HasSuffix(files[0].Name(), "-mana.csv") {
+		t.Errorf("expected one statement, got %v", files)
+	}
+
+	// a balance the ledger can't explain is a discrepancy
+	s.SetBalance(950)
+	code, out, _ = runCLI(t, s.Server, "ledger", "-user", "me")
+	if code != 1 || !strings.Contains(out, "DISCREPANCY") {
+		t.Errorf("expected a discrepancy, got exit code %d: %v", code, out)
+	}
+}
+
+func TestExportBets(t *testing.T) {
+	s := mangotest.NewServer()
+	defer s.Close()
+
+	s.AddUser(mango.User{Id: "u1", Username: "alice"})
+	s.AddBet(mango.Bet{ContractId: "m1", UserId: "u1", Amount: 30, CreatedTime: 1710000000000})
+	s.AddBet(mango.Bet{ContractId: "m1", UserId: "u1", Amount: 10, CreatedTime: 1717200000000})
+	s.AddBet(mango.Bet{ContractId: "m1", UserId: "u2", Amount: 20, CreatedTime: 1717200000000})
+
+	path := filepath.Join(t.TempDir(), "bets.csv")
+	code, _, errOut := runCLI(t, s.Server, "export", "bets", "-user", "alice", "-from", "2024-05-01", "-o", path)
+	if code != 0 {
+		t.Fatalf("exit code %d: %v", code, errOut)
+	}
+	if !strings.Contains(errOut, "exported 1 bets") {
+		t.Errorf("unexpected stderr %v", errOut)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "id,contract_id,user_id") || !strings.Contains(lines[1], ",10,") {
+		t.Errorf("unexpected CSV %q", b)
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	if code := run([]string{"nonsense"}, &stdout, &stderr); code != 2 {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "usage") {
+		t.Errorf("expected usage on stderr, got %v", stderr.String())
+	}
+}
+
+func TestScreen(t *testing.T) {
+	s := mangotest.NewServer()
+	defer s.Close()
+
+	s.AddMarket(mango.FullMarket{Id: "m1", Question: "Will it rain?", OutcomeType: mango.Binary, Pool: mango.Pool{"YES": 100, "NO": 100}, P: 0.5, Volume24Hours: 900})
+	s.AddMarket(mango.FullMarket{Id: "m2", Question: "Will it snow?", OutcomeType: mango.Binary, Pool: mango.Pool{"YES": 100, "NO": 100}, P: 0.5, Volume24Hours: 10})
+
+	screens := filepath.Join(t.TempDir(), "screens.json")
+	code, _, errOut := runCLI(t, s.Server, "screen", "save", "movers", `volume24Hours > 500 && outcomeType == "BINARY"`, "-description", "busy markets", "-screens", screens)
+	if code != 0 {
+		t.Fatalf("exit code %d: %v", code, errOut)
+	}
+
+	code, out, errOut := runCLI(t, s.Server, "screen", "list", "-screens", screens)
+	if code != 0 || !strings.Contains(out, "movers") || !strings.Contains(out, "busy markets") {
+		t.Errorf("unexpected screens, exit code %d: %v%v", code, out, errOut)
+	}
+
+	code, out, errOut = runCLI(t, s.Server, "screen", "run", "movers", "-screens", screens)
+	if code != 0 {
+		t.Fatalf("exit code %d: %v", code, errOut)
+	}
+	if !strings.Contains(out, "Will it rain?") || strings.Contains(out, "Will it snow?") {
+		t.Errorf("unexpected matches %v", out)
+	}
+
+	code, out, _ = runCLI(t, s.Server, "-output", "json", "screen", "run", `question contains "snow"`, "-screens", screens)
+	var matches []mango.FullMarket
+	if code != 0 || json.Unmarshal([]byte(out), &matches) != nil || len(matches) != 1 || matches[0].Id != "m2" {
+		t.Errorf("unexpected matches, exit code %d: %v", code, out)
+	}
+
+	code, _, errOut = runCLI(t, s.Server, "screen", "run", "volume24Hours >", "-screens", screens)
+	if code != 1 || !strings.Contains(errOut, "column") {
+		t.Errorf("expected a syntax error, got exit code %d: %v", code, errOut)
+	}
+
+	if code, _, _ := runCLI(t, s.Server, "screen", "delete", "movers", "-screens", screens); code != 0 {
+		t.Errorf("expected to delete the screen, got exit code %d", code)
+	}
+	if code, _, _ := runCLI(t, s.Server, "screen", "delete", "movers", "-screens", screens); code != 1 {
+		t.Errorf("expected deleting again to fail, got exit code %d", code)
+	}
+}
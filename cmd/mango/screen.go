@@ -0,0 +1,205 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/jonnyspicer/mango"
+	"github.com/jonnyspicer/mango/screener"
+)
+
+// searchFlags registers the flags that narrow the markets a screen looks at.
+func searchFlags(fs *flag.FlagSet) *mango.SearchMarketsRequest {
+	var req mango.SearchMarketsRequest
+	fs.StringVar(&req.Term, "term", "", "only look at markets matching this search text")
+	fs.StringVar(&req.Filter, "filter", "", `only look at "open", "closed" or "resolved" markets`)
+	fs.StringVar(&req.Sort, "sort", "", `the order to look at markets in, eg "newest" or "liquidity"`)
+	fs.StringVar(&req.ContractType, "type", "", `only look at markets of this type, eg "BINARY"`)
+	fs.StringVar(&req.TopicSlug, "topic", "", "only look at markets in this topic")
+	fs.StringVar(&req.CreatorId, "creator", "", "only look at markets created by this user ID")
+	return &req
+}
+
+// libraryFlag registers the flag for the file saved screens are kept in.
+func libraryFlag(fs *flag.FlagSet) *string {
+	return fs.String("screens", "", "the file saved screens are kept in (default screens.json in the mango config directory)")
+}
+
+func openLibrary(path string) (*screener.Library, error) {
+	if path == "" {
+		var err error
+		if path, err = screener.DefaultLibraryPath(); err != nil {
+			return nil, fmt.Errorf("error finding screens file: %w", err)
+		}
+	}
+	return screener.Open(path)
+}
+
+func screenRun(a *app, args []string) error {
+	fs := a.flagSet("screen run")
+	search := searchFlags(fs)
+	limit := fs.Int("limit", 20, "the most matching markets to list")
+	scan := fs.Int("scan", 5000, "the most markets to look at, or 0 for no limit")
+	path := libraryFlag(fs)
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 || *limit <= 0 {
+		return errUsage
+	}
+
+	lib, err := openLibrary(*path)
+	if err != nil {
+		return err
+	}
+
+	// a saved screen's search can be narrowed further with flags
+	s, ok := lib.Get(args[0])
+	if !ok {
+		s = screener.Screen{Expr: args[0]}
+	}
+	for _, f := range []struct{ flag, saved *string }{
+		{&search.Term, &s.Search.Term},
+		{&search.Filter, &s.Search.Filter},
+		{&search.Sort, &s.Search.Sort},
+		{&search.ContractType, &s.Search.ContractType},
+		{&search.TopicSlug, &s.Search.TopicSlug},
+		{&search.CreatorId, &s.Search.CreatorId},
+	} {
+		if *f.flag != "" {
+			*f.saved = *f.flag
+		}
+	}
+
+	e, err := s.Compile()
+	if err != nil {
+		return err
+	}
+
+	if a.dryRun {
+		return a.printDryRun("SearchMarkets", s.Search)
+	}
+
+	matches := []mango.FullMarket{}
+	seen := map[string]bool{}
+	scanned := 0
+	err = a.mc().PageSearchMarkets(s.Search, func(page []mango.FullMarket) error {
+		for _, m := range page {
+			scanned++
+			if !seen[m.Id] && e.MatchFull(m) {
+				seen[m.Id] = true
+				matches = append(matches, m)
+			}
+			if len(matches) == *limit || *scan > 0 && scanned >= *scan {
+				return mango.ErrStopPagination
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.print(matches, func() table {
+		t := table{header: []string{"ID", "QUESTION", "TYPE", "PROB", "VOLUME 24H", "CLOSES"}}
+		for _, m := range matches {
+			t.rows = append(t.rows, []string{
+				m.Id,
+				m.Question,
+				string(m.OutcomeType),
+				formatProb(m.Probability),
+				formatMana(m.Volume24Hours),
+				formatTime(m.CloseTime),
+			})
+		}
+		return t
+	})
+}
+
+func screenSave(a *app, args []string) error {
+	fs := a.flagSet("screen save")
+	search := searchFlags(fs)
+	description := fs.String("description", "", "what the screen looks for")
+	path := libraryFlag(fs)
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return errUsage
+	}
+
+	s := screener.Screen{Name: args[0], Description: *description, Expr: args[1], Search: *search}
+	if a.dryRun {
+		return a.printDryRun("SaveScreen", s)
+	}
+
+	lib, err := openLibrary(*path)
+	if err != nil {
+		return err
+	}
+	if err := lib.Save(s); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.stdout, "saved screen %v to %v\n", s.Name, lib.Path)
+	return nil
+}
+
+func screenList(a *app, args []string) error {
+	fs := a.flagSet("screen list")
+	path := libraryFlag(fs)
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	lib, err := openLibrary(*path)
+	if err != nil {
+		return err
+	}
+
+	screens := lib.Screens
+	if screens == nil {
+		screens = []screener.Screen{}
+	}
+	return a.print(screens, func() table {
+		t := table{header: []string{"NAME", "EXPRESSION", "DESCRIPTION"}}
+		for _, s := range screens {
+			t.rows = append(t.rows, []string{s.Name, strings.Join(strings.Fields(s.Expr), " "), s.Description})
+		}
+		return t
+	})
+}
+
+func screenDelete(a *app, args []string) error {
+	fs := a.flagSet("screen delete")
+	path := libraryFlag(fs)
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	lib, err := openLibrary(*path)
+	if err != nil {
+		return err
+	}
+	ok, err := lib.Delete(args[0])
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("no screen named %v", args[0])
+	}
+
+	fmt.Fprintf(a.stdout, "deleted screen %v\n", args[0])
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jonnyspicer/mango/tui"
+	"golang.org/x/term"
+)
+
+func runTUI(a *app, args []string) error {
+	fs := a.flagSet("tui")
+	var cfg tui.Config
+	fs.DurationVar(&cfg.Interval, "interval", 0, "how often to refresh probabilities and positions")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errUsage
+	}
+	cfg.Markets = args
+	cfg.DryRun = a.dryRun
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return fmt.Errorf("tui must be run in a terminal")
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("error configuring terminal: %v", err)
+	}
+	defer term.Restore(fd, state)
+
+	return tui.New(a.mc(), cfg).Run(os.Stdin, a.stdout)
+}
@@ -1,5 +1,12 @@
 package mango
 
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/jonnyspicer/mango/richtext"
+)
+
 // Comment represents a Comment object in the Manifold backend.
 //
 // This type isn't documented by Manifold and its structure was inferred from API calls.
@@ -22,6 +29,10 @@ type Comment struct {
 	BetAmount                float64 `json:"betAmount,omitempty"`
 	BetId                    string  `json:"betId,omitempty"`
 	BetOutcome               string  `json:"betOutcome,omitempty"`
+	BountyAwarded            float64 `json:"bountyAwarded,omitempty"`
+	Likes                    int64   `json:"likes,omitempty"`
+	// Content is the comment as a rich text document.
+	Content *richtext.Node `json:"content,omitempty"`
 }
 
 // PostCommentRequest represents the parameters required to post a
@@ -31,6 +42,24 @@ type PostCommentRequest struct {
 	Content    string `json:"content,omitempty"`
 	Html       string `json:"html,omitempty"`
 	Markdown   string `json:"markdown,omitempty"`
+	// Doc is the comment as a rich text document, built with the richtext package. If set,
+	// it is sent as the content in place of Content.
+	Doc *richtext.Node `json:"-"`
+	// ReplyToCommentId is the ID of the comment this one replies to, if any.
+	ReplyToCommentId string `json:"replyToCommentId,omitempty"`
+}
+
+// MarshalJSON sends [PostCommentRequest.Doc] as the comment's content, if it is set.
+func (pcr PostCommentRequest) MarshalJSON() ([]byte, error) {
+	type request PostCommentRequest
+	if pcr.Doc == nil {
+		return json.Marshal(request(pcr))
+	}
+
+	return json.Marshal(struct {
+		request
+		Content *richtext.Node `json:"content"`
+	}{request(pcr), pcr.Doc})
 }
 
 // GetCommentsRequest represents the optional parameters that can be supplied to
@@ -38,4 +67,68 @@ type PostCommentRequest struct {
 type GetCommentsRequest struct {
 	ContractId   string `json:"contractId,omitempty"`
 	ContractSlug string `json:"contractSlug,omitempty"`
+	UserId       string `json:"userId,omitempty"`
+	Limit        int64  `json:"limit,omitempty"`
+	Page         int64  `json:"page,omitempty"`
+}
+
+// ReactRequest represents the parameters for reacting to a comment or market.
+type ReactRequest struct {
+	ContentId string `json:"contentId"`
+	// ContentType is either "comment" or "contract".
+	ContentType string `json:"contentType"`
+	// ReactionType is either "like", the default, or "dislike".
+	ReactionType string `json:"reactionType,omitempty"`
+	// Remove takes the reaction away instead of adding it.
+	Remove bool `json:"remove,omitempty"`
+}
+
+// CommentThread represents a comment and the replies to it.
+type CommentThread struct {
+	Comment Comment `json:"comment"`
+	// Replies are the comments that reply to this one, oldest first.
+	Replies []*CommentThread `json:"replies,omitempty"`
+}
+
+// Threads arranges comments into threads using [Comment.ReplyToCommentId], with the
+// comments that don't reply to anything at the top, oldest first. Replies to comments
+// that aren't in the slice start threads of their own.
+func Threads(comments []Comment) []*CommentThread {
+	sorted := append([]Comment(nil), comments...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].CreatedTime < sorted[j].CreatedTime })
+
+	// a comment can only reply to one before it, which also stops replies going round in circles
+	var out []*CommentThread
+	threads := make(map[string]*CommentThread, len(sorted))
+	for _, c := range sorted {
+		t := &CommentThread{Comment: c}
+		if parent, ok := threads[c.ReplyToCommentId]; ok {
+			parent.Replies = append(parent.Replies, t)
+		} else {
+			out = append(out, t)
+		}
+		threads[c.Id] = t
+	}
+
+	return out
+}
+
+// Walk calls fn with each comment in the thread, depth first, along with how deeply it is
+// nested: 0 for the comment that starts the thread, 1 for its replies, and so on.
+func (t *CommentThread) Walk(fn func(c Comment, depth int)) {
+	t.walk(fn, 0)
+}
+
+func (t *CommentThread) walk(fn func(c Comment, depth int), depth int) {
+	fn(t.Comment, depth)
+	for _, r := range t.Replies {
+		r.walk(fn, depth+1)
+	}
+}
+
+// Len returns the number of comments in the thread, including the one that starts it.
+func (t *CommentThread) Len() int {
+	n := 0
+	t.Walk(func(Comment, int) { n++ })
+	return n
 }
@@ -0,0 +1,74 @@
+package mango
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jonnyspicer/mango/richtext"
+)
+
+func TestThreads(t *testing.T) {
+	comments := []Comment{
+		{Id: "d", ReplyToCommentId: "b", CreatedTime: 4},
+		{Id: "c", ReplyToCommentId: "a", CreatedTime: 3},
+		{Id: "b", ReplyToCommentId: "a", CreatedTime: 2},
+		{Id: "e", ReplyToCommentId: "gone", CreatedTime: 5},
+		{Id: "a", CreatedTime: 1},
+	}
+
+	threads := Threads(comments)
+	if len(threads) != 2 || threads[0].Comment.Id != "a" || threads[1].Comment.Id != "e" {
+		t.Fatalf("unexpected threads %+v", threads)
+	}
+
+	var walked []string
+	threads[0].Walk(func(c Comment, depth int) {
+		walked = append(walked, c.Id+string(rune('0'+depth)))
+	})
+	if got := fmt.Sprint(walked); got != "[a0 b1 d2 c1]" {
+		t.Errorf("unexpected walk %v", got)
+	}
+	if n := threads[0].Len(); n != 4 {
+		t.Errorf("expected 4 comments in the thread, got %d", n)
+	}
+}
+
+func TestPostCommentDoc(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v0/comment/" {
+			t.Errorf("unexpected path %v", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer server.Close()
+
+	mc := ClientInstance(server.Client(), &server.URL, &testKey)
+	defer mc.Destroy()
+
+	doc := richtext.Doc(richtext.Paragraph(richtext.Text("hello "), richtext.Mention("u1", "alice")))
+	err := mc.ReplyToComment(Comment{Id: "c1", ContractId: "m1"}, PostCommentRequest{Content: "ignored", Doc: &doc})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body["contractId"] != "m1" || body["replyToCommentId"] != "c1" {
+		t.Errorf("unexpected body %v", body)
+	}
+	content, ok := body["content"].(map[string]any)
+	if !ok || content["type"] != "doc" {
+		t.Errorf("expected the document as the content, got %v", body["content"])
+	}
+
+	// without a document, the content is sent as it was
+	b, err := json.Marshal(PostCommentRequest{ContractId: "m1", Content: "plain"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"contractId":"m1","content":"plain"}` {
+		t.Errorf("unexpected request %s", b)
+	}
+}
@@ -9,6 +9,7 @@ import "fmt"
 // [the Manifold API docs for GET /v0/market/marketId/positions]: https://docs.manifold.markets/api#get-v0marketmarketidpositions
 type ContractMetric struct {
 	ContractId    string             `json:"contractId"`
+	AnswerId      string             `json:"answerId,omitempty"`
 	From          map[string]Period  `json:"from,omitempty"`
 	HasNoShares   bool               `json:"hasNoShares"`
 	HasShares     bool               `json:"hasShares"`
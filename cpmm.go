@@ -0,0 +1,187 @@
+package mango
+
+import (
+	"fmt"
+	"math"
+)
+
+// takerFeeConstant is the constant used by Manifold to calculate the fee charged
+// on trades against a CPMM market: fee = 0.07 * p * (1 - p) * shares.
+const takerFeeConstant = 0.07
+
+// creatorFeeFraction is the share of the taker fee paid to the market creator.
+// The remainder is kept by the platform.
+const creatorFeeFraction = 0.5
+
+// BetSimulation represents the predicted result of a market order on a CPMM market.
+//
+// It is calculated locally and ignores any limit orders that would be matched,
+// so it is an estimate of what [Client.PostBet] will return.
+type BetSimulation struct {
+	Outcome    string  `json:"outcome"`
+	Amount     float64 `json:"amount"`
+	Shares     float64 `json:"shares"`
+	Fees       Fees    `json:"fees"`
+	ProbBefore float64 `json:"probBefore"`
+	ProbAfter  float64 `json:"probAfter"`
+	Pool       Pool    `json:"pool"` // the pool after the bet
+}
+
+// CPMMProbability returns the probability of YES implied by a CPMM pool and its P parameter.
+func CPMMProbability(pool Pool, p float64) float64 {
+	y, n := pool["YES"], pool["NO"]
+	return p * n / ((1-p)*y + p*n)
+}
+
+// SimulateBet predicts the result of betting amount on outcome in a CPMM market
+// with the given pool and P parameter, as found in [FullMarket.Pool] and [FullMarket.P].
+//
+// The amount includes fees, so the returned shares are those bought with the
+// amount remaining once fees have been paid.
+func SimulateBet(pool Pool, p float64, outcome string, amount float64) (*BetSimulation, error) {
+	if err := validateCPMM(pool, p, outcome); err != nil {
+		return nil, err
+	}
+	if amount < 0 {
+		return nil, fmt.Errorf("amount must not be negative, got %v", amount)
+	}
+
+	sim := &BetSimulation{
+		Outcome:    outcome,
+		Amount:     amount,
+		ProbBefore: CPMMProbability(pool, p),
+	}
+
+	// the fee depends on the shares bought, which depend on the amount left after
+	// the fee, so iterate until the fee stops changing
+	var fee, shares float64
+	for i := 0; i < 50; i++ {
+		shares = cpmmShares(pool, p, outcome, amount-fee)
+		if shares <= 0 {
+			break
+		}
+
+		avg := (amount - fee) / shares
+		next := math.Min(takerFeeConstant*avg*(1-avg)*shares, amount)
+		if math.Abs(next-fee) < 1e-9 {
+			fee = next
+			break
+		}
+		fee = next
+	}
+
+	sim.Shares = shares
+	sim.Fees = Fees{
+		CreatorFee:  fee * creatorFeeFraction,
+		PlatformFee: fee * (1 - creatorFeeFraction),
+	}
+	sim.Pool = cpmmPoolAfter(pool, outcome, amount-fee, shares)
+	sim.ProbAfter = CPMMProbability(sim.Pool, p)
+
+	return sim, nil
+}
+
+// SimulateSale predicts the result of selling shares of outcome back to a CPMM market with
+// the given pool and P parameter. The returned simulation's Shares are the shares sold and its
+// Amount is the mana received once fees have been paid.
+//
+// Selling is the reverse of buying: the shares go back into the pool, and mana is taken out
+// of both sides of it until y^p * n^(1-p) is back where it started.
+func SimulateSale(pool Pool, p float64, outcome string, shares float64) (*BetSimulation, error) {
+	if err := validateCPMM(pool, p, outcome); err != nil {
+		return nil, err
+	}
+	if shares < 0 {
+		return nil, fmt.Errorf("shares must not be negative, got %v", shares)
+	}
+
+	sim := &BetSimulation{
+		Outcome:    outcome,
+		Shares:     shares,
+		ProbBefore: CPMMProbability(pool, p),
+		Pool:       Pool{"YES": pool["YES"], "NO": pool["NO"]},
+	}
+	sim.ProbAfter = sim.ProbBefore
+	if shares == 0 {
+		return sim, nil
+	}
+
+	// selling NO is selling YES in a mirrored pool
+	y, n, q := pool["YES"], pool["NO"], p
+	if outcome == "NO" {
+		y, n, q = n, y, 1-p
+	}
+	logK := q*math.Log(y) + (1-q)*math.Log(n)
+
+	// the pool's invariant falls as more mana is taken out, so bisect for the amount
+	// that restores it
+	lo, hi := 0.0, math.Min(shares, n)
+	for i := 0; i < 100; i++ {
+		mid := (lo + hi) / 2
+		if q*math.Log(y+shares-mid)+(1-q)*math.Log(n-mid) > logK {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	value := lo
+
+	avg := value / shares
+	fee := math.Min(takerFeeConstant*avg*(1-avg)*shares, value)
+
+	sim.Amount = value - fee
+	sim.Fees = Fees{
+		CreatorFee:  fee * creatorFeeFraction,
+		PlatformFee: fee * (1 - creatorFeeFraction),
+	}
+	if outcome == "YES" {
+		sim.Pool = Pool{"YES": y + shares - value, "NO": n - value}
+	} else {
+		sim.Pool = Pool{"YES": n - value, "NO": y + shares - value}
+	}
+	sim.ProbAfter = CPMMProbability(sim.Pool, p)
+
+	return sim, nil
+}
+
+// cpmmShares returns the number of shares of outcome bought by betting amount,
+// keeping y^p * n^(1-p) constant.
+func cpmmShares(pool Pool, p float64, outcome string, amount float64) float64 {
+	if amount <= 0 {
+		return 0
+	}
+
+	y, n := pool["YES"], pool["NO"]
+	k := math.Pow(y, p) * math.Pow(n, 1-p)
+
+	if outcome == "YES" {
+		return y + amount - math.Pow(k*math.Pow(amount+n, p-1), 1/p)
+	}
+
+	return n + amount - math.Pow(k*math.Pow(amount+y, -p), 1/(1-p))
+}
+
+// cpmmPoolAfter returns the pool after amount has been used to buy shares of outcome.
+func cpmmPoolAfter(pool Pool, outcome string, amount, shares float64) Pool {
+	y, n := pool["YES"], pool["NO"]
+
+	if outcome == "YES" {
+		return Pool{"YES": y + amount - shares, "NO": n + amount}
+	}
+
+	return Pool{"YES": y + amount, "NO": n + amount - shares}
+}
+
+func validateCPMM(pool Pool, p float64, outcome string) error {
+	if pool["YES"] <= 0 || pool["NO"] <= 0 {
+		return fmt.Errorf("pool must have positive YES and NO liquidity, got %v", pool)
+	}
+	if p <= 0 || p >= 1 {
+		return fmt.Errorf("p must be between 0 and 1, got %v", p)
+	}
+	if outcome != "YES" && outcome != "NO" {
+		return fmt.Errorf(`outcome must be "YES" or "NO", got %q`, outcome)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package mango
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCPMMProbability(t *testing.T) {
+	if p := CPMMProbability(Pool{"YES": 100, "NO": 100}, 0.5); p != 0.5 {
+		t.Errorf("expected 0.5 for a balanced pool, got %v", p)
+	}
+	if p := CPMMProbability(Pool{"YES": 50, "NO": 150}, 0.5); math.Abs(p-0.75) > 1e-9 {
+		t.Errorf("expected 0.75, got %v", p)
+	}
+}
+
+func TestSimulateBet(t *testing.T) {
+	pool := Pool{"YES": 100, "NO": 100}
+
+	sim, err := SimulateBet(pool, 0.5, "YES", 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if sim.ProbAfter <= sim.ProbBefore {
+		t.Errorf("expected a YES bet to raise the probability, got %v -> %v", sim.ProbBefore, sim.ProbAfter)
+	}
+
+	fee := sim.Fees.CreatorFee + sim.Fees.PlatformFee + sim.Fees.LiquidityFee
+	if fee <= 0 || fee >= 1 {
+		t.Errorf("expected a small positive fee, got %v", fee)
+	}
+
+	// without fees, M10 into a 100/100 pool at p=0.5 buys 100 + 10 - 100*100/110 shares
+	noFee := 110 - 100*100/110.0
+	if sim.Shares >= noFee || sim.Shares < noFee-1 {
+		t.Errorf("expected slightly fewer than %v shares, got %v", noFee, sim.Shares)
+	}
+
+	// the invariant y^p * n^(1-p) is preserved
+	k := math.Sqrt(pool["YES"] * pool["NO"])
+	k2 := math.Sqrt(sim.Pool["YES"] * sim.Pool["NO"])
+	if math.Abs(k-k2) > 1e-9 {
+		t.Errorf("expected constant product %v, got %v", k, k2)
+	}
+
+	no, err := SimulateBet(pool, 0.5, "NO", 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if math.Abs(no.Shares-sim.Shares) > 1e-9 || math.Abs((1-no.ProbAfter)-sim.ProbAfter) > 1e-9 {
+		t.Errorf("expected NO bet to mirror YES bet, got %+v and %+v", no, sim)
+	}
+}
+
+func TestSimulateBetInvalid(t *testing.T) {
+	if _, err := SimulateBet(Pool{"YES": 100}, 0.5, "YES", 10); err == nil {
+		t.Error("expected an error for a pool without NO liquidity")
+	}
+	if _, err := SimulateBet(Pool{"YES": 100, "NO": 100}, 0, "YES", 10); err == nil {
+		t.Error("expected an error for p of 0")
+	}
+	if _, err := SimulateBet(Pool{"YES": 100, "NO": 100}, 0.5, "MAYBE", 10); err == nil {
+		t.Error("expected an error for an invalid outcome")
+	}
+}
+
+func TestSimulateSale(t *testing.T) {
+	pool := Pool{"YES": 100, "NO": 100}
+
+	for _, outcome := range []string{"YES", "NO"} {
+		bet, err := SimulateBet(pool, 0.5, outcome, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sale, err := SimulateSale(bet.Pool, 0.5, outcome, bet.Shares)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// selling straight back undoes the bet, less fees both ways
+		if sale.Amount >= 10 || sale.Amount < 9 {
+			t.Errorf("%v: expected to get back a little under M10, got %v", outcome, sale.Amount)
+		}
+		if math.Abs(sale.ProbAfter-0.5) > 0.01 || math.Abs(sale.ProbBefore-bet.ProbAfter) > 1e-9 {
+			t.Errorf("%v: expected the probability to return to about 0.5, got %v", outcome, sale.ProbAfter)
+		}
+	}
+
+	if _, err := SimulateSale(pool, 0.5, "YES", -1); err == nil {
+		t.Error("expected an error for negative shares")
+	}
+}
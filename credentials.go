@@ -0,0 +1,319 @@
+package mango
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// ErrNoCredentials is returned by a [CredentialProvider] that has no API key to offer.
+var ErrNoCredentials = errors.New("no API key found")
+
+const apiKeyVar = "MANIFOLD_API_KEY"
+
+// CredentialProvider supplies the API key used to authenticate requests.
+//
+// APIKey is called before every authenticated request rather than once when the
+// client is created, so a provider can return a new key after it is rotated.
+// Implementations must be safe for concurrent use.
+type CredentialProvider interface {
+	APIKey() (string, error)
+}
+
+// WithCredentials sets the [CredentialProvider] the client uses to authenticate requests.
+//
+// It takes precedence over an API key passed directly to [ClientInstance] or [NewClient].
+func WithCredentials(p CredentialProvider) ClientOption {
+	return func(mc *Client) {
+		mc.creds = p
+	}
+}
+
+// StaticCredentials is a [CredentialProvider] that always returns the same key.
+type StaticCredentials string
+
+// APIKey returns the key, or [ErrNoCredentials] if it is empty.
+func (s StaticCredentials) APIKey() (string, error) {
+	if s == "" {
+		return "", ErrNoCredentials
+	}
+	return string(s), nil
+}
+
+// EnvCredentials is a [CredentialProvider] that reads the key from an environment variable.
+//
+// If Var is empty then `MANIFOLD_API_KEY` is used.
+type EnvCredentials struct {
+	Var string
+}
+
+// APIKey returns the value of the environment variable.
+func (e EnvCredentials) APIKey() (string, error) {
+	name := e.Var
+	if name == "" {
+		name = apiKeyVar
+	}
+
+	if k := os.Getenv(name); k != "" {
+		return k, nil
+	}
+
+	return "", fmt.Errorf("%w in environment variable %v", ErrNoCredentials, name)
+}
+
+// DotEnvCredentials is a [CredentialProvider] that reads `MANIFOLD_API_KEY` from a .env file.
+//
+// If Path is empty then the .env file in the current working directory is used.
+type DotEnvCredentials struct {
+	Path string
+}
+
+// APIKey reads the .env file and returns the key it contains.
+func (d DotEnvCredentials) APIKey() (string, error) {
+	path := d.Path
+	if path == "" {
+		path = ".env"
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("env")
+
+	if err := v.ReadInConfig(); err != nil {
+		return "", fmt.Errorf("error reading config file: %w", err)
+	}
+
+	if k := v.GetString(apiKeyVar); k != "" {
+		return k, nil
+	}
+
+	return "", fmt.Errorf("%w in %v", ErrNoCredentials, path)
+}
+
+// FileCredentials is a [CredentialProvider] that reads the key from a file containing nothing but the key.
+//
+// Surrounding whitespace is ignored. This suits secrets mounted as files, such as Docker or Kubernetes secrets.
+type FileCredentials struct {
+	Path string
+}
+
+// APIKey reads the file and returns its contents.
+func (f FileCredentials) APIKey() (string, error) {
+	b, err := os.ReadFile(f.Path)
+	if err != nil {
+		return "", fmt.Errorf("error reading credentials file: %w", err)
+	}
+
+	if k := strings.TrimSpace(string(b)); k != "" {
+		return k, nil
+	}
+
+	return "", fmt.Errorf("%w in %v", ErrNoCredentials, f.Path)
+}
+
+// ProfileCredentials is a [CredentialProvider] that reads the key for a named profile
+// from a shared credentials file, in the style of `~/.aws/credentials`:
+//
+//	[default]
+//	api_key = ...
+//
+//	[my-bot]
+//	api_key = ...
+//
+// If Path is empty then `credentials` in the mango directory of [os.UserConfigDir] is used,
+// and if Profile is empty then "default" is used. Like ssh keys, the file must not be
+// readable by other users.
+type ProfileCredentials struct {
+	Path    string
+	Profile string
+}
+
+// DefaultCredentialsPath returns the path of the shared credentials file used by [ProfileCredentials].
+func DefaultCredentialsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "mango", "credentials"), nil
+}
+
+// APIKey reads the credentials file and returns the key for the profile.
+func (p ProfileCredentials) APIKey() (string, error) {
+	path := p.Path
+	if path == "" {
+		var err error
+		if path, err = DefaultCredentialsPath(); err != nil {
+			return "", fmt.Errorf("error finding credentials file: %w", err)
+		}
+	}
+
+	profile := p.Profile
+	if profile == "" {
+		profile = "default"
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading credentials file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("error reading credentials file: %w", err)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		return "", fmt.Errorf("credentials file %v is accessible by other users, its permissions should be 0600", path)
+	}
+
+	var section string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || section != profile || strings.TrimSpace(k) != "api_key" {
+			continue
+		}
+
+		if v = strings.Trim(strings.TrimSpace(v), `"'`); v != "" {
+			return v, nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", fmt.Errorf("error reading credentials file: %w", err)
+	}
+
+	return "", fmt.Errorf("%w for profile %v in %v", ErrNoCredentials, profile, path)
+}
+
+// CommandCredentials is a [CredentialProvider] that runs an external command and uses its output
+// as the key, in the style of git credential helpers. This allows keys to be fetched from a
+// password manager or secrets store, for example:
+//
+//	mango.CommandCredentials{Command: "op", Args: []string{"read", "op://bots/manifold/api-key"}}
+//
+// The command is run every time a key is needed, so it is usually wrapped in [NewCachedCredentials].
+// If Timeout is zero the command is given 10 seconds to complete.
+type CommandCredentials struct {
+	Command string
+	Args    []string
+	Timeout time.Duration
+}
+
+// APIKey runs the command and returns its trimmed standard output.
+func (c CommandCredentials) APIKey() (string, error) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stderr strings.Builder
+	cmd := exec.CommandContext(ctx, c.Command, c.Args...)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("error running credential command %v: %w: %v", c.Command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	if k := strings.TrimSpace(string(out)); k != "" {
+		return k, nil
+	}
+
+	return "", fmt.Errorf("%w in output of %v", ErrNoCredentials, c.Command)
+}
+
+// ChainCredentials is a [CredentialProvider] that tries each provider in turn
+// and returns the first key found.
+type ChainCredentials []CredentialProvider
+
+// APIKey returns the first key found, or an error describing why each provider failed.
+func (c ChainCredentials) APIKey() (string, error) {
+	errs := []error{ErrNoCredentials}
+
+	for _, p := range c {
+		k, err := p.APIKey()
+		if err == nil && k != "" {
+			return k, nil
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return "", errors.Join(errs...)
+}
+
+// CachedCredentials wraps a [CredentialProvider] and remembers the key it returns for a
+// fixed period, so that slow providers such as [CommandCredentials] are not called for every request.
+type CachedCredentials struct {
+	provider CredentialProvider
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	key     string
+	expires time.Time
+}
+
+// NewCachedCredentials returns a [CachedCredentials] that fetches a fresh key from p once ttl has elapsed.
+func NewCachedCredentials(p CredentialProvider, ttl time.Duration) *CachedCredentials {
+	return &CachedCredentials{provider: p, ttl: ttl}
+}
+
+// APIKey returns the cached key, fetching a new one if it has expired.
+// Errors are not cached.
+func (c *CachedCredentials) APIKey() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.key != "" && time.Now().Before(c.expires) {
+		return c.key, nil
+	}
+
+	k, err := c.provider.APIKey()
+	if err != nil {
+		return "", err
+	}
+
+	c.key = k
+	c.expires = time.Now().Add(c.ttl)
+
+	return k, nil
+}
+
+// Invalidate discards the cached key, so that the next request fetches a fresh one.
+// Call it after rotating a key.
+func (c *CachedCredentials) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.key = ""
+}
+
+// defaultCredentials reads the key from the .env file in the current working directory,
+// falling back to the `MANIFOLD_API_KEY` environment variable.
+func defaultCredentials() CredentialProvider {
+	return NewCachedCredentials(ChainCredentials{DotEnvCredentials{}, EnvCredentials{}}, time.Minute)
+}
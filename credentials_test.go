@@ -0,0 +1,170 @@
+package mango
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEnvCredentials(t *testing.T) {
+	t.Setenv("MANGO_TEST_KEY", "env-key")
+
+	k, err := EnvCredentials{Var: "MANGO_TEST_KEY"}.APIKey()
+	if err != nil || k != "env-key" {
+		t.Errorf("expected env-key, got %q, %v", k, err)
+	}
+
+	if _, err := (EnvCredentials{Var: "MANGO_TEST_UNSET"}).APIKey(); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("expected ErrNoCredentials, got %v", err)
+	}
+}
+
+func TestFileCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte("  file-key\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := FileCredentials{Path: path}.APIKey()
+	if err != nil || k != "file-key" {
+		t.Errorf("expected file-key, got %q, %v", k, err)
+	}
+}
+
+func TestDotEnvCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte(`MANIFOLD_API_KEY = "dotenv-key"`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := DotEnvCredentials{Path: path}.APIKey()
+	if err != nil || k != "dotenv-key" {
+		t.Errorf("expected dotenv-key, got %q, %v", k, err)
+	}
+}
+
+func TestProfileCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	contents := `# mango credentials
+[default]
+api_key = default-key
+
+[bot]
+api_key = "bot-key"
+`
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := ProfileCredentials{Path: path}.APIKey()
+	if err != nil || k != "default-key" {
+		t.Errorf("expected default-key, got %q, %v", k, err)
+	}
+
+	k, err = ProfileCredentials{Path: path, Profile: "bot"}.APIKey()
+	if err != nil || k != "bot-key" {
+		t.Errorf("expected bot-key, got %q, %v", k, err)
+	}
+
+	if _, err := (ProfileCredentials{Path: path, Profile: "missing"}).APIKey(); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("expected ErrNoCredentials for missing profile, got %v", err)
+	}
+
+	if err := os.Chmod(path, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (ProfileCredentials{Path: path}).APIKey(); err == nil {
+		t.Error("expected an error for a world-readable credentials file")
+	}
+}
+
+func TestCommandCredentials(t *testing.T) {
+	k, err := CommandCredentials{Command: "echo", Args: []string{"command-key"}}.APIKey()
+	if err != nil || k != "command-key" {
+		t.Errorf("expected command-key, got %q, %v", k, err)
+	}
+
+	if _, err := (CommandCredentials{Command: "false"}).APIKey(); err == nil {
+		t.Error("expected an error from a failing command")
+	}
+}
+
+func TestChainCredentials(t *testing.T) {
+	chain := ChainCredentials{StaticCredentials(""), StaticCredentials("second")}
+
+	k, err := chain.APIKey()
+	if err != nil || k != "second" {
+		t.Errorf("expected second, got %q, %v", k, err)
+	}
+
+	if _, err := (ChainCredentials{}).APIKey(); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("expected ErrNoCredentials, got %v", err)
+	}
+}
+
+type rotatingCredentials struct {
+	keys []string
+	i    int
+}
+
+func (r *rotatingCredentials) APIKey() (string, error) {
+	k := r.keys[r.i]
+	if r.i < len(r.keys)-1 {
+		r.i++
+	}
+	return k, nil
+}
+
+func TestCachedCredentialsRotation(t *testing.T) {
+	var seen []string
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusOK)
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	creds := NewCachedCredentials(&rotatingCredentials{keys: []string{"old", "new"}}, time.Hour)
+
+	mc := NewClient(server.Client(), &server.URL, &testKey, WithCredentials(creds))
+
+	for i := 0; i < 2; i++ {
+		if err := mc.CancelBet("bet1"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	creds.Invalidate()
+
+	if err := mc.CancelBet("bet1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"Key old", "Key old", "Key new"}
+	for i := range expected {
+		if seen[i] != expected[i] {
+			t.Errorf("request %d: expected %q, got %q", i, expected[i], seen[i])
+		}
+	}
+}
+
+func TestNewClientIsNotSingleton(t *testing.T) {
+	a, b := "a", "b"
+	c1 := NewClient(nil, nil, &a)
+	c2 := NewClient(nil, nil, &b)
+
+	if c1 == c2 {
+		t.Fatal("expected NewClient to return distinct clients")
+	}
+
+	k, _ := c2.creds.APIKey()
+	if k != "b" {
+		t.Errorf("expected second client to use key b, got %q", k)
+	}
+}
@@ -0,0 +1,254 @@
+// Package export streams Manifold data into files for analysis in tools like pandas and DuckDB.
+//
+// Records are fetched a page at a time using the client's paging methods, flattened into
+// row types with fixed columns, and written as CSV, NDJSON or Parquet:
+//
+//	f, _ := os.Create("bets.parquet")
+//	defer f.Close()
+//
+//	w, _ := export.NewWriter[export.BetRow](f, export.Parquet)
+//	n, err := export.Bets(mc, export.Filter{UserId: "abc123"}, w)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	w.Close()
+package export
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+)
+
+// Filter limits which records are exported. Empty fields don't filter.
+type Filter struct {
+	UserId   string    // the bettor, creator, commenter, sender or recipient, or holder of the position
+	MarketId string    // the market
+	From     time.Time // only records created at or after this time
+	To       time.Time // only records created before this time
+}
+
+func (f Filter) inRange(ms int64) bool {
+	t := time.UnixMilli(ms)
+	return (f.From.IsZero() || !t.Before(f.From)) && (f.To.IsZero() || t.Before(f.To))
+}
+
+// beforeRange reports whether a record is older than the filter's range. Most endpoints return
+// records newest first, so once one of these is seen there is no need to fetch more pages.
+func (f Filter) beforeRange(ms int64) bool {
+	return !f.From.IsZero() && time.UnixMilli(ms).Before(f.From)
+}
+
+// Bets writes the bets matching the filter, newest first, and returns how many were written.
+func Bets(mc *mango.Client, f Filter, w Writer[BetRow]) (int, error) {
+	n := 0
+
+	err := mc.PageBets(mango.GetBetsRequest{UserId: f.UserId, ContractId: f.MarketId}, func(page []mango.Bet) error {
+		rows := make([]BetRow, 0, len(page))
+		stop := false
+		for _, b := range page {
+			if f.beforeRange(b.CreatedTime) {
+				stop = true
+				break
+			}
+			if f.inRange(b.CreatedTime) {
+				rows = append(rows, NewBetRow(b))
+			}
+		}
+
+		if err := w.Write(rows...); err != nil {
+			return err
+		}
+		n += len(rows)
+
+		if stop {
+			return mango.ErrStopPagination
+		}
+		return nil
+	})
+	if err != nil {
+		return n, fmt.Errorf("error exporting bets: %v", err)
+	}
+
+	return n, nil
+}
+
+// Markets writes the markets matching the filter, newest first, and returns how many were
+// written. The user filter matches the market's creator. If a market ID is given, only that
+// market is written.
+func Markets(mc *mango.Client, f Filter, w Writer[MarketRow]) (int, error) {
+	if f.MarketId != "" {
+		m, err := mc.GetMarketByID(f.MarketId)
+		if err != nil {
+			return 0, fmt.Errorf("error exporting markets: %v", err)
+		}
+		if (f.UserId != "" && m.CreatorId != f.UserId) || !f.inRange(m.CreatedTime) {
+			return 0, nil
+		}
+		return 1, w.Write(NewFullMarketRow(*m))
+	}
+
+	n := 0
+
+	err := mc.PageMarkets(mango.GetMarketsRequest{}, func(page []mango.LiteMarket) error {
+		rows := make([]MarketRow, 0, len(page))
+		stop := false
+		for _, m := range page {
+			if f.beforeRange(m.CreatedTime) {
+				stop = true
+				break
+			}
+			if f.inRange(m.CreatedTime) && (f.UserId == "" || m.CreatorId == f.UserId) {
+				rows = append(rows, NewMarketRow(m))
+			}
+		}
+
+		if err := w.Write(rows...); err != nil {
+			return err
+		}
+		n += len(rows)
+
+		if stop {
+			return mango.ErrStopPagination
+		}
+		return nil
+	})
+	if err != nil {
+		return n, fmt.Errorf("error exporting markets: %v", err)
+	}
+
+	return n, nil
+}
+
+// Comments writes the comments on a market matching the filter and returns how many were
+// written. The filter must have a market ID.
+func Comments(mc *mango.Client, f Filter, w Writer[CommentRow]) (int, error) {
+	if f.MarketId == "" {
+		return 0, fmt.Errorf("exporting comments requires a market")
+	}
+
+	comments, err := mc.GetComments(mango.GetCommentsRequest{ContractId: f.MarketId})
+	if err != nil {
+		return 0, fmt.Errorf("error exporting comments: %v", err)
+	}
+
+	rows := make([]CommentRow, 0, len(*comments))
+	for _, c := range *comments {
+		if f.inRange(c.CreatedTime) && (f.UserId == "" || c.UserId == f.UserId) {
+			rows = append(rows, NewCommentRow(c))
+		}
+	}
+
+	return len(rows), w.Write(rows...)
+}
+
+// Transactions writes the transactions matching the filter and returns how many were written.
+// The user filter matches transactions sent or received by the user, and the market filter
+// matches transactions to or from the market.
+func Transactions(mc *mango.Client, f Filter, w Writer[TxnRow]) (int, error) {
+	base := mango.GetTransactionsRequest{}
+	if !f.From.IsZero() {
+		base.After = f.From.UnixMilli() - 1
+	}
+	if !f.To.IsZero() {
+		base.Before = f.To.UnixMilli()
+	}
+
+	// a transaction can only be filtered on one end at a time, so fetch each end
+	// the filter is interested in and drop the duplicates
+	var reqs []mango.GetTransactionsRequest
+	for _, id := range []string{f.UserId, f.MarketId} {
+		if id == "" {
+			continue
+		}
+		from, to := base, base
+		from.FromId, to.ToId = id, id
+		reqs = append(reqs, from, to)
+	}
+	if len(reqs) == 0 {
+		reqs = append(reqs, base)
+	}
+
+	n := 0
+	seen := map[string]bool{}
+
+	for _, req := range reqs {
+		err := mc.PageTransactions(req, func(page []mango.Txn) error {
+			rows := make([]TxnRow, 0, len(page))
+			for _, t := range page {
+				if seen[t.Id] || !f.inRange(t.CreatedTime) || !txnMatches(t, f) {
+					continue
+				}
+				seen[t.Id] = true
+				rows = append(rows, NewTxnRow(t))
+			}
+
+			n += len(rows)
+			return w.Write(rows...)
+		})
+		if err != nil {
+			return n, fmt.Errorf("error exporting transactions: %v", err)
+		}
+	}
+
+	return n, nil
+}
+
+func txnMatches(t mango.Txn, f Filter) bool {
+	involves := func(id string) bool {
+		return id == "" || t.FromId == id || t.ToId == id
+	}
+	return involves(f.UserId) && involves(f.MarketId)
+}
+
+// ContractMetrics writes positions and returns how many were written. With a user,
+// it writes the user's positions, optionally limited to one market. With only a
+// market, it writes every position in that market. The time filter matches the
+// position's last bet.
+func ContractMetrics(mc *mango.Client, f Filter, w Writer[ContractMetricRow]) (int, error) {
+	n := 0
+
+	write := func(cms []mango.ContractMetric) error {
+		rows := make([]ContractMetricRow, 0, len(cms))
+		for _, cm := range cms {
+			if f.inRange(cm.LastBetTime) && (f.MarketId == "" || cm.ContractId == f.MarketId) {
+				// metrics fetched for a user don't always say whose they are
+				if cm.UserId == "" {
+					cm.UserId = f.UserId
+				}
+				rows = append(rows, NewContractMetricRow(cm))
+			}
+		}
+
+		n += len(rows)
+		return w.Write(rows...)
+	}
+
+	if f.UserId == "" {
+		if f.MarketId == "" {
+			return 0, fmt.Errorf("exporting positions requires a user or a market")
+		}
+
+		cms, err := mc.GetMarketPositions(mango.GetMarketPositionsRequest{MarketId: f.MarketId})
+		if err != nil {
+			return 0, fmt.Errorf("error exporting positions: %v", err)
+		}
+		return n, write(*cms)
+	}
+
+	err := mc.PageUserContractMetrics(mango.GetUserContractMetricsRequest{UserId: f.UserId}, func(page mango.UserContractMetricsResponse) error {
+		// keep the order of the contracts, which the map of metrics loses
+		for _, c := range page.Contracts {
+			if err := write(page.MetricsByContract[c.Id]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return n, fmt.Errorf("error exporting positions: %v", err)
+	}
+
+	return n, nil
+}
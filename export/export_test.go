@@ -0,0 +1,163 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+	"github.com/jonnyspicer/mango/mangotest"
+)
+
+// memWriter collects rows in memory.
+type memWriter[T any] struct {
+	rows []T
+}
+
+func (m *memWriter[T]) Write(rows ...T) error {
+	m.rows = append(m.rows, rows...)
+	return nil
+}
+
+func (m *memWriter[T]) Close() error {
+	return nil
+}
+
+func TestBets(t *testing.T) {
+	s := mangotest.NewServer()
+	defer s.Close()
+
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		s.AddBet(mango.Bet{ContractId: "m1", UserId: "u1", Amount: float64(i), CreatedTime: base.AddDate(0, 0, i).UnixMilli()})
+	}
+	s.AddBet(mango.Bet{ContractId: "m1", UserId: "u2", CreatedTime: base.UnixMilli()})
+
+	w := &memWriter[BetRow]{}
+	n, err := Bets(s.Client(), Filter{UserId: "u1", From: base.AddDate(0, 0, 1), To: base.AddDate(0, 0, 4)}, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 3 || len(w.rows) != 3 {
+		t.Fatalf("expected 3 bets, got %d", n)
+	}
+	for i, want := range []float64{3, 2, 1} {
+		if w.rows[i].Amount != want {
+			t.Errorf("row %d: expected amount %v, got %v", i, want, w.rows[i].Amount)
+		}
+	}
+}
+
+func TestMarkets(t *testing.T) {
+	s := mangotest.NewServer()
+	defer s.Close()
+
+	s.AddMarket(mango.FullMarket{Id: "m1", CreatorId: "u1", Question: "One?", Pool: mango.Pool{"YES": 50, "NO": 150}, P: 0.5})
+	s.AddMarket(mango.FullMarket{Id: "m2", CreatorId: "u2", Question: "Two?"})
+	s.AddMarket(mango.FullMarket{Id: "m3", CreatorId: "u1", Question: "Three?"})
+
+	var b bytes.Buffer
+	w, err := NewWriter[MarketRow](&b, CSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := Markets(s.Client(), Filter{UserId: "u1"}, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	recs, _ := csv.NewReader(&b).ReadAll()
+	if n != 2 || len(recs) != 3 || recs[1][0] != "m3" || recs[2][0] != "m1" {
+		t.Errorf("unexpected export of %d markets: %v", n, recs)
+	}
+
+	one := &memWriter[MarketRow]{}
+	if n, err := Markets(s.Client(), Filter{MarketId: "m1"}, one); err != nil || n != 1 {
+		t.Fatalf("expected one market, got %d, %v", n, err)
+	}
+	if one.rows[0].PoolNo != 150 || one.rows[0].Probability != 0.75 {
+		t.Errorf("unexpected row %+v", one.rows[0])
+	}
+}
+
+func TestComments(t *testing.T) {
+	s := mangotest.NewServer()
+	defer s.Close()
+
+	s.AddComment(mango.Comment{ContractId: "m1", UserId: "u1", Text: "first"})
+	s.AddComment(mango.Comment{ContractId: "m1", UserId: "u2", Text: "second"})
+	s.AddComment(mango.Comment{ContractId: "m2", UserId: "u1", Text: "elsewhere"})
+
+	w := &memWriter[CommentRow]{}
+	n, err := Comments(s.Client(), Filter{MarketId: "m1", UserId: "u1"}, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || w.rows[0].Text != "first" {
+		t.Errorf("unexpected comments %+v", w.rows)
+	}
+
+	if _, err := Comments(s.Client(), Filter{}, w); err == nil {
+		t.Error("expected an error without a market")
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	s := mangotest.NewServer()
+	defer s.Close()
+
+	s.AddTxn(mango.Txn{Id: "t1", FromId: "u1", ToId: "u2", Amount: 10})
+	s.AddTxn(mango.Txn{Id: "t2", FromId: "u2", ToId: "u1", Amount: 20})
+	s.AddTxn(mango.Txn{Id: "t3", FromId: "u2", ToId: "u3", Amount: 30})
+	s.AddTxn(mango.Txn{Id: "t4", FromId: "u1", ToId: "u1", Amount: 40})
+
+	w := &memWriter[TxnRow]{}
+	n, err := Transactions(s.Client(), Filter{UserId: "u1"}, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 3 {
+		t.Fatalf("expected 3 transactions, got %d: %+v", n, w.rows)
+	}
+	seen := map[string]bool{}
+	for _, r := range w.rows {
+		seen[r.Id] = true
+	}
+	if !seen["t1"] || !seen["t2"] || !seen["t4"] {
+		t.Errorf("unexpected transactions %+v", w.rows)
+	}
+}
+
+func TestContractMetrics(t *testing.T) {
+	s := mangotest.NewServer()
+	defer s.Close()
+
+	s.AddMarket(mango.FullMarket{Id: "m1", Question: "One?", Pool: mango.Pool{"YES": 100, "NO": 100}, P: 0.5})
+	s.AddMarket(mango.FullMarket{Id: "m2", Question: "Two?", Pool: mango.Pool{"YES": 100, "NO": 100}, P: 0.5})
+
+	mc := s.Client()
+	for _, id := range []string{"m1", "m2"} {
+		if _, err := mc.PostBet(mango.PostBetRequest{ContractId: id, Outcome: "NO", Amount: 10}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := &memWriter[ContractMetricRow]{}
+	n, err := ContractMetrics(mc, Filter{UserId: s.Me().Id}, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || w.rows[0].SharesNo <= 0 || w.rows[0].UserId != s.Me().Id {
+		t.Errorf("unexpected positions %+v", w.rows)
+	}
+
+	w = &memWriter[ContractMetricRow]{}
+	if n, err := ContractMetrics(mc, Filter{MarketId: "m2"}, w); err != nil || n != 1 || w.rows[0].ContractId != "m2" {
+		t.Errorf("unexpected positions %+v, %v", w.rows, err)
+	}
+}
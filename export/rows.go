@@ -0,0 +1,301 @@
+package export
+
+import (
+	"encoding/json"
+
+	"github.com/jonnyspicer/mango"
+)
+
+// The row types below are flat versions of the API types with a fixed set of columns, so that
+// exports can be loaded into tools like pandas and DuckDB and compared across runs. Times are
+// epoch milliseconds, stored as timestamps in Parquet. Column names come from the parquet tags.
+
+// BetRow is a flattened [mango.Bet]. Fees are split into columns and fills are summarised.
+type BetRow struct {
+	Id              string  `json:"id" parquet:"id"`
+	ContractId      string  `json:"contract_id" parquet:"contract_id"`
+	UserId          string  `json:"user_id" parquet:"user_id"`
+	UserUsername    string  `json:"user_username" parquet:"user_username"`
+	CreatedTime     int64   `json:"created_time" parquet:"created_time,timestamp(millisecond)"`
+	Outcome         string  `json:"outcome" parquet:"outcome"`
+	Amount          float64 `json:"amount" parquet:"amount"`
+	Shares          float64 `json:"shares" parquet:"shares"`
+	ProbBefore      float64 `json:"prob_before" parquet:"prob_before"`
+	ProbAfter       float64 `json:"prob_after" parquet:"prob_after"`
+	LimitProb       float64 `json:"limit_prob" parquet:"limit_prob"`
+	OrderAmount     float64 `json:"order_amount" parquet:"order_amount"`
+	IsFilled        bool    `json:"is_filled" parquet:"is_filled"`
+	IsCancelled     bool    `json:"is_cancelled" parquet:"is_cancelled"`
+	IsRedemption    bool    `json:"is_redemption" parquet:"is_redemption"`
+	IsAnte          bool    `json:"is_ante" parquet:"is_ante"`
+	LoanAmount      float64 `json:"loan_amount" parquet:"loan_amount"`
+	FeeLiquidity    float64 `json:"fee_liquidity" parquet:"fee_liquidity"`
+	FeePlatform     float64 `json:"fee_platform" parquet:"fee_platform"`
+	FeeCreator      float64 `json:"fee_creator" parquet:"fee_creator"`
+	FillCount       int64   `json:"fill_count" parquet:"fill_count"`
+	FilledAmount    float64 `json:"filled_amount" parquet:"filled_amount"`
+	FilledShares    float64 `json:"filled_shares" parquet:"filled_shares"`
+	LastFillTime    int64   `json:"last_fill_time" parquet:"last_fill_time,timestamp(millisecond)"`
+	HasMatchedFills bool    `json:"has_matched_fills" parquet:"has_matched_fills"`
+}
+
+// NewBetRow flattens a bet.
+func NewBetRow(b mango.Bet) BetRow {
+	id := b.Id
+	if id == "" {
+		id = b.BetId
+	}
+
+	r := BetRow{
+		Id:           id,
+		ContractId:   b.ContractId,
+		UserId:       b.UserId,
+		UserUsername: b.UserUsername,
+		CreatedTime:  b.CreatedTime,
+		Outcome:      b.Outcome,
+		Amount:       b.Amount,
+		Shares:       b.Shares,
+		ProbBefore:   b.ProbBefore,
+		ProbAfter:    b.ProbAfter,
+		LimitProb:    b.LimitProb,
+		OrderAmount:  b.OrderAmount,
+		IsFilled:     b.IsFilled,
+		IsCancelled:  b.IsCancelled,
+		IsRedemption: b.IsRedemption,
+		IsAnte:       b.IsAnte,
+		LoanAmount:   b.LoanAmount,
+		FeeLiquidity: b.Fees.LiquidityFee,
+		FeePlatform:  b.Fees.PlatformFee,
+		FeeCreator:   b.Fees.CreatorFee,
+		FillCount:    int64(len(b.Fills)),
+	}
+
+	for _, f := range b.Fills {
+		r.FilledAmount += f.Amount
+		r.FilledShares += f.Shares
+		if f.Timestamp > r.LastFillTime {
+			r.LastFillTime = f.Timestamp
+		}
+		if f.MatchedBetId != nil {
+			r.HasMatchedFills = true
+		}
+	}
+
+	return r
+}
+
+// MarketRow is a flattened [mango.LiteMarket] or [mango.FullMarket]. The YES and NO
+// pools have their own columns, and the whole pool is also kept as a JSON object.
+type MarketRow struct {
+	Id                    string  `json:"id" parquet:"id"`
+	Question              string  `json:"question" parquet:"question"`
+	Url                   string  `json:"url" parquet:"url"`
+	CreatorId             string  `json:"creator_id" parquet:"creator_id"`
+	CreatorUsername       string  `json:"creator_username" parquet:"creator_username"`
+	CreatedTime           int64   `json:"created_time" parquet:"created_time,timestamp(millisecond)"`
+	CloseTime             int64   `json:"close_time" parquet:"close_time,timestamp(millisecond)"`
+	OutcomeType           string  `json:"outcome_type" parquet:"outcome_type"`
+	Mechanism             string  `json:"mechanism" parquet:"mechanism"`
+	Probability           float64 `json:"probability" parquet:"probability"`
+	P                     float64 `json:"p" parquet:"p"`
+	PoolYes               float64 `json:"pool_yes" parquet:"pool_yes"`
+	PoolNo                float64 `json:"pool_no" parquet:"pool_no"`
+	Pool                  string  `json:"pool" parquet:"pool"`
+	TotalLiquidity        float64 `json:"total_liquidity" parquet:"total_liquidity"`
+	Volume                float64 `json:"volume" parquet:"volume"`
+	Volume24Hours         float64 `json:"volume_24_hours" parquet:"volume_24_hours"`
+	IsResolved            bool    `json:"is_resolved" parquet:"is_resolved"`
+	Resolution            string  `json:"resolution" parquet:"resolution"`
+	ResolutionTime        int64   `json:"resolution_time" parquet:"resolution_time,timestamp(millisecond)"`
+	ResolutionProbability float64 `json:"resolution_probability" parquet:"resolution_probability"`
+	LastUpdatedTime       int64   `json:"last_updated_time" parquet:"last_updated_time,timestamp(millisecond)"`
+	AnswerCount           int64   `json:"answer_count" parquet:"answer_count"`
+}
+
+// NewMarketRow flattens a lite market.
+func NewMarketRow(m mango.LiteMarket) MarketRow {
+	return MarketRow{
+		Id:                    m.Id,
+		Question:              m.Question,
+		Url:                   m.Url,
+		CreatorId:             m.CreatorId,
+		CreatorUsername:       m.CreatorUsername,
+		CreatedTime:           m.CreatedTime,
+		CloseTime:             m.CloseTime,
+		OutcomeType:           string(m.OutcomeType),
+		Mechanism:             m.Mechanism,
+		Probability:           m.Probability,
+		P:                     m.P,
+		PoolYes:               m.Pool["YES"],
+		PoolNo:                m.Pool["NO"],
+		Pool:                  poolJSON(m.Pool),
+		TotalLiquidity:        m.TotalLiquidity,
+		Volume:                m.Volume,
+		Volume24Hours:         m.Volume24Hours,
+		IsResolved:            m.IsResolved,
+		Resolution:            m.Resolution,
+		ResolutionTime:        m.ResolutionTime,
+		ResolutionProbability: m.ResolutionProbability,
+		LastUpdatedTime:       m.LastUpdatedTime,
+	}
+}
+
+// NewFullMarketRow flattens a full market.
+func NewFullMarketRow(m mango.FullMarket) MarketRow {
+	return MarketRow{
+		Id:                    m.Id,
+		Question:              m.Question,
+		Url:                   m.Url,
+		CreatorId:             m.CreatorId,
+		CreatorUsername:       m.CreatorUsername,
+		CreatedTime:           m.CreatedTime,
+		CloseTime:             m.CloseTime,
+		OutcomeType:           string(m.OutcomeType),
+		Mechanism:             m.Mechanism,
+		Probability:           m.Probability,
+		P:                     m.P,
+		PoolYes:               m.Pool["YES"],
+		PoolNo:                m.Pool["NO"],
+		Pool:                  poolJSON(m.Pool),
+		TotalLiquidity:        m.TotalLiquidity,
+		Volume:                m.Volume,
+		Volume24Hours:         m.Volume24Hours,
+		IsResolved:            m.IsResolved,
+		Resolution:            m.Resolution,
+		ResolutionTime:        m.ResolutionTime,
+		ResolutionProbability: m.ResolutionProbability,
+		LastUpdatedTime:       m.LastUpdatedTime,
+		AnswerCount:           int64(len(m.Answers)),
+	}
+}
+
+// poolJSON encodes a pool with its keys sorted, so the column is stable between exports.
+func poolJSON(p mango.Pool) string {
+	if len(p) == 0 {
+		return ""
+	}
+	// encoding/json sorts map keys
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
+// CommentRow is a flattened [mango.Comment].
+type CommentRow struct {
+	Id                       string  `json:"id" parquet:"id"`
+	ContractId               string  `json:"contract_id" parquet:"contract_id"`
+	ContractSlug             string  `json:"contract_slug" parquet:"contract_slug"`
+	UserId                   string  `json:"user_id" parquet:"user_id"`
+	UserUsername             string  `json:"user_username" parquet:"user_username"`
+	CreatedTime              int64   `json:"created_time" parquet:"created_time,timestamp(millisecond)"`
+	Text                     string  `json:"text" parquet:"text"`
+	ReplyToCommentId         string  `json:"reply_to_comment_id" parquet:"reply_to_comment_id"`
+	CommenterPositionProb    float64 `json:"commenter_position_prob" parquet:"commenter_position_prob"`
+	CommenterPositionShares  float64 `json:"commenter_position_shares" parquet:"commenter_position_shares"`
+	CommenterPositionOutcome string  `json:"commenter_position_outcome" parquet:"commenter_position_outcome"`
+	BetId                    string  `json:"bet_id" parquet:"bet_id"`
+	BetAmount                float64 `json:"bet_amount" parquet:"bet_amount"`
+	BetOutcome               string  `json:"bet_outcome" parquet:"bet_outcome"`
+}
+
+// NewCommentRow flattens a comment.
+func NewCommentRow(c mango.Comment) CommentRow {
+	return CommentRow{
+		Id:                       c.Id,
+		ContractId:               c.ContractId,
+		ContractSlug:             c.ContractSlug,
+		UserId:                   c.UserId,
+		UserUsername:             c.UserUsername,
+		CreatedTime:              c.CreatedTime,
+		Text:                     c.Text,
+		ReplyToCommentId:         c.ReplyToCommentId,
+		CommenterPositionProb:    c.CommenterPositionProb,
+		CommenterPositionShares:  c.CommenterPositionShares,
+		CommenterPositionOutcome: c.CommenterPositionOutcome,
+		BetId:                    c.BetId,
+		BetAmount:                c.BetAmount,
+		BetOutcome:               c.BetOutcome,
+	}
+}
+
+// TxnRow is a flattened [mango.Txn].
+type TxnRow struct {
+	Id          string  `json:"id" parquet:"id"`
+	CreatedTime int64   `json:"created_time" parquet:"created_time,timestamp(millisecond)"`
+	FromId      string  `json:"from_id" parquet:"from_id"`
+	FromType    string  `json:"from_type" parquet:"from_type"`
+	ToId        string  `json:"to_id" parquet:"to_id"`
+	ToType      string  `json:"to_type" parquet:"to_type"`
+	Amount      float64 `json:"amount" parquet:"amount"`
+	Token       string  `json:"token" parquet:"token"`
+	Category    string  `json:"category" parquet:"category"`
+	Description string  `json:"description" parquet:"description"`
+}
+
+// NewTxnRow flattens a transaction.
+func NewTxnRow(t mango.Txn) TxnRow {
+	return TxnRow{
+		Id:          t.Id,
+		CreatedTime: t.CreatedTime,
+		FromId:      t.FromId,
+		FromType:    t.FromType,
+		ToId:        t.ToId,
+		ToType:      t.ToType,
+		Amount:      t.Amount,
+		Token:       t.Token,
+		Category:    t.Category,
+		Description: t.Description,
+	}
+}
+
+// ContractMetricRow is a flattened [mango.ContractMetric]. YES and NO shares and the
+// day, week and month profits have their own columns, and all shares are also kept
+// as a JSON object.
+type ContractMetricRow struct {
+	ContractId    string  `json:"contract_id" parquet:"contract_id"`
+	UserId        string  `json:"user_id" parquet:"user_id"`
+	UserUsername  string  `json:"user_username" parquet:"user_username"`
+	Invested      float64 `json:"invested" parquet:"invested"`
+	Loan          float64 `json:"loan" parquet:"loan"`
+	Payout        float64 `json:"payout" parquet:"payout"`
+	Profit        float64 `json:"profit" parquet:"profit"`
+	ProfitPercent float64 `json:"profit_percent" parquet:"profit_percent"`
+	HasShares     bool    `json:"has_shares" parquet:"has_shares"`
+	MaxShares     string  `json:"max_shares_outcome" parquet:"max_shares_outcome"`
+	SharesYes     float64 `json:"shares_yes" parquet:"shares_yes"`
+	SharesNo      float64 `json:"shares_no" parquet:"shares_no"`
+	Shares        string  `json:"shares" parquet:"shares"`
+	ProfitDay     float64 `json:"profit_day" parquet:"profit_day"`
+	ProfitWeek    float64 `json:"profit_week" parquet:"profit_week"`
+	ProfitMonth   float64 `json:"profit_month" parquet:"profit_month"`
+	LastBetTime   int64   `json:"last_bet_time" parquet:"last_bet_time,timestamp(millisecond)"`
+}
+
+// NewContractMetricRow flattens a contract metric.
+func NewContractMetricRow(cm mango.ContractMetric) ContractMetricRow {
+	r := ContractMetricRow{
+		ContractId:    cm.ContractId,
+		UserId:        cm.UserId,
+		UserUsername:  cm.UserUsername,
+		Invested:      cm.Invested,
+		Loan:          cm.Loan,
+		Payout:        cm.Payout,
+		Profit:        cm.Profit,
+		ProfitPercent: cm.ProfitPercent,
+		HasShares:     cm.HasShares,
+		MaxShares:     cm.MaxShares,
+		SharesYes:     cm.TotalShares["YES"],
+		SharesNo:      cm.TotalShares["NO"],
+		ProfitDay:     cm.From["day"].Profit,
+		ProfitWeek:    cm.From["week"].Profit,
+		ProfitMonth:   cm.From["month"].Profit,
+		LastBetTime:   cm.LastBetTime,
+	}
+
+	if len(cm.TotalShares) > 0 {
+		// encoding/json sorts map keys, so the column is stable between exports
+		b, _ := json.Marshal(cm.TotalShares)
+		r.Shares = string(b)
+	}
+
+	return r
+}
@@ -0,0 +1,164 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Format is an export file format.
+type Format string
+
+const (
+	CSV     Format = "csv"
+	NDJSON  Format = "ndjson"
+	Parquet Format = "parquet"
+)
+
+// ParseFormat returns the format with the given name. "jsonl" is accepted for NDJSON.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "csv":
+		return CSV, nil
+	case "ndjson", "jsonl":
+		return NDJSON, nil
+	case "parquet":
+		return Parquet, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected csv, ndjson or parquet", s)
+}
+
+// FormatForPath returns the format implied by a file's extension, or false if there isn't one.
+func FormatForPath(path string) (Format, bool) {
+	f, err := ParseFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+	return f, err == nil
+}
+
+// Writer writes rows of type T in a particular format. Close must be called to flush
+// the output, but does not close the underlying io.Writer.
+type Writer[T any] interface {
+	Write(rows ...T) error
+	Close() error
+}
+
+// NewWriter returns a [Writer] for the given format. T should be one of the row types in this package,
+// or another struct with parquet tags naming its columns.
+func NewWriter[T any](w io.Writer, f Format) (Writer[T], error) {
+	switch f {
+	case CSV:
+		return newCSVWriter[T](w)
+	case NDJSON:
+		return &ndjsonWriter[T]{enc: json.NewEncoder(w)}, nil
+	case Parquet:
+		return &parquetWriter[T]{w: parquet.NewGenericWriter[T](w)}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", f)
+}
+
+// Columns returns the column names for a row type, in order.
+func Columns[T any]() []string {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+
+	cols := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("parquet"), ",")
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		cols = append(cols, name)
+	}
+
+	return cols
+}
+
+type csvWriter[T any] struct {
+	w *csv.Writer
+}
+
+func newCSVWriter[T any](w io.Writer) (*csvWriter[T], error) {
+	if reflect.TypeOf((*T)(nil)).Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("CSV rows must be structs")
+	}
+
+	cw := &csvWriter[T]{w: csv.NewWriter(w)}
+
+	// the header is written straight away so an empty export still has its columns
+	if err := cw.w.Write(Columns[T]()); err != nil {
+		return nil, err
+	}
+
+	return cw, nil
+}
+
+func (cw *csvWriter[T]) Write(rows ...T) error {
+	for _, r := range rows {
+		v := reflect.ValueOf(r)
+
+		rec := make([]string, v.NumField())
+		for i := range rec {
+			rec[i] = formatValue(v.Field(i))
+		}
+
+		if err := cw.w.Write(rec); err != nil {
+			return err
+		}
+	}
+
+	return cw.w.Error()
+}
+
+func formatValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func (cw *csvWriter[T]) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type ndjsonWriter[T any] struct {
+	enc *json.Encoder
+}
+
+func (nw *ndjsonWriter[T]) Write(rows ...T) error {
+	for _, r := range rows {
+		if err := nw.enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (nw *ndjsonWriter[T]) Close() error {
+	return nil
+}
+
+type parquetWriter[T any] struct {
+	w *parquet.GenericWriter[T]
+}
+
+func (pw *parquetWriter[T]) Write(rows ...T) error {
+	_, err := pw.w.Write(rows)
+	return err
+}
+
+func (pw *parquetWriter[T]) Close() error {
+	return pw.w.Close()
+}
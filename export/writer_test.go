@@ -0,0 +1,159 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jonnyspicer/mango"
+	"github.com/parquet-go/parquet-go"
+)
+
+var testBet = mango.Bet{
+	Id:          "b1",
+	ContractId:  "m1",
+	UserId:      "u1",
+	CreatedTime: 1717200000000,
+	Outcome:     "YES",
+	Amount:      10,
+	Shares:      18.5,
+	Fees:        mango.Fees{CreatorFee: 0.1, PlatformFee: 0.1},
+	Fills: []mango.Fill{
+		{Amount: 4, Shares: 7, Timestamp: 1717200000000},
+		{Amount: 6, Shares: 11.5, Timestamp: 1717200005000},
+	},
+}
+
+func TestCSVWriter(t *testing.T) {
+	var b bytes.Buffer
+
+	w, err := NewWriter[BetRow](&b, CSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(NewBetRow(testBet)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	recs, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("expected a header and one row, got %d records", len(recs))
+	}
+
+	row := map[string]string{}
+	for i, col := range recs[0] {
+		row[col] = recs[1][i]
+	}
+
+	want := map[string]string{
+		"id":             "b1",
+		"created_time":   "1717200000000",
+		"shares":         "18.5",
+		"fee_creator":    "0.1",
+		"fill_count":     "2",
+		"filled_amount":  "10",
+		"last_fill_time": "1717200005000",
+		"is_filled":      "false",
+	}
+	for col, v := range want {
+		if row[col] != v {
+			t.Errorf("column %v: got %q, want %q", col, row[col], v)
+		}
+	}
+}
+
+func TestCSVWriterEmpty(t *testing.T) {
+	var b bytes.Buffer
+
+	w, err := NewWriter[TxnRow](&b, CSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	if got := strings.TrimSpace(b.String()); got != strings.Join(Columns[TxnRow](), ",") {
+		t.Errorf("expected just the header, got %q", got)
+	}
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	var b bytes.Buffer
+
+	w, err := NewWriter[MarketRow](&b, NDJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(
+		NewMarketRow(mango.LiteMarket{Id: "m1", Pool: mango.Pool{"YES": 10, "NO": 20}}),
+		NewMarketRow(mango.LiteMarket{Id: "m2"}),
+	)
+	w.Close()
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+
+	var row map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &row); err != nil {
+		t.Fatal(err)
+	}
+	if row["pool_yes"] != 10.0 || row["pool"] != `{"NO":20,"YES":10}` {
+		t.Errorf("unexpected row %v", row)
+	}
+	if len(row) != len(Columns[MarketRow]()) {
+		t.Errorf("expected %d columns, got %d", len(Columns[MarketRow]()), len(row))
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	var b bytes.Buffer
+
+	w, err := NewWriter[BetRow](&b, Parquet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(NewBetRow(testBet), NewBetRow(mango.Bet{Id: "b2"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := parquet.Read[BetRow](bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0] != NewBetRow(testBet) || rows[1].Id != "b2" {
+		t.Errorf("unexpected rows %+v", rows)
+	}
+
+	f, err := parquet.OpenFile(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	col, ok := f.Schema().Lookup("created_time")
+	if !ok || col.Node.Type().LogicalType().Timestamp == nil {
+		t.Errorf("expected created_time to be a timestamp")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, ok := FormatForPath("out/bets.jsonl"); !ok || f != NDJSON {
+		t.Errorf("unexpected format %v", f)
+	}
+	if _, ok := FormatForPath("bets.txt"); ok {
+		t.Error("expected no format for .txt")
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
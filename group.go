@@ -41,3 +41,22 @@ type Group struct {
 	MostRecentChatActivityTime  int64             `json:"mostRecentChatActivityTime,omitempty"`
 	ChatDisabled                bool              `json:"chatDisabled,omitempty"`
 }
+
+// GroupMember represents a member of a [Group].
+//
+// This type isn't documented by Manifold and its structure was inferred from API calls.
+type GroupMember struct {
+	UserId      string `json:"userId"`
+	GroupId     string `json:"groupId"`
+	Role        string `json:"role,omitempty"`
+	CreatedTime int64  `json:"createdTime"`
+}
+
+// RelatedGroups represents the groups directly above and below a [Group] in Manifold's
+// topic hierarchy.
+//
+// This type isn't documented by Manifold and its structure was inferred from API calls.
+type RelatedGroups struct {
+	Above []Group `json:"above"`
+	Below []Group `json:"below"`
+}
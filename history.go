@@ -0,0 +1,152 @@
+package mango
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ProbabilityPoint represents the movement of a market's probability over one bucket of a
+// [ProbabilityHistory]. Time is the start of the bucket in milliseconds since the epoch.
+type ProbabilityPoint struct {
+	Time   int64   `json:"time"`
+	Open   float64 `json:"open"`
+	High   float64 `json:"high"`
+	Low    float64 `json:"low"`
+	Close  float64 `json:"close"`
+	Volume float64 `json:"volume"`
+	Bets   int     `json:"bets"`
+}
+
+// ProbabilityHistory represents a market's probability over time, built from the bets placed
+// on it. For multiple choice markets there is one history per answer, and AnswerId is set.
+//
+// Points are in time order and have no gaps: a bucket with no bets holds the previous
+// bucket's closing probability.
+type ProbabilityHistory struct {
+	MarketId   string             `json:"marketId"`
+	AnswerId   string             `json:"answerId,omitempty"`
+	Resolution time.Duration      `json:"resolution"`
+	Points     []ProbabilityPoint `json:"points"`
+}
+
+// At returns the probability at time t, which is the closing probability of the bucket
+// containing t. If t is before the first bet then false is returned.
+func (ph ProbabilityHistory) At(t time.Time) (float64, bool) {
+	ms := t.UnixMilli()
+
+	i := sort.Search(len(ph.Points), func(i int) bool { return ph.Points[i].Time > ms })
+	if i == 0 {
+		return 0, false
+	}
+
+	return ph.Points[i-1].Close, true
+}
+
+// GetProbabilityHistory returns the probability history of a market, with the bets placed
+// on it grouped into buckets of the given resolution. Buckets are aligned to multiples of the
+// resolution since the Unix epoch, so a resolution of 24 hours gives one point per UTC day.
+//
+// Binary markets have a single history. Multiple choice markets have one history for each
+// answer that has been bet on, ordered by answer id. Each answer's history only reflects the
+// bets placed on that answer.
+//
+// Every bet on the market is fetched, which for busy markets can take several requests, and
+// small resolutions over long-running markets give a lot of points.
+func (mc *Client) GetProbabilityHistory(marketId string, resolution time.Duration) ([]ProbabilityHistory, error) {
+	if marketId == "" {
+		return nil, fmt.Errorf("a market id is required")
+	}
+	if resolution < time.Millisecond {
+		return nil, fmt.Errorf("resolution must be at least 1ms, got %v", resolution)
+	}
+
+	var bets []Bet
+	err := mc.PageBets(GetBetsRequest{ContractId: marketId}, func(page []Bet) error {
+		bets = append(bets, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ProbabilityHistories(marketId, bets, resolution), nil
+}
+
+// ProbabilityHistories builds probability histories from bets in any order, as described
+// in [Client.GetProbabilityHistory].
+func ProbabilityHistories(marketId string, bets []Bet, resolution time.Duration) []ProbabilityHistory {
+	byAnswer := map[string][]Bet{}
+	for _, b := range bets {
+		// unfilled limit orders and bets without probabilities don't move the market
+		if b.OrderAmount > 0 && b.Amount == 0 || b.ProbBefore == 0 && b.ProbAfter == 0 {
+			continue
+		}
+		byAnswer[b.AnswerId] = append(byAnswer[b.AnswerId], b)
+	}
+
+	answers := make([]string, 0, len(byAnswer))
+	for a := range byAnswer {
+		answers = append(answers, a)
+	}
+	sort.Strings(answers)
+
+	histories := make([]ProbabilityHistory, 0, len(answers))
+	for _, a := range answers {
+		histories = append(histories, ProbabilityHistory{
+			MarketId:   marketId,
+			AnswerId:   a,
+			Resolution: resolution,
+			Points:     probabilityPoints(byAnswer[a], resolution.Milliseconds()),
+		})
+	}
+
+	return histories
+}
+
+// probabilityPoints groups bets into buckets of step milliseconds.
+func probabilityPoints(bets []Bet, step int64) []ProbabilityPoint {
+	// bets are usually fetched newest first, so reversing them keeps bets placed in the
+	// same millisecond in order
+	sorted := make([]Bet, len(bets))
+	for i, b := range bets {
+		sorted[len(bets)-1-i] = b
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].CreatedTime < sorted[j].CreatedTime })
+
+	bucket := func(t int64) int64 {
+		b := t - t%step
+		if t < 0 && t%step != 0 {
+			b -= step
+		}
+		return b
+	}
+
+	var points []ProbabilityPoint
+	for _, b := range sorted {
+		start := bucket(b.CreatedTime)
+
+		n := len(points)
+		if n == 0 || points[n-1].Time != start {
+			// fill any buckets without bets with the last closing probability
+			if n > 0 {
+				last := points[n-1].Close
+				for t := points[n-1].Time + step; t < start; t += step {
+					points = append(points, ProbabilityPoint{Time: t, Open: last, High: last, Low: last, Close: last})
+				}
+			}
+			points = append(points, ProbabilityPoint{Time: start, Open: b.ProbBefore, High: b.ProbBefore, Low: b.ProbBefore})
+		}
+
+		p := &points[len(points)-1]
+		p.High = max(p.High, b.ProbBefore, b.ProbAfter)
+		p.Low = min(p.Low, b.ProbBefore, b.ProbAfter)
+		p.Close = b.ProbAfter
+		p.Bets++
+		if !b.IsRedemption {
+			p.Volume += b.Amount
+		}
+	}
+
+	return points
+}
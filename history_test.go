@@ -0,0 +1,94 @@
+package mango
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetProbabilityHistory(t *testing.T) {
+	const hour = int64(time.Hour / time.Millisecond)
+
+	// newest first, as the API returns them
+	bets := []Bet{
+		{Id: "5", ContractId: "m1", CreatedTime: 3*hour + 10, ProbBefore: 0.55, ProbAfter: 0.5, Amount: 5},
+		{Id: "4", ContractId: "m1", CreatedTime: hour + 30, ProbBefore: 0.7, ProbAfter: 0.55, Amount: 20},
+		{Id: "3", ContractId: "m1", CreatedTime: hour + 20, ProbBefore: 0.6, ProbAfter: 0.7, Amount: 10},
+		{Id: "2", ContractId: "m1", CreatedTime: hour + 20, ProbBefore: 0.4, ProbAfter: 0.6, Amount: 10},
+		{Id: "1", ContractId: "m1", CreatedTime: 10, ProbBefore: 0.5, ProbAfter: 0.4, Amount: 10},
+		{Id: "0", ContractId: "m1", CreatedTime: 20, Amount: 0, LimitProb: 0.2},
+		// an unfilled limit order, which has the probability when it was placed
+		{Id: "6", ContractId: "m1", CreatedTime: 30, ProbBefore: 0.4, ProbAfter: 0.4, OrderAmount: 50, LimitProb: 0.2},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v0/bets/" || r.URL.Query().Get("contractId") != "m1" {
+			t.Errorf("unexpected request %v", r.URL)
+		}
+		if r.URL.Query().Get("before") != "" {
+			json.NewEncoder(w).Encode([]Bet{})
+			return
+		}
+		json.NewEncoder(w).Encode(bets)
+	}))
+	defer server.Close()
+
+	mc := ClientInstance(server.Client(), &server.URL, &testKey)
+	defer mc.Destroy()
+
+	hs, err := mc.GetProbabilityHistory("m1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hs) != 1 || hs[0].AnswerId != "" {
+		t.Fatalf("expected one history, got %+v", hs)
+	}
+
+	want := []ProbabilityPoint{
+		{Time: 0, Open: 0.5, High: 0.5, Low: 0.4, Close: 0.4, Volume: 10, Bets: 1},
+		{Time: hour, Open: 0.4, High: 0.7, Low: 0.4, Close: 0.55, Volume: 40, Bets: 3},
+		{Time: 2 * hour, Open: 0.55, High: 0.55, Low: 0.55, Close: 0.55},
+		{Time: 3 * hour, Open: 0.55, High: 0.55, Low: 0.5, Close: 0.5, Volume: 5, Bets: 1},
+	}
+	points := hs[0].Points
+	if len(points) != len(want) {
+		t.Fatalf("expected %d points, got %+v", len(want), points)
+	}
+	for i := range want {
+		if points[i] != want[i] {
+			t.Errorf("point %d: got %+v, want %+v", i, points[i], want[i])
+		}
+	}
+
+	if _, ok := hs[0].At(time.UnixMilli(-1)); ok {
+		t.Error("expected no probability before the first bet")
+	}
+	if p, ok := hs[0].At(time.UnixMilli(2*hour + 5)); !ok || p != 0.55 {
+		t.Errorf("expected 0.55, got %v", p)
+	}
+	if p, _ := hs[0].At(time.UnixMilli(10 * hour)); p != 0.5 {
+		t.Errorf("expected 0.5, got %v", p)
+	}
+
+	if _, err := mc.GetProbabilityHistory("m1", 0); err == nil {
+		t.Error("expected an error for a zero resolution")
+	}
+}
+
+func TestProbabilityHistoriesByAnswer(t *testing.T) {
+	bets := []Bet{
+		{AnswerId: "b", CreatedTime: 5, ProbBefore: 0.3, ProbAfter: 0.35},
+		{AnswerId: "a", CreatedTime: 2, ProbBefore: 0.5, ProbAfter: 0.6},
+		{AnswerId: "a", CreatedTime: 1, ProbBefore: 0.4, ProbAfter: 0.5},
+	}
+
+	hs := ProbabilityHistories("m1", bets, time.Minute)
+	if len(hs) != 2 || hs[0].AnswerId != "a" || hs[1].AnswerId != "b" {
+		t.Fatalf("expected a history per answer, got %+v", hs)
+	}
+	if p := hs[0].Points; len(p) != 1 || p[0].Open != 0.4 || p[0].Close != 0.6 || p[0].Bets != 2 {
+		t.Errorf("unexpected points for a: %+v", p)
+	}
+}
@@ -0,0 +1,228 @@
+package mango
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RequestInfo describes a completed request to the Manifold API.
+// It is passed to every [AfterRequestHook] registered on a [Client].
+type RequestInfo struct {
+	Context       context.Context // set with [Client.WithContext], or context.Background
+	Method        string
+	URL           string
+	Endpoint      string // the API path with identifiers replaced, eg "/v0/market/{id}/sell"
+	MarketId      string // the market ID in the path, if there is one
+	StatusCode    int    // zero if no response was received
+	Latency       time.Duration
+	Bytes         int64         // size of the response body
+	Attempt       int           // zero for the first attempt, incremented for each retry
+	RateLimitWait time.Duration // time spent waiting on the rate limiter before sending
+	Err           error         // non-nil if the request could not be completed
+}
+
+// BeforeRequestHook is called immediately before a request is sent.
+// Hooks may add headers to the request but must not read or replace its body.
+type BeforeRequestHook func(req *http.Request)
+
+// AfterRequestHook is called once a request has completed, whether or not it succeeded.
+type AfterRequestHook func(info RequestInfo)
+
+// WithLogger sets the [slog.Logger] the client uses for diagnostic output.
+//
+// By default the client discards all log output.
+func WithLogger(l *slog.Logger) ClientOption {
+	return func(mc *Client) {
+		if l != nil {
+			mc.logger = l
+		}
+	}
+}
+
+// WithBeforeRequest registers a hook that is called before every request the client makes.
+func WithBeforeRequest(h BeforeRequestHook) ClientOption {
+	return func(mc *Client) {
+		mc.before = append(mc.before, h)
+	}
+}
+
+// WithAfterRequest registers a hook that is called after every request the client makes.
+func WithAfterRequest(h AfterRequestHook) ClientOption {
+	return func(mc *Client) {
+		mc.after = append(mc.after, h)
+	}
+}
+
+// WithRetries makes the client retry requests that fail transiently, up to max times.
+//
+// GET requests are retried on network errors and 5xx responses, and every request
+// is retried on a 429 response. The delay between attempts starts at backoff and
+// doubles on each retry. By default requests are not retried.
+func WithRetries(max int, backoff time.Duration) ClientOption {
+	return func(mc *Client) {
+		mc.retries = max
+		mc.backoff = backoff
+	}
+}
+
+// send is the single path through which every request to the API is made.
+// It applies the rate limiter, retries and request hooks, and buffers the
+// response body so that its size can be reported and the connection released.
+func (mc *Client) send(req *http.Request) (*http.Response, error) {
+	if req.URL == nil || req.URL.Host == "" {
+		return nil, fmt.Errorf("invalid request URL: %q", req.URL)
+	}
+
+	if mc.ctx != nil {
+		req = req.WithContext(mc.ctx)
+	}
+
+	endpoint, marketId := endpointTemplate(req.URL.Path)
+
+	for attempt := 0; ; attempt++ {
+		wait, err := mc.limiter.wait(req.Context())
+		if err != nil {
+			return nil, err
+		}
+
+		info := RequestInfo{
+			Context:       req.Context(),
+			Method:        req.Method,
+			URL:           req.URL.String(),
+			Endpoint:      endpoint,
+			MarketId:      marketId,
+			Attempt:       attempt,
+			RateLimitWait: wait,
+		}
+
+		for _, h := range mc.before {
+			h(req)
+		}
+
+		start := time.Now()
+		resp, err := mc.client.Do(req)
+		if err == nil {
+			info.StatusCode = resp.StatusCode
+
+			var body []byte
+			body, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+			info.Bytes = int64(len(body))
+		}
+		info.Latency = time.Since(start)
+		info.Err = err
+
+		mc.logRequest(info)
+
+		for _, h := range mc.after {
+			h(info)
+		}
+
+		if !mc.shouldRetry(req, info, attempt) {
+			if err != nil {
+				return nil, err
+			}
+			return resp, nil
+		}
+
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, fmt.Errorf("error rewinding request body: %v", err)
+			}
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(mc.backoff * time.Duration(1<<attempt)):
+		}
+	}
+}
+
+func (mc *Client) shouldRetry(req *http.Request, info RequestInfo, attempt int) bool {
+	if attempt >= mc.retries || req.Context().Err() != nil {
+		return false
+	}
+
+	// a request with a body can only be resent if the body can be recreated
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+
+	if info.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	if req.Method != http.MethodGet {
+		return false
+	}
+
+	return info.Err != nil || info.StatusCode >= http.StatusInternalServerError
+}
+
+// endpointTemplate returns the given API path with any identifier replaced by "{id}",
+// along with the market ID if the path refers to a single market.
+func endpointTemplate(path string) (string, string) {
+	prefix := "/" + version
+	if !strings.HasPrefix(path, prefix) {
+		return path, ""
+	}
+
+	rest := strings.TrimPrefix(path, prefix)
+
+	// more specific paths must come before the paths they start with
+	for _, p := range []string{postCancellation, getGroupByID, getUserByID, getMarketByID, getMarketBySlug, getGroupBySlug, getUserByUsername} {
+		if !strings.HasPrefix(rest, p) || len(rest) == len(p) {
+			continue
+		}
+
+		id, suffix, found := strings.Cut(rest[len(p):], "/")
+		if found {
+			suffix = "/" + suffix
+		}
+
+		var marketId string
+		if p == getMarketByID {
+			marketId = id
+		}
+
+		return prefix + p + "{id}" + suffix, marketId
+	}
+
+	return path, ""
+}
+
+func (mc *Client) logRequest(info RequestInfo) {
+	attrs := []slog.Attr{
+		slog.String("method", info.Method),
+		slog.String("url", info.URL),
+		slog.String("endpoint", info.Endpoint),
+		slog.Int("status", info.StatusCode),
+		slog.Duration("latency", info.Latency),
+		slog.Int64("bytes", info.Bytes),
+		slog.Int("attempt", info.Attempt),
+	}
+
+	if info.Err != nil {
+		attrs = append(attrs, slog.String("error", info.Err.Error()))
+		mc.logger.LogAttrs(info.Context, slog.LevelWarn, "manifold request failed", attrs...)
+		return
+	}
+
+	mc.logger.LogAttrs(info.Context, slog.LevelDebug, "manifold request", attrs...)
+}
+
+// discardHandler is a [slog.Handler] that drops every record.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
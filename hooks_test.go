@@ -0,0 +1,255 @@
+package mango
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestHooks(t *testing.T) {
+	body := `{"id":"abc","username":"jonny"}`
+	var gotHeader string
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("X-Trace")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(body))
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var infos []RequestInfo
+
+	mc := ClientInstance(server.Client(), &server.URL, &testKey,
+		WithBeforeRequest(func(req *http.Request) {
+			req.Header.Set("X-Trace", "123")
+		}),
+		WithAfterRequest(func(info RequestInfo) {
+			infos = append(infos, info)
+		}),
+	)
+	defer mc.Destroy()
+
+	user, err := mc.GetUserByUsername("jonny")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user.Id != "abc" {
+		t.Errorf("expected user id abc, got %v", user.Id)
+	}
+
+	if gotHeader != "123" {
+		t.Errorf("expected before hook to set header, got %q", gotHeader)
+	}
+
+	if len(infos) != 1 {
+		t.Fatalf("expected 1 after hook call, got %d", len(infos))
+	}
+
+	info := infos[0]
+	if info.Method != http.MethodGet {
+		t.Errorf("expected method GET, got %v", info.Method)
+	}
+	if !strings.HasSuffix(info.URL, "/v0/user/jonny") {
+		t.Errorf("unexpected url %v", info.URL)
+	}
+	if info.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", info.StatusCode)
+	}
+	if info.Bytes != int64(len(body)) {
+		t.Errorf("expected %d bytes, got %d", len(body), info.Bytes)
+	}
+	if info.Err != nil {
+		t.Errorf("unexpected error in request info: %v", info.Err)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{}`))
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	mc := ClientInstance(server.Client(), &server.URL, &testKey, WithLogger(logger))
+	defer mc.Destroy()
+
+	if _, err := mc.GetAuthenticatedUser(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "manifold request") || !strings.Contains(out, "status=200") {
+		t.Errorf("expected request to be logged, got %q", out)
+	}
+	if strings.Contains(out, testKey) {
+		t.Error("expected API key not to be logged")
+	}
+}
+
+func TestInvalidRequestURL(t *testing.T) {
+	var called bool
+
+	mc := ClientInstance(nil, nil, &testKey, WithAfterRequest(func(RequestInfo) {
+		called = true
+	}))
+	defer mc.Destroy()
+
+	if requestURL(mc.url, getMe, "", "", "odd") != "" {
+		t.Fatal("expected an odd number of params to produce an empty URL")
+	}
+
+	if _, err := mc.getRequest(""); err == nil {
+		t.Error("expected an error for an empty URL")
+	}
+	if called {
+		t.Error("expected no request to be made for an empty URL")
+	}
+}
+
+func TestEndpointTemplate(t *testing.T) {
+	tests := []struct {
+		path     string
+		endpoint string
+		marketId string
+	}{
+		{"/v0/market/abc123/sell/", "/v0/market/{id}/sell/", "abc123"},
+		{"/v0/market/abc123", "/v0/market/{id}", "abc123"},
+		{"/v0/market/", "/v0/market/", ""},
+		{"/v0/markets/", "/v0/markets/", ""},
+		{"/v0/bet/cancel/bet1", "/v0/bet/cancel/{id}", ""},
+		{"/v0/user/by-id/u1/lite/", "/v0/user/by-id/{id}/lite/", ""},
+		{"/v0/user/jonny", "/v0/user/{id}", ""},
+		{"/v0/slug/some-market", "/v0/slug/{id}", ""},
+		{"/other", "/other", ""},
+	}
+
+	for _, tt := range tests {
+		endpoint, marketId := endpointTemplate(tt.path)
+		if endpoint != tt.endpoint || marketId != tt.marketId {
+			t.Errorf("endpointTemplate(%q) = %q, %q, want %q, %q", tt.path, endpoint, marketId, tt.endpoint, tt.marketId)
+		}
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var calls int
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":"abc"}`))
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var attempts []int
+
+	mc := ClientInstance(server.Client(), &server.URL, &testKey,
+		WithRetries(2, time.Millisecond),
+		WithAfterRequest(func(info RequestInfo) {
+			attempts = append(attempts, info.Attempt)
+		}),
+	)
+	defer mc.Destroy()
+
+	if _, err := mc.GetMarketByID("abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+	if len(attempts) != 3 || attempts[2] != 2 {
+		t.Errorf("expected attempts [0 1 2], got %v", attempts)
+	}
+
+	// POST requests are not retried on server errors
+	calls = 0
+	if err := mc.CancelBet("bet1"); err == nil {
+		t.Error("expected an error cancelling bet")
+	}
+	if calls != 1 {
+		t.Errorf("expected POST not to be retried, got %d calls", calls)
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	calls := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":"abc"}`))
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var infos []RequestInfo
+	mc := ClientInstance(server.Client(), &server.URL, &testKey,
+		WithRetries(3, time.Millisecond),
+		WithAfterRequest(func(info RequestInfo) {
+			infos = append(infos, info)
+		}),
+	)
+	defer mc.Destroy()
+
+	type key struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "caller"))
+
+	if _, err := mc.WithContext(ctx).GetUserByUsername("jonny"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := mc.GetUserByUsername("jonny"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(infos) != 2 || infos[0].Context.Value(key{}) != "caller" || infos[1].Context.Value(key{}) != nil {
+		t.Fatalf("expected only the first request to have the caller's context, got %+v", infos)
+	}
+
+	// a cancelled request fails without being retried
+	cancel()
+	if _, err := mc.WithContext(ctx).GetUserByUsername("jonny"); err == nil {
+		t.Error("expected an error for a cancelled context")
+	}
+	if calls != 2 || len(infos) != 3 {
+		t.Errorf("expected the cancelled request to be tried once and not sent, got %d calls and %d attempts", calls, len(infos))
+	}
+}
+
+func TestWithContextBackoff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	mc := ClientInstance(server.Client(), &server.URL, &testKey, WithRetries(3, time.Hour))
+	defer mc.Destroy()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := mc.WithContext(ctx).GetUserByUsername("jonny"); err == nil {
+		t.Error("expected an error for a cancelled context")
+	}
+	if time.Since(start) > time.Second {
+		t.Error("expected the backoff to stop when the context was done")
+	}
+}
@@ -355,7 +355,7 @@ func TestIntegrationLegacyGetComments(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		actual, err := mc.GetComments(GetCommentsRequest{test.ci, test.cs})
+		actual, err := mc.GetComments(GetCommentsRequest{ContractId: test.ci, ContractSlug: test.cs})
 		if err != nil {
 			t.Errorf("error getting comments: %v", err)
 			continue
@@ -0,0 +1,30 @@
+// Package optimize has the numerical searches shared by the packages that size trades.
+package optimize
+
+import "math"
+
+// Maximise returns the x in [from, to] that maximises f, which must be concave, using a
+// golden-section search.
+func Maximise(from, to float64, f func(float64) float64) float64 {
+	ratio := (math.Sqrt(5) - 1) / 2
+
+	lo, hi := from, to
+	for i := 0; i < 200 && hi-lo > 1e-6; i++ {
+		a := hi - ratio*(hi-lo)
+		b := lo + ratio*(hi-lo)
+		if f(a) < f(b) {
+			lo = a
+		} else {
+			hi = b
+		}
+	}
+
+	// the search never evaluates the ends of the range, so check them too
+	best := (lo + hi) / 2
+	for _, x := range []float64{from, to} {
+		if f(x) > f(best) {
+			best = x
+		}
+	}
+	return best
+}
@@ -0,0 +1,18 @@
+// Package slicesx has small slice helpers shared by mango's packages.
+package slicesx
+
+// Dedupe returns the non-empty strings in s with duplicates removed, preserving order.
+func Dedupe(s []string) []string {
+	seen := make(map[string]bool, len(s))
+	out := make([]string, 0, len(s))
+
+	for _, v := range s {
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		out = append(out, v)
+	}
+
+	return out
+}
@@ -0,0 +1,269 @@
+package mango
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// LeaderboardKind represents what a Manifold leaderboard ranks users by.
+type LeaderboardKind string
+
+const (
+	ProfitLeaderboard   LeaderboardKind = "profit"
+	LossLeaderboard     LeaderboardKind = "loss"
+	VolumeLeaderboard   LeaderboardKind = "volume"
+	CreatorLeaderboard  LeaderboardKind = "creator"
+	ReferralLeaderboard LeaderboardKind = "referral"
+)
+
+// LeaderboardEntry represents a user's place on a leaderboard.
+type LeaderboardEntry struct {
+	UserId string `json:"userId"`
+	// Score is what the leaderboard ranks by: mana for the profit, loss and volume
+	// leaderboards, the number of traders for the creator leaderboard, and the number of
+	// referrals for the referral leaderboard.
+	Score float64 `json:"score"`
+}
+
+// GetLeaderboardRequest represents the optional parameters that can be supplied to
+// get a leaderboard.
+type GetLeaderboardRequest struct {
+	// Kind defaults to ProfitLeaderboard.
+	Kind LeaderboardKind `json:"kind,omitempty"`
+	// Token is "MANA", the default, or "CASH".
+	Token string `json:"token,omitempty"`
+	// GroupId limits the leaderboard to the markets in a group.
+	GroupId string `json:"groupId,omitempty"`
+	// Limit defaults to 50, and can be at most 500.
+	Limit int64 `json:"limit,omitempty"`
+}
+
+// maxLeaderboardLimit is the most entries the leaderboard endpoint returns.
+const maxLeaderboardLimit = 500
+
+// GetLeaderboard returns the entries of an all-time leaderboard, highest scoring first.
+//
+// If there is an error making the request, then nil and an error
+// will be returned.
+func (mc *Client) GetLeaderboard(req GetLeaderboardRequest) (*[]LeaderboardEntry, error) {
+	if req.Kind == "" {
+		req.Kind = ProfitLeaderboard
+	}
+	var limit string
+	if req.Limit > 0 {
+		limit = strconv.FormatInt(req.Limit, 10)
+	}
+
+	resp, err := mc.getRequest(requestURL(mc.url, getLeaderboard, "", "",
+		"kind", string(req.Kind),
+		"token", req.Token,
+		"groupId", req.GroupId,
+		"limit", limit,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error making http request: %v", err)
+	}
+
+	return parseResponse(resp, []LeaderboardEntry{})
+}
+
+// LeadUser represents a member of one of the Manifold leaderboards, and their associated user information
+type LeadUser struct {
+	Rank     int
+	Username string
+	// Mana is the profit of a trader, rounded to whole mana.
+	Mana int
+	// Traders is the number of traders on a creator's markets.
+	Traders int
+	// Score is the unrounded value the leaderboard ranks by.
+	Score float64
+	User  User
+}
+
+// LeaderType represents a Manifold leaderboard which an item can be part of
+type LeaderType int
+
+const (
+	Trader LeaderType = iota
+	Creator
+	// Referrer
+)
+
+// LeaderPeriod represents a time period that a Manifold leaderboard can represent
+type LeaderPeriod int
+
+const (
+	// Deprecated: Manifold no longer publishes daily leaderboards, so there are no leaders
+	// for this period.
+	Daily LeaderPeriod = iota
+	// Deprecated: Manifold no longer publishes weekly leaderboards, so there are no leaders
+	// for this period.
+	Weekly
+	Monthly
+	All
+)
+
+// ErrUnsupportedLeaderboard is returned by [Client.GetLeadUsers] for leaderboards the
+// Manifold API has no data for.
+var ErrUnsupportedLeaderboard = errors.New("leaderboard not available from the Manifold API")
+
+// GetLeadersRequest represents the parameters that can be supplied to get the users on a
+// leaderboard.
+type GetLeadersRequest struct {
+	Type   LeaderType
+	Period LeaderPeriod
+	// Season is the league season to rank monthly traders by, which defaults to the
+	// current one. See [LeagueSeason].
+	Season int
+	// Limit defaults to 20, and Offset is the number of leaders to skip.
+	Limit  int64
+	Offset int64
+}
+
+// GetLeaders returns the top 20 users on a leaderboard. It takes a LeaderType, which can have one of the following values:
+//   - Trader - an item on the top traders leaderboard
+//   - Creator - an item on the top creators leaderboard
+//
+// And a LeaderPeriod, which can have one of the following values:
+//   - Monthly - for traders only
+//   - All
+//
+// The leaderboards are built from the Manifold API as described in [Client.GetLeadUsers].
+// Manifold no longer publishes daily or weekly leaderboards, or monthly ones for creators,
+// so for those GetLeaders returns no leaders.
+//
+// Deprecated: GetLeaders can't report errors, so it returns no leaders if the leaderboard
+// can't be fetched, and leaves out leaders whose information can't be fetched. Use
+// [Client.GetLeadUsers] instead.
+func (mc *Client) GetLeaders(t LeaderType, p LeaderPeriod) *[]LeadUser {
+	leaders, _ := mc.GetLeadUsers(GetLeadersRequest{Type: t, Period: p})
+	if leaders == nil {
+		leaders = &[]LeadUser{}
+	}
+	return leaders
+}
+
+// GetLeadUsers returns a page of the users on a leaderboard, highest ranked first. It takes a
+// [GetLeadersRequest] with the following parameters:
+//   - [GetLeadersRequest.Type] - Trader, ranked by profit, or Creator, ranked by the number of
+//     traders on their markets.
+//   - [GetLeadersRequest.Period] - All, or Monthly for traders, which ranks them by the mana
+//     they earned in a league season. Manifold has no data for other periods, so they
+//     return [ErrUnsupportedLeaderboard].
+//   - [GetLeadersRequest.Limit] and [GetLeadersRequest.Offset] - which page of leaders to
+//     return. The all-time leaderboards only go as far as the top 500.
+//
+// If the information of some leaders cannot be fetched, the leaders that were found are
+// returned alongside an error.
+func (mc *Client) GetLeadUsers(req GetLeadersRequest) (*[]LeadUser, error) {
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+
+	entries, err := mc.leaderboard(req)
+	if err != nil {
+		return nil, err
+	}
+
+	start := min(req.Offset, int64(len(entries)))
+	stop := min(start+req.Limit, int64(len(entries)))
+	entries = entries[start:stop]
+
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = e.UserId
+	}
+	users, errs := mc.GetUsersByIDs(ids, nil)
+
+	leaders := []LeadUser{}
+	var failed []error
+	for i, e := range entries {
+		if err, ok := errs[e.UserId]; ok {
+			failed = append(failed, fmt.Errorf("error getting user %v: %w", e.UserId, err))
+			continue
+		}
+		u := users[e.UserId]
+
+		l := LeadUser{Rank: int(start) + i + 1, Username: u.Username, Score: e.Score, User: u}
+		if req.Type == Creator {
+			l.Traders = int(math.Round(e.Score))
+		} else {
+			l.Mana = int(math.Round(e.Score))
+		}
+		leaders = append(leaders, l)
+	}
+
+	return &leaders, errors.Join(failed...)
+}
+
+// PageLeaders calls fn with each page of the users on a leaderboard until there are no more
+// leaders, fn returns an error, or a request fails. req.Offset sets where to start and
+// req.Limit sets the page size, which defaults to 20.
+func (mc *Client) PageLeaders(req GetLeadersRequest, fn func([]LeadUser) error) error {
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+
+	return paginate(req.Limit, fn, func() ([]LeadUser, error) {
+		page, err := mc.GetLeadUsers(req)
+		if err != nil {
+			return nil, err
+		}
+		req.Offset += req.Limit
+		return *page, nil
+	})
+}
+
+// leaderboard returns enough of the leaderboard's entries, highest scoring first, to cover
+// the requested page.
+func (mc *Client) leaderboard(req GetLeadersRequest) ([]LeaderboardEntry, error) {
+	switch {
+	case req.Type != Trader && req.Type != Creator:
+		return nil, fmt.Errorf("unknown leader type %d", req.Type)
+	case req.Period == All:
+		kind := ProfitLeaderboard
+		if req.Type == Creator {
+			kind = CreatorLeaderboard
+		}
+		if req.Offset >= maxLeaderboardLimit {
+			return nil, nil
+		}
+
+		entries, err := mc.GetLeaderboard(GetLeaderboardRequest{Kind: kind, Limit: min(req.Offset+req.Limit, maxLeaderboardLimit)})
+		if err != nil {
+			return nil, fmt.Errorf("error getting leaderboard: %w", err)
+		}
+		return *entries, nil
+	case req.Period == Monthly && req.Type == Trader:
+		season := req.Season
+		if season == 0 {
+			season = LeagueSeason(time.Now())
+		}
+
+		rows, err := mc.GetLeagues(GetLeaguesRequest{Season: season})
+		if err != nil {
+			return nil, fmt.Errorf("error getting league season %d: %w", season, err)
+		}
+		entries := make([]LeaderboardEntry, 0, len(*rows))
+		for _, r := range *rows {
+			entries = append(entries, LeaderboardEntry{UserId: r.UserId, Score: r.ManaEarned})
+		}
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].Score > entries[j].Score })
+		return entries, nil
+	default:
+		return nil, ErrUnsupportedLeaderboard
+	}
+}
+
+// LeagueSeason returns the number of the Manifold league season that t falls in. Seasons
+// are calendar months, starting with season 1 in May 2023. Manifold ends each season at a
+// random time shortly after its month, so the previous season may still be running early
+// in a month.
+func LeagueSeason(t time.Time) int {
+	t = t.UTC()
+	return (t.Year()-2023)*12 + int(t.Month()) - int(time.May) + 1
+}
@@ -0,0 +1,139 @@
+package mango
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// leaderboardServer serves a profit leaderboard of users u1 to u7, a league season and
+// the users themselves, except for u3.
+func leaderboardServer(t *testing.T) *Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v0/"), "/")
+		q := r.URL.Query()
+		switch {
+		case path == "leaderboard":
+			if q.Get("kind") != "profit" {
+				t.Errorf("unexpected leaderboard %v", r.URL)
+			}
+			limit, _ := strconv.Atoi(q.Get("limit"))
+			out := []LeaderboardEntry{}
+			for i := 1; i <= 7 && len(out) < limit; i++ {
+				out = append(out, LeaderboardEntry{UserId: fmt.Sprintf("u%d", i), Score: float64(800 - 100*i)})
+			}
+			json.NewEncoder(w).Encode(out)
+		case path == "leagues":
+			if q.Get("season") != "12" {
+				t.Errorf("unexpected season %v", r.URL)
+			}
+			json.NewEncoder(w).Encode([]LeagueEntry{
+				{UserId: "u1", ManaEarned: 10, Season: 12},
+				{UserId: "u2", ManaEarned: 30.4, Season: 12},
+				{UserId: "u4", ManaEarned: 20, Season: 12},
+			})
+		case strings.HasPrefix(path, "user/by-id/") && path != "user/by-id/u3":
+			id := strings.TrimPrefix(path, "user/by-id/")
+			json.NewEncoder(w).Encode(User{Id: id, Username: "user" + id})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	mc := ClientInstance(server.Client(), &server.URL, &testKey)
+	t.Cleanup(mc.Destroy)
+	return mc
+}
+
+func TestGetLeadUsers(t *testing.T) {
+	mc := leaderboardServer(t)
+
+	leaders, err := mc.GetLeadUsers(GetLeadersRequest{Type: Trader, Period: All, Limit: 3, Offset: 1})
+	if err == nil || !strings.Contains(err.Error(), "u3") {
+		t.Errorf("expected an error for u3, got %v", err)
+	}
+	if len(*leaders) != 2 {
+		t.Fatalf("expected 2 leaders, got %+v", *leaders)
+	}
+	if l := (*leaders)[0]; l.Rank != 2 || l.Username != "useru2" || l.Mana != 600 || l.User.Id != "u2" {
+		t.Errorf("unexpected leader %+v", l)
+	}
+	if l := (*leaders)[1]; l.Rank != 4 || l.Score != 400 {
+		t.Errorf("unexpected leader %+v", l)
+	}
+
+	leaders, err = mc.GetLeadUsers(GetLeadersRequest{Type: Trader, Period: Monthly, Season: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*leaders) != 3 || (*leaders)[0].User.Id != "u2" || (*leaders)[0].Mana != 30 || (*leaders)[2].Rank != 3 {
+		t.Errorf("unexpected monthly leaders %+v", *leaders)
+	}
+
+	for _, req := range []GetLeadersRequest{{Period: Daily}, {Period: Weekly}, {Type: Creator, Period: Monthly}} {
+		if _, err := mc.GetLeadUsers(req); !errors.Is(err, ErrUnsupportedLeaderboard) {
+			t.Errorf("expected %+v to be unsupported, got %v", req, err)
+		}
+	}
+}
+
+func TestGetLeaders(t *testing.T) {
+	mc := leaderboardServer(t)
+
+	// leaders that can't be found are left out
+	leaders := mc.GetLeaders(Trader, All)
+	if len(*leaders) != 6 || (*leaders)[0].User.Id != "u1" || (*leaders)[2].Rank != 4 {
+		t.Errorf("unexpected leaders %+v", *leaders)
+	}
+
+	if leaders := mc.GetLeaders(Trader, Daily); leaders == nil || len(*leaders) != 0 {
+		t.Errorf("expected no daily leaders, got %+v", leaders)
+	}
+}
+
+func TestPageLeaders(t *testing.T) {
+	mc := leaderboardServer(t)
+
+	var ids []string
+	err := mc.PageLeaders(GetLeadersRequest{Period: Monthly, Season: 12, Limit: 2}, func(page []LeadUser) error {
+		for _, l := range page {
+			ids = append(ids, l.User.Id)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ids) != "[u2 u4 u1]" {
+		t.Errorf("unexpected leaders %v", ids)
+	}
+
+	// a leader that can't be found stops paging with an error
+	err = mc.PageLeaders(GetLeadersRequest{Period: All, Limit: 2}, func([]LeadUser) error { return nil })
+	if err == nil {
+		t.Errorf("expected an error for u3")
+	}
+}
+
+func TestLeagueSeason(t *testing.T) {
+	for _, tt := range []struct {
+		time   string
+		season int
+	}{
+		{"2023-05-01T00:00:00Z", 1},
+		{"2023-12-31T12:00:00Z", 8},
+		{"2024-04-15T00:00:00Z", 12},
+	} {
+		at, _ := time.Parse(time.RFC3339, tt.time)
+		if got := LeagueSeason(at); got != tt.season {
+			t.Errorf("expected season %d at %v, got %d", tt.season, tt.time, got)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package ledger
+
+import "strings"
+
+// Token is a currency that balances are kept in.
+type Token string
+
+const (
+	Mana  Token = "MANA"
+	Cash  Token = "CASH"
+	Spice Token = "SPICE"
+)
+
+// Tokens are the tokens a [Ledger] keeps balances for, in the order they're reported.
+var Tokens = []Token{Mana, Cash, Spice}
+
+// tokenOf returns the token of a transaction. Manifold writes mana as "M$".
+func tokenOf(token string) Token {
+	switch t := strings.ToUpper(token); t {
+	case "", "M$", "MANA":
+		return Mana
+	default:
+		return Token(t)
+	}
+}
+
+// Class groups transactions by what they're for.
+type Class string
+
+const (
+	// Trade is buying and selling shares. Bets aren't transactions, so trades come from
+	// the user's bets.
+	Trade Class = "trade"
+	// Payout is what shares pay when a market resolves, or stops paying when a resolution
+	// is undone.
+	Payout Class = "payout"
+	// Loan is mana lent against the value of open positions.
+	Loan Class = "loan"
+	// Bonus is mana given by Manifold, such as for signing up, streaks, quests and leagues.
+	Bonus Class = "bonus"
+	// Managram is mana sent between users, directly or with a manalink.
+	Managram Class = "managram"
+	// Liquidity is mana added to or withdrawn from a market's pool.
+	Liquidity Class = "liquidity"
+	// Bounty is mana posted on, added to or awarded from a bounty question.
+	Bounty Class = "bounty"
+	// Purchase is buying mana, converting between tokens and cashing out.
+	Purchase Class = "purchase"
+	// Fee is paying for something on Manifold, such as a boost.
+	Fee Class = "fee"
+	// Donation is giving to charity.
+	Donation Class = "donation"
+	// Other is any transaction category the ledger doesn't know, which reconciliation
+	// reports separately.
+	Other Class = "other"
+)
+
+var classes = map[string]Class{
+	"CONTRACT_RESOLUTION_PAYOUT":      Payout,
+	"CONTRACT_UNDO_RESOLUTION_PAYOUT": Payout,
+	"LOAN":                            Loan,
+	"SIGNUP_BONUS":                    Bonus,
+	"UNIQUE_BETTOR_BONUS":             Bonus,
+	"CANCEL_UNIQUE_BETTOR_BONUS":      Bonus,
+	"BETTING_STREAK_BONUS":            Bonus,
+	"QUEST_REWARD":                    Bonus,
+	"LEAGUE_PRIZE":                    Bonus,
+	"REFERRAL":                        Bonus,
+	"AIR_DROP":                        Bonus,
+	"CONSUMPTION_BONUS":               Bonus,
+	"MARKET_BOOST_REDEEM":             Bonus,
+	"MANIFOLD_TOP_UP":                 Bonus,
+	"MANA_PAYMENT":                    Managram,
+	"MANALINK":                        Managram,
+	"ADD_SUBSIDY":                     Liquidity,
+	"REMOVE_SUBSIDY":                  Liquidity,
+	"CREATE_CONTRACT_ANTE":            Liquidity,
+	"BOUNTY_POSTED":                   Bounty,
+	"BOUNTY_ADDED":                    Bounty,
+	"BOUNTY_AWARDED":                  Bounty,
+	"BOUNTY_CANCELED":                 Bounty,
+	"MANA_PURCHASE":                   Purchase,
+	"EXTRA_PURCHASED_MANA":            Purchase,
+	"CONVERT_CASH":                    Purchase,
+	"CONVERT_CASH_DONE":               Purchase,
+	"CASH_OUT":                        Purchase,
+	"CONTRACT_RESOLUTION_FEE":         Fee,
+	"MARKET_BOOST_CREATE":             Fee,
+	"BOT_COMMENT_FEE":                 Fee,
+	"CHARITY":                         Donation,
+}
+
+// Classify returns the class of a transaction category.
+func Classify(category string) Class {
+	if c, ok := classes[strings.ToUpper(category)]; ok {
+		return c
+	}
+	return Other
+}
@@ -0,0 +1,335 @@
+// Package ledger turns a Manifold user's transactions and bets into an account ledger,
+// for bookkeeping.
+//
+// Each transaction is classified, as a payout, loan, bonus, managram and so on, and
+// entered with the user's bets in time order, keeping a running balance of each token.
+// The balances can be reconciled against the user's portfolio to find discrepancies the
+// ledger can't explain, and the entries split into monthly statements:
+//
+//	l, err := ledger.Build(mc, userId)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	live, err := mc.GetUserPortfolio(userId)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	for _, r := range l.Reconcile(*live, 0.01) {
+//		if r.Discrepancy {
+//			log.Printf("%v is out by %.2f", r.Token, r.Difference)
+//		}
+//	}
+//
+//	for _, s := range l.Statements(time.UTC) {
+//		f, _ := os.Create(fmt.Sprintf("%v-%v.csv", s.Month.Format("2006-01"), s.Token))
+//		s.WriteCSV(f)
+//		f.Close()
+//	}
+package ledger
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+)
+
+// Entry is a single movement in one of a user's balances.
+type Entry struct {
+	// Time is when the entry happened, in milliseconds since the epoch.
+	Time int64  `json:"time"`
+	Id   string `json:"id"`
+	// Class is what the entry is for, and Category the category of its transaction, or
+	// "BET" for a trade.
+	Class    Class  `json:"class"`
+	Category string `json:"category"`
+	Token    Token  `json:"token"`
+	// Amount is positive for money coming in and negative for money going out. Balance
+	// is the token's balance after the entry.
+	Amount  float64 `json:"amount"`
+	Balance float64 `json:"balance"`
+	// Counterparty is the ID of the user, market or other account at the other end of the
+	// entry, if any, and MarketId the market it involves, if any.
+	Counterparty string `json:"counterparty,omitempty"`
+	MarketId     string `json:"marketId,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Ledger is the entries of every change to a user's balances, oldest first.
+type Ledger struct {
+	UserId  string  `json:"userId"`
+	Entries []Entry `json:"entries"`
+}
+
+// Build fetches every transaction sent or received by the user, and all of their bets,
+// and enters them into a ledger.
+func Build(mc *mango.Client, userId string) (*Ledger, error) {
+	var txns []mango.Txn
+	seen := map[string]bool{}
+	for _, req := range []mango.GetTransactionsRequest{{FromId: userId}, {ToId: userId}} {
+		err := mc.PageTransactions(req, func(page []mango.Txn) error {
+			for _, t := range page {
+				if !seen[t.Id] {
+					seen[t.Id] = true
+					txns = append(txns, t)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error getting transactions: %w", err)
+		}
+	}
+
+	var bets []mango.Bet
+	err := mc.PageBets(mango.GetBetsRequest{UserId: userId}, func(page []mango.Bet) error {
+		bets = append(bets, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting bets: %w", err)
+	}
+
+	return Compute(userId, txns, bets), nil
+}
+
+// Compute enters the user's transactions and bets into a ledger. Transactions and bets
+// that don't involve the user are ignored. Bets are taken to be in mana, and only the part
+// of a bet not paid for by a loan comes out of the balance.
+func Compute(userId string, txns []mango.Txn, bets []mango.Bet) *Ledger {
+	l := &Ledger{UserId: userId, Entries: []Entry{}}
+
+	for _, t := range txns {
+		e := Entry{
+			Time:        t.CreatedTime,
+			Id:          t.Id,
+			Class:       Classify(t.Category),
+			Category:    t.Category,
+			Token:       tokenOf(t.Token),
+			Description: t.Description,
+		}
+
+		var otherType string
+		switch userId {
+		case t.FromId:
+			if t.ToId == userId {
+				continue
+			}
+			e.Amount, e.Counterparty, otherType = -t.Amount, t.ToId, t.ToType
+		case t.ToId:
+			e.Amount, e.Counterparty, otherType = t.Amount, t.FromId, t.FromType
+		default:
+			continue
+		}
+		if otherType == "CONTRACT" {
+			e.MarketId = e.Counterparty
+		}
+
+		l.Entries = append(l.Entries, e)
+	}
+
+	for _, b := range bets {
+		if b.UserId != userId || b.Amount == 0 && b.LoanAmount == 0 {
+			continue
+		}
+
+		desc := b.Outcome
+		if b.AnswerId != "" {
+			desc += " on answer " + b.AnswerId
+		}
+		l.Entries = append(l.Entries, Entry{
+			Time:        b.CreatedTime,
+			Id:          b.Id,
+			Class:       Trade,
+			Category:    "BET",
+			Token:       Mana,
+			Amount:      -(b.Amount - b.LoanAmount),
+			MarketId:    b.ContractId,
+			Description: fmt.Sprintf("%.2f shares of %v", b.Shares, desc),
+		})
+	}
+
+	sort.SliceStable(l.Entries, func(i, j int) bool { return l.Entries[i].Time < l.Entries[j].Time })
+
+	balances := map[Token]float64{}
+	for i := range l.Entries {
+		e := &l.Entries[i]
+		balances[e.Token] += e.Amount
+		e.Balance = balances[e.Token]
+	}
+
+	return l
+}
+
+// Balances returns the balance of each token after every entry up to and including the
+// given time, in milliseconds since the epoch. A time of 0 includes every entry.
+func (l *Ledger) Balances(at int64) map[Token]float64 {
+	out := map[Token]float64{}
+	for _, e := range l.Entries {
+		if at > 0 && e.Time > at {
+			break
+		}
+		out[e.Token] = e.Balance
+	}
+	return out
+}
+
+// Unclassified returns the entries whose transaction category the ledger doesn't know.
+func (l *Ledger) Unclassified() []Entry {
+	var out []Entry
+	for _, e := range l.Entries {
+		if e.Class == Other {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Reconciliation compares the ledger's balance of a token with the user's portfolio.
+type Reconciliation struct {
+	Token     Token   `json:"token"`
+	Ledger    float64 `json:"ledger"`
+	Portfolio float64 `json:"portfolio"`
+	// Difference is the portfolio's balance less the ledger's.
+	Difference float64 `json:"difference"`
+	// Unclassified is the total of the entries the ledger couldn't classify, which may
+	// account for some of the difference.
+	Unclassified float64 `json:"unclassified"`
+	// Discrepancy is true if the difference is more than the tolerance.
+	Discrepancy bool `json:"discrepancy"`
+}
+
+// Reconcile compares the ledger's balances with those of the user's portfolio, as of the
+// portfolio's timestamp. Differences of more than tolerance are flagged as discrepancies.
+func (l *Ledger) Reconcile(live mango.LivePortfolioMetrics, tolerance float64) []Reconciliation {
+	balances := l.Balances(live.Timestamp)
+	portfolio := map[Token]float64{Mana: live.Balance, Cash: live.CashBalance, Spice: live.SpiceBalance}
+
+	unclassified := map[Token]float64{}
+	for _, e := range l.Unclassified() {
+		if live.Timestamp == 0 || e.Time <= live.Timestamp {
+			unclassified[e.Token] += e.Amount
+		}
+	}
+
+	out := make([]Reconciliation, 0, len(Tokens))
+	for _, t := range Tokens {
+		r := Reconciliation{
+			Token:        t,
+			Ledger:       balances[t],
+			Portfolio:    portfolio[t],
+			Difference:   portfolio[t] - balances[t],
+			Unclassified: unclassified[t],
+		}
+		r.Discrepancy = math.Abs(r.Difference) > tolerance
+		out = append(out, r)
+	}
+
+	return out
+}
+
+// Statement is the entries of one token in one calendar month.
+type Statement struct {
+	// Month is the start of the month.
+	Month   time.Time `json:"month"`
+	Token   Token     `json:"token"`
+	Opening float64   `json:"opening"`
+	Closing float64   `json:"closing"`
+	// Totals is the net amount of each class of entry.
+	Totals  map[Class]float64 `json:"totals"`
+	Entries []Entry           `json:"entries"`
+}
+
+// Statements splits the ledger into monthly statements for each token, in the given time
+// zone. They are ordered by month, and then by token. Months without entries in a token
+// have no statement for it.
+func (l *Ledger) Statements(loc *time.Location) []Statement {
+	type key struct {
+		month time.Time
+		token Token
+	}
+
+	var keys []key
+	statements := map[key]*Statement{}
+	for _, e := range l.Entries {
+		t := time.UnixMilli(e.Time).In(loc)
+		k := key{time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc), e.Token}
+
+		s, ok := statements[k]
+		if !ok {
+			s = &Statement{Month: k.month, Token: k.token, Opening: e.Balance - e.Amount, Totals: map[Class]float64{}}
+			statements[k] = s
+			keys = append(keys, k)
+		}
+		s.Closing = e.Balance
+		s.Totals[e.Class] += e.Amount
+		s.Entries = append(s.Entries, e)
+	}
+
+	// tokens other than the usual ones come after them, by name
+	rank := func(t Token) int {
+		for i, u := range Tokens {
+			if t == u {
+				return i
+			}
+		}
+		return len(Tokens)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		switch {
+		case !a.month.Equal(b.month):
+			return a.month.Before(b.month)
+		case rank(a.token) != rank(b.token):
+			return rank(a.token) < rank(b.token)
+		default:
+			return a.token < b.token
+		}
+	})
+
+	out := make([]Statement, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, *statements[k])
+	}
+	return out
+}
+
+// WriteCSV writes the statement's entries as CSV, with a header row. Times are written in
+// RFC 3339 format, in the statement's time zone.
+func (s Statement) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	cw.Write([]string{"time", "id", "class", "category", "token", "amount", "balance", "counterparty", "market_id", "description"})
+	for _, e := range s.Entries {
+		cw.Write([]string{
+			time.UnixMilli(e.Time).In(s.Month.Location()).Format(time.RFC3339),
+			e.Id,
+			string(e.Class),
+			e.Category,
+			string(e.Token),
+			formatAmount(e.Amount),
+			formatAmount(e.Balance),
+			e.Counterparty,
+			e.MarketId,
+			e.Description,
+		})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatAmount formats an amount without the noise of adding floats up.
+func formatAmount(v float64) string {
+	v = math.Round(v*1e6) / 1e6
+	if v == 0 {
+		v = 0 // not -0
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
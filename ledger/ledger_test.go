@@ -0,0 +1,184 @@
+package ledger
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+	"github.com/jonnyspicer/mango/mangotest"
+)
+
+func ms(s string) int64 {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return t.UnixMilli()
+}
+
+func testLedger() *Ledger {
+	txns := []mango.Txn{
+		{Id: "t1", CreatedTime: ms("2024-04-01T00:00:00Z"), FromId: "BANK", FromType: "BANK", ToId: "u1", ToType: "USER", Amount: 1000, Token: "M$", Category: "SIGNUP_BONUS"},
+		{Id: "t2", CreatedTime: ms("2024-04-10T00:00:00Z"), FromId: "u1", FromType: "USER", ToId: "u2", ToType: "USER", Amount: 50, Token: "M$", Category: "MANA_PAYMENT", Description: "thanks"},
+		{Id: "t3", CreatedTime: ms("2024-05-02T00:00:00Z"), FromId: "m1", FromType: "CONTRACT", ToId: "u1", ToType: "USER", Amount: 180, Token: "M$", Category: "CONTRACT_RESOLUTION_PAYOUT"},
+		{Id: "t4", CreatedTime: ms("2024-05-03T00:00:00Z"), FromId: "BANK", FromType: "BANK", ToId: "u1", ToType: "USER", Amount: 5, Token: "CASH", Category: "CASH_BONUS"},
+		{Id: "t5", CreatedTime: ms("2024-05-04T00:00:00Z"), FromId: "u2", ToId: "u3", Amount: 10, Category: "MANA_PAYMENT"},
+	}
+	bets := []mango.Bet{
+		{Id: "b1", CreatedTime: ms("2024-04-20T00:00:00Z"), UserId: "u1", ContractId: "m1", Outcome: "YES", Amount: 100, LoanAmount: 10, Shares: 180},
+		{Id: "b2", CreatedTime: ms("2024-04-21T00:00:00Z"), UserId: "u1", ContractId: "m2", Outcome: "NO", OrderAmount: 50, LimitProb: 0.3},
+		{Id: "b3", CreatedTime: ms("2024-04-22T00:00:00Z"), UserId: "u2", ContractId: "m1", Amount: 20},
+	}
+
+	return Compute("u1", txns, bets)
+}
+
+func TestCompute(t *testing.T) {
+	l := testLedger()
+
+	want := []struct {
+		id      string
+		class   Class
+		token   Token
+		amount  float64
+		balance float64
+	}{
+		{"t1", Bonus, Mana, 1000, 1000},
+		{"t2", Managram, Mana, -50, 950},
+		{"b1", Trade, Mana, -90, 860},
+		{"t3", Payout, Mana, 180, 1040},
+		{"t4", Other, Cash, 5, 5},
+	}
+	if len(l.Entries) != len(want) {
+		t.Fatalf("expected %d entries, got %+v", len(want), l.Entries)
+	}
+	for i, w := range want {
+		e := l.Entries[i]
+		if e.Id != w.id || e.Class != w.class || e.Token != w.token || e.Amount != w.amount || e.Balance != w.balance {
+			t.Errorf("entry %d: expected %+v, got %+v", i, w, e)
+		}
+	}
+
+	if e := l.Entries[3]; e.MarketId != "m1" || e.Counterparty != "m1" {
+		t.Errorf("expected the payout to be from m1, got %+v", e)
+	}
+	if e := l.Entries[1]; e.MarketId != "" || e.Counterparty != "u2" {
+		t.Errorf("expected the managram to be to u2, got %+v", e)
+	}
+
+	if b := l.Balances(ms("2024-04-30T00:00:00Z")); b[Mana] != 860 || b[Cash] != 0 {
+		t.Errorf("unexpected balances at the end of April %v", b)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	l := testLedger()
+
+	rs := l.Reconcile(mango.LivePortfolioMetrics{Balance: 1040, CashBalance: 7, Timestamp: ms("2024-06-01T00:00:00Z")}, 0.01)
+	if len(rs) != 3 {
+		t.Fatalf("expected a reconciliation for each token, got %+v", rs)
+	}
+
+	if r := rs[0]; r.Token != Mana || r.Discrepancy || r.Difference != 0 {
+		t.Errorf("expected mana to reconcile, got %+v", r)
+	}
+	if r := rs[1]; r.Token != Cash || !r.Discrepancy || r.Difference != 2 || r.Unclassified != 5 {
+		t.Errorf("expected cash to be out by 2, got %+v", r)
+	}
+	if r := rs[2]; r.Token != Spice || r.Discrepancy {
+		t.Errorf("expected spice to reconcile, got %+v", r)
+	}
+
+	// entries after the portfolio's timestamp are left out
+	rs = l.Reconcile(mango.LivePortfolioMetrics{Balance: 860, Timestamp: ms("2024-05-01T00:00:00Z")}, 0.01)
+	if rs[0].Discrepancy || rs[1].Discrepancy {
+		t.Errorf("expected balances at the start of May to reconcile, got %+v", rs)
+	}
+}
+
+func TestStatements(t *testing.T) {
+	l := testLedger()
+
+	ss := l.Statements(time.UTC)
+	if len(ss) != 3 {
+		t.Fatalf("expected 3 statements, got %+v", ss)
+	}
+
+	april := ss[0]
+	if april.Month.Format("2006-01") != "2024-04" || april.Token != Mana || april.Opening != 0 || april.Closing != 860 || len(april.Entries) != 3 {
+		t.Errorf("unexpected April statement %+v", april)
+	}
+	if april.Totals[Bonus] != 1000 || april.Totals[Managram] != -50 || april.Totals[Trade] != -90 {
+		t.Errorf("unexpected April totals %v", april.Totals)
+	}
+	if may := ss[1]; may.Token != Mana || may.Opening != 860 || may.Closing != 1040 {
+		t.Errorf("unexpected May statement %+v", may)
+	}
+	if ss[2].Token != Cash {
+		t.Errorf("expected the cash statement to come after mana, got %v", ss[2].Token)
+	}
+
+	// in a time zone behind UTC, the signup bonus was in March
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	if ss := l.Statements(ny); ss[0].Month.Month() != time.March {
+		t.Errorf("expected the first statement in New York to be for March, got %v", ss[0].Month)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testLedger().Statements(time.UTC)[0].WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 || rows[0][0] != "time" || rows[0][5] != "amount" {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+	if r := rows[2]; r[0] != "2024-04-10T00:00:00Z" || r[2] != "managram" || r[5] != "-50" || r[6] != "950" || r[9] != "thanks" {
+		t.Errorf("unexpected row %v", r)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	s := mangotest.NewServer()
+	defer s.Close()
+
+	s.AddUser(mango.User{Id: "u1", Username: "alice"})
+	s.AddMarket(mango.FullMarket{Id: "m1", Question: "Will it rain?", Pool: mango.Pool{"YES": 100, "NO": 100}, P: 0.5})
+	s.AddTxn(mango.Txn{FromId: "BANK", FromType: "BANK", ToId: s.Me().Id, ToType: "USER", Amount: 1000, Category: "SIGNUP_BONUS"})
+
+	mc := s.Client()
+	if _, err := mc.PostBet(mango.PostBetRequest{ContractId: "m1", Outcome: "YES", Amount: 40}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.SendManagram(mango.SendManagramRequest{ToIds: []string{"u1"}, Amount: 25}); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Build(mc, s.Me().Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Entries) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", l.Entries)
+	}
+
+	live, err := mc.GetUserPortfolio(s.Me().Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the fake's starting balance is the signup bonus, so the ledger should match it
+	if r := l.Reconcile(*live, 0.01)[0]; r.Discrepancy || math.Abs(r.Ledger-935) > 1e-9 {
+		t.Errorf("expected mana to reconcile at 935, got %+v", r)
+	}
+}
@@ -0,0 +1,455 @@
+package mango
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+)
+
+// minManagram is the smallest amount Manifold lets you send in a managram.
+const minManagram = 10
+
+// txnWindow is how much earlier than a payment was sent its transaction is looked for,
+// to allow for the clocks of the client and Manifold disagreeing.
+const txnWindow = int64(time.Minute / time.Millisecond)
+
+// Payment represents mana to send to one user with [Client.BulkManagram]. The user is
+// given by either Username or UserId.
+type Payment struct {
+	Username string  `json:"username,omitempty"`
+	UserId   string  `json:"userId,omitempty"`
+	Amount   float64 `json:"amount"`
+	Message  string  `json:"message,omitempty"`
+}
+
+// PaymentStatus is what happened to a [Payment].
+type PaymentStatus string
+
+const (
+	// PaymentPending payments haven't been sent, such as in a dry run.
+	PaymentPending PaymentStatus = "pending"
+	// PaymentSent payments were sent.
+	PaymentSent PaymentStatus = "sent"
+	// PaymentFailed payments got an error and no transaction for them could be found.
+	// The next time the payments are sent with the same journal, they are looked for
+	// again, and sent again only if they still can't be found.
+	PaymentFailed PaymentStatus = "failed"
+
+	// paymentSending payments were being sent when the journal was last written, so they
+	// may or may not have been sent.
+	paymentSending PaymentStatus = "sending"
+)
+
+// PaymentResult represents the outcome of a [Payment]. Payment.UserId is always set,
+// once usernames have been resolved.
+type PaymentResult struct {
+	Payment
+	Status PaymentStatus `json:"status"`
+	Error  string        `json:"error,omitempty"`
+	// SentTime is when the payment was sent, in milliseconds since the epoch.
+	SentTime int64 `json:"sentTime,omitempty"`
+	// Txn is the transaction the payment made, for reconciling against. It can be nil
+	// for a payment that was sent if the transaction couldn't be found.
+	Txn *Txn `json:"txn,omitempty"`
+
+	key string
+}
+
+// BulkManagramOptions represents the optional parameters that can be supplied to
+// [Client.BulkManagram].
+type BulkManagramOptions struct {
+	// Journal is the path of a file that records what happened to each payment, one JSON
+	// object per line. Payments it records as sent are skipped, so sending the same
+	// payments with the same journal again picks up where a run that failed part way
+	// through stopped.
+	Journal string
+	// BatchSize is the most users paid by a single managram. Defaults to 20.
+	BatchSize int
+	// DryRun resolves and checks the payments without sending any of them.
+	DryRun bool
+}
+
+// journalEntry is a line of a [BulkManagramOptions.Journal].
+type journalEntry struct {
+	Key    string        `json:"key"`
+	UserId string        `json:"userId"`
+	Amount float64       `json:"amount"`
+	Status PaymentStatus `json:"status"`
+	Time   int64         `json:"time"`
+	TxnId  string        `json:"txnId,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// BulkManagram sends each user their own amount and message. Payments with the same
+// amount and message are sent together, up to [BulkManagramOptions.BatchSize] users at
+// a time.
+//
+// Every payment is checked before any are sent: each must be for at least M10, usernames
+// are resolved with [Client.GetUserByUsername], and the total must not be more than the
+// authenticated user's balance. If any check fails, nothing is sent.
+//
+// A batch that fails doesn't stop the others being sent. The results are in the same
+// order as the payments, and those that were sent have the [Txn] that paid them, found
+// with [Client.GetTransactions]. A timeout or server error can come after Manifold has
+// moved the mana, so the payments of a batch that failed are looked for in the
+// transactions too, and count as sent if they're there. The errors of every batch with
+// payments that weren't found are returned together.
+//
+// With a journal, a payment that failed or was being sent when the program stopped is
+// looked for in the authenticated user's transactions, and sent again only if it isn't
+// there.
+func (mc *Client) BulkManagram(payments []Payment, opts *BulkManagramOptions) ([]PaymentResult, error) {
+	if opts == nil {
+		opts = &BulkManagramOptions{}
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 20
+	}
+
+	results, err := mc.resolvePayments(payments)
+	if err != nil {
+		return nil, err
+	}
+
+	me, err := mc.GetAuthenticatedUser()
+	if err != nil {
+		return nil, fmt.Errorf("error getting authenticated user: %v", err)
+	}
+
+	j := &paymentJournal{path: opts.Journal, dryRun: opts.DryRun}
+	entries, err := j.load()
+	if err != nil {
+		return nil, err
+	}
+
+	// catch up with the journal, checking the payments that may have been sent
+	var sending []*PaymentResult
+	claimed := map[string]bool{}
+	for i := range results {
+		r := &results[i]
+		e, ok := entries[r.key]
+		if !ok {
+			continue
+		}
+		r.SentTime, r.Error = e.Time, e.Error
+		switch e.Status {
+		case PaymentSent:
+			r.Status = PaymentSent
+			if e.TxnId != "" {
+				r.Txn = &Txn{Id: e.TxnId}
+				claimed[e.TxnId] = true
+			}
+			sending = append(sending, r)
+		case paymentSending:
+			sending = append(sending, r)
+		case PaymentFailed:
+			r.Status = PaymentFailed
+			sending = append(sending, r)
+		}
+	}
+	if err := mc.findPaymentTxns(me.Id, sending, claimed); err != nil {
+		return results, err
+	}
+	for _, r := range sending {
+		if r.Status != PaymentSent && r.Txn != nil {
+			r.Status, r.Error = PaymentSent, ""
+		}
+		if e := entries[r.key]; r.Status == PaymentSent && (e.Status != PaymentSent || e.TxnId == "" && r.Txn != nil) {
+			if err := j.write(r); err != nil {
+				return results, err
+			}
+		}
+	}
+
+	var total float64
+	var pending []*PaymentResult
+	for i := range results {
+		if r := &results[i]; r.Status != PaymentSent {
+			total += r.Amount
+			pending = append(pending, r)
+		}
+	}
+	if total > me.Balance {
+		return results, fmt.Errorf("payments total M%.2f but the balance is only M%.2f", total, me.Balance)
+	}
+	if opts.DryRun {
+		return results, nil
+	}
+
+	type failure struct {
+		batch []*PaymentResult
+		err   error
+	}
+	var failures []failure
+	var sent, failed []*PaymentResult
+	for _, batch := range paymentBatches(pending, batchSize) {
+		ids := make([]string, len(batch))
+		now := time.Now().UnixMilli()
+		for i, r := range batch {
+			ids[i] = r.UserId
+			r.Status, r.SentTime, r.Error = paymentSending, now, ""
+			if err := j.write(r); err != nil {
+				return results, err
+			}
+		}
+
+		err := mc.SendManagram(SendManagramRequest{ToIds: ids, Amount: batch[0].Amount, Message: batch[0].Message})
+		for _, r := range batch {
+			r.Status = PaymentSent
+			if err != nil {
+				r.Status, r.Error = PaymentFailed, err.Error()
+				failed = append(failed, r)
+			} else {
+				sent = append(sent, r)
+			}
+			if err := j.write(r); err != nil {
+				return results, err
+			}
+		}
+		if err != nil {
+			failures = append(failures, failure{batch, fmt.Errorf("error paying %v: %w", strings.Join(ids, ", "), err)})
+		}
+	}
+
+	// payments that failed may still have been made, so they're looked for after those
+	// known to be sent have claimed their transactions
+	var errs []error
+	if err := mc.findPaymentTxns(me.Id, append(sent, failed...), claimed); err != nil {
+		errs = append(errs, err)
+	}
+	for _, r := range append(sent, failed...) {
+		if r.Txn == nil {
+			continue
+		}
+		r.Status, r.Error = PaymentSent, ""
+		if err := j.write(r); err != nil {
+			errs = append(errs, err)
+			break
+		}
+	}
+	for _, f := range failures {
+		for _, r := range f.batch {
+			if r.Status == PaymentFailed {
+				errs = append(errs, f.err)
+				break
+			}
+		}
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// resolvePayments checks the payments and looks up the ids of the users they're to.
+func (mc *Client) resolvePayments(payments []Payment) ([]PaymentResult, error) {
+	var errs []error
+	ids := map[string]string{}
+	seen := map[string]int{}
+	results := make([]PaymentResult, len(payments))
+	for i, p := range payments {
+		switch {
+		case p.Amount < minManagram:
+			errs = append(errs, fmt.Errorf("payment %d is for M%v, less than the minimum of M%d", i+1, p.Amount, minManagram))
+			continue
+		case p.UserId == "" && p.Username == "":
+			errs = append(errs, fmt.Errorf("payment %d has no username or user id", i+1))
+			continue
+		case p.UserId == "":
+			id, ok := ids[p.Username]
+			if !ok {
+				u, err := mc.GetUserByUsername(p.Username)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("payment %d is to unknown user %v: %w", i+1, p.Username, err))
+					continue
+				}
+				id = u.Id
+				ids[p.Username] = id
+			}
+			p.UserId = id
+		}
+
+		// identical payments are told apart by how many came before them
+		key := fmt.Sprintf("%v %v %q", p.UserId, p.Amount, p.Message)
+		results[i] = PaymentResult{Payment: p, Status: PaymentPending, key: fmt.Sprintf("%v #%d", key, seen[key])}
+		seen[key]++
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return results, nil
+}
+
+// paymentBatches groups payments with the same amount and message into batches of at
+// most size users, none of whom are paid twice in the same batch.
+func paymentBatches(payments []*PaymentResult, size int) [][]*PaymentResult {
+	type group struct {
+		amount  float64
+		message string
+	}
+
+	var order []group
+	groups := map[group][][]*PaymentResult{}
+	for _, p := range payments {
+		g := group{p.Amount, p.Message}
+		batches, ok := groups[g]
+		if !ok {
+			order = append(order, g)
+		}
+
+		placed := false
+		for i, b := range batches {
+			if len(b) < size && !paysUser(b, p.UserId) {
+				batches[i] = append(b, p)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			batches = append(batches, []*PaymentResult{p})
+		}
+		groups[g] = batches
+	}
+
+	var out [][]*PaymentResult
+	for _, g := range order {
+		out = append(out, groups[g]...)
+	}
+	return out
+}
+
+func paysUser(batch []*PaymentResult, userId string) bool {
+	for _, p := range batch {
+		if p.UserId == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// findPaymentTxns looks through the payments sent by the given user for the transaction
+// that made each payment, skipping those already claimed by other payments. Payments
+// whose Txn has only an id get the rest of it.
+func (mc *Client) findPaymentTxns(fromId string, payments []*PaymentResult, claimed map[string]bool) error {
+	if len(payments) == 0 {
+		return nil
+	}
+
+	after := payments[0].SentTime
+	for _, p := range payments {
+		after = min(after, p.SentTime)
+	}
+
+	var txns []Txn
+	err := mc.PageTransactions(GetTransactionsRequest{FromId: fromId, Category: "MANA_PAYMENT", After: after - txnWindow}, func(page []Txn) error {
+		txns = append(txns, page...)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error getting transactions: %v", err)
+	}
+
+	// transactions come newest first, and are matched oldest first
+	for _, p := range payments {
+		if p.Txn == nil || p.Txn.CreatedTime != 0 {
+			continue
+		}
+		for i := range txns {
+			if txns[i].Id == p.Txn.Id {
+				p.Txn = &txns[i]
+				break
+			}
+		}
+	}
+	for _, p := range payments {
+		if p.Txn != nil {
+			continue
+		}
+		for i := len(txns) - 1; i >= 0; i-- {
+			t := txns[i]
+			if claimed[t.Id] || t.ToId != p.UserId || t.Amount != p.Amount || t.CreatedTime < p.SentTime-txnWindow {
+				continue
+			}
+			claimed[t.Id] = true
+			p.Txn = &t
+			break
+		}
+	}
+
+	return nil
+}
+
+// paymentJournal reads and writes a [BulkManagramOptions.Journal].
+type paymentJournal struct {
+	path   string
+	dryRun bool
+}
+
+// load returns the latest entry for each payment in the journal.
+func (j *paymentJournal) load() (map[string]journalEntry, error) {
+	out := map[string]journalEntry{}
+	if j.path == "" {
+		return out, nil
+	}
+
+	f, err := os.Open(j.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return out, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading journal: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("error parsing journal %v line %d: %v", j.path, line, err)
+		}
+		out[e.Key] = e
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading journal: %v", err)
+	}
+
+	return out, nil
+}
+
+// write appends the state of a payment to the journal.
+func (j *paymentJournal) write(r *PaymentResult) error {
+	if j.path == "" || j.dryRun {
+		return nil
+	}
+
+	e := journalEntry{Key: r.key, UserId: r.UserId, Amount: r.Amount, Status: r.Status, Time: r.SentTime, Error: r.Error}
+	if r.Txn != nil {
+		e.TxnId = r.Txn.Id
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("error encoding journal entry: %v", err)
+	}
+
+	f, err := os.OpenFile(j.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing journal: %v", err)
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing journal: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error writing journal: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,266 @@
+package mango
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// managramServer is a fake of the endpoints BulkManagram uses.
+type managramServer struct {
+	balance float64
+	users   map[string]string // username to id
+	reject  map[string]bool   // ids that managrams to fail
+	lost    map[string]bool   // ids that are paid, but whose managrams get a server error
+	txns    []Txn
+	calls   []SendManagramRequest
+}
+
+func (s *managramServer) start(t *testing.T) (*Client, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v0/"), "/")
+		switch {
+		case path == "me":
+			json.NewEncoder(w).Encode(User{Id: "me", Balance: s.balance})
+		case strings.HasPrefix(path, "user/"):
+			id, ok := s.users[strings.TrimPrefix(path, "user/")]
+			if !ok {
+				http.Error(w, `{"message":"user not found"}`, http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(User{Id: id})
+		case path == "managram":
+			var req SendManagramRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			s.calls = append(s.calls, req)
+			for _, id := range req.ToIds {
+				if s.reject[id] {
+					http.Error(w, `{"message":"user not found"}`, http.StatusNotFound)
+					return
+				}
+			}
+			for _, id := range req.ToIds {
+				s.balance -= req.Amount
+				s.txns = append(s.txns, Txn{
+					Id: fmt.Sprintf("txn%d", len(s.txns)+1), CreatedTime: time.Now().UnixMilli(),
+					FromId: "me", ToId: id, Amount: req.Amount, Category: "MANA_PAYMENT",
+				})
+			}
+			for _, id := range req.ToIds {
+				if s.lost[id] {
+					http.Error(w, `{"message":"internal server error"}`, http.StatusInternalServerError)
+					return
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]string{})
+		case path == "txns":
+			if r.URL.Query().Get("fromId") != "me" || r.URL.Query().Get("category") != "MANA_PAYMENT" {
+				t.Errorf("unexpected request %v", r.URL)
+			}
+			out := []Txn{}
+			if r.URL.Query().Get("offset") == "" {
+				for i := len(s.txns) - 1; i >= 0; i-- {
+					out = append(out, s.txns[i])
+				}
+			}
+			json.NewEncoder(w).Encode(out)
+		default:
+			t.Errorf("unexpected path %v", r.URL.Path)
+		}
+	}))
+
+	mc := ClientInstance(server.Client(), &server.URL, &testKey)
+	return mc, func() {
+		mc.Destroy()
+		server.Close()
+	}
+}
+
+func TestBulkManagram(t *testing.T) {
+	s := &managramServer{balance: 1000, users: map[string]string{"alice": "a", "carol": "c"}}
+	mc, stop := s.start(t)
+	defer stop()
+
+	results, err := mc.BulkManagram([]Payment{
+		{Username: "alice", Amount: 100, Message: "1st place"},
+		{UserId: "b", Amount: 50, Message: "runner up"},
+		{Username: "carol", Amount: 50, Message: "runner up"},
+		{Username: "alice", Amount: 50, Message: "runner up"},
+	}, &BulkManagramOptions{BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.calls) != 3 {
+		t.Fatalf("expected 3 managrams, got %+v", s.calls)
+	}
+	if c := s.calls[1]; c.Amount != 50 || c.Message != "runner up" || strings.Join(c.ToIds, ",") != "b,c" {
+		t.Errorf("unexpected batch %+v", c)
+	}
+	if s.balance != 750 {
+		t.Errorf("expected balance 750, got %v", s.balance)
+	}
+
+	for i, want := range []string{"a", "b", "c", "a"} {
+		r := results[i]
+		if r.Status != PaymentSent || r.UserId != want || r.Txn == nil || r.Txn.ToId != want || r.Txn.Amount != r.Amount {
+			t.Errorf("unexpected result %d %+v", i, r)
+		}
+	}
+	if results[0].Txn.Id == results[3].Txn.Id {
+		t.Errorf("payments to the same user share transaction %v", results[0].Txn.Id)
+	}
+}
+
+func TestBulkManagramChecks(t *testing.T) {
+	tests := []struct {
+		name     string
+		balance  float64
+		payments []Payment
+		want     string
+	}{
+		{"minimum", 1000, []Payment{{UserId: "a", Amount: 5}}, "less than the minimum"},
+		{"no user", 1000, []Payment{{Amount: 50}}, "no username or user id"},
+		{"unknown user", 1000, []Payment{{Username: "dave", Amount: 50}}, "unknown user dave"},
+		{"balance", 80, []Payment{{UserId: "a", Amount: 50}, {UserId: "b", Amount: 50}}, "balance is only M80.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &managramServer{balance: tt.balance, users: map[string]string{"alice": "a"}}
+			mc, stop := s.start(t)
+			defer stop()
+
+			_, err := mc.BulkManagram(tt.payments, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+			if len(s.calls) != 0 {
+				t.Errorf("expected nothing to be sent, got %+v", s.calls)
+			}
+		})
+	}
+}
+
+func TestBulkManagramResume(t *testing.T) {
+	s := &managramServer{balance: 1000, users: map[string]string{}, reject: map[string]bool{"c": true}}
+	mc, stop := s.start(t)
+	defer stop()
+
+	journal := filepath.Join(t.TempDir(), "payroll.jsonl")
+	payments := []Payment{
+		{UserId: "a", Amount: 20, Message: "bounty"},
+		{UserId: "b", Amount: 30},
+		{UserId: "c", Amount: 30},
+		{UserId: "a", Amount: 20, Message: "bounty"},
+	}
+	opts := &BulkManagramOptions{Journal: journal}
+
+	results, err := mc.BulkManagram(payments, opts)
+	if err == nil || !strings.Contains(err.Error(), "error paying b, c") {
+		t.Fatalf("expected the second batch to fail, got %v", err)
+	}
+	if results[0].Status != PaymentSent || results[3].Status != PaymentSent || results[1].Status != PaymentFailed || results[2].Status != PaymentFailed {
+		t.Fatalf("unexpected results %+v", results)
+	}
+
+	// the failed batch is sent again, and nothing else
+	s.reject = nil
+	s.calls = nil
+	results, err = mc.BulkManagram(payments, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.calls) != 1 || strings.Join(s.calls[0].ToIds, ",") != "b,c" {
+		t.Errorf("expected only the failed batch to be sent, got %+v", s.calls)
+	}
+	for i, r := range results {
+		if r.Status != PaymentSent || r.Txn == nil {
+			t.Errorf("unexpected result %d %+v", i, r)
+		}
+	}
+
+	// a payment that may have been sent is only sent again if its transaction is missing
+	s.calls = nil
+	sent := time.Now().UnixMilli()
+	s.txns = append(s.txns, Txn{Id: "txn-d", CreatedTime: sent + 5, FromId: "me", ToId: "d", Amount: 10, Category: "MANA_PAYMENT"})
+	var lines []string
+	for _, id := range []string{"d", "e"} {
+		b, _ := json.Marshal(journalEntry{Key: fmt.Sprintf("%v 10 \"\" #0", id), UserId: id, Amount: 10, Status: paymentSending, Time: sent})
+		lines = append(lines, string(b))
+	}
+	f, err := os.OpenFile(journal, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(strings.Join(lines, "\n") + "\n")
+	f.Close()
+
+	results, err = mc.BulkManagram(append(payments, Payment{UserId: "d", Amount: 10}, Payment{UserId: "e", Amount: 10}), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.calls) != 1 || strings.Join(s.calls[0].ToIds, ",") != "e" {
+		t.Errorf("expected only e to be paid, got %+v", s.calls)
+	}
+	if r := results[4]; r.Status != PaymentSent || r.Txn == nil || r.Txn.Id != "txn-d" {
+		t.Errorf("expected d's payment to be found, got %+v", r)
+	}
+}
+
+func TestBulkManagramServerError(t *testing.T) {
+	s := &managramServer{balance: 1000, users: map[string]string{}, lost: map[string]bool{"a": true}}
+	mc, stop := s.start(t)
+	defer stop()
+
+	journal := filepath.Join(t.TempDir(), "payroll.jsonl")
+	payments := []Payment{{UserId: "a", Amount: 20}, {UserId: "b", Amount: 30}}
+	opts := &BulkManagramOptions{Journal: journal}
+
+	// the server moved the mana before failing, so the payment counts as sent
+	results, err := mc.BulkManagram(payments, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := results[0]; r.Status != PaymentSent || r.Txn == nil || r.Txn.ToId != "a" || r.Error != "" {
+		t.Errorf("expected a's payment to be found, got %+v", r)
+	}
+
+	// and isn't sent again
+	s.lost = nil
+	s.calls = nil
+	if _, err := mc.BulkManagram(payments, opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.calls) != 0 {
+		t.Errorf("expected nothing to be sent, got %+v", s.calls)
+	}
+
+	// nor is a payment journaled as failed whose transaction turns up later
+	sent := time.Now().UnixMilli()
+	s.txns = append(s.txns, Txn{Id: "txn-c", CreatedTime: sent + 5, FromId: "me", ToId: "c", Amount: 10, Category: "MANA_PAYMENT"})
+	b, _ := json.Marshal(journalEntry{Key: `c 10 "" #0`, UserId: "c", Amount: 10, Status: PaymentFailed, Time: sent, Error: "status 502"})
+	f, err := os.OpenFile(journal, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(append(b, '\n'))
+	f.Close()
+
+	results, err = mc.BulkManagram(append(payments, Payment{UserId: "c", Amount: 10}), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.calls) != 0 {
+		t.Errorf("expected nothing to be sent, got %+v", s.calls)
+	}
+	if r := results[2]; r.Status != PaymentSent || r.Txn == nil || r.Txn.Id != "txn-c" || r.Error != "" {
+		t.Errorf("expected c's payment to be found, got %+v", r)
+	}
+}
@@ -0,0 +1,438 @@
+package mangotest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/jonnyspicer/mango"
+	"github.com/jonnyspicer/mango/richtext"
+)
+
+// AddComment records a comment on a market.
+func (s *Server) AddComment(c mango.Comment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c.Id == "" {
+		c.Id = s.id("comment")
+	}
+	if c.CreatedTime == 0 {
+		c.CreatedTime = s.now()
+	}
+	s.comments = append(s.comments, &c)
+}
+
+// AddTxn records a transaction.
+func (s *Server) AddTxn(t mango.Txn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t.Id == "" {
+		t.Id = s.id("txn")
+	}
+	if t.CreatedTime == 0 {
+		t.CreatedTime = s.now()
+	}
+	if t.Token == "" {
+		t.Token = "M$"
+	}
+	s.txns = append(s.txns, &t)
+}
+
+func (s *Server) getComments(w http.ResponseWriter, r *http.Request, _ string) {
+	q := r.URL.Query()
+	limit := queryInt(r, "limit", 1000)
+	skip := queryInt(r, "page", 0) * limit
+
+	// comments are returned newest first
+	out := []mango.Comment{}
+	for i := len(s.comments) - 1; i >= 0 && len(out) < limit; i-- {
+		c := s.comments[i]
+		if id := q.Get("contractId"); id != "" && c.ContractId != id {
+			continue
+		}
+		if slug := q.Get("contractSlug"); slug != "" && c.ContractSlug != slug {
+			continue
+		}
+		if id := q.Get("userId"); id != "" && c.UserId != id {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		out = append(out, *c)
+	}
+
+	writeJSON(w, out)
+}
+
+func (s *Server) postComment(w http.ResponseWriter, r *http.Request, _ string) {
+	var req struct {
+		ContractId       string          `json:"contractId"`
+		Content          json.RawMessage `json:"content"`
+		Html             string          `json:"html"`
+		Markdown         string          `json:"markdown"`
+		ReplyToCommentId string          `json:"replyToCommentId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	m, ok := s.markets[req.ContractId]
+	if !ok {
+		writeError(w, http.StatusNotFound, "market not found")
+		return
+	}
+	if req.ReplyToCommentId != "" && s.comment(req.ReplyToCommentId) == nil {
+		writeError(w, http.StatusNotFound, "comment to reply to not found")
+		return
+	}
+
+	// content is either plain text or a rich text document
+	c := &mango.Comment{ContractId: m.Id, ContractQuestion: m.Question, ReplyToCommentId: req.ReplyToCommentId, Text: req.Markdown}
+	var doc richtext.Node
+	if err := json.Unmarshal(req.Content, &c.Text); err != nil && json.Unmarshal(req.Content, &doc) == nil {
+		c.Content, c.Text = &doc, doc.PlainText()
+	}
+	if c.Text == "" {
+		c.Text = req.Html
+	}
+	if c.Text == "" {
+		writeError(w, http.StatusBadRequest, "comment has no content")
+		return
+	}
+
+	me := s.users[s.me]
+	c.Id, c.CreatedTime = s.id("comment"), s.now()
+	c.UserId, c.UserUsername, c.UserName = me.Id, me.Username, me.Name
+	c.CommentType = "contract"
+	s.comments = append(s.comments, c)
+
+	writeJSON(w, c)
+}
+
+func (s *Server) react(w http.ResponseWriter, r *http.Request, _ string) {
+	var req mango.ReactRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c := s.comment(req.ContentId)
+	if req.ContentType != "comment" || c == nil {
+		writeError(w, http.StatusNotFound, "comment not found")
+		return
+	}
+	switch req.ReactionType {
+	case "", "like":
+	case "dislike":
+		// comments don't count dislikes
+		writeJSON(w, map[string]string{})
+		return
+	default:
+		writeError(w, http.StatusBadRequest, "unknown reaction type")
+		return
+	}
+	if req.Remove {
+		c.Likes = max(c.Likes-1, 0)
+	} else {
+		c.Likes++
+	}
+
+	writeJSON(w, map[string]string{})
+}
+
+func (s *Server) comment(id string) *mango.Comment {
+	for _, c := range s.comments {
+		if c.Id == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func (s *Server) getTxns(w http.ResponseWriter, r *http.Request, _ string) {
+	q := r.URL.Query()
+	limit := queryInt(r, "limit", 100)
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	before, _ := strconv.ParseInt(q.Get("before"), 10, 64)
+	after, _ := strconv.ParseInt(q.Get("after"), 10, 64)
+
+	// transactions are returned newest first
+	out := []mango.Txn{}
+	for i := len(s.txns) - 1; i >= 0 && len(out) < limit; i-- {
+		t := s.txns[i]
+		switch {
+		case q.Get("fromId") != "" && t.FromId != q.Get("fromId"),
+			q.Get("toId") != "" && t.ToId != q.Get("toId"),
+			q.Get("category") != "" && t.Category != q.Get("category"),
+			q.Get("token") != "" && t.Token != q.Get("token"),
+			before > 0 && t.CreatedTime >= before,
+			after > 0 && t.CreatedTime <= after:
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		out = append(out, *t)
+	}
+
+	writeJSON(w, out)
+}
+
+func (s *Server) sendManagram(w http.ResponseWriter, r *http.Request, _ string) {
+	var req mango.SendManagramRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(req.ToIds) == 0 {
+		writeError(w, http.StatusBadRequest, "no recipients")
+		return
+	}
+	if req.Amount < 10 {
+		writeError(w, http.StatusBadRequest, "minimum amount is 10")
+		return
+	}
+	for _, id := range req.ToIds {
+		if _, ok := s.users[id]; !ok || id == s.me {
+			writeError(w, http.StatusNotFound, "user "+id+" not found")
+			return
+		}
+	}
+
+	me := s.users[s.me]
+	if total := req.Amount * float64(len(req.ToIds)); total > me.Balance {
+		writeError(w, http.StatusForbidden, "insufficient balance")
+		return
+	}
+
+	// each recipient gets their own transaction
+	for _, id := range req.ToIds {
+		me.Balance -= req.Amount
+		s.users[id].Balance += req.Amount
+		s.txns = append(s.txns, &mango.Txn{
+			Id:          s.id("txn"),
+			CreatedTime: s.now(),
+			FromId:      s.me,
+			FromType:    "USER",
+			ToId:        id,
+			ToType:      "USER",
+			Amount:      req.Amount,
+			Token:       "M$",
+			Category:    "MANA_PAYMENT",
+			Description: req.Message,
+		})
+	}
+
+	writeJSON(w, map[string]string{})
+}
+
+// AddGroup adds a group that can be looked up by ID or slug.
+func (s *Server) AddGroup(g mango.Group) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if g.Id == "" {
+		g.Id = s.id("group")
+	}
+	if g.CreatedTime == 0 {
+		g.CreatedTime = s.now()
+	}
+	s.groupList = append(s.groupList, &g)
+}
+
+func (s *Server) getUsers(w http.ResponseWriter, r *http.Request, _ string) {
+	limit := queryInt(r, "limit", 1000)
+	before := r.URL.Query().Get("before")
+
+	// users are returned newest first
+	out := []mango.User{}
+	started := before == ""
+	for i := len(s.userIds) - 1; i >= 0 && len(out) < limit; i-- {
+		id := s.userIds[i]
+		if !started {
+			started = id == before
+			continue
+		}
+		out = append(out, *s.users[id])
+	}
+
+	writeJSON(w, out)
+}
+
+func (s *Server) getGroups(w http.ResponseWriter, _ *http.Request, _ string) {
+	out := []mango.Group{}
+	for _, g := range s.groupList {
+		out = append(out, *g)
+	}
+	writeJSON(w, out)
+}
+
+func (s *Server) getGroupByID(w http.ResponseWriter, _ *http.Request, id string) {
+	if g := s.group(id); g != nil {
+		writeJSON(w, g)
+		return
+	}
+	writeError(w, http.StatusNotFound, "group not found")
+}
+
+func (s *Server) getGroupBySlug(w http.ResponseWriter, _ *http.Request, slug string) {
+	for _, g := range s.groupList {
+		if g.Slug == slug {
+			writeJSON(w, g)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "group not found")
+}
+
+// AddGroupMember makes a user a member of a group, as if they had followed it.
+func (s *Server) AddGroupMember(groupId, userId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.follow(groupId, userId)
+}
+
+// AddSubtopic puts the topic with ID belowId below the one with ID aboveId in the topic
+// hierarchy.
+func (s *Server) AddSubtopic(aboveId, belowId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.below[aboveId] = append(s.below[aboveId], belowId)
+}
+
+// group returns the group with the given ID, or nil if there isn't one.
+func (s *Server) group(id string) *mango.Group {
+	for _, g := range s.groupList {
+		if g.Id == id {
+			return g
+		}
+	}
+	return nil
+}
+
+// follow makes a user a member of a group, if they aren't already.
+func (s *Server) follow(groupId, userId string) {
+	for _, m := range s.members[groupId] {
+		if m == userId {
+			return
+		}
+	}
+	s.members[groupId] = append(s.members[groupId], userId)
+	if g := s.group(groupId); g != nil {
+		g.TotalMembers++
+	}
+}
+
+func (s *Server) getGroupMembers(w http.ResponseWriter, _ *http.Request, id string) {
+	if s.group(id) == nil {
+		writeError(w, http.StatusNotFound, "group not found")
+		return
+	}
+
+	out := []mango.GroupMember{}
+	for _, userId := range s.members[id] {
+		out = append(out, mango.GroupMember{UserId: userId, GroupId: id, Role: "member"})
+	}
+	writeJSON(w, out)
+}
+
+func (s *Server) getRelatedGroups(w http.ResponseWriter, _ *http.Request, id string) {
+	if s.group(id) == nil {
+		writeError(w, http.StatusNotFound, "group not found")
+		return
+	}
+
+	out := mango.RelatedGroups{Above: []mango.Group{}, Below: []mango.Group{}}
+	for _, g := range s.groupList {
+		for _, b := range s.below[g.Id] {
+			if b == id {
+				out.Above = append(out.Above, *g)
+			}
+		}
+	}
+	for _, b := range s.below[id] {
+		if g := s.group(b); g != nil {
+			out.Below = append(out.Below, *g)
+		}
+	}
+	writeJSON(w, out)
+}
+
+func (s *Server) followTopic(w http.ResponseWriter, r *http.Request, _ string) {
+	var body struct {
+		GroupId string `json:"groupId"`
+		Follow  bool   `json:"follow"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	g := s.group(body.GroupId)
+	if g == nil {
+		writeError(w, http.StatusNotFound, "group not found")
+		return
+	}
+
+	if body.Follow {
+		s.follow(g.Id, s.me)
+	} else {
+		members := s.members[g.Id]
+		for i, m := range members {
+			if m == s.me {
+				s.members[g.Id] = append(members[:i:i], members[i+1:]...)
+				g.TotalMembers--
+				break
+			}
+		}
+	}
+
+	writeJSON(w, map[string]string{})
+}
+
+// AddPortfolioHistory adds snapshots to the portfolio history of a user, which is returned
+// whatever period is asked for.
+func (s *Server) AddPortfolioHistory(userId string, metrics ...mango.PortfolioMetrics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.history[userId] = append(s.history[userId], metrics...)
+}
+
+func (s *Server) getUserPortfolio(w http.ResponseWriter, r *http.Request, _ string) {
+	userId := r.URL.Query().Get("userId")
+	u, ok := s.users[userId]
+	if !ok {
+		writeError(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	lpm := mango.LivePortfolioMetrics{
+		UserId:        u.Id,
+		Balance:       u.Balance,
+		TotalDeposits: u.TotalDeposits,
+		Timestamp:     s.now(),
+	}
+	for _, id := range s.order {
+		if m := s.markets[id]; !m.IsResolved {
+			lpm.InvestmentValue += s.metric(m, userId).Payout
+		}
+	}
+	if u.TotalDeposits > 0 {
+		lpm.Profit = lpm.Balance + lpm.InvestmentValue - u.TotalDeposits
+	}
+
+	writeJSON(w, lpm)
+}
+
+func (s *Server) getUserPortfolioHistory(w http.ResponseWriter, r *http.Request, _ string) {
+	out := append([]mango.PortfolioMetrics{}, s.history[r.URL.Query().Get("userId")]...)
+	writeJSON(w, out)
+}
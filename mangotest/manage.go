@@ -0,0 +1,438 @@
+package mangotest
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jonnyspicer/mango"
+)
+
+// MarketGroups returns the IDs of the groups a market has been added to.
+func (s *Server) MarketGroups(id string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.groups[id]...)
+}
+
+// createMarket creates a market owned by the authenticated user. Binary markets start with
+// a pool of M100 on each side, weighted to the initial probability. Creation is free.
+func (s *Server) createMarket(w http.ResponseWriter, r *http.Request, _ string) {
+	var pmr mango.PostMarketRequest
+	if err := json.NewDecoder(r.Body).Decode(&pmr); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if pmr.Question == "" || pmr.OutcomeType == "" {
+		writeError(w, http.StatusBadRequest, "question and outcomeType are required")
+		return
+	}
+
+	me := s.users[s.me]
+	m := &mango.FullMarket{
+		Id:              s.id("market"),
+		CreatorId:       me.Id,
+		CreatorUsername: me.Username,
+		CreatorName:     me.Name,
+		CreatedTime:     s.now(),
+		CloseTime:       pmr.CloseTime,
+		Question:        pmr.Question,
+		Url:             "https://manifold.markets/" + me.Username + "/" + slugify(pmr.Question),
+		OutcomeType:     pmr.OutcomeType,
+		TotalLiquidity:  100,
+		TextDescription: pmr.Description + pmr.DescriptionMarkdown,
+	}
+	m.LastUpdatedTime = m.CreatedTime
+
+	switch pmr.OutcomeType {
+	case mango.Binary:
+		p := 0.5
+		if pmr.InitialProb > 0 {
+			p = float64(pmr.InitialProb) / 100
+		}
+		m.Mechanism = "cpmm-1"
+		m.Pool = mango.Pool{"YES": 100, "NO": 100}
+		m.P = p
+		m.Probability = p
+	case mango.MultipleChoice, mango.FreeResponse:
+		for i, text := range pmr.Answers {
+			m.Answers = append(m.Answers, mango.Answer{
+				Id:          s.id("answer"),
+				ContractId:  m.Id,
+				Number:      int64(i),
+				Text:        text,
+				Probability: 1 / float64(len(pmr.Answers)),
+				PoolYes:     100 * float64(len(pmr.Answers)-1),
+				PoolNo:      100,
+				CreatedTime: m.CreatedTime,
+			})
+		}
+	}
+
+	s.markets[m.Id] = m
+	s.order = append(s.order, m.Id)
+	if pmr.GroupId != "" {
+		s.addToGroup(m, pmr.GroupId)
+	}
+
+	writeJSON(w, map[string]string{"id": m.Id})
+}
+
+// slugify turns a question into a URL slug, as Manifold does.
+func slugify(q string) string {
+	var b strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(q) {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			b.WriteRune(c)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// ownMarket returns the market with the given ID if it was created by the authenticated user,
+// writing an error otherwise.
+func (s *Server) ownMarket(w http.ResponseWriter, id string) (*mango.FullMarket, bool) {
+	m, ok := s.markets[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "market not found")
+		return nil, false
+	}
+	if m.CreatorId != s.me {
+		writeError(w, http.StatusForbidden, "only the creator can change this market")
+		return nil, false
+	}
+	return m, true
+}
+
+func (s *Server) addMarketToGroup(w http.ResponseWriter, r *http.Request, id string) {
+	var body struct {
+		GroupId string `json:"groupId"`
+		Remove  bool   `json:"remove"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.GroupId == "" {
+		writeError(w, http.StatusBadRequest, "groupId is required")
+		return
+	}
+	m, ok := s.ownMarket(w, id)
+	if !ok {
+		return
+	}
+
+	if body.Remove {
+		s.removeFromGroup(m, body.GroupId)
+		writeJSON(w, map[string]string{})
+		return
+	}
+
+	for _, g := range s.groups[id] {
+		if g == body.GroupId {
+			writeJSON(w, map[string]string{})
+			return
+		}
+	}
+	s.addToGroup(m, body.GroupId)
+
+	writeJSON(w, map[string]string{})
+}
+
+// addToGroup records that a market is in a group, and adds the group's slug to the market
+// if the group is known.
+func (s *Server) addToGroup(m *mango.FullMarket, groupId string) {
+	s.groups[m.Id] = append(s.groups[m.Id], groupId)
+
+	for _, g := range s.groupList {
+		if g.Id == groupId {
+			m.GroupSlugs = append(m.GroupSlugs, g.Slug)
+			return
+		}
+	}
+}
+
+// removeFromGroup undoes addToGroup.
+func (s *Server) removeFromGroup(m *mango.FullMarket, groupId string) {
+	ids := s.groups[m.Id][:0]
+	for _, g := range s.groups[m.Id] {
+		if g != groupId {
+			ids = append(ids, g)
+		}
+	}
+	s.groups[m.Id] = ids
+
+	if g := s.group(groupId); g != nil {
+		slugs := m.GroupSlugs[:0]
+		for _, slug := range m.GroupSlugs {
+			if slug != g.Slug {
+				slugs = append(slugs, slug)
+			}
+		}
+		m.GroupSlugs = slugs
+	}
+}
+
+func (s *Server) addLiquidity(w http.ResponseWriter, r *http.Request, id string) {
+	var body struct {
+		Amount float64 `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	m, ok := s.markets[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "market not found")
+		return
+	}
+
+	me := s.users[s.me]
+	if body.Amount <= 0 || body.Amount > me.Balance {
+		writeError(w, http.StatusForbidden, "insufficient balance")
+		return
+	}
+
+	me.Balance -= body.Amount
+	m.TotalLiquidity += body.Amount
+	s.txns = append(s.txns, &mango.Txn{
+		Id: s.id("txn"), CreatedTime: s.now(), FromId: s.me, FromType: "USER", ToId: m.Id, ToType: "CONTRACT",
+		Amount: body.Amount, Token: "M$", Category: "ADD_SUBSIDY",
+	})
+
+	writeJSON(w, map[string]string{})
+}
+
+func (s *Server) addBounty(w http.ResponseWriter, r *http.Request, id string) {
+	var body struct {
+		Amount float64 `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	m, ok := s.markets[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "market not found")
+		return
+	}
+	if m.OutcomeType != mango.BountiedQuestion {
+		writeError(w, http.StatusBadRequest, "market is not a bountied question")
+		return
+	}
+
+	me := s.users[s.me]
+	if body.Amount <= 0 || body.Amount > me.Balance {
+		writeError(w, http.StatusForbidden, "insufficient balance")
+		return
+	}
+
+	me.Balance -= body.Amount
+	m.TotalBounty += body.Amount
+	m.BountyLeft += body.Amount
+	s.txns = append(s.txns, &mango.Txn{
+		Id: s.id("txn"), CreatedTime: s.now(), FromId: s.me, FromType: "USER", ToId: m.Id, ToType: "CONTRACT",
+		Amount: body.Amount, Token: "M$", Category: "BOUNTY_ADDED",
+	})
+
+	writeJSON(w, map[string]string{})
+}
+
+func (s *Server) awardBounty(w http.ResponseWriter, r *http.Request, id string) {
+	var body struct {
+		Amount    float64 `json:"amount"`
+		CommentId string  `json:"commentId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	m, ok := s.ownMarket(w, id)
+	if !ok {
+		return
+	}
+	if m.OutcomeType != mango.BountiedQuestion {
+		writeError(w, http.StatusBadRequest, "market is not a bountied question")
+		return
+	}
+	if body.Amount <= 0 || body.Amount > m.BountyLeft {
+		writeError(w, http.StatusBadRequest, "amount is more than the bounty left")
+		return
+	}
+
+	c := s.comment(body.CommentId)
+	if c == nil || c.ContractId != m.Id {
+		writeError(w, http.StatusNotFound, "comment not found")
+		return
+	}
+
+	m.BountyLeft -= body.Amount
+	c.BountyAwarded += body.Amount
+	if u, ok := s.users[c.UserId]; ok {
+		u.Balance += body.Amount
+	}
+	s.txns = append(s.txns, &mango.Txn{
+		Id: s.id("txn"), CreatedTime: s.now(), FromId: m.Id, FromType: "CONTRACT", ToId: c.UserId, ToType: "USER",
+		Amount: body.Amount, Token: "M$", Category: "BOUNTY_AWARDED",
+	})
+
+	writeJSON(w, map[string]string{})
+}
+
+func (s *Server) closeMarket(w http.ResponseWriter, r *http.Request, id string) {
+	var body struct {
+		CloseTime int64 `json:"closeTime"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	m, ok := s.ownMarket(w, id)
+	if !ok {
+		return
+	}
+
+	if body.CloseTime == 0 {
+		body.CloseTime = time.Now().UnixMilli()
+	}
+	m.CloseTime = body.CloseTime
+	m.LastUpdatedTime = s.now()
+
+	writeJSON(w, map[string]string{})
+}
+
+// resolveMarket resolves a market. Binary markets pay out to shareholders and cancel open limit orders.
+func (s *Server) resolveMarket(w http.ResponseWriter, r *http.Request, id string) {
+	var rmr mango.ResolveMarketRequest
+	if err := json.NewDecoder(r.Body).Decode(&rmr); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	m, ok := s.ownMarket(w, id)
+	if !ok {
+		return
+	}
+	if m.IsResolved {
+		writeError(w, http.StatusBadRequest, "market is already resolved")
+		return
+	}
+
+	var yes float64
+	switch rmr.Outcome {
+	case "YES":
+		yes = 1
+	case "NO":
+		yes = 0
+	case "MKT":
+		yes = m.Probability
+		if rmr.ProbabilityInt > 0 {
+			yes = float64(rmr.ProbabilityInt) / 100
+		}
+	case "CANCEL":
+	default:
+		if m.OutcomeType == mango.Binary {
+			writeError(w, http.StatusBadRequest, "invalid outcome "+strconv.Quote(rmr.Outcome))
+			return
+		}
+	}
+
+	now := s.now()
+	m.IsResolved = true
+	m.Resolution = rmr.Outcome
+	m.ResolutionTime = now
+	m.ResolutionProbability = yes
+	m.LastUpdatedTime = now
+	if m.CloseTime == 0 || m.CloseTime > now {
+		m.CloseTime = now
+	}
+
+	if m.OutcomeType == mango.Binary {
+		for _, b := range s.bets {
+			// cancelled orders still pay out for the part of them that was filled
+			if b.ContractId != id {
+				continue
+			}
+
+			u, ok := s.users[b.UserId]
+			if !ok {
+				continue
+			}
+
+			if b.OrderAmount > 0 && !b.IsFilled && !b.IsCancelled && b.UserId == s.me {
+				u.Balance += b.OrderAmount - b.Amount
+				b.IsCancelled = true
+			}
+
+			switch {
+			case rmr.Outcome == "CANCEL":
+				u.Balance += b.Amount
+			case b.Outcome == "YES":
+				u.Balance += b.Shares * yes
+			case b.Outcome == "NO":
+				u.Balance += b.Shares * (1 - yes)
+			}
+		}
+	}
+
+	writeJSON(w, map[string]string{})
+}
+
+func (s *Server) searchMarkets(w http.ResponseWriter, r *http.Request, _ string) {
+	q := r.URL.Query()
+	term := strings.ToLower(q.Get("term"))
+	limit := queryInt(r, "limit", 100)
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	now := time.Now().UnixMilli()
+
+	out := []mango.FullMarket{}
+	for i := len(s.order) - 1; i >= 0 && len(out) < limit; i-- {
+		m := s.markets[s.order[i]]
+		if term != "" && !strings.Contains(strings.ToLower(m.Question), term) {
+			continue
+		}
+		if c := q.Get("creatorId"); c != "" && m.CreatorId != c {
+			continue
+		}
+		if t := q.Get("contractType"); t != "" && t != "ALL" && string(m.OutcomeType) != t {
+			continue
+		}
+		if t := q.Get("topicSlug"); t != "" && !slices.Contains(m.GroupSlugs, t) {
+			continue
+		}
+
+		closed := m.CloseTime != 0 && m.CloseTime < now
+		switch q.Get("filter") {
+		case "open":
+			if closed || m.IsResolved {
+				continue
+			}
+		case "closed":
+			if !closed || m.IsResolved {
+				continue
+			}
+		case "resolved":
+			if !m.IsResolved {
+				continue
+			}
+		}
+
+		if offset > 0 {
+			offset--
+			continue
+		}
+		out = append(out, *m)
+	}
+
+	writeJSON(w, out)
+}
@@ -0,0 +1,144 @@
+package mangotest
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/jonnyspicer/mango"
+)
+
+// TraderId is the ID of the user that places the bets made with [Server.Trade].
+const TraderId = "user-trader"
+
+// Trade places a market order from another user, with unlimited mana, so that tests can
+// fill the authenticated user's limit orders.
+//
+// As on Manifold, the order trades against the market's pool until the probability
+// reaches the limit of an open order on the other side, fills as much of that order as
+// it can at its limit, and carries on until the whole amount is spent. Each order that is
+// filled gets a [mango.Fill] matched to the returned bet.
+func (s *Server) Trade(marketId, outcome string, amount float64) (mango.Bet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.markets[marketId]
+	if !ok {
+		return mango.Bet{}, fmt.Errorf("market %v not found", marketId)
+	}
+	if outcome != "YES" && outcome != "NO" {
+		return mango.Bet{}, fmt.Errorf("outcome must be YES or NO, got %q", outcome)
+	}
+	if amount <= 0 {
+		return mango.Bet{}, fmt.Errorf("amount must be positive, got %v", amount)
+	}
+
+	if _, ok := s.users[TraderId]; !ok {
+		s.users[TraderId] = &mango.User{Id: TraderId, Username: "trader", Name: "Trader", CreatedTime: s.now()}
+		s.userIds = append(s.userIds, TraderId)
+	}
+	trader := s.users[TraderId]
+
+	bk, ok := s.book(m, "")
+	if !ok || len(bk.pool) == 0 {
+		return mango.Bet{}, fmt.Errorf("market %v can't be traded on", marketId)
+	}
+
+	b := &mango.Bet{
+		Id:           s.id("bet"),
+		ContractId:   m.Id,
+		Outcome:      outcome,
+		UserId:       trader.Id,
+		UserUsername: trader.Username,
+		UserName:     trader.Name,
+		CreatedTime:  s.now(),
+		ProbBefore:   bk.prob,
+		IsFilled:     true,
+	}
+
+	remaining := amount
+	for remaining > 1e-9 {
+		bk, _ = s.book(m, "")
+		order := s.nextOrder(m.Id, outcome, bk.prob)
+
+		// trade against the pool up to the order's limit, or with everything left if
+		// there is no order to reach
+		fill := remaining
+		if order != nil {
+			fill = amountToLimit(bk, outcome, order.LimitProb, remaining)
+		}
+		if fill > 1e-9 {
+			sim, err := mango.SimulateBet(bk.pool, bk.p, outcome, fill)
+			if err != nil {
+				return mango.Bet{}, err
+			}
+			b.Shares += sim.Shares
+			b.Fees.CreatorFee += sim.Fees.CreatorFee
+			b.Fees.LiquidityFee += sim.Fees.LiquidityFee
+			b.Fees.PlatformFee += sim.Fees.PlatformFee
+			b.Fills = append(b.Fills, mango.Fill{Amount: fill, Shares: sim.Shares, Timestamp: b.CreatedTime})
+			bk.set(sim.Pool, sim.ProbAfter)
+			remaining -= fill
+		}
+		if order == nil || remaining <= 1e-9 {
+			break
+		}
+
+		// then match the order at its limit, where each share costs the taker the
+		// probability of their outcome and the maker the rest
+		price := order.LimitProb
+		if outcome == "NO" {
+			price = 1 - order.LimitProb
+		}
+		shares := math.Min(remaining/price, (order.OrderAmount-order.Amount)/(1-price))
+
+		takerId, makerId := b.Id, order.Id
+		order.Amount += shares * (1 - price)
+		order.Shares += shares
+		order.Fills = append(order.Fills, mango.Fill{MatchedBetId: &takerId, Amount: shares * (1 - price), Shares: shares, Timestamp: b.CreatedTime})
+		order.IsFilled = order.OrderAmount-order.Amount < 1e-9
+
+		b.Shares += shares
+		b.Fills = append(b.Fills, mango.Fill{MatchedBetId: &makerId, Amount: shares * price, Shares: shares, Timestamp: b.CreatedTime})
+		remaining -= shares * price
+	}
+
+	bk, _ = s.book(m, "")
+	b.Amount = amount - math.Max(remaining, 0)
+	b.ProbAfter = bk.prob
+	m.Volume += b.Amount
+	m.LastUpdatedTime = b.CreatedTime
+
+	s.bets = append(s.bets, b)
+
+	return *b, nil
+}
+
+// nextOrder returns the open limit order in a market that a bet on outcome would reach
+// first from prob, or nil if there are none.
+func (s *Server) nextOrder(marketId, outcome string, prob float64) *mango.Bet {
+	var next *mango.Bet
+	for _, b := range s.bets {
+		if b.ContractId != marketId || b.AnswerId != "" || b.Outcome == outcome || b.OrderAmount == 0 || b.IsFilled || b.IsCancelled {
+			continue
+		}
+
+		// a YES bet raises the probability towards NO orders above it, and a NO bet
+		// lowers it towards YES orders below it
+		if outcome == "YES" {
+			if b.LimitProb < prob-1e-9 {
+				continue
+			}
+			if next == nil || b.LimitProb < next.LimitProb {
+				next = b
+			}
+		} else {
+			if b.LimitProb > prob+1e-9 {
+				continue
+			}
+			if next == nil || b.LimitProb > next.LimitProb {
+				next = b
+			}
+		}
+	}
+	return next
+}
@@ -0,0 +1,820 @@
+// Package mangotest provides an in-memory fake of the Manifold API for testing
+// programs built on mango without touching real markets or mana.
+//
+// The fake implements the read endpoints for markets, bets, comments,
+// transactions, users, groups and positions, lets the authenticated user create,
+// close and resolve markets, move them between groups, follow topics, comment,
+// award bounties and send managrams, and buys and sells shares in CPMM markets
+// and their answers using [mango.SimulateBet] and [mango.SimulateSale], so prices
+// move as they would on Manifold. Limit orders wait in the order book until
+// [Server.Trade] fills them with bets from another user:
+//
+//	s := mangotest.NewServer()
+//	defer s.Close()
+//
+//	s.AddMarket(mango.FullMarket{Id: "m1", Question: "Will it rain?", Pool: mango.Pool{"YES": 100, "NO": 100}, P: 0.5})
+//
+//	mc := s.Client()
+package mangotest
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+)
+
+// APIKey is the key the fake server expects on authenticated requests.
+const APIKey = "mangotest-api-key"
+
+// Server is a fake Manifold API server.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	me        string
+	users     map[string]*mango.User
+	userIds   []string // user IDs in the order they were added
+	markets   map[string]*mango.FullMarket
+	order     []string // market IDs in the order they were added
+	bets      []*mango.Bet
+	groups    map[string][]string // market ID to group IDs
+	groupList []*mango.Group
+	members   map[string][]string // group ID to member user IDs
+	below     map[string][]string // group ID to the IDs of the topics below it
+	comments  []*mango.Comment
+	txns      []*mango.Txn
+	history   map[string][]mango.PortfolioMetrics // user ID to portfolio history
+	nextId    int
+	lastTime  int64
+}
+
+// NewServer starts a fake server with a single authenticated user who has a balance of M1000.
+func NewServer() *Server {
+	s := &Server{
+		users:   map[string]*mango.User{},
+		markets: map[string]*mango.FullMarket{},
+		groups:  map[string][]string{},
+		members: map[string][]string{},
+		below:   map[string][]string{},
+		history: map[string][]mango.PortfolioMetrics{},
+	}
+
+	s.me = "user-me"
+	s.users[s.me] = &mango.User{Id: s.me, Username: "me", Name: "Me", Balance: 1000, CreatedTime: s.now()}
+	s.userIds = append(s.userIds, s.me)
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+
+	return s
+}
+
+// Client returns a new [mango.Client] that sends its requests to the fake server.
+func (s *Server) Client(opts ...mango.ClientOption) *mango.Client {
+	url := s.URL
+	key := APIKey
+	return mango.NewClient(s.Server.Client(), &url, &key, opts...)
+}
+
+// Me returns the authenticated user.
+func (s *Server) Me() mango.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return *s.users[s.me]
+}
+
+// SetBalance sets the balance of the authenticated user.
+func (s *Server) SetBalance(b float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[s.me].Balance = b
+}
+
+// AddUser adds a user that can be looked up by ID or username.
+func (s *Server) AddUser(u mango.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u.CreatedTime == 0 {
+		u.CreatedTime = s.now()
+	}
+	if _, ok := s.users[u.Id]; !ok {
+		s.userIds = append(s.userIds, u.Id)
+	}
+	s.users[u.Id] = &u
+}
+
+// UpdateMarket changes a market in place, such as to change its close time or volume,
+// and marks it as updated.
+func (s *Server) UpdateMarket(id string, update func(m *mango.FullMarket)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.markets[id]
+	if !ok {
+		return false
+	}
+	update(m)
+	m.LastUpdatedTime = s.now()
+	return true
+}
+
+// AddMarket adds a market. Binary markets need a Pool and P to be traded on,
+// and their Probability is derived from them.
+func (s *Server) AddMarket(m mango.FullMarket) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m.OutcomeType == "" {
+		m.OutcomeType = mango.Binary
+	}
+	if m.Mechanism == "" && m.P > 0 {
+		m.Mechanism = "cpmm-1"
+	}
+	if m.P > 0 && len(m.Pool) > 0 {
+		m.Probability = mango.CPMMProbability(m.Pool, m.P)
+	}
+	for i, a := range m.Answers {
+		if a.PoolYes > 0 && a.PoolNo > 0 {
+			m.Answers[i].Probability = mango.CPMMProbability(mango.Pool{"YES": a.PoolYes, "NO": a.PoolNo}, 0.5)
+		}
+	}
+	if m.CreatedTime == 0 {
+		m.CreatedTime = s.now()
+	}
+	m.LastUpdatedTime = m.CreatedTime
+
+	if _, ok := s.markets[m.Id]; !ok {
+		s.order = append(s.order, m.Id)
+	}
+	s.markets[m.Id] = &m
+}
+
+// Market returns the current state of the market with the given ID.
+func (s *Server) Market(id string) (mango.FullMarket, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.markets[id]
+	if !ok {
+		return mango.FullMarket{}, false
+	}
+	return *m, true
+}
+
+// AddBet records a bet made by another user, such as historic trading activity.
+// The market's pool is not changed.
+func (s *Server) AddBet(b mango.Bet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b.Id == "" {
+		b.Id = s.id("bet")
+	}
+	if b.CreatedTime == 0 {
+		b.CreatedTime = s.now()
+	}
+	s.bets = append(s.bets, &b)
+}
+
+// Bets returns every bet that has been made, oldest first.
+func (s *Server) Bets() []mango.Bet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]mango.Bet, len(s.bets))
+	for i, b := range s.bets {
+		out[i] = *b
+	}
+	return out
+}
+
+// now returns a strictly increasing timestamp in milliseconds, so bets are always ordered.
+func (s *Server) now() int64 {
+	t := time.Now().UnixMilli()
+	if t <= s.lastTime {
+		t = s.lastTime + 1
+	}
+	s.lastTime = t
+	return t
+}
+
+func (s *Server) id(prefix string) string {
+	s.nextId++
+	return fmt.Sprintf("%v-%d", prefix, s.nextId)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/v0/")
+
+	if r.Method == http.MethodPost && r.Header.Get("Authorization") != "Key "+APIKey {
+		writeError(w, http.StatusUnauthorized, "invalid API key")
+		return
+	}
+
+	for _, route := range routes {
+		if route.method != r.Method {
+			continue
+		}
+		if arg, ok := match(route.pattern, path); ok {
+			route.handler(s, w, r, arg)
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "no such endpoint: "+r.Method+" "+r.URL.Path)
+}
+
+type route struct {
+	method  string
+	pattern string // "*" matches a single path segment
+	handler func(s *Server, w http.ResponseWriter, r *http.Request, arg string)
+}
+
+var routes = []route{
+	{http.MethodGet, "me", (*Server).getMe},
+	{http.MethodGet, "user/by-id/*", (*Server).getUserByID},
+	{http.MethodGet, "user/*", (*Server).getUserByUsername},
+	{http.MethodGet, "market/*/prob", (*Server).getMarketProb},
+	{http.MethodGet, "market/*/positions", (*Server).getPositions},
+	{http.MethodGet, "market/*", (*Server).getMarket},
+	{http.MethodGet, "slug/*", (*Server).getMarketBySlug},
+	{http.MethodGet, "markets", (*Server).getMarkets},
+	{http.MethodGet, "market-probs", (*Server).getMarketProbs},
+	{http.MethodGet, "bets", (*Server).getBets},
+	{http.MethodGet, "get-user-contract-metrics-with-contracts", (*Server).getUserContractMetrics},
+	{http.MethodGet, "get-user-portfolio", (*Server).getUserPortfolio},
+	{http.MethodGet, "get-user-portfolio-history", (*Server).getUserPortfolioHistory},
+	{http.MethodGet, "search-markets", (*Server).searchMarkets},
+	{http.MethodGet, "comments", (*Server).getComments},
+	{http.MethodGet, "users", (*Server).getUsers},
+	{http.MethodGet, "groups", (*Server).getGroups},
+	{http.MethodGet, "group/by-id/*", (*Server).getGroupByID},
+	{http.MethodGet, "group/by-id/*/members", (*Server).getGroupMembers},
+	{http.MethodGet, "group/by-id/*/groups", (*Server).getRelatedGroups},
+	{http.MethodGet, "group/*", (*Server).getGroupBySlug},
+	{http.MethodGet, "txns", (*Server).getTxns},
+	{http.MethodPost, "market", (*Server).createMarket},
+	{http.MethodPost, "market/*/group", (*Server).addMarketToGroup},
+	{http.MethodPost, "market/*/liquidity", (*Server).addLiquidity},
+	{http.MethodPost, "market/*/close", (*Server).closeMarket},
+	{http.MethodPost, "market/*/resolve", (*Server).resolveMarket},
+	{http.MethodPost, "market/*/sell", (*Server).sellShares},
+	{http.MethodPost, "market/*/add-bounty", (*Server).addBounty},
+	{http.MethodPost, "market/*/award-bounty", (*Server).awardBounty},
+	{http.MethodPost, "bet", (*Server).postBet},
+	{http.MethodPost, "bet/cancel/*", (*Server).cancelBet},
+	{http.MethodPost, "managram", (*Server).sendManagram},
+	{http.MethodPost, "comment", (*Server).postComment},
+	{http.MethodPost, "react", (*Server).react},
+	{http.MethodPost, "follow-topic", (*Server).followTopic},
+}
+
+// match reports whether path matches pattern, returning the segment matched by "*".
+func match(pattern, path string) (string, bool) {
+	ps := strings.Split(pattern, "/")
+	xs := strings.Split(strings.Trim(path, "/"), "/")
+	if len(ps) != len(xs) {
+		return "", false
+	}
+
+	var arg string
+	for i := range ps {
+		switch {
+		case ps[i] == "*" && xs[i] != "":
+			arg = xs[i]
+		case ps[i] != xs[i]:
+			return "", false
+		}
+	}
+
+	return arg, true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": msg})
+}
+
+func (s *Server) getMe(w http.ResponseWriter, r *http.Request, _ string) {
+	if r.Header.Get("Authorization") != "Key "+APIKey {
+		writeError(w, http.StatusUnauthorized, "invalid API key")
+		return
+	}
+	writeJSON(w, s.users[s.me])
+}
+
+func (s *Server) getUserByID(w http.ResponseWriter, _ *http.Request, id string) {
+	u, ok := s.users[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	writeJSON(w, u)
+}
+
+func (s *Server) getUserByUsername(w http.ResponseWriter, _ *http.Request, username string) {
+	for _, u := range s.users {
+		if u.Username == username {
+			writeJSON(w, u)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "user not found")
+}
+
+func (s *Server) getMarket(w http.ResponseWriter, _ *http.Request, id string) {
+	m, ok := s.markets[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "market not found")
+		return
+	}
+	writeJSON(w, m)
+}
+
+func (s *Server) getMarketBySlug(w http.ResponseWriter, _ *http.Request, slug string) {
+	for _, id := range s.order {
+		if m := s.markets[id]; slugOf(m) == slug {
+			writeJSON(w, m)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "market not found")
+}
+
+// slugOf returns the slug of a market, taken from the end of its URL.
+func slugOf(m *mango.FullMarket) string {
+	return m.Url[strings.LastIndex(m.Url, "/")+1:]
+}
+
+func (s *Server) getMarkets(w http.ResponseWriter, r *http.Request, _ string) {
+	limit := queryInt(r, "limit", 1000)
+	before := r.URL.Query().Get("before")
+
+	// markets are returned newest first, or most recently updated first
+	ids := s.order
+	switch sort := r.URL.Query().Get("sort"); sort {
+	case "", "created-time":
+	case "updated-time":
+		ids = append([]string(nil), s.order...)
+		slices.SortStableFunc(ids, func(a, b string) int {
+			return cmp.Compare(s.markets[a].LastUpdatedTime, s.markets[b].LastUpdatedTime)
+		})
+	default:
+		writeError(w, http.StatusBadRequest, "unsupported sort "+sort)
+		return
+	}
+
+	out := []mango.FullMarket{}
+	started := before == ""
+	for i := len(ids) - 1; i >= 0 && len(out) < limit; i-- {
+		id := ids[i]
+		if !started {
+			started = id == before
+			continue
+		}
+		out = append(out, *s.markets[id])
+	}
+
+	writeJSON(w, out)
+}
+
+func (s *Server) getMarketProb(w http.ResponseWriter, _ *http.Request, id string) {
+	m, ok := s.markets[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "market not found")
+		return
+	}
+	writeJSON(w, marketProb(m))
+}
+
+func (s *Server) getMarketProbs(w http.ResponseWriter, r *http.Request, _ string) {
+	out := map[string]mango.MarketProb{}
+	for _, id := range r.URL.Query()["ids"] {
+		if m, ok := s.markets[id]; ok {
+			out[id] = marketProb(m)
+		}
+	}
+	writeJSON(w, out)
+}
+
+func marketProb(m *mango.FullMarket) mango.MarketProb {
+	if len(m.Answers) == 0 {
+		return mango.MarketProb{Prob: m.Probability}
+	}
+
+	probs := map[string]float64{}
+	for _, a := range m.Answers {
+		probs[a.Id] = a.Probability
+	}
+	return mango.MarketProb{AnswerProbs: probs}
+}
+
+func (s *Server) getBets(w http.ResponseWriter, r *http.Request, _ string) {
+	q := r.URL.Query()
+	limit := queryInt(r, "limit", 1000)
+	before := q.Get("before")
+
+	// bets are returned newest first
+	out := []mango.Bet{}
+	started := before == ""
+	for i := len(s.bets) - 1; i >= 0 && len(out) < limit; i-- {
+		b := s.bets[i]
+		if !started {
+			started = b.Id == before
+			continue
+		}
+		if c := q.Get("contractId"); c != "" && b.ContractId != c {
+			continue
+		}
+		if u := q.Get("userId"); u != "" && b.UserId != u {
+			continue
+		}
+		if u := q.Get("username"); u != "" && b.UserUsername != u {
+			continue
+		}
+		out = append(out, *b)
+	}
+
+	writeJSON(w, out)
+}
+
+func (s *Server) getPositions(w http.ResponseWriter, r *http.Request, id string) {
+	m, ok := s.markets[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "market not found")
+		return
+	}
+
+	userId := r.URL.Query().Get("userId")
+
+	var users []string
+	seen := map[string]bool{}
+	for _, b := range s.bets {
+		if b.ContractId == id && !seen[b.UserId] && (userId == "" || b.UserId == userId) {
+			seen[b.UserId] = true
+			users = append(users, b.UserId)
+		}
+	}
+
+	out := []mango.ContractMetric{}
+	for _, u := range users {
+		out = append(out, s.metric(m, u))
+	}
+
+	writeJSON(w, out)
+}
+
+func (s *Server) getUserContractMetrics(w http.ResponseWriter, r *http.Request, _ string) {
+	userId := r.URL.Query().Get("userId")
+
+	resp := mango.UserContractMetricsResponse{
+		MetricsByContract: map[string][]mango.ContractMetric{},
+		Contracts:         []mango.FullMarket{},
+	}
+
+	for _, id := range s.order {
+		m := s.markets[id]
+		cm := s.metric(m, userId)
+		if !cm.HasShares && cm.Invested == 0 {
+			continue
+		}
+		resp.MetricsByContract[id] = []mango.ContractMetric{cm}
+		resp.Contracts = append(resp.Contracts, *m)
+	}
+
+	writeJSON(w, resp)
+}
+
+// metric calculates a user's position in a market from their bets.
+func (s *Server) metric(m *mango.FullMarket, userId string) mango.ContractMetric {
+	cm := mango.ContractMetric{
+		ContractId:  m.Id,
+		UserId:      userId,
+		TotalShares: map[string]float64{},
+	}
+
+	for _, b := range s.bets {
+		// a cancelled limit order keeps the shares it was filled with
+		if b.ContractId != m.Id || b.UserId != userId {
+			continue
+		}
+		cm.TotalShares[b.Outcome] += b.Shares
+		cm.Invested += b.Amount
+		cm.LastBetTime = b.CreatedTime
+		if u, ok := s.users[userId]; ok {
+			cm.UserUsername = u.Username
+			cm.UserName = u.Name
+		}
+	}
+
+	yes, no := cm.TotalShares["YES"], cm.TotalShares["NO"]
+	cm.HasYesShares = yes > 1e-9
+	cm.HasNoShares = no > 1e-9
+	cm.HasShares = cm.HasYesShares || cm.HasNoShares
+	if yes >= no {
+		cm.MaxShares = "YES"
+	} else {
+		cm.MaxShares = "NO"
+	}
+
+	p := m.Probability
+	if m.IsResolved {
+		p = m.ResolutionProbability
+	}
+
+	if m.Resolution == "CANCEL" {
+		cm.Payout = cm.Invested
+	} else {
+		cm.Payout = yes*p + no*(1-p)
+	}
+	cm.Profit = cm.Payout - cm.Invested
+	if cm.Invested > 0 {
+		cm.ProfitPercent = cm.Profit / cm.Invested * 100
+	}
+
+	return cm
+}
+
+func (s *Server) postBet(w http.ResponseWriter, r *http.Request, _ string) {
+	var pbr mango.PostBetRequest
+	if err := json.NewDecoder(r.Body).Decode(&pbr); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	m, ok := s.markets[pbr.ContractId]
+	if !ok {
+		writeError(w, http.StatusNotFound, "market not found")
+		return
+	}
+	if m.IsResolved || (m.CloseTime != 0 && m.CloseTime <= time.Now().UnixMilli()) {
+		writeError(w, http.StatusForbidden, "market is closed")
+		return
+	}
+	if pbr.Outcome != "YES" && pbr.Outcome != "NO" {
+		writeError(w, http.StatusBadRequest, "outcome must be YES or NO")
+		return
+	}
+
+	bk, ok := s.book(m, pbr.AnswerId)
+	if !ok {
+		writeError(w, http.StatusNotFound, "answer not found")
+		return
+	}
+
+	me := s.users[s.me]
+	if pbr.Amount <= 0 || pbr.Amount > me.Balance {
+		writeError(w, http.StatusForbidden, "insufficient balance")
+		return
+	}
+
+	// a limit order only fills until the probability reaches the limit,
+	// and the rest of it waits in the order book
+	fill := pbr.Amount
+	if pbr.LimitProb != nil {
+		fill = amountToLimit(bk, pbr.Outcome, *pbr.LimitProb, pbr.Amount)
+	}
+
+	b := &mango.Bet{
+		Id:           s.id("bet"),
+		ContractId:   m.Id,
+		AnswerId:     pbr.AnswerId,
+		Outcome:      pbr.Outcome,
+		UserId:       me.Id,
+		UserUsername: me.Username,
+		UserName:     me.Name,
+		CreatedTime:  s.now(),
+		ProbBefore:   bk.prob,
+		ProbAfter:    bk.prob,
+	}
+	if pbr.LimitProb != nil {
+		b.OrderAmount = pbr.Amount
+		b.LimitProb = *pbr.LimitProb
+	}
+
+	if fill > 0 {
+		sim, err := mango.SimulateBet(bk.pool, bk.p, pbr.Outcome, fill)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		b.Amount = fill
+		b.Shares = sim.Shares
+		b.Fees = sim.Fees
+		b.ProbAfter = sim.ProbAfter
+		b.Fills = []mango.Fill{{Amount: fill, Shares: sim.Shares, Timestamp: b.CreatedTime}}
+
+		bk.set(sim.Pool, sim.ProbAfter)
+		m.Volume += fill
+		m.LastUpdatedTime = b.CreatedTime
+	}
+	b.IsFilled = pbr.LimitProb == nil || fill >= pbr.Amount-1e-9
+
+	// the whole order amount is reserved, and returned if it is cancelled
+	me.Balance -= pbr.Amount
+
+	s.bets = append(s.bets, b)
+
+	resp := *b
+	resp.BetId, resp.Id = b.Id, ""
+	writeJSON(w, resp)
+}
+
+// book represents the CPMM pool that bets trade against, which is either a market's
+// or, in a multiple choice market, one of its answers'.
+type book struct {
+	pool mango.Pool
+	p    float64
+	prob float64
+	set  func(pool mango.Pool, prob float64)
+}
+
+// book returns the pool that bets on a market, or on one of its answers, trade against.
+func (s *Server) book(m *mango.FullMarket, answerId string) (book, bool) {
+	if answerId == "" {
+		return book{m.Pool, m.P, m.Probability, func(pool mango.Pool, prob float64) {
+			m.Pool, m.Probability = pool, prob
+		}}, true
+	}
+
+	for i := range m.Answers {
+		a := &m.Answers[i]
+		if a.Id != answerId {
+			continue
+		}
+		return book{mango.Pool{"YES": a.PoolYes, "NO": a.PoolNo}, 0.5, a.Probability, func(pool mango.Pool, prob float64) {
+			a.PoolYes, a.PoolNo, a.Probability = pool["YES"], pool["NO"], prob
+		}}, true
+	}
+
+	return book{}, false
+}
+
+// amountToLimit returns how much of amount can be bet on outcome before the
+// book's probability passes limit.
+func amountToLimit(bk book, outcome string, limit, amount float64) float64 {
+	past := func(p float64) bool {
+		if outcome == "YES" {
+			return p > limit
+		}
+		return p < limit
+	}
+
+	if past(bk.prob) || bk.prob == limit {
+		return 0
+	}
+	if sim, err := mango.SimulateBet(bk.pool, bk.p, outcome, amount); err != nil || !past(sim.ProbAfter) {
+		return amount
+	}
+
+	lo, hi := 0.0, amount
+	for i := 0; i < 60; i++ {
+		mid := (lo + hi) / 2
+		if sim, _ := mango.SimulateBet(bk.pool, bk.p, outcome, mid); past(sim.ProbAfter) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	return lo
+}
+
+func (s *Server) cancelBet(w http.ResponseWriter, _ *http.Request, id string) {
+	for _, b := range s.bets {
+		if b.Id != id {
+			continue
+		}
+		if b.UserId != s.me || b.OrderAmount == 0 || b.IsFilled || b.IsCancelled {
+			writeError(w, http.StatusBadRequest, "bet is not an open limit order")
+			return
+		}
+
+		b.IsCancelled = true
+		s.users[s.me].Balance += b.OrderAmount - b.Amount
+		writeJSON(w, b)
+		return
+	}
+
+	writeError(w, http.StatusNotFound, "bet not found")
+}
+
+func (s *Server) sellShares(w http.ResponseWriter, r *http.Request, id string) {
+	// shares aren't always whole, so they can't be decoded into a SellSharesRequest
+	var ssr struct {
+		Outcome  string  `json:"outcome"`
+		Shares   float64 `json:"shares"`
+		AnswerId string  `json:"answerId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&ssr); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	m, ok := s.markets[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "market not found")
+		return
+	}
+	if m.IsResolved || (m.CloseTime != 0 && m.CloseTime <= time.Now().UnixMilli()) {
+		writeError(w, http.StatusForbidden, "market is closed")
+		return
+	}
+	bk, ok := s.book(m, ssr.AnswerId)
+	if !ok {
+		writeError(w, http.StatusNotFound, "answer not found")
+		return
+	}
+
+	held := map[string]float64{}
+	for _, b := range s.bets {
+		if b.ContractId == id && b.AnswerId == ssr.AnswerId && b.UserId == s.me {
+			held[b.Outcome] += b.Shares
+		}
+	}
+
+	outcome := ssr.Outcome
+	if outcome == "" {
+		switch {
+		case held["YES"] > 1e-9 && held["NO"] <= 1e-9:
+			outcome = "YES"
+		case held["NO"] > 1e-9 && held["YES"] <= 1e-9:
+			outcome = "NO"
+		default:
+			writeError(w, http.StatusBadRequest, "outcome is required")
+			return
+		}
+	}
+
+	shares := ssr.Shares
+	if shares == 0 {
+		shares = held[outcome]
+	}
+	if shares <= 0 || shares > held[outcome]+1e-9 {
+		writeError(w, http.StatusBadRequest, "not enough shares to sell")
+		return
+	}
+
+	sim, err := mango.SimulateSale(bk.pool, bk.p, outcome, shares)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	me := s.users[s.me]
+	b := &mango.Bet{
+		Id:           s.id("bet"),
+		ContractId:   m.Id,
+		AnswerId:     ssr.AnswerId,
+		Outcome:      outcome,
+		UserId:       me.Id,
+		UserUsername: me.Username,
+		UserName:     me.Name,
+		CreatedTime:  s.now(),
+		Amount:       -sim.Amount,
+		Shares:       -shares,
+		Fees:         sim.Fees,
+		ProbBefore:   sim.ProbBefore,
+		ProbAfter:    sim.ProbAfter,
+		IsFilled:     true,
+	}
+
+	bk.set(sim.Pool, sim.ProbAfter)
+	m.LastUpdatedTime = b.CreatedTime
+	me.Balance += sim.Amount
+
+	s.bets = append(s.bets, b)
+
+	resp := *b
+	resp.BetId, resp.Id = b.Id, ""
+	writeJSON(w, resp)
+}
+
+func queryInt(r *http.Request, key string, def int) int {
+	if v, err := strconv.Atoi(r.URL.Query().Get(key)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,459 @@
+package mangotest
+
+import (
+	"testing"
+
+	"github.com/jonnyspicer/mango"
+	"github.com/jonnyspicer/mango/richtext"
+)
+
+func newTestServer(t *testing.T) (*Server, *mango.Client) {
+	t.Helper()
+
+	s := NewServer()
+	t.Cleanup(s.Close)
+
+	s.AddMarket(mango.FullMarket{
+		Id:       "m1",
+		Question: "Will it rain?",
+		Url:      "https://manifold.markets/someone/will-it-rain",
+		Pool:     mango.Pool{"YES": 100, "NO": 100},
+		P:        0.5,
+	})
+
+	return s, s.Client()
+}
+
+func TestMarketOrder(t *testing.T) {
+	s, mc := newTestServer(t)
+
+	bet, err := mc.PostBet(mango.PostBetRequest{ContractId: "m1", Outcome: "YES", Amount: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if bet.Id == "" || bet.Shares <= 0 || !bet.IsFilled {
+		t.Errorf("unexpected bet: %+v", bet)
+	}
+
+	m, err := mc.GetMarketBySlug("will-it-rain")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.Probability <= 0.5 || m.Probability != bet.ProbAfter {
+		t.Errorf("expected probability to rise to %v, got %v", bet.ProbAfter, m.Probability)
+	}
+
+	if b := s.Me().Balance; b != 990 {
+		t.Errorf("expected balance 990, got %v", b)
+	}
+
+	positions, err := mc.GetUserContractMetricsWithContracts(mango.GetUserContractMetricsRequest{UserId: s.Me().Id})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cm := positions.MetricsByContract["m1"]
+	if len(cm) != 1 || cm[0].TotalShares["YES"] != bet.Shares {
+		t.Errorf("unexpected positions: %+v", positions.MetricsByContract)
+	}
+}
+
+func TestLimitOrder(t *testing.T) {
+	s, mc := newTestServer(t)
+
+	limit := 0.55
+	bet, err := mc.PostBet(mango.PostBetRequest{ContractId: "m1", Outcome: "YES", Amount: 100, LimitProb: &limit})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if bet.IsFilled || bet.Amount <= 0 || bet.Amount >= 100 {
+		t.Errorf("expected a partially filled order, got %+v", bet)
+	}
+	if d := bet.ProbAfter - limit; d > 1e-6 || d < -1e-6 {
+		t.Errorf("expected order to fill up to %v, got %v", limit, bet.ProbAfter)
+	}
+
+	if err := mc.CancelBet(bet.Id); err != nil {
+		t.Fatalf("unexpected error cancelling: %v", err)
+	}
+	if err := mc.CancelBet(bet.Id); err == nil {
+		t.Error("expected an error cancelling an already cancelled order")
+	}
+
+	if b := s.Me().Balance; b != 1000-bet.Amount {
+		t.Errorf("expected unfilled amount to be refunded, got balance %v", b)
+	}
+}
+
+func TestSellShares(t *testing.T) {
+	s, mc := newTestServer(t)
+
+	bet, err := mc.PostBet(mango.PostBetRequest{ContractId: "m1", Outcome: "YES", Amount: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := mc.SellShares("m1", mango.SellSharesRequest{Outcome: "NO"}); err == nil {
+		t.Error("expected an error selling shares that aren't held")
+	}
+	if err := mc.SellShares("m1", mango.SellSharesRequest{FractionalShares: bet.Shares / 2}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mc.SellShares("m1", mango.SellSharesRequest{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	m, _ := s.Market("m1")
+	if d := m.Probability - 0.5; d > 1e-6 || d < -1e-6 {
+		t.Errorf("expected probability to return to 0.5, got %v", m.Probability)
+	}
+	if b := s.Me().Balance; b >= 1000 || b < 999 {
+		t.Errorf("expected to lose a little to fees, got balance %v", b)
+	}
+
+	s.AddMarket(mango.FullMarket{
+		Id:       "m2",
+		Question: "Who will win?",
+		Answers: []mango.Answer{
+			{Id: "a1", PoolYes: 100, PoolNo: 100},
+			{Id: "a2", PoolYes: 100, PoolNo: 100},
+		},
+	})
+
+	bet, err = mc.PostBet(mango.PostBetRequest{ContractId: "m2", AnswerId: "a2", Outcome: "NO", Amount: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mc.SellShares("m2", mango.SellSharesRequest{AnswerId: "a1"}); err == nil {
+		t.Error("expected an error selling shares in another answer")
+	}
+	if err := mc.SellShares("m2", mango.SellSharesRequest{AnswerId: "a2", Outcome: "NO", FractionalShares: bet.Shares}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	m, _ = s.Market("m2")
+	if p := m.Answers[1].Probability; p < 0.5-1e-6 || p > 0.5+1e-6 {
+		t.Errorf("expected the answer to return to 0.5, got %v", p)
+	}
+}
+
+func TestTrade(t *testing.T) {
+	s, mc := newTestServer(t)
+
+	limit := 0.4
+	order, err := mc.PostBet(mango.PostBetRequest{ContractId: "m1", Outcome: "YES", Amount: 20, LimitProb: &limit})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if order.Amount != 0 {
+		t.Fatalf("expected the order to rest, got %+v", order)
+	}
+
+	// a small trade doesn't reach the order
+	if _, err := s.Trade("m1", "NO", 5); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b := s.Bets()[0]; len(b.Fills) != 0 {
+		t.Errorf("expected the order to be unfilled, got %+v", b)
+	}
+
+	// a larger one pushes the probability down to the limit, fills the order, and then
+	// carries on against the pool
+	trade, err := s.Trade("m1", "NO", 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	filled := s.Bets()[0]
+	if !filled.IsFilled || len(filled.Fills) != 1 || *filled.Fills[0].MatchedBetId != trade.Id {
+		t.Fatalf("expected the order to be filled by the trade, got %+v", filled)
+	}
+	if d := filled.Amount - 20; d > 1e-6 || d < -1e-6 {
+		t.Errorf("expected M20 to be filled, got %v", filled.Amount)
+	}
+	if d := filled.Shares - 20/limit; d > 1e-6 || d < -1e-6 {
+		t.Errorf("expected %v shares at the limit, got %v", 20/limit, filled.Shares)
+	}
+	if trade.Amount != 100 || trade.ProbAfter >= limit || len(trade.Fills) != 3 {
+		t.Errorf("expected the trade to go past the limit, got %+v", trade)
+	}
+
+	// the filled shares count towards the user's position
+	positions, err := mc.GetUserContractMetricsWithContracts(mango.GetUserContractMetricsRequest{UserId: s.Me().Id})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cm := positions.MetricsByContract["m1"]; len(cm) != 1 || cm[0].TotalShares["YES"] != filled.Shares {
+		t.Errorf("unexpected positions: %+v", positions.MetricsByContract)
+	}
+}
+
+func TestBetsPagination(t *testing.T) {
+	_, mc := newTestServer(t)
+
+	for i := 0; i < 3; i++ {
+		if _, err := mc.PostBet(mango.PostBetRequest{ContractId: "m1", Outcome: "NO", Amount: 5}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	first, err := mc.GetBets(mango.GetBetsRequest{ContractId: "m1", Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*first) != 2 {
+		t.Fatalf("expected 2 bets, got %d", len(*first))
+	}
+
+	rest, err := mc.GetBets(mango.GetBetsRequest{ContractId: "m1", Before: (*first)[1].Id})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*rest) != 1 || (*rest)[0].CreatedTime >= (*first)[1].CreatedTime {
+		t.Errorf("expected one older bet, got %+v", *rest)
+	}
+}
+
+func TestMarketLifecycle(t *testing.T) {
+	s, mc := newTestServer(t)
+
+	id, err := mc.CreateMarket(mango.PostMarketRequest{OutcomeType: mango.Binary, Question: "Will it snow?", InitialProb: 30})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := mc.AddMarketToGroup(*id, "weather"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if g := s.MarketGroups(*id); len(g) != 1 || g[0] != "weather" {
+		t.Errorf("unexpected groups %v", g)
+	}
+
+	found, err := mc.SearchMarkets(mango.SearchMarketsRequest{Term: "snow", CreatorId: s.Me().Id})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*found) != 1 || (*found)[0].Id != *id || (*found)[0].Probability != 0.3 {
+		t.Fatalf("unexpected search results %+v", *found)
+	}
+
+	bet, err := mc.PostBet(mango.PostBetRequest{ContractId: *id, Outcome: "YES", Amount: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := mc.CloseMarket(*id, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := mc.PostBet(mango.PostBetRequest{ContractId: *id, Outcome: "YES", Amount: 10}); err == nil {
+		t.Error("expected an error betting on a closed market")
+	}
+
+	if err := mc.ResolveMarket(*id, mango.ResolveMarketRequest{Outcome: "YES"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mc.ResolveMarket(*id, mango.ResolveMarketRequest{Outcome: "NO"}); err == nil {
+		t.Error("expected an error resolving twice")
+	}
+
+	if b := s.Me().Balance; b != 1000-10+bet.Shares {
+		t.Errorf("expected winning shares to pay out, got balance %v", b)
+	}
+	if m, _ := s.Market(*id); !m.IsResolved || m.Resolution != "YES" {
+		t.Errorf("unexpected market %+v", m)
+	}
+}
+
+func TestPortfolio(t *testing.T) {
+	s, mc := newTestServer(t)
+
+	if _, err := mc.PostBet(mango.PostBetRequest{ContractId: "m1", Outcome: "YES", Amount: 100}); err != nil {
+		t.Fatal(err)
+	}
+	s.AddPortfolioHistory(s.Me().Id, mango.PortfolioMetrics{Balance: 1000})
+
+	p, err := mc.GetPortfolio(s.Me().Id, mango.PeriodDaily)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.History) != 1 || len(p.Positions) != 1 || p.Live.Balance != 900 {
+		t.Fatalf("unexpected portfolio %+v", p)
+	}
+	if v := p.Positions[0].Value(); p.Live.InvestmentValue != v || v <= 0 {
+		t.Errorf("expected the investment value to match the position, got %v and %v", p.Live.InvestmentValue, v)
+	}
+
+	// the worst case is the market resolving NO, which loses everything
+	if r := p.Positions[0].AtRisk(); r != p.Positions[0].Value() {
+		t.Errorf("expected the whole position to be at risk, got %v", r)
+	}
+}
+
+func TestSendManagram(t *testing.T) {
+	s, mc := newTestServer(t)
+	s.AddUser(mango.User{Id: "u1", Username: "alice"})
+	s.AddUser(mango.User{Id: "u2", Username: "bob"})
+
+	if err := mc.SendManagram(mango.SendManagramRequest{ToIds: []string{"u1", "u2"}, Amount: 5}); err == nil {
+		t.Error("expected sending less than the minimum to fail")
+	}
+	if err := mc.SendManagram(mango.SendManagramRequest{ToIds: []string{"u1", "u2"}, Amount: 600}); err == nil {
+		t.Error("expected sending more than the balance to fail")
+	}
+
+	if err := mc.SendManagram(mango.SendManagramRequest{ToIds: []string{"u1", "u2"}, Amount: 50, Message: "thanks"}); err != nil {
+		t.Fatal(err)
+	}
+	if b := s.Me().Balance; b != 900 {
+		t.Errorf("expected balance 900, got %v", b)
+	}
+
+	txns, err := mc.GetTransactions(mango.GetTransactionsRequest{FromId: s.Me().Id, Category: "MANA_PAYMENT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*txns) != 2 || (*txns)[0].ToId != "u2" || (*txns)[0].Amount != 50 || (*txns)[0].Description != "thanks" {
+		t.Errorf("unexpected transactions %+v", *txns)
+	}
+}
+
+func TestAwardBounty(t *testing.T) {
+	s, mc := newTestServer(t)
+	s.AddUser(mango.User{Id: "u1", Username: "alice"})
+	s.AddMarket(mango.FullMarket{Id: "q1", Question: "Best bread recipe?", CreatorId: s.Me().Id, OutcomeType: mango.BountiedQuestion})
+	s.AddComment(mango.Comment{Id: "c1", ContractId: "q1", UserId: "u1"})
+
+	if err := mc.AddBounty("q1", 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.AwardBounty("q1", 150, "c1"); err == nil {
+		t.Error("expected awarding more than the bounty to fail")
+	}
+	if err := mc.AwardBounty("q1", 60, "c1"); err != nil {
+		t.Fatal(err)
+	}
+
+	m, _ := s.Market("q1")
+	if m.TotalBounty != 100 || m.BountyLeft != 40 {
+		t.Errorf("unexpected bounty %v left of %v", m.BountyLeft, m.TotalBounty)
+	}
+	if b := s.Me().Balance; b != 900 {
+		t.Errorf("expected balance 900, got %v", b)
+	}
+
+	comments, err := mc.GetComments(mango.GetCommentsRequest{ContractId: "q1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*comments)[0].BountyAwarded != 60 {
+		t.Errorf("expected the comment to be awarded 60, got %+v", (*comments)[0])
+	}
+}
+
+func TestPostComment(t *testing.T) {
+	_, mc := newTestServer(t)
+
+	doc := richtext.Doc(richtext.Paragraph(richtext.Text("Rain is "), richtext.Text("likely", richtext.Bold())))
+	if err := mc.PostComment("m1", mango.PostCommentRequest{Doc: &doc}); err != nil {
+		t.Fatal(err)
+	}
+	comments, err := mc.GetComments(mango.GetCommentsRequest{ContractId: "m1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*comments) != 1 || (*comments)[0].Text != "Rain is likely" || (*comments)[0].Content == nil {
+		t.Fatalf("unexpected comments %+v", *comments)
+	}
+
+	parent := (*comments)[0]
+	if err := mc.ReplyToComment(parent, mango.PostCommentRequest{Markdown: "agreed"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.React(mango.ReactRequest{ContentId: parent.Id, ContentType: "comment"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.React(mango.ReactRequest{ContentId: parent.Id, ContentType: "comment", ReactionType: "dislike"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.React(mango.ReactRequest{ContentId: parent.Id, ContentType: "comment", ReactionType: "love"}); err == nil {
+		t.Error("expected an error for an unknown reaction type")
+	}
+
+	comments, err = mc.GetComments(mango.GetCommentsRequest{UserId: "user-me"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	threads := mango.Threads(*comments)
+	if len(threads) != 1 || threads[0].Comment.Likes != 1 || len(threads[0].Replies) != 1 || threads[0].Replies[0].Comment.Text != "agreed" {
+		t.Errorf("unexpected threads %+v", threads)
+	}
+}
+
+func TestGroups(t *testing.T) {
+	s, mc := newTestServer(t)
+
+	s.AddGroup(mango.Group{Id: "g1", Slug: "science", Name: "Science"})
+	s.AddGroup(mango.Group{Id: "g2", Slug: "physics", Name: "Physics"})
+	s.AddGroup(mango.Group{Id: "g3", Slug: "astronomy", Name: "Astronomy"})
+	s.AddSubtopic("g1", "g2")
+	s.AddSubtopic("g1", "g3")
+	s.AddSubtopic("g2", "g3")
+	s.AddGroupMember("g2", "someone")
+
+	var ids []string
+	for _, q := range []string{"Fusion by 2040?", "Dark matter found?", "LK-99 replicates?"} {
+		id, err := mc.CreateMarket(mango.PostMarketRequest{OutcomeType: mango.Binary, Question: q, InitialProb: 50, GroupId: "g2"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, *id)
+	}
+	if err := mc.RemoveMarketFromGroup(ids[1], "g2"); err != nil {
+		t.Fatal(err)
+	}
+	if g := s.MarketGroups(ids[1]); len(g) != 0 {
+		t.Errorf("expected the market to have left the group, got %v", g)
+	}
+
+	var pages [][]string
+	err := mc.PageGroupMarkets("physics", mango.SearchMarketsRequest{Limit: 1}, func(page []mango.FullMarket) error {
+		var p []string
+		for _, m := range page {
+			p = append(p, m.Id)
+		}
+		pages = append(pages, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 2 || pages[0][0] != ids[2] || pages[1][0] != ids[0] {
+		t.Errorf("unexpected pages %v", pages)
+	}
+
+	if err := mc.FollowGroup("g2"); err != nil {
+		t.Fatal(err)
+	}
+	members, err := mc.GetGroupMembers("g2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*members) != 2 || (*members)[1].UserId != s.Me().Id {
+		t.Errorf("unexpected members %+v", *members)
+	}
+	if err := mc.UnfollowGroup("g2"); err != nil {
+		t.Fatal(err)
+	}
+	if g, _ := mc.GetGroupById("g2"); g.TotalMembers != 1 {
+		t.Errorf("expected 1 member after unfollowing, got %d", g.TotalMembers)
+	}
+
+	tree, err := mc.TopicTree("g2", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree.Above) != 1 || tree.Above[0].Id != "g1" || tree.Root.Len() != 2 || tree.Root.Find("g3") == nil {
+		t.Errorf("unexpected topic tree %+v", tree)
+	}
+}
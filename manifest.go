@@ -0,0 +1,590 @@
+package mango
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest describes a set of markets that should exist, so that recurring markets
+// can be created from a file rather than by hand. A manifest is usually written in YAML:
+//
+//	defaults:
+//	  outcomeType: BINARY
+//	  initialProb: 50
+//	  groups: [sports-group-id]
+//	  liquidity: 100
+//	markets:
+//	  - question: "Will {{.Team}} win on {{.Date}}?"
+//	    closeTime: "{{.Date}}T17:00:00Z"
+//	    each:
+//	      - {Team: Arsenal, Date: 2024-06-01}
+//	      - {Team: Chelsea, Date: 2024-06-02}
+//	  - question: Who will win the league this season?
+//	    outcomeType: MULTIPLE_CHOICE
+//	    answers: [Arsenal, Chelsea, Liverpool]
+//	    closeTime: now + 30d
+//
+// Field names match those of [PostMarketRequest]. The question, slug, descriptions, close time
+// and answers are Go templates, executed with the manifest's vars, the vars of each entry in
+// "each", and .Now, the time the manifest is planned.
+type Manifest struct {
+	Defaults ManifestMarket   `json:"defaults,omitempty"`
+	Vars     map[string]any   `json:"vars,omitempty"`
+	Markets  []ManifestMarket `json:"markets"`
+}
+
+// ManifestMarket describes a market, or a series of markets, in a [Manifest].
+type ManifestMarket struct {
+	Question            string      `json:"question,omitempty"`
+	Slug                string      `json:"slug,omitempty"` // used to find the market if it already exists
+	OutcomeType         OutcomeType `json:"outcomeType,omitempty"`
+	Description         string      `json:"description,omitempty"`
+	DescriptionMarkdown string      `json:"descriptionMarkdown,omitempty"`
+	CloseTime           string      `json:"closeTime,omitempty"` // see [ParseCloseTime]
+	Visibility          string      `json:"visibility,omitempty"`
+	InitialProb         int64       `json:"initialProb,omitempty"`
+	Min                 int64       `json:"min,omitempty"`
+	Max                 int64       `json:"max,omitempty"`
+	IsLogScale          bool        `json:"isLogScale,omitempty"`
+	InitialVal          int64       `json:"initialValue,omitempty"`
+	Answers             []string    `json:"answers,omitempty"`
+	Groups              []string    `json:"groups,omitempty"`    // group IDs to add the market to
+	Liquidity           int64       `json:"liquidity,omitempty"` // extra liquidity to add once created
+
+	// Each expands the entry into one market per element, with the element's keys available to the templates.
+	Each []map[string]any `json:"each,omitempty"`
+}
+
+// UnmarshalJSON allows a close time to be given as either a string or a number.
+func (mm *ManifestMarket) UnmarshalJSON(b []byte) error {
+	type plain ManifestMarket
+	aux := struct {
+		*plain
+		CloseTime json.RawMessage `json:"closeTime,omitempty"`
+	}{plain: (*plain)(mm)}
+
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	if len(aux.CloseTime) > 0 {
+		var s string
+		if err := json.Unmarshal(aux.CloseTime, &s); err != nil {
+			s = string(aux.CloseTime)
+		}
+		mm.CloseTime = s
+	}
+
+	return nil
+}
+
+// PlannedMarket is a single market in a [ManifestPlan].
+type PlannedMarket struct {
+	Request   PostMarketRequest `json:"request"`
+	Slug      string            `json:"slug,omitempty"`
+	Groups    []string          `json:"groups,omitempty"`
+	Liquidity int64             `json:"liquidity,omitempty"`
+	Id        string            `json:"id,omitempty"`     // set if the market exists or has been created
+	Exists    bool              `json:"exists,omitempty"` // true if the market existed before the plan was applied
+
+	// AddGroups and AddLiquidity are the steps still to be done for a market that has been
+	// created: the groups it hasn't been added to yet, and whether its extra liquidity hasn't
+	// been added. They are left over when an earlier apply failed part way through.
+	AddGroups    []string `json:"addGroups,omitempty"`
+	AddLiquidity bool     `json:"addLiquidity,omitempty"`
+}
+
+// Unfinished reports whether the market has been created but not yet added to all of its
+// groups or given its extra liquidity.
+func (pm PlannedMarket) Unfinished() bool {
+	return len(pm.AddGroups) > 0 || pm.AddLiquidity
+}
+
+// ManifestPlan lists the markets described by a [Manifest] and whether each one already exists.
+type ManifestPlan struct {
+	Markets []PlannedMarket `json:"markets"`
+}
+
+// ToCreate returns the markets in the plan that do not exist yet.
+func (p *ManifestPlan) ToCreate() []PlannedMarket {
+	var out []PlannedMarket
+	for _, pm := range p.Markets {
+		if !pm.Exists {
+			out = append(out, pm)
+		}
+	}
+	return out
+}
+
+// ToApply returns the markets in the plan that [Client.ApplyPlan] has work to do on: those
+// that do not exist yet, and those that are [PlannedMarket.Unfinished].
+func (p *ManifestPlan) ToApply() []PlannedMarket {
+	var out []PlannedMarket
+	for _, pm := range p.Markets {
+		if !pm.Exists || pm.Unfinished() {
+			out = append(out, pm)
+		}
+	}
+	return out
+}
+
+// String returns a human-readable summary of the plan.
+func (p *ManifestPlan) String() string {
+	var b strings.Builder
+
+	create, finish := len(p.ToCreate()), len(p.ToApply())-len(p.ToCreate())
+	fmt.Fprintf(&b, "%d to create, %d to finish, %d already exist\n", create, finish, len(p.Markets)-create-finish)
+
+	for _, pm := range p.Markets {
+		switch {
+		case pm.Exists && pm.Unfinished():
+			fmt.Fprintf(&b, "  ~ %v (%v)", pm.Request.Question, pm.Id)
+			if len(pm.AddGroups) > 0 {
+				fmt.Fprintf(&b, ", add to groups %v", strings.Join(pm.AddGroups, ", "))
+			}
+			if pm.AddLiquidity {
+				fmt.Fprintf(&b, ", add M%d liquidity", pm.Liquidity)
+			}
+			b.WriteString("\n")
+		case pm.Exists:
+			fmt.Fprintf(&b, "  = %v (%v)\n", pm.Request.Question, pm.Id)
+		case pm.Id != "":
+			fmt.Fprintf(&b, "  + %v (created %v)\n", pm.Request.Question, pm.Id)
+		default:
+			fmt.Fprintf(&b, "  + %v", pm.Request.Question)
+			if pm.Request.CloseTime != 0 {
+				fmt.Fprintf(&b, ", closes %v", time.UnixMilli(pm.Request.CloseTime).UTC().Format(time.RFC3339))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	return b.String()
+}
+
+// LoadManifest reads a [Manifest] from a YAML or JSON file.
+func LoadManifest(path string) (*Manifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest: %v", err)
+	}
+
+	return ParseManifest(b)
+}
+
+// ParseManifest parses a [Manifest] from YAML or JSON.
+func ParseManifest(b []byte) (*Manifest, error) {
+	// YAML is a superset of JSON, so decode generically and then use
+	// the JSON tags shared with the API request types to map the fields
+	var n yaml.Node
+	if err := yaml.Unmarshal(b, &n); err != nil {
+		return nil, fmt.Errorf("error parsing manifest: %v", err)
+	}
+
+	v, err := yamlValue(&n)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing manifest: %v", err)
+	}
+
+	j, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing manifest: %v", err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(j, &m); err != nil {
+		return nil, fmt.Errorf("error parsing manifest: %v", err)
+	}
+
+	return &m, nil
+}
+
+// yamlValue converts a YAML node into values that can be marshalled as JSON. Unlike decoding
+// into an any, dates are kept as they were written so they can be used in templates.
+func yamlValue(n *yaml.Node) (any, error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return yamlValue(n.Content[0])
+	case yaml.AliasNode:
+		return yamlValue(n.Alias)
+	case yaml.SequenceNode:
+		out := make([]any, 0, len(n.Content))
+		for _, c := range n.Content {
+			v, err := yamlValue(c)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	case yaml.MappingNode:
+		out := make(map[string]any, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			v, err := yamlValue(n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			out[n.Content[i].Value] = v
+		}
+		return out, nil
+	}
+
+	if n.ShortTag() == "!!timestamp" {
+		return n.Value, nil
+	}
+
+	var v any
+	if err := n.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Expand returns the markets described by the manifest, with defaults applied, templates
+// executed and close times resolved relative to now. It does not make any requests.
+func (m Manifest) Expand(now time.Time) ([]PlannedMarket, error) {
+	var out []PlannedMarket
+
+	for i, mm := range m.Markets {
+		mm = mm.withDefaults(m.Defaults)
+
+		each := mm.Each
+		if len(each) == 0 {
+			each = []map[string]any{nil}
+		}
+
+		for _, vars := range each {
+			data := map[string]any{"Now": now}
+			for k, v := range m.Vars {
+				data[k] = v
+			}
+			for k, v := range vars {
+				data[k] = v
+			}
+
+			pm, err := mm.plan(data, now)
+			if err != nil {
+				return nil, fmt.Errorf("market %d: %v", i+1, err)
+			}
+			out = append(out, *pm)
+		}
+	}
+
+	return out, nil
+}
+
+func (mm ManifestMarket) withDefaults(d ManifestMarket) ManifestMarket {
+	if mm.OutcomeType == "" {
+		mm.OutcomeType = d.OutcomeType
+	}
+	if mm.Description == "" {
+		mm.Description = d.Description
+	}
+	if mm.DescriptionMarkdown == "" {
+		mm.DescriptionMarkdown = d.DescriptionMarkdown
+	}
+	if mm.CloseTime == "" {
+		mm.CloseTime = d.CloseTime
+	}
+	if mm.Visibility == "" {
+		mm.Visibility = d.Visibility
+	}
+	if mm.InitialProb == 0 {
+		mm.InitialProb = d.InitialProb
+	}
+	if mm.Groups == nil {
+		mm.Groups = d.Groups
+	}
+	if mm.Liquidity == 0 {
+		mm.Liquidity = d.Liquidity
+	}
+
+	return mm
+}
+
+func (mm ManifestMarket) plan(data map[string]any, now time.Time) (*PlannedMarket, error) {
+	var err error
+	render := func(s string) string {
+		if err != nil || !strings.Contains(s, "{{") {
+			return s
+		}
+		var out string
+		out, err = executeTemplate(s, data)
+		return out
+	}
+
+	pm := PlannedMarket{
+		Request: PostMarketRequest{
+			OutcomeType:         mm.OutcomeType,
+			Question:            render(mm.Question),
+			Description:         render(mm.Description),
+			DescriptionMarkdown: render(mm.DescriptionMarkdown),
+			Visibility:          mm.Visibility,
+			InitialProb:         mm.InitialProb,
+			Min:                 mm.Min,
+			Max:                 mm.Max,
+			IsLogScale:          mm.IsLogScale,
+			InitialVal:          mm.InitialVal,
+		},
+		Slug:      render(mm.Slug),
+		Groups:    mm.Groups,
+		Liquidity: mm.Liquidity,
+	}
+	for _, a := range mm.Answers {
+		pm.Request.Answers = append(pm.Request.Answers, render(a))
+	}
+	closeTime := render(mm.CloseTime)
+	if err != nil {
+		return nil, err
+	}
+
+	if pm.Request.Question == "" {
+		return nil, fmt.Errorf("question is required")
+	}
+	if pm.Request.OutcomeType == "" {
+		return nil, fmt.Errorf("outcomeType is required for %q", pm.Request.Question)
+	}
+
+	if closeTime != "" {
+		if pm.Request.CloseTime, err = ParseCloseTime(closeTime, now); err != nil {
+			return nil, fmt.Errorf("invalid closeTime for %q: %v", pm.Request.Question, err)
+		}
+	}
+
+	return &pm, nil
+}
+
+func executeTemplate(s string, data map[string]any) (string, error) {
+	t, err := template.New("").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("error parsing template %q: %v", s, err)
+	}
+
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("error executing template %q: %v", s, err)
+	}
+
+	return b.String(), nil
+}
+
+// ParseCloseTime converts a close time expression into epoch milliseconds. It accepts:
+//   - epoch milliseconds, eg "1704067199000"
+//   - an RFC 3339 timestamp, eg "2024-06-01T17:00:00Z"
+//   - a date and time in UTC, eg "2024-06-01 17:00"
+//   - a date, meaning the last second of that day in UTC, eg "2024-06-01"
+//   - an offset from now in weeks, days, hours and minutes, eg "now + 7d", "now+1w2d" or "+36h"
+func ParseCloseTime(expr string, now time.Time) (int64, error) {
+	expr = strings.TrimSpace(expr)
+
+	if ms, err := strconv.ParseInt(expr, 10, 64); err == nil {
+		return ms, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, expr); err == nil {
+		return t.UnixMilli(), nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02T15:04:05", "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, expr, time.UTC); err == nil {
+			return t.UnixMilli(), nil
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02", expr, time.UTC); err == nil {
+		return t.Add(24*time.Hour - time.Second).UnixMilli(), nil
+	}
+
+	rel := strings.ReplaceAll(expr, " ", "")
+	if rel == "now" {
+		return now.UnixMilli(), nil
+	}
+	rel = strings.TrimPrefix(rel, "now")
+	if !strings.HasPrefix(rel, "+") {
+		return 0, fmt.Errorf("unrecognised time %q", expr)
+	}
+
+	d, err := parseOffset(rel[1:])
+	if err != nil {
+		return 0, fmt.Errorf("unrecognised time %q: %v", expr, err)
+	}
+
+	return now.Add(d).UnixMilli(), nil
+}
+
+// parseOffset parses a duration like time.ParseDuration, but also accepts days ("d") and weeks ("w").
+func parseOffset(s string) (time.Duration, error) {
+	var total time.Duration
+
+	for s != "" {
+		i := 0
+		for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+			i++
+		}
+		if i == 0 || i == len(s) {
+			return 0, fmt.Errorf("expected a number followed by a unit")
+		}
+
+		n, err := strconv.ParseFloat(s[:i], 64)
+		if err != nil {
+			return 0, err
+		}
+
+		unit := map[byte]time.Duration{'w': 7 * 24 * time.Hour, 'd': 24 * time.Hour, 'h': time.Hour, 'm': time.Minute, 's': time.Second}[s[i]]
+		if unit == 0 {
+			return 0, fmt.Errorf("unknown unit %q", s[i])
+		}
+
+		total += time.Duration(n * float64(unit))
+		s = s[i+1:]
+	}
+
+	return total, nil
+}
+
+// PlanManifest works out which of the markets described by a [Manifest] already exist.
+//
+// A market exists if there is a market with its slug, when one is given, or if the
+// authenticated user has created a market with exactly the same question. A market that
+// exists but isn't in all of its groups, or that has extra liquidity the user hasn't added
+// to it, is [PlannedMarket.Unfinished], so that applying the plan finishes it.
+func (mc *Client) PlanManifest(m Manifest) (*ManifestPlan, error) {
+	markets, err := m.Expand(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	me, err := mc.GetAuthenticatedUser()
+	if err != nil {
+		return nil, fmt.Errorf("error getting authenticated user: %v", err)
+	}
+
+	// group slugs by ID, as markets list their groups by slug
+	slugs := map[string]string{}
+
+	for i := range markets {
+		pm := &markets[i]
+
+		existing, err := mc.findManifestMarket(*pm, me.Id)
+		if err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			continue
+		}
+		pm.Id, pm.Exists = existing.Id, true
+
+		in := map[string]bool{}
+		for _, s := range existing.GroupSlugs {
+			in[s] = true
+		}
+		for _, g := range pm.Groups {
+			if _, ok := slugs[g]; !ok {
+				group, err := mc.GetGroupById(g)
+				if err != nil {
+					return nil, fmt.Errorf("error getting group %v: %v", g, err)
+				}
+				slugs[g] = group.Slug
+			}
+			if !in[slugs[g]] {
+				pm.AddGroups = append(pm.AddGroups, g)
+			}
+		}
+
+		if pm.Liquidity > 0 {
+			txns, err := mc.GetTransactions(GetTransactionsRequest{FromId: me.Id, ToId: pm.Id, Category: "ADD_SUBSIDY", Limit: 1})
+			if err != nil {
+				return nil, fmt.Errorf("error getting liquidity added to %q: %v", pm.Request.Question, err)
+			}
+			pm.AddLiquidity = len(*txns) == 0
+		}
+	}
+
+	return &ManifestPlan{Markets: markets}, nil
+}
+
+// findManifestMarket returns the market that pm describes, or nil if it doesn't exist yet.
+func (mc *Client) findManifestMarket(pm PlannedMarket, creatorId string) (*FullMarket, error) {
+	if pm.Slug != "" {
+		if fm, err := mc.GetMarketBySlug(pm.Slug); err == nil {
+			return fm, nil
+		}
+	}
+
+	found, err := mc.SearchMarkets(SearchMarketsRequest{
+		Term:      pm.Request.Question,
+		Filter:    "all",
+		CreatorId: creatorId,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error searching for %q: %v", pm.Request.Question, err)
+	}
+
+	for _, fm := range *found {
+		if strings.EqualFold(strings.TrimSpace(fm.Question), strings.TrimSpace(pm.Request.Question)) {
+			return &fm, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ApplyPlan creates the markets in a [ManifestPlan] that don't exist yet, adds them to their
+// groups and adds any extra liquidity, and finishes markets that are [PlannedMarket.Unfinished].
+// The IDs of created markets, and the steps still to be done, are recorded in the plan.
+//
+// If an error occurs, the markets created so far are kept, so applying the plan again, or
+// planning and applying the manifest again, will carry on where it left off.
+func (mc *Client) ApplyPlan(p *ManifestPlan) error {
+	for i := range p.Markets {
+		pm := &p.Markets[i]
+
+		if !pm.Exists && pm.Id == "" {
+			id, err := mc.CreateMarket(pm.Request)
+			if err != nil {
+				return fmt.Errorf("error creating %q: %v", pm.Request.Question, err)
+			}
+			pm.Id = *id
+			pm.AddGroups = append([]string(nil), pm.Groups...)
+			pm.AddLiquidity = pm.Liquidity > 0
+		}
+
+		for len(pm.AddGroups) > 0 {
+			g := pm.AddGroups[0]
+			if err := mc.AddMarketToGroup(pm.Id, g); err != nil {
+				return fmt.Errorf("error adding %q to group %v: %v", pm.Request.Question, g, err)
+			}
+			pm.AddGroups = pm.AddGroups[1:]
+		}
+
+		if pm.AddLiquidity {
+			if err := mc.AddLiquidity(pm.Id, pm.Liquidity); err != nil {
+				return fmt.Errorf("error adding liquidity to %q: %v", pm.Request.Question, err)
+			}
+			pm.AddLiquidity = false
+		}
+	}
+
+	return nil
+}
+
+// ApplyManifest plans a [Manifest] and then creates the markets that don't exist yet and
+// finishes those that are [PlannedMarket.Unfinished].
+// It returns the plan, including the IDs of every market, even if an error occurs part way through.
+func (mc *Client) ApplyManifest(m Manifest) (*ManifestPlan, error) {
+	p, err := mc.PlanManifest(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, mc.ApplyPlan(p)
+}
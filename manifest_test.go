@@ -0,0 +1,291 @@
+package mango
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseCloseTime(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]time.Time{
+		"1704067199000":        time.UnixMilli(1704067199000),
+		"2024-06-01T17:00:00Z": time.Date(2024, 6, 1, 17, 0, 0, 0, time.UTC),
+		"2024-06-01 17:30":     time.Date(2024, 6, 1, 17, 30, 0, 0, time.UTC),
+		"2024-06-02":           time.Date(2024, 6, 2, 23, 59, 59, 0, time.UTC),
+		"now":                  now,
+		"now + 7d":             now.AddDate(0, 0, 7),
+		"now+1w2d":             now.AddDate(0, 0, 9),
+		"+36h":                 now.Add(36 * time.Hour),
+		"now + 1.5h":           now.Add(90 * time.Minute),
+	}
+
+	for expr, want := range tests {
+		got, err := ParseCloseTime(expr, now)
+		if err != nil {
+			t.Errorf("%q: %v", expr, err)
+			continue
+		}
+		if got != want.UnixMilli() {
+			t.Errorf("%q: got %v, want %v", expr, time.UnixMilli(got).UTC(), want)
+		}
+	}
+
+	for _, expr := range []string{"", "tomorrow", "now + 7", "now + 7y", "now - 1d"} {
+		if _, err := ParseCloseTime(expr, now); err == nil {
+			t.Errorf("%q: expected an error", expr)
+		}
+	}
+}
+
+const testManifest = `
+defaults:
+  outcomeType: BINARY
+  initialProb: 50
+  groups: [sports]
+  liquidity: 100
+vars:
+  League: Premier League
+markets:
+  - question: "Will {{.Team}} win in the {{.League}} on {{.Date}}?"
+    closeTime: "{{.Date}}T17:00:00Z"
+    each:
+      - {Team: Arsenal, Date: 2024-06-01}
+      - {Team: Chelsea, Date: 2024-06-02}
+  - question: Who will win the league?
+    outcomeType: MULTIPLE_CHOICE
+    answers: [Arsenal, Chelsea]
+    groups: []
+    closeTime: 1704067199000
+`
+
+func TestManifestExpand(t *testing.T) {
+	m, err := ParseManifest([]byte(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	markets, err := m.Expand(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(markets) != 3 {
+		t.Fatalf("expected 3 markets, got %d", len(markets))
+	}
+
+	first := markets[0]
+	if first.Request.Question != "Will Arsenal win in the Premier League on 2024-06-01?" {
+		t.Errorf("unexpected question %q", first.Request.Question)
+	}
+	if first.Request.CloseTime != time.Date(2024, 6, 1, 17, 0, 0, 0, time.UTC).UnixMilli() {
+		t.Errorf("unexpected close time %v", first.Request.CloseTime)
+	}
+	if first.Request.OutcomeType != Binary || first.Request.InitialProb != 50 || first.Liquidity != 100 {
+		t.Errorf("defaults not applied: %+v", first)
+	}
+	if len(first.Groups) != 1 || first.Groups[0] != "sports" {
+		t.Errorf("unexpected groups %v", first.Groups)
+	}
+
+	last := markets[2]
+	if last.Request.OutcomeType != MultipleChoice || len(last.Request.Answers) != 2 {
+		t.Errorf("unexpected market %+v", last)
+	}
+	if len(last.Groups) != 0 {
+		t.Errorf("expected groups to be overridden, got %v", last.Groups)
+	}
+	if last.Request.CloseTime != 1704067199000 {
+		t.Errorf("unexpected close time %v", last.Request.CloseTime)
+	}
+}
+
+func TestManifestExpandMissingVar(t *testing.T) {
+	m, err := ParseManifest([]byte(`
+markets:
+  - question: "Will {{.Team}} win?"
+    outcomeType: BINARY
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Expand(time.Now()); err == nil {
+		t.Error("expected an error for a missing template var")
+	}
+}
+
+// manifestServer is a fake of the endpoints used to plan and apply a manifest. It starts
+// with an existing market for Arsenal, which is in the sports group and has had liquidity added.
+type manifestServer struct {
+	mu        sync.Mutex
+	created   []PostMarketRequest
+	calls     []string
+	groups    map[string][]string // market ID to group slugs
+	subsidies map[string]bool     // market IDs liquidity has been added to
+	failGroup bool                // fail the next request to add a market to a group
+}
+
+func newManifestServer(t *testing.T) (*manifestServer, *Client) {
+	t.Helper()
+
+	ms := &manifestServer{
+		groups:    map[string][]string{"existing": {"sports-slug"}},
+		subsidies: map[string]bool{"existing": true},
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ms.mu.Lock()
+		defer ms.mu.Unlock()
+
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v0/"), "/")
+		q := r.URL.Query()
+
+		switch {
+		case path == "me":
+			json.NewEncoder(w).Encode(User{Id: "creator"})
+		case path == "group/by-id/sports":
+			json.NewEncoder(w).Encode(Group{Id: "sports", Slug: "sports-slug"})
+		case path == "txns":
+			var txns []Txn
+			if q.Get("fromId") == "creator" && q.Get("category") == "ADD_SUBSIDY" && ms.subsidies[q.Get("toId")] {
+				txns = append(txns, Txn{FromId: "creator", ToId: q.Get("toId"), Category: "ADD_SUBSIDY"})
+			}
+			json.NewEncoder(w).Encode(txns)
+		case path == "search-markets":
+			if q.Get("creatorId") != "creator" {
+				t.Errorf("unexpected creatorId %q", q.Get("creatorId"))
+			}
+			var found []FullMarket
+			for i, pm := range ms.created {
+				if pm.Question == q.Get("term") {
+					id := "new-" + string(rune('a'+i))
+					found = append(found, FullMarket{Id: id, Question: pm.Question, GroupSlugs: ms.groups[id]})
+				}
+			}
+			if strings.Contains(q.Get("term"), "Arsenal") {
+				found = append(found, FullMarket{Id: "existing", Question: "will arsenal win in the premier league on 2024-06-01?", GroupSlugs: ms.groups["existing"]})
+			}
+			json.NewEncoder(w).Encode(found)
+		case path == "market" && r.Method == http.MethodPost:
+			var pm PostMarketRequest
+			json.NewDecoder(r.Body).Decode(&pm)
+			ms.created = append(ms.created, pm)
+			json.NewEncoder(w).Encode(marketIdResponse{Id: "new-" + string(rune('a'+len(ms.created)-1))})
+		case strings.HasPrefix(path, "market/"):
+			id := strings.Split(path, "/")[1]
+			if strings.HasSuffix(path, "/group") {
+				if ms.failGroup {
+					ms.failGroup = false
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				ms.groups[id] = append(ms.groups[id], "sports-slug")
+			} else {
+				ms.subsidies[id] = true
+			}
+			ms.calls = append(ms.calls, path)
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	mc := ClientInstance(server.Client(), &server.URL, &testKey)
+	t.Cleanup(mc.Destroy)
+
+	return ms, mc
+}
+
+func TestApplyManifest(t *testing.T) {
+	ms, mc := newManifestServer(t)
+
+	m, err := ParseManifest([]byte(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := mc.PlanManifest(*m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.ToCreate()) != 2 || len(plan.ToApply()) != 2 {
+		t.Fatalf("expected 2 markets to create, got %d:\n%v", len(plan.ToCreate()), plan)
+	}
+	if !plan.Markets[0].Exists || plan.Markets[0].Id != "existing" || plan.Markets[0].Unfinished() {
+		t.Errorf("expected the first market to exist, got %+v", plan.Markets[0])
+	}
+
+	if err := mc.ApplyPlan(plan); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ms.created) != 2 {
+		t.Fatalf("expected 2 markets to be created, got %d", len(ms.created))
+	}
+	if plan.Markets[1].Id != "new-a" || plan.Markets[2].Id != "new-b" {
+		t.Errorf("unexpected ids in plan:\n%v", plan)
+	}
+
+	want := []string{"market/new-a/group", "market/new-a/liquidity", "market/new-b/liquidity"}
+	if strings.Join(ms.calls, ",") != strings.Join(want, ",") {
+		t.Errorf("got calls %v, want %v", ms.calls, want)
+	}
+
+	// applying again should find everything and create nothing
+	again, err := mc.ApplyManifest(*m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again.ToApply()) != 0 || len(ms.created) != 2 || len(ms.calls) != len(want) {
+		t.Errorf("expected nothing to be done on the second run:\n%v", again)
+	}
+}
+
+func TestApplyManifestResume(t *testing.T) {
+	ms, mc := newManifestServer(t)
+	ms.failGroup = true
+
+	m, err := ParseManifest([]byte(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := mc.ApplyManifest(*m)
+	if err == nil {
+		t.Fatal("expected adding the market to its group to fail")
+	}
+	if pm := plan.Markets[1]; pm.Id != "new-a" || !pm.Unfinished() || len(pm.AddGroups) != 1 || !pm.AddLiquidity {
+		t.Fatalf("expected the created market to be unfinished, got %+v", pm)
+	}
+
+	// planning again finds the market, and that it still needs its group and liquidity
+	again, err := mc.PlanManifest(*m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pm := again.Markets[1]; !pm.Exists || !reflect.DeepEqual(pm.AddGroups, []string{"sports"}) || !pm.AddLiquidity {
+		t.Fatalf("expected the market to be planned as unfinished, got %+v", pm)
+	}
+	if len(again.ToCreate()) != 1 || len(again.ToApply()) != 2 || !strings.Contains(again.String(), "1 to finish") {
+		t.Errorf("expected one market to create and one to finish:\n%v", again)
+	}
+
+	if err := mc.ApplyPlan(again); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"market/new-a/group", "market/new-a/liquidity", "market/new-b/liquidity"}
+	if strings.Join(ms.calls, ",") != strings.Join(want, ",") || len(ms.created) != 2 {
+		t.Errorf("got calls %v and %d markets created, want %v and 2", ms.calls, len(ms.created), want)
+	}
+}
@@ -1,6 +1,7 @@
 package mango
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 )
@@ -10,11 +11,12 @@ import (
 type OutcomeType string
 
 const (
-	Binary         OutcomeType = "BINARY"
-	FreeResponse   OutcomeType = "FREE_RESPONSE"
-	MultipleChoice OutcomeType = "MULTIPLE_CHOICE"
-	Numeric        OutcomeType = "NUMERIC"
-	PseudoNumeric  OutcomeType = "PSEUDO-NUMERIC"
+	Binary           OutcomeType = "BINARY"
+	FreeResponse     OutcomeType = "FREE_RESPONSE"
+	MultipleChoice   OutcomeType = "MULTIPLE_CHOICE"
+	Numeric          OutcomeType = "NUMERIC"
+	PseudoNumeric    OutcomeType = "PSEUDO-NUMERIC"
+	BountiedQuestion OutcomeType = "BOUNTIED_QUESTION"
 )
 
 // Pool represents the potential outcomes for a market.
@@ -33,6 +35,8 @@ type Answer struct {
 	ContractId  string  `json:"contractId"`
 	Text        string  `json:"text"`
 	Probability           float64 `json:"probability"`
+	PoolYes               float64 `json:"poolYes,omitempty"`
+	PoolNo                float64 `json:"poolNo,omitempty"`
 	Resolution            string  `json:"resolution,omitempty"`
 	ResolutionTime        int64   `json:"resolutionTime,omitempty"`
 	ResolutionProbability float64 `json:"resolutionProbability,omitempty"`
@@ -44,6 +48,8 @@ type Answer struct {
 type GetMarketsRequest struct {
 	Before string `json:"before,omitempty"`
 	Limit  int64  `json:"limit,omitempty"`
+	Sort   string `json:"sort,omitempty"`
+	Order  string `json:"order,omitempty"`
 }
 
 // PostMarketRequest represents the parameters required to create a new market via the API
@@ -84,6 +90,24 @@ type Resolution struct {
 type SellSharesRequest struct {
 	Outcome string `json:"outcome,omitempty"`
 	Shares  int64  `json:"shares,omitempty"`
+	// FractionalShares is the number of shares to sell when it isn't a whole number. If set,
+	// it is sent in place of Shares.
+	FractionalShares float64 `json:"-"`
+	AnswerId         string  `json:"answerId,omitempty"`
+}
+
+// MarshalJSON sends [SellSharesRequest.FractionalShares] as the number of shares, if it
+// is set.
+func (ssr SellSharesRequest) MarshalJSON() ([]byte, error) {
+	type request SellSharesRequest
+	if ssr.FractionalShares == 0 {
+		return json.Marshal(request(ssr))
+	}
+
+	return json.Marshal(struct {
+		request
+		Shares float64 `json:"shares"`
+	}{request(ssr), ssr.FractionalShares})
 }
 
 type marketIdResponse struct {
@@ -145,7 +169,9 @@ type FullMarket struct {
 	CloseTime             int64       `json:"closeTime"`
 	Question              string      `json:"question"`
 	Answers               []Answer    `json:"answers,omitempty"`
+	ShouldAnswersSumToOne bool        `json:"shouldAnswersSumToOne,omitempty"`
 	Tags                  []string    `json:"tags"`
+	GroupSlugs            []string    `json:"groupSlugs,omitempty"`
 	Url                   string      `json:"url"`
 	Pool                  Pool        `json:"pool"`
 	Probability           float64     `json:"probability"`
@@ -160,6 +186,10 @@ type FullMarket struct {
 	ResolutionTime        int64       `json:"resolutionTime"`
 	ResolutionProbability float64     `json:"resolutionProbability"`
 	LastUpdatedTime       int64       `json:"lastUpdatedTime"`
+	// TotalBounty is the mana put up for a bountied question, and BountyLeft how much of
+	// it hasn't been awarded yet.
+	TotalBounty float64 `json:"totalBounty,omitempty"`
+	BountyLeft  float64 `json:"bountyLeft,omitempty"`
 	// Description field returns HTML marshalled to JSON, see https://tiptap.dev/guide/output#option-1-json
 	// Description     string `json:"description"` TODO: work out how to parse this field
 	TextDescription string `json:"textDescription"`
@@ -0,0 +1,70 @@
+package marketmaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jonnyspicer/mango"
+)
+
+// FairValue estimates the probability that a market resolves YES, which the [Maker]
+// quotes around.
+type FairValue interface {
+	FairValue(ctx context.Context, m mango.FullMarket) (float64, error)
+}
+
+// FairValueFunc is a function that implements [FairValue].
+type FairValueFunc func(ctx context.Context, m mango.FullMarket) (float64, error)
+
+// FairValue calls f.
+func (f FairValueFunc) FairValue(ctx context.Context, m mango.FullMarket) (float64, error) {
+	return f(ctx, m)
+}
+
+// Fixed returns a [FairValue] that is always prob.
+func Fixed(prob float64) FairValue {
+	return FairValueFunc(func(context.Context, mango.FullMarket) (float64, error) {
+		return prob, nil
+	})
+}
+
+// Current returns a [FairValue] that is the market's own probability, so the maker only
+// earns the spread and leaves the price to other traders.
+func Current() FairValue {
+	return FairValueFunc(func(_ context.Context, m mango.FullMarket) (float64, error) {
+		if m.Probability <= 0 || m.Probability >= 1 {
+			return 0, fmt.Errorf("market %v has no probability", m.Id)
+		}
+		return m.Probability, nil
+	})
+}
+
+// Average returns a [FairValue] that is the weighted average of other sources. The
+// weights are taken in the same order as the sources, and sources without a weight have
+// a weight of 1. Sources that fail are left out, unless they all do.
+func Average(sources []FairValue, weights ...float64) FairValue {
+	return FairValueFunc(func(ctx context.Context, m mango.FullMarket) (float64, error) {
+		var sum, total float64
+		var errs []error
+		for i, src := range sources {
+			p, err := src.FairValue(ctx, m)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			w := 1.0
+			if i < len(weights) {
+				w = weights[i]
+			}
+			sum += w * p
+			total += w
+		}
+
+		if total == 0 {
+			return 0, errors.Join(append([]error{fmt.Errorf("no fair value for market %v", m.Id)}, errs...)...)
+		}
+		return sum / total, nil
+	})
+}
@@ -0,0 +1,483 @@
+// Package marketmaker is a reference market maker for binary markets on Manifold.
+//
+// A [Maker] quotes each of its markets with a ladder of limit orders on both sides of a
+// fair value: YES orders below it and NO orders above it, so that it earns the spread as
+// other traders fill them. The fair value comes from a pluggable [FairValue], such as a
+// model, another market, or the market's own probability.
+//
+// Each run the maker checks its orders for new fills, works out its position and profit
+// at the fair value, and brings its quotes up to date: orders that have been filled, or
+// are no longer where the ladder says they should be, are cancelled and replaced. Limits
+// on inventory stop it quoting the side that would add to an already large position, and
+// a loss limit stops it quoting a market altogether:
+//
+//	mk, err := marketmaker.New(mc, []marketmaker.Market{{
+//		Id:           "1LZpVeeTGAjkF4IgPAMk",
+//		Fair:         marketmaker.Current(),
+//		Levels:       3,
+//		Size:         10,
+//		MaxInventory: 200,
+//		MaxLoss:      50,
+//	}})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	mk.Run(ctx, time.Minute)
+//
+// Use [mangotest.Server.Trade] to fill the maker's orders when testing it against the
+// fake server.
+package marketmaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+)
+
+// minOrder is the smallest order, in mana, that Manifold accepts.
+const minOrder = 1
+
+// Market describes how to make a market.
+type Market struct {
+	// Id is the ID of a binary CPMM market.
+	Id string
+	// Fair is the source of the fair value that the market is quoted around.
+	Fair FairValue
+
+	// Levels is the number of orders on each side of the fair value. It defaults to 3.
+	Levels int
+	// Spread is how far from the fair value the nearest orders are, and Step how far
+	// apart the orders are from there. They default to 0.02. Order limits are rounded to
+	// the nearest percent, as Manifold requires.
+	Spread float64
+	Step   float64
+	// Size is the mana of each order. It defaults to 10.
+	Size float64
+
+	// MaxInventory is the most shares of either outcome, net of the other, that the maker
+	// will hold. Orders are made smaller, or left out, so that filling them can't take the
+	// position past it. Zero means no limit.
+	MaxInventory float64
+	// MaxLoss is the most mana the maker is willing to lose in the market, valuing its
+	// position at the fair value. Once it is lost, every order is cancelled and the market
+	// is no longer quoted. Zero means no limit.
+	MaxLoss float64
+}
+
+// State represents what the maker knows about a market after its last run.
+type State struct {
+	MarketId string `json:"marketId"`
+	// Prob and Fair are the market's probability and its fair value.
+	Prob float64 `json:"prob"`
+	Fair float64 `json:"fair"`
+
+	// YesShares and NoShares are the shares held, and Invested the mana spent on them.
+	YesShares float64 `json:"yesShares"`
+	NoShares  float64 `json:"noShares"`
+	Invested  float64 `json:"invested"`
+	// Profit is the value of the shares held at the fair value, less Invested.
+	Profit float64 `json:"profit"`
+
+	// Orders are the maker's open limit orders.
+	Orders []mango.Bet `json:"orders"`
+	// Fills counts the fills of the maker's orders seen so far.
+	Fills int `json:"fills"`
+
+	// Halted is true once the market is no longer quoted, and Reason says why.
+	Halted bool   `json:"halted,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Inventory returns the shares held of YES, net of NO. It is negative when more NO
+// shares are held.
+func (st State) Inventory() float64 {
+	return st.YesShares - st.NoShares
+}
+
+// Maker makes markets. A Maker isn't safe for concurrent use.
+type Maker struct {
+	mc      *mango.Client
+	markets []Market
+	states  map[string]*State
+	seen    map[string]int // order ID to the number of its fills seen
+	logger  *slog.Logger
+	me      string
+}
+
+// Option configures a [Maker].
+type Option func(*Maker)
+
+// WithLogger sets the logger the maker reports its orders and fills to. By default nothing is logged.
+func WithLogger(l *slog.Logger) Option {
+	return func(mk *Maker) {
+		mk.logger = l
+	}
+}
+
+// New returns a maker for the given markets.
+func New(mc *mango.Client, markets []Market, opts ...Option) (*Maker, error) {
+	mk := &Maker{
+		mc:     mc,
+		states: map[string]*State{},
+		seen:   map[string]int{},
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	for _, opt := range opts {
+		opt(mk)
+	}
+
+	for _, m := range markets {
+		if m.Id == "" {
+			return nil, fmt.Errorf("market must have an id")
+		}
+		if _, ok := mk.states[m.Id]; ok {
+			return nil, fmt.Errorf("duplicate market %v", m.Id)
+		}
+		if m.Fair == nil {
+			return nil, fmt.Errorf("market %v must have a fair value", m.Id)
+		}
+
+		if m.Levels == 0 {
+			m.Levels = 3
+		}
+		if m.Spread == 0 {
+			m.Spread = 0.02
+		}
+		if m.Step == 0 {
+			m.Step = 0.02
+		}
+		if m.Size == 0 {
+			m.Size = 10
+		}
+
+		switch {
+		case m.Levels < 0:
+			return nil, fmt.Errorf("market %v: levels must not be negative, got %d", m.Id, m.Levels)
+		case m.Spread < 0.01 || m.Step < 0.01:
+			return nil, fmt.Errorf("market %v: spread and step must be at least 0.01", m.Id)
+		case m.Size < minOrder:
+			return nil, fmt.Errorf("market %v: size must be at least M%d", m.Id, minOrder)
+		case m.MaxInventory < 0 || m.MaxLoss < 0:
+			return nil, fmt.Errorf("market %v: limits must not be negative", m.Id)
+		}
+
+		mk.markets = append(mk.markets, m)
+		mk.states[m.Id] = &State{MarketId: m.Id}
+	}
+
+	return mk, nil
+}
+
+// State returns the state of a market after the maker's last run.
+func (mk *Maker) State(marketId string) (State, bool) {
+	st, ok := mk.states[marketId]
+	if !ok {
+		return State{}, false
+	}
+	return *st, true
+}
+
+// Run calls [Maker.RunOnce] every interval until ctx is cancelled, and then cancels every
+// open order. Errors are logged rather than returned, apart from those cancelling the orders.
+func (mk *Maker) Run(ctx context.Context, interval time.Duration) error {
+	for {
+		if err := mk.RunOnce(ctx); err != nil {
+			mk.logger.Error("market making run failed", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(ctx.Err(), mk.CancelAll())
+		case <-time.After(interval):
+		}
+	}
+}
+
+// RunOnce checks every market that hasn't been halted for fills and updates its quotes.
+// It carries on past errors, returning them all at the end.
+func (mk *Maker) RunOnce(ctx context.Context) error {
+	if mk.me == "" {
+		me, err := mk.mc.GetAuthenticatedUser()
+		if err != nil {
+			return fmt.Errorf("error getting authenticated user: %w", err)
+		}
+		mk.me = me.Id
+	}
+
+	var errs []error
+	for _, m := range mk.markets {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if mk.states[m.Id].Halted {
+			continue
+		}
+		if err := mk.quote(ctx, m); err != nil {
+			errs = append(errs, fmt.Errorf("market %v: %w", m.Id, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// CancelAll cancels the maker's open orders in every market.
+func (mk *Maker) CancelAll() error {
+	var errs []error
+	for _, m := range mk.markets {
+		st := mk.states[m.Id]
+		if mk.me != "" {
+			bets, err := mk.bets(m.Id)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("market %v: %w", m.Id, err))
+				continue
+			}
+			mk.update(st, bets)
+		}
+		if err := mk.cancel(st, st.Orders); err != nil {
+			errs = append(errs, fmt.Errorf("market %v: %w", m.Id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// quote brings the maker's position and orders in a market up to date.
+func (mk *Maker) quote(ctx context.Context, cfg Market) error {
+	st := mk.states[cfg.Id]
+
+	m, err := mk.mc.GetMarketByID(cfg.Id)
+	if err != nil {
+		return fmt.Errorf("error getting market: %w", err)
+	}
+	if m.OutcomeType != mango.Binary {
+		return mk.halt(st, fmt.Sprintf("market is %v, not binary", m.OutcomeType))
+	}
+
+	bets, err := mk.bets(cfg.Id)
+	if err != nil {
+		return err
+	}
+	filled := mk.update(st, bets)
+	st.Prob = m.Probability
+
+	if m.IsResolved || (m.CloseTime != 0 && m.CloseTime <= time.Now().UnixMilli()) {
+		return mk.halt(st, "market is closed")
+	}
+
+	fair, err := cfg.Fair.FairValue(ctx, *m)
+	if err != nil {
+		return fmt.Errorf("error getting fair value: %w", err)
+	}
+	if fair <= 0 || fair >= 1 {
+		return fmt.Errorf("fair value must be between 0 and 1, got %v", fair)
+	}
+	st.Fair = fair
+	st.Profit = st.YesShares*fair + st.NoShares*(1-fair) - st.Invested
+
+	if cfg.MaxLoss > 0 && st.Profit < -cfg.MaxLoss {
+		return mk.halt(st, fmt.Sprintf("lost M%.2f, more than the limit of M%.2f", -st.Profit, cfg.MaxLoss))
+	}
+
+	// keep the orders that are still where they should be, and haven't been filled since
+	// they were last looked at, and replace the rest
+	want := cfg.ladder(fair, st.Inventory())
+	placed := make([]bool, len(want))
+	var keep, stale []mango.Bet
+	for _, o := range st.Orders {
+		i := matchOrder(want, placed, o)
+		if i < 0 || filled[o.Id] {
+			stale = append(stale, o)
+			continue
+		}
+		placed[i] = true
+		keep = append(keep, o)
+	}
+
+	errs := []error{mk.cancel(st, stale)}
+	st.Orders = keep
+
+	for i, q := range want {
+		if placed[i] {
+			continue
+		}
+
+		limit := q.limit
+		bet, err := mk.mc.PostBet(mango.PostBetRequest{
+			ContractId: cfg.Id,
+			Outcome:    q.outcome,
+			Amount:     q.amount,
+			LimitProb:  &limit,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error placing %v order at %v: %w", q.outcome, q.limit, err))
+			continue
+		}
+		if bet.Id == "" {
+			bet.Id = bet.BetId
+		}
+		mk.seen[bet.Id] = len(bet.Fills)
+		mk.logger.Info("placed order", "market", cfg.Id, "outcome", q.outcome, "limit", q.limit, "amount", q.amount)
+
+		if !bet.IsFilled && !bet.IsCancelled {
+			st.Orders = append(st.Orders, *bet)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// bets returns all of the maker's bets in a market.
+func (mk *Maker) bets(marketId string) ([]mango.Bet, error) {
+	var out []mango.Bet
+	err := mk.mc.PageBets(mango.GetBetsRequest{UserId: mk.me, ContractId: marketId}, func(bets []mango.Bet) error {
+		out = append(out, bets...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting bets: %w", err)
+	}
+	return out, nil
+}
+
+// update works out the maker's position and open orders in a market from its bets, and
+// returns the IDs of the orders with fills that haven't been seen before.
+func (mk *Maker) update(st *State, bets []mango.Bet) map[string]bool {
+	st.YesShares, st.NoShares, st.Invested = 0, 0, 0
+	st.Orders = nil
+	filled := map[string]bool{}
+
+	for _, b := range bets {
+		if b.AnswerId != "" {
+			continue
+		}
+
+		switch b.Outcome {
+		case "YES":
+			st.YesShares += b.Shares
+		case "NO":
+			st.NoShares += b.Shares
+		}
+		st.Invested += b.Amount
+
+		if b.OrderAmount == 0 {
+			continue
+		}
+		if n := len(b.Fills); n > mk.seen[b.Id] {
+			mk.logger.Info("order filled", "market", b.ContractId, "outcome", b.Outcome, "limit", b.LimitProb, "filled", b.Amount, "of", b.OrderAmount)
+			st.Fills += n - mk.seen[b.Id]
+			mk.seen[b.Id] = n
+			filled[b.Id] = true
+		}
+		if !b.IsFilled && !b.IsCancelled {
+			st.Orders = append(st.Orders, b)
+		}
+	}
+
+	return filled
+}
+
+// cancel cancels orders and removes them from the market's state.
+func (mk *Maker) cancel(st *State, orders []mango.Bet) error {
+	var errs []error
+	cancelled := map[string]bool{}
+	for _, o := range orders {
+		if err := mk.mc.CancelBet(o.Id); err != nil {
+			errs = append(errs, fmt.Errorf("error cancelling order %v: %w", o.Id, err))
+			continue
+		}
+		cancelled[o.Id] = true
+		mk.logger.Info("cancelled order", "market", st.MarketId, "outcome", o.Outcome, "limit", o.LimitProb)
+	}
+
+	var open []mango.Bet
+	for _, o := range st.Orders {
+		if !cancelled[o.Id] {
+			open = append(open, o)
+		}
+	}
+	st.Orders = open
+
+	return errors.Join(errs...)
+}
+
+// halt stops quoting a market and cancels its orders.
+func (mk *Maker) halt(st *State, reason string) error {
+	mk.logger.Warn("halting market", "market", st.MarketId, "reason", reason)
+	st.Halted, st.Reason = true, reason
+	return mk.cancel(st, st.Orders)
+}
+
+// order represents an order the maker wants to have open.
+type order struct {
+	outcome string
+	limit   float64
+	amount  float64
+}
+
+// ladder returns the orders to quote a market with, given its fair value and the shares
+// of YES held, net of NO.
+func (cfg Market) ladder(fair, inventory float64) []order {
+	// room is how many more shares of each outcome can be bought before reaching the
+	// inventory limit
+	yesRoom, noRoom := math.Inf(1), math.Inf(1)
+	if cfg.MaxInventory > 0 {
+		yesRoom = cfg.MaxInventory - inventory
+		noRoom = cfg.MaxInventory + inventory
+	}
+
+	var out []order
+	for i := 0; i < cfg.Levels; i++ {
+		offset := cfg.Spread + float64(i)*cfg.Step
+		if o, ok := rung("YES", fair-offset, cfg.Size, &yesRoom); ok {
+			out = append(out, o)
+		}
+		if o, ok := rung("NO", fair+offset, cfg.Size, &noRoom); ok {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// rung returns an order at limit of at most size, and small enough that filling it
+// doesn't buy more than room shares, which it takes the order's shares from.
+func rung(outcome string, limit, size float64, room *float64) (order, bool) {
+	limit = math.Round(limit*100) / 100
+	if limit < 0.01 || limit > 0.99 {
+		return order{}, false
+	}
+
+	// shares bought by an order cost its limit if it is for YES, and the rest if for NO
+	price := limit
+	if outcome == "NO" {
+		price = 1 - limit
+	}
+
+	amount := math.Floor(math.Min(size, *room*price)*100) / 100
+	if amount < minOrder {
+		return order{}, false
+	}
+	*room -= amount / price
+
+	return order{outcome, limit, amount}, true
+}
+
+// matchOrder returns the index of the wanted order that o matches and that hasn't been
+// placed yet, or -1 if there isn't one.
+func matchOrder(want []order, placed []bool, o mango.Bet) int {
+	for i, w := range want {
+		if placed[i] || w.outcome != o.Outcome {
+			continue
+		}
+		if math.Abs(w.limit-o.LimitProb) < 1e-9 && math.Abs(w.amount-(o.OrderAmount-o.Amount)) < 0.01 {
+			return i
+		}
+	}
+	return -1
+}
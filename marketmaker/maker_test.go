@@ -0,0 +1,229 @@
+package marketmaker
+
+import (
+	"context"
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/jonnyspicer/mango"
+	"github.com/jonnyspicer/mango/mangotest"
+)
+
+func newTestServer(t *testing.T) *mangotest.Server {
+	t.Helper()
+
+	s := mangotest.NewServer()
+	t.Cleanup(s.Close)
+
+	s.AddMarket(mango.FullMarket{Id: "m1", Question: "Will it rain?", Pool: mango.Pool{"YES": 1000, "NO": 1000}, P: 0.5})
+
+	return s
+}
+
+// limits returns the limits of a market's open orders for each outcome, in order.
+func limits(st State) map[string][]float64 {
+	out := map[string][]float64{}
+	for _, o := range st.Orders {
+		out[o.Outcome] = append(out[o.Outcome], o.LimitProb)
+	}
+	for _, l := range out {
+		sort.Float64s(l)
+	}
+	return out
+}
+
+func TestQuote(t *testing.T) {
+	s := newTestServer(t)
+
+	mk, err := New(s.Client(), []Market{{Id: "m1", Fair: Current(), Levels: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mk.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	st, _ := mk.State("m1")
+	want := map[string][]float64{"YES": {0.46, 0.48}, "NO": {0.52, 0.54}}
+	if got := limits(st); !equal(got, want) {
+		t.Fatalf("expected orders at %v, got %v", want, got)
+	}
+	if b := s.Me().Balance; b != 960 {
+		t.Errorf("expected M40 to be reserved for orders, got balance %v", b)
+	}
+
+	// nothing has changed, so the orders are left alone
+	if err := mk.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.Bets()); n != 4 {
+		t.Errorf("expected the same 4 orders, got %d bets", n)
+	}
+
+	// someone sells through the nearest YES order
+	if _, err := s.Trade("m1", "NO", 60); err != nil {
+		t.Fatal(err)
+	}
+	if err := mk.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	st, _ = mk.State("m1")
+	if st.Fills != 1 || st.YesShares <= 0 || st.Inventory() <= 0 {
+		t.Fatalf("expected a fill of YES shares, got %+v", st)
+	}
+	if math.Abs(st.Invested-10) > 1e-6 {
+		t.Errorf("expected M10 invested, got %v", st.Invested)
+	}
+
+	// the market has moved, so the ladder follows it
+	m, _ := s.Market("m1")
+	if st.Prob != m.Probability || st.Fair != m.Probability {
+		t.Errorf("expected the fair value to be the market's probability %v, got %+v", m.Probability, st)
+	}
+	nearest := math.Round((m.Probability-0.02)*100) / 100
+	if got := limits(st); len(got["YES"]) != 2 || got["YES"][1] != nearest || len(got["NO"]) != 2 {
+		t.Errorf("expected the ladder to be requoted around %v, got %v", m.Probability, got)
+	}
+
+	if err := mk.CancelAll(); err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range s.Bets() {
+		if b.UserId == s.Me().Id && b.OrderAmount > 0 && !b.IsFilled && !b.IsCancelled {
+			t.Errorf("expected every order to be cancelled, got %+v", b)
+		}
+	}
+}
+
+func TestInventoryLimit(t *testing.T) {
+	s := newTestServer(t)
+
+	mk, err := New(s.Client(), []Market{{Id: "m1", Fair: Fixed(0.5), Levels: 2, MaxInventory: 30}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mk.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first YES order buys about 20.8 shares, so the second is cut down to fit within
+	// the limit of 30
+	st, _ := mk.State("m1")
+	var yes float64
+	for _, o := range st.Orders {
+		if o.Outcome == "YES" {
+			yes += o.OrderAmount / o.LimitProb
+		}
+	}
+	if yes > 30+1e-6 || yes < 29 {
+		t.Errorf("expected YES orders for just under 30 shares, got %v", yes)
+	}
+
+	// once the YES orders are filled, there is no room to buy any more
+	if _, err := s.Trade("m1", "NO", 200); err != nil {
+		t.Fatal(err)
+	}
+	if err := mk.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	st, _ = mk.State("m1")
+	if got := limits(st); len(got["YES"]) != 0 || len(got["NO"]) != 2 {
+		t.Errorf("expected only NO orders, got %v", got)
+	}
+}
+
+func TestLossLimit(t *testing.T) {
+	s := newTestServer(t)
+
+	fair := 0.5
+	source := FairValueFunc(func(context.Context, mango.FullMarket) (float64, error) { return fair, nil })
+
+	mk, err := New(s.Client(), []Market{{Id: "m1", Fair: source, Levels: 1, MaxLoss: 5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mk.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Trade("m1", "NO", 100); err != nil {
+		t.Fatal(err)
+	}
+
+	// the YES shares just bought at 0.48 are worth much less if the fair value falls to 0.2
+	fair = 0.2
+	if err := mk.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	st, _ := mk.State("m1")
+	if !st.Halted || len(st.Orders) != 0 || st.Profit > -5 {
+		t.Fatalf("expected the market to be halted, got %+v", st)
+	}
+
+	n := len(s.Bets())
+	if err := mk.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Bets()) != n {
+		t.Error("expected no more orders once halted")
+	}
+}
+
+func TestNew(t *testing.T) {
+	s := newTestServer(t)
+
+	invalid := [][]Market{
+		{{Id: "m1"}},
+		{{Fair: Current()}},
+		{{Id: "m1", Fair: Current()}, {Id: "m1", Fair: Current()}},
+		{{Id: "m1", Fair: Current(), Spread: 0.001}},
+		{{Id: "m1", Fair: Current(), Size: 0.5}},
+		{{Id: "m1", Fair: Current(), MaxLoss: -1}},
+	}
+	for _, markets := range invalid {
+		if _, err := New(s.Client(), markets); err == nil {
+			t.Errorf("expected an error for %+v", markets)
+		}
+	}
+}
+
+func TestAverage(t *testing.T) {
+	failing := FairValueFunc(func(context.Context, mango.FullMarket) (float64, error) {
+		return 0, context.Canceled
+	})
+
+	p, err := Average([]FairValue{Fixed(0.2), Fixed(0.8), failing}, 3, 1).FairValue(context.Background(), mango.FullMarket{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(p-0.35) > 1e-9 {
+		t.Errorf("expected 0.35, got %v", p)
+	}
+
+	if _, err := Average([]FairValue{failing}).FairValue(context.Background(), mango.FullMarket{}); err == nil {
+		t.Error("expected an error when every source fails")
+	}
+}
+
+func equal(a, b map[string][]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, x := range a {
+		y := b[k]
+		if len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if math.Abs(x[i]-y[i]) > 1e-9 {
+				return false
+			}
+		}
+	}
+	return true
+}
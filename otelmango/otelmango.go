@@ -0,0 +1,172 @@
+// Package otelmango provides OpenTelemetry instrumentation for the mango client.
+//
+// It records a span for every request made to the Manifold API, along with
+// metrics for request latency, retries, rate limiter waits and errors:
+//
+//	mc := mango.ClientInstance(nil, nil, nil, otelmango.Instrument()...)
+//
+// Spans are children of the span in the context of the request, so make requests with
+// [mango.Client.WithContext] to have them show up in the caller's trace:
+//
+//	market, err := mc.WithContext(ctx).GetMarketByID(id)
+//
+// By default the global tracer and meter providers are used.
+//
+// otelmango is a module of its own, so that programs that don't use it don't depend on
+// the OpenTelemetry SDK.
+package otelmango
+
+import (
+	"context"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const scope = "github.com/jonnyspicer/mango/otelmango"
+
+// Attribute keys recorded on spans and metrics.
+const (
+	EndpointKey = attribute.Key("mango.endpoint")
+	MarketIdKey = attribute.Key("mango.market_id")
+	AttemptKey  = attribute.Key("mango.attempt")
+	MethodKey   = attribute.Key("http.request.method")
+	StatusKey   = attribute.Key("http.response.status_code")
+)
+
+type config struct {
+	tp trace.TracerProvider
+	mp metric.MeterProvider
+}
+
+// Option configures the instrumentation returned by [Instrument].
+type Option func(*config)
+
+// WithTracerProvider sets the TracerProvider used to create spans.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tp = tp
+	}
+}
+
+// WithMeterProvider sets the MeterProvider used to create metric instruments.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.mp = mp
+	}
+}
+
+type instruments struct {
+	tracer   trace.Tracer
+	duration metric.Float64Histogram
+	requests metric.Int64Counter
+	retries  metric.Int64Counter
+	errors   metric.Int64Counter
+	waits    metric.Float64Histogram
+}
+
+// Instrument returns the [mango.ClientOption] needed to instrument a client.
+// Pass them to [mango.ClientInstance] when creating the client.
+func Instrument(opts ...Option) []mango.ClientOption {
+	c := config{
+		tp: otel.GetTracerProvider(),
+		mp: otel.GetMeterProvider(),
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	in := newInstruments(c)
+
+	return []mango.ClientOption{mango.WithAfterRequest(in.record)}
+}
+
+func newInstruments(c config) *instruments {
+	meter := c.mp.Meter(scope)
+
+	// creating an instrument only fails for invalid names, in which case
+	// the returned no-op instrument is still safe to use
+	duration, _ := meter.Float64Histogram("mango.request.duration",
+		metric.WithDescription("Duration of requests to the Manifold API."),
+		metric.WithUnit("s"))
+	requests, _ := meter.Int64Counter("mango.requests",
+		metric.WithDescription("Number of requests made to the Manifold API, including retries."),
+		metric.WithUnit("{request}"))
+	retries, _ := meter.Int64Counter("mango.request.retries",
+		metric.WithDescription("Number of requests that were retries of an earlier attempt."),
+		metric.WithUnit("{request}"))
+	errors, _ := meter.Int64Counter("mango.request.errors",
+		metric.WithDescription("Number of requests that failed or received a non-2xx response."),
+		metric.WithUnit("{request}"))
+	waits, _ := meter.Float64Histogram("mango.ratelimit.wait",
+		metric.WithDescription("Time spent waiting on the client rate limiter before sending a request."),
+		metric.WithUnit("s"))
+
+	return &instruments{
+		tracer:   c.tp.Tracer(scope),
+		duration: duration,
+		requests: requests,
+		retries:  retries,
+		errors:   errors,
+		waits:    waits,
+	}
+}
+
+func (in *instruments) record(info mango.RequestInfo) {
+	ctx := info.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	end := time.Now()
+	start := end.Add(-info.Latency)
+
+	attrs := []attribute.KeyValue{
+		MethodKey.String(info.Method),
+		EndpointKey.String(info.Endpoint),
+		StatusKey.Int(info.StatusCode),
+	}
+	metricAttrs := metric.WithAttributes(attrs...)
+
+	spanAttrs := append(attrs, AttemptKey.Int(info.Attempt))
+	if info.MarketId != "" {
+		spanAttrs = append(spanAttrs, MarketIdKey.String(info.MarketId))
+	}
+
+	_, span := in.tracer.Start(ctx, info.Method+" "+info.Endpoint,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(spanAttrs...),
+	)
+
+	failed := info.Err != nil || info.StatusCode < 200 || info.StatusCode > 299
+	if info.Err != nil {
+		span.RecordError(info.Err, trace.WithTimestamp(end))
+		span.SetStatus(codes.Error, info.Err.Error())
+	} else if failed {
+		span.SetStatus(codes.Error, "non-2xx status code")
+	}
+
+	if info.RateLimitWait > 0 {
+		span.AddEvent("rate limit wait", trace.WithTimestamp(start), trace.WithAttributes(
+			attribute.Float64("mango.ratelimit.wait", info.RateLimitWait.Seconds()),
+		))
+	}
+
+	span.End(trace.WithTimestamp(end))
+
+	in.requests.Add(ctx, 1, metricAttrs)
+	in.duration.Record(ctx, info.Latency.Seconds(), metricAttrs)
+	in.waits.Record(ctx, info.RateLimitWait.Seconds(), metric.WithAttributes(EndpointKey.String(info.Endpoint)))
+
+	if info.Attempt > 0 {
+		in.retries.Add(ctx, 1, metricAttrs)
+	}
+	if failed {
+		in.errors.Add(ctx, 1, metricAttrs)
+	}
+}
@@ -0,0 +1,131 @@
+package otelmango
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jonnyspicer/mango"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInstrument(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v0/market/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":"abc123"}`))
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	key := "test-api-key"
+	mc := mango.ClientInstance(server.Client(), &server.URL, &key,
+		Instrument(WithTracerProvider(tp), WithMeterProvider(mp))...)
+	defer mc.Destroy()
+
+	if _, err := mc.GetMarketByID("abc123"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := mc.GetMarketByID("missing"); err == nil {
+		t.Fatal("expected an error for a missing market")
+	}
+
+	ended := spans.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(ended))
+	}
+
+	span := ended[0]
+	if span.Name() != "GET /v0/market/{id}" {
+		t.Errorf("unexpected span name %q", span.Name())
+	}
+
+	attrs := attribute.NewSet(span.Attributes()...)
+	if v, _ := attrs.Value(MarketIdKey); v.AsString() != "abc123" {
+		t.Errorf("expected market id attribute abc123, got %q", v.AsString())
+	}
+	if v, _ := attrs.Value(StatusKey); v.AsInt64() != http.StatusOK {
+		t.Errorf("expected status attribute 200, got %d", v.AsInt64())
+	}
+
+	if ended[1].Status().Code != codes.Error {
+		t.Errorf("expected failed request span to have error status, got %v", ended[1].Status())
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("error collecting metrics: %v", err)
+	}
+
+	sums := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					sums[m.Name] += dp.Value
+				}
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					sums[m.Name] += int64(dp.Count)
+				}
+			}
+		}
+	}
+
+	if sums["mango.requests"] != 2 {
+		t.Errorf("expected 2 requests recorded, got %d", sums["mango.requests"])
+	}
+	if sums["mango.request.errors"] != 1 {
+		t.Errorf("expected 1 error recorded, got %d", sums["mango.request.errors"])
+	}
+	if sums["mango.request.duration"] != 2 {
+		t.Errorf("expected 2 latency observations, got %d", sums["mango.request.duration"])
+	}
+}
+
+func TestInstrumentParentSpan(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"abc123"}`))
+	}))
+	defer server.Close()
+
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+
+	key := "test-api-key"
+	mc := mango.ClientInstance(server.Client(), &server.URL, &key, Instrument(WithTracerProvider(tp))...)
+	defer mc.Destroy()
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "caller")
+	if _, err := mc.WithContext(ctx).GetMarketByID("abc123"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parent.End()
+
+	ended := spans.Ended()
+	if len(ended) != 2 || ended[0].Name() != "GET /v0/market/{id}" {
+		t.Fatalf("expected a request span and the caller's span, got %v", ended)
+	}
+	if got, want := ended[0].Parent().SpanID(), parent.SpanContext().SpanID(); got != want {
+		t.Errorf("expected the request span to be a child of %v, got %v", want, got)
+	}
+	if ended[0].SpanContext().TraceID() != parent.SpanContext().TraceID() {
+		t.Errorf("expected the request span to be in the caller's trace")
+	}
+}
@@ -0,0 +1,198 @@
+package mango
+
+import "errors"
+
+// ErrStopPagination can be returned by the callback passed to a paging method such as
+// [Client.PageBets] to stop fetching pages. The paging method then returns nil.
+var ErrStopPagination = errors.New("stop pagination")
+
+// PageBets calls fn with each page of bets matching req, newest first, until there are no
+// more bets, fn returns an error, or a request fails. req.Before sets where to start and
+// req.Limit sets the page size, which defaults to the maximum of 1000.
+func (mc *Client) PageBets(req GetBetsRequest, fn func([]Bet) error) error {
+	if req.Limit == 0 {
+		req.Limit = defaultLimit
+	}
+
+	return paginate(req.Limit, fn, func() ([]Bet, error) {
+		page, err := mc.GetBets(req)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(*page); n > 0 {
+			req.Before = (*page)[n-1].Id
+		}
+		return *page, nil
+	})
+}
+
+// PageMarkets calls fn with each page of markets, newest first, until there are no more
+// markets, fn returns an error, or a request fails. req.Before sets where to start and
+// req.Limit sets the page size, which defaults to the maximum of 1000.
+func (mc *Client) PageMarkets(req GetMarketsRequest, fn func([]LiteMarket) error) error {
+	if req.Limit == 0 {
+		req.Limit = defaultLimit
+	}
+
+	return paginate(req.Limit, fn, func() ([]LiteMarket, error) {
+		page, err := mc.GetMarkets(req)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(*page); n > 0 {
+			req.Before = (*page)[n-1].Id
+		}
+		return *page, nil
+	})
+}
+
+// PageUsers calls fn with each page of users, newest first, until there are no more
+// users, fn returns an error, or a request fails. req.Before sets where to start and
+// req.Limit sets the page size, which defaults to the maximum of 1000.
+func (mc *Client) PageUsers(req GetUsersRequest, fn func([]User) error) error {
+	if req.Limit == 0 {
+		req.Limit = defaultLimit
+	}
+
+	return paginate(req.Limit, fn, func() ([]User, error) {
+		page, err := mc.GetUsers(req)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(*page); n > 0 {
+			req.Before = (*page)[n-1].Id
+		}
+		return *page, nil
+	})
+}
+
+// PageComments calls fn with each page of comments matching req until there are no more
+// comments, fn returns an error, or a request fails. req.Page sets the page to start at
+// and req.Limit the page size, which defaults to the maximum of 1000. Use it with
+// [GetCommentsRequest.UserId] to fetch every comment a user has made.
+func (mc *Client) PageComments(req GetCommentsRequest, fn func([]Comment) error) error {
+	if req.Limit == 0 {
+		req.Limit = defaultLimit
+	}
+
+	return paginate(req.Limit, fn, func() ([]Comment, error) {
+		page, err := mc.GetComments(req)
+		if err != nil {
+			return nil, err
+		}
+		req.Page++
+		return *page, nil
+	})
+}
+
+// PageSearchMarkets calls fn with each page of the markets matching req until there are no
+// more markets, fn returns an error, or a request fails. req.Offset sets where to start and
+// req.Limit sets the page size, which defaults to 100. Markets can move between pages as
+// they are traded unless req.Sort is "newest".
+func (mc *Client) PageSearchMarkets(req SearchMarketsRequest, fn func([]FullMarket) error) error {
+	if req.Limit == 0 {
+		req.Limit = 100
+	}
+
+	return paginate(req.Limit, fn, func() ([]FullMarket, error) {
+		page, err := mc.SearchMarkets(req)
+		if err != nil {
+			return nil, err
+		}
+		req.Offset += int64(len(*page))
+		return *page, nil
+	})
+}
+
+// PageGroupMarkets calls fn with each page of the markets in a group until there are no
+// more markets, fn returns an error, or a request fails. Unlike [Client.GetMarketsForGroup],
+// which takes the group's id, the group is given by its slug, [Group.Slug], because the
+// markets are found with [Client.SearchMarkets]. Other fields of req, such as Filter and
+// ContractType, narrow the markets further. req.Sort defaults to "newest" so that pages
+// don't shift as markets are traded, req.Offset sets where to start and req.Limit sets the
+// page size, which defaults to 100.
+func (mc *Client) PageGroupMarkets(groupSlug string, req SearchMarketsRequest, fn func([]FullMarket) error) error {
+	if groupSlug == "" {
+		return errors.New("a group slug is required")
+	}
+	req.TopicSlug = groupSlug
+	if req.Sort == "" {
+		req.Sort = "newest"
+	}
+
+	return mc.PageSearchMarkets(req, fn)
+}
+
+// PageTransactions calls fn with each page of transactions matching req until there are
+// no more transactions, fn returns an error, or a request fails. req.Offset sets where
+// to start and req.Limit sets the page size, which defaults to 100.
+func (mc *Client) PageTransactions(req GetTransactionsRequest, fn func([]Txn) error) error {
+	if req.Limit == 0 {
+		req.Limit = 100
+	}
+
+	return paginate(req.Limit, fn, func() ([]Txn, error) {
+		page, err := mc.GetTransactions(req)
+		if err != nil {
+			return nil, err
+		}
+		req.Offset += int64(len(*page))
+		return *page, nil
+	})
+}
+
+// PageUserContractMetrics calls fn with each page of a user's contract metrics until there
+// are no more, fn returns an error, or a request fails. req.Offset sets where to start and
+// req.Limit sets the page size, which defaults to 1000.
+func (mc *Client) PageUserContractMetrics(req GetUserContractMetricsRequest, fn func(UserContractMetricsResponse) error) error {
+	if req.Limit == 0 {
+		req.Limit = defaultLimit
+	}
+
+	for {
+		page, err := mc.GetUserContractMetricsWithContracts(req)
+		if err != nil {
+			return err
+		}
+		if len(page.Contracts) == 0 {
+			return nil
+		}
+
+		if err := fn(*page); err != nil {
+			if errors.Is(err, ErrStopPagination) {
+				return nil
+			}
+			return err
+		}
+
+		if int64(len(page.Contracts)) < req.Limit {
+			return nil
+		}
+		req.Offset += int64(len(page.Contracts))
+	}
+}
+
+// paginate calls fetch until it returns an empty or short page, passing each page to fn.
+// fetch is responsible for moving its request on to the next page.
+func paginate[T any](limit int64, fn func([]T) error, fetch func() ([]T, error)) error {
+	for {
+		page, err := fetch()
+		if err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			return nil
+		}
+
+		if err := fn(page); err != nil {
+			if errors.Is(err, ErrStopPagination) {
+				return nil
+			}
+			return err
+		}
+
+		if int64(len(page)) < limit {
+			return nil
+		}
+	}
+}
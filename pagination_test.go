@@ -0,0 +1,161 @@
+package mango
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// betsHandler serves n bets with IDs "0" to "n-1", newest (highest) first, honouring before and limit.
+func betsHandler(t *testing.T, n int, requests *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		start := n - 1
+		if b := r.URL.Query().Get("before"); b != "" {
+			id, err := strconv.Atoi(b)
+			if err != nil {
+				t.Errorf("unexpected before %q", b)
+			}
+			start = id - 1
+		}
+
+		page := []Bet{}
+		for i := start; i >= 0 && len(page) < limit; i-- {
+			page = append(page, Bet{Id: strconv.Itoa(i)})
+		}
+		json.NewEncoder(w).Encode(page)
+	})
+}
+
+func TestPageBets(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(betsHandler(t, 25, &requests))
+	defer server.Close()
+
+	mc := ClientInstance(server.Client(), &server.URL, &testKey)
+	defer mc.Destroy()
+
+	var ids []string
+	err := mc.PageBets(GetBetsRequest{Limit: 10}, func(page []Bet) error {
+		for _, b := range page {
+			ids = append(ids, b.Id)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ids) != 25 || ids[0] != "24" || ids[24] != "0" {
+		t.Errorf("unexpected ids %v", ids)
+	}
+	if requests != 3 {
+		t.Errorf("expected 3 requests, got %d", requests)
+	}
+}
+
+func TestPageBetsStop(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(betsHandler(t, 25, &requests))
+	defer server.Close()
+
+	mc := ClientInstance(server.Client(), &server.URL, &testKey)
+	defer mc.Destroy()
+
+	pages := 0
+	err := mc.PageBets(GetBetsRequest{Limit: 10}, func(page []Bet) error {
+		pages++
+		return ErrStopPagination
+	})
+	if err != nil || pages != 1 || requests != 1 {
+		t.Errorf("expected to stop after one page, got %d pages, %d requests, err %v", pages, requests, err)
+	}
+
+	boom := errors.New("boom")
+	err = mc.PageBets(GetBetsRequest{Limit: 10}, func(page []Bet) error {
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Errorf("expected the callback's error, got %v", err)
+	}
+}
+
+func TestPageTransactions(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+		page := []Txn{}
+		for i := offset; i < 7 && len(page) < limit; i++ {
+			page = append(page, Txn{Id: strconv.Itoa(i)})
+		}
+		json.NewEncoder(w).Encode(page)
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	mc := ClientInstance(server.Client(), &server.URL, &testKey)
+	defer mc.Destroy()
+
+	var n int
+	err := mc.PageTransactions(GetTransactionsRequest{Limit: 3}, func(page []Txn) error {
+		for _, txn := range page {
+			if txn.Id != strconv.Itoa(n) {
+				t.Errorf("expected txn %d, got %v", n, txn.Id)
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 7 {
+		t.Errorf("expected 7 transactions, got %d", n)
+	}
+}
+
+func TestPageComments(t *testing.T) {
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("userId") != "u1" {
+			t.Errorf("unexpected request %v", r.URL)
+		}
+		pages = append(pages, q.Get("page"))
+
+		page, _ := strconv.Atoi(q.Get("page"))
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		out := []Comment{}
+		for i := page * limit; i < 7 && len(out) < limit; i++ {
+			out = append(out, Comment{Id: strconv.Itoa(i)})
+		}
+		json.NewEncoder(w).Encode(out)
+	}))
+	defer server.Close()
+
+	mc := ClientInstance(server.Client(), &server.URL, &testKey)
+	defer mc.Destroy()
+
+	var n int
+	err := mc.PageComments(GetCommentsRequest{UserId: "u1", Limit: 3}, func(page []Comment) error {
+		n += len(page)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 7 {
+		t.Errorf("expected 7 comments, got %d", n)
+	}
+	if len(pages) != 3 || pages[0] != "" || pages[2] != "2" {
+		t.Errorf("unexpected pages %q", pages)
+	}
+}
@@ -1,5 +1,11 @@
 package mango
 
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
 // LivePortfolioMetrics represents a user's current portfolio state.
 type LivePortfolioMetrics struct {
 	InvestmentValue     float64 `json:"investmentValue"`
@@ -35,3 +41,264 @@ const (
 	PeriodMonthly PortfolioPeriod = "monthly"
 	PeriodAllTime PortfolioPeriod = "allTime"
 )
+
+// Portfolio combines a user's live portfolio metrics, their portfolio history and their
+// position in every market they have bet on, and answers questions about them: where their
+// mana is at risk, how exposed they are to each group, where their profit came from, and what
+// they would be worth if markets resolved one way or another.
+//
+// Use [Client.GetPortfolio] to build one.
+type Portfolio struct {
+	UserId    string               `json:"userId"`
+	Live      LivePortfolioMetrics `json:"live"`
+	History   []PortfolioMetrics   `json:"history,omitempty"`
+	Positions []Position           `json:"positions"`
+}
+
+// Position represents a user's holding in a market, or in one answer of a multiple choice market.
+type Position struct {
+	Market   FullMarket     `json:"market"`
+	AnswerId string         `json:"answerId,omitempty"`
+	Metric   ContractMetric `json:"metric"`
+}
+
+// GroupExposure represents the positions a user holds in the markets of one group.
+// Positions in markets that are not in any group have an empty Slug.
+type GroupExposure struct {
+	Slug      string  `json:"slug"`
+	Positions int     `json:"positions"`
+	Invested  float64 `json:"invested"`
+	Value     float64 `json:"value"`
+	AtRisk    float64 `json:"atRisk"`
+	Profit    float64 `json:"profit"`
+}
+
+// PnL breaks a user's profit down by where it came from. Loans aren't profit, so none of
+// it counts them; the user's outstanding loans are in [LivePortfolioMetrics.LoanTotal].
+type PnL struct {
+	// Realized is the profit locked in by selling shares in markets that haven't resolved.
+	Realized float64 `json:"realized"`
+	// Unrealized is the current value of the shares still held in markets that haven't
+	// resolved, less what was paid for them. It moves with the markets' prices.
+	Unrealized float64 `json:"unrealized"`
+	// Resolution is the profit on markets that have resolved.
+	Resolution float64 `json:"resolution"`
+	// Total is the sum of Realized, Unrealized and Resolution.
+	Total float64 `json:"total"`
+}
+
+// GetPortfolio builds the [Portfolio] of the user with the given id. If period is not
+// empty, the user's portfolio history over that period is fetched as well.
+//
+// Every contract metric the user has is fetched, which for active users can take several
+// requests.
+func (mc *Client) GetPortfolio(userId string, period PortfolioPeriod) (*Portfolio, error) {
+	if userId == "" {
+		return nil, fmt.Errorf("userId is required")
+	}
+
+	live, err := mc.GetUserPortfolio(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Portfolio{UserId: userId, Live: *live}
+
+	if period != "" {
+		history, err := mc.GetUserPortfolioHistory(userId, period)
+		if err != nil {
+			return nil, err
+		}
+		p.History = *history
+	}
+
+	p.Positions, err = mc.GetPositions(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// GetPositions returns the position of the user with the given id in every market they
+// have bet on, with a position for each answer of a multiple choice market.
+func (mc *Client) GetPositions(userId string) ([]Position, error) {
+	if userId == "" {
+		return nil, fmt.Errorf("userId is required")
+	}
+
+	var out []Position
+	err := mc.PageUserContractMetrics(GetUserContractMetricsRequest{UserId: userId, PerAnswer: true}, func(page UserContractMetricsResponse) error {
+		for _, m := range page.Contracts {
+			out = append(out, positions(m, page.MetricsByContract[m.Id])...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// positions returns the positions held in a market. When metrics are broken down by answer,
+// a position is returned for each answer instead of one for the whole market.
+func positions(m FullMarket, metrics []ContractMetric) []Position {
+	var out, summary []Position
+	for _, cm := range metrics {
+		if cm.AnswerId == "" {
+			summary = append(summary, Position{Market: m, Metric: cm})
+		} else {
+			out = append(out, Position{Market: m, AnswerId: cm.AnswerId, Metric: cm})
+		}
+	}
+
+	if len(out) == 0 {
+		return summary
+	}
+	return out
+}
+
+// Resolved reports whether the position's market, or its answer, has resolved.
+func (p Position) Resolved() bool {
+	if p.Market.IsResolved {
+		return true
+	}
+	for _, a := range p.Market.Answers {
+		if a.Id == p.AnswerId {
+			return a.Resolution != ""
+		}
+	}
+	return false
+}
+
+// Key returns the market id of the position, followed by a slash and the answer id if the
+// position is in an answer. It identifies the position in a [Scenario].
+func (p Position) Key() string {
+	if p.AnswerId == "" {
+		return p.Market.Id
+	}
+	return p.Market.Id + "/" + p.AnswerId
+}
+
+// Prob returns the current probability of the position's market, or of its answer.
+func (p Position) Prob() float64 {
+	for _, a := range p.Market.Answers {
+		if a.Id == p.AnswerId {
+			return a.Probability
+		}
+	}
+	return p.Market.Probability
+}
+
+// Value returns the current value of the position's shares.
+func (p Position) Value() float64 {
+	return p.Metric.Payout
+}
+
+// PayoutIf returns what the position would pay out if it resolved to the given probability,
+// so 1 for YES, 0 for NO, or anything in between for MKT. Any loan taken against the position
+// is repaid out of the payout.
+func (p Position) PayoutIf(prob float64) float64 {
+	return math.Max(p.shareValue(prob)-p.Metric.Loan, 0)
+}
+
+// AtRisk returns how much of the position's current value would be lost if it resolved
+// the wrong way.
+func (p Position) AtRisk() float64 {
+	if p.Resolved() {
+		return 0
+	}
+
+	worst := math.Min(p.shareValue(1), p.shareValue(0))
+	return math.Max(p.Value()-worst, 0)
+}
+
+// shareValue returns what the position's shares are worth if it resolves to prob.
+func (p Position) shareValue(prob float64) float64 {
+	return p.Metric.TotalShares["YES"]*prob + p.Metric.TotalShares["NO"]*(1-prob)
+}
+
+// Open returns the positions in markets that have not resolved and which still hold shares.
+func (p *Portfolio) Open() []Position {
+	var out []Position
+	for _, pos := range p.Positions {
+		if !pos.Resolved() && pos.Metric.HasShares {
+			out = append(out, pos)
+		}
+	}
+	return out
+}
+
+// AtRisk returns the n open positions with the most mana at risk, most first. If n is zero
+// or less, every open position is returned.
+func (p *Portfolio) AtRisk(n int) []Position {
+	out := p.Open()
+	sort.SliceStable(out, func(i, j int) bool { return out[i].AtRisk() > out[j].AtRisk() })
+
+	if n > 0 && n < len(out) {
+		out = out[:n]
+	}
+	return out
+}
+
+// ExposureByGroup returns the user's open positions totalled by the groups their markets are
+// in, with the most mana at risk first. A market in several groups counts towards each of
+// them, so the totals can add up to more than the whole portfolio.
+func (p *Portfolio) ExposureByGroup() []GroupExposure {
+	groups := map[string]*GroupExposure{}
+
+	for _, pos := range p.Open() {
+		slugs := pos.Market.GroupSlugs
+		if len(slugs) == 0 {
+			slugs = []string{""}
+		}
+
+		for _, s := range slugs {
+			g, ok := groups[s]
+			if !ok {
+				g = &GroupExposure{Slug: s}
+				groups[s] = g
+			}
+			g.Positions++
+			g.Invested += pos.Metric.Invested
+			g.Value += pos.Value()
+			g.AtRisk += pos.AtRisk()
+			g.Profit += pos.Metric.Profit
+		}
+	}
+
+	out := make([]GroupExposure, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].AtRisk != out[j].AtRisk {
+			return out[i].AtRisk > out[j].AtRisk
+		}
+		return out[i].Slug < out[j].Slug
+	})
+
+	return out
+}
+
+// PnL returns the user's profit broken down into realized and unrealized trading profit,
+// and profit from resolutions.
+func (p *Portfolio) PnL() PnL {
+	var pnl PnL
+	for _, pos := range p.Positions {
+		if pos.Resolved() {
+			pnl.Resolution += pos.Metric.Profit
+			continue
+		}
+
+		// a position's profit is what its shares are worth now less what they cost, plus
+		// whatever was made selling shares
+		unrealized := pos.Metric.Payout - pos.Metric.Invested
+		pnl.Unrealized += unrealized
+		pnl.Realized += pos.Metric.Profit - unrealized
+	}
+	pnl.Total = pnl.Realized + pnl.Unrealized + pnl.Resolution
+
+	return pnl
+}
@@ -0,0 +1,135 @@
+package mango
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testPortfolio() *Portfolio {
+	multi := FullMarket{Id: "d", Answers: []Answer{{Id: "x"}, {Id: "y"}}}
+
+	return &Portfolio{
+		UserId: "u1",
+		Live:   LivePortfolioMetrics{Balance: 100},
+		Positions: []Position{
+			{Market: FullMarket{Id: "a", GroupSlugs: []string{"politics"}}, Metric: ContractMetric{
+				HasShares: true, TotalShares: map[string]float64{"YES": 50}, Invested: 20, Payout: 30, Profit: 10, Loan: 5,
+			}},
+			{Market: FullMarket{Id: "b", GroupSlugs: []string{"politics", "sports"}}, Metric: ContractMetric{
+				HasShares: true, TotalShares: map[string]float64{"NO": 40}, Invested: 25, Payout: 20, Profit: -5,
+			}},
+			{Market: FullMarket{Id: "c", IsResolved: true, Resolution: "YES"}, Metric: ContractMetric{
+				HasShares: true, TotalShares: map[string]float64{"YES": 10}, Invested: 5, Payout: 10, Profit: 5,
+			}},
+			{Market: multi, AnswerId: "x", Metric: ContractMetric{
+				HasShares: true, TotalShares: map[string]float64{"YES": 20}, Invested: 5, Payout: 8, Profit: 3,
+			}},
+			{Market: multi, AnswerId: "y", Metric: ContractMetric{
+				HasShares: true, TotalShares: map[string]float64{"NO": 10}, Invested: 6, Payout: 4, Profit: -2,
+			}},
+		},
+	}
+}
+
+func TestGetPortfolio(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("userId") != "u1" {
+			t.Errorf("unexpected request %v", r.URL)
+		}
+
+		switch r.URL.Path {
+		case "/v0/get-user-portfolio/":
+			json.NewEncoder(w).Encode(LivePortfolioMetrics{UserId: "u1", Balance: 100})
+		case "/v0/get-user-portfolio-history/":
+			if r.URL.Query().Get("period") != "weekly" {
+				t.Errorf("unexpected period %v", r.URL)
+			}
+			json.NewEncoder(w).Encode([]PortfolioMetrics{{Balance: 90}, {Balance: 100}})
+		case "/v0/get-user-contract-metrics-with-contracts/":
+			if r.URL.Query().Get("perAnswer") != "true" {
+				t.Errorf("expected metrics per answer, got %v", r.URL)
+			}
+			json.NewEncoder(w).Encode(UserContractMetricsResponse{
+				MetricsByContract: map[string][]ContractMetric{
+					"a": {{ContractId: "a", Payout: 30}},
+					"d": {{ContractId: "d", Payout: 12}, {ContractId: "d", AnswerId: "x", Payout: 8}, {ContractId: "d", AnswerId: "y", Payout: 4}},
+				},
+				Contracts: []FullMarket{{Id: "a"}, {Id: "d"}},
+			})
+		default:
+			t.Errorf("unexpected path %v", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	mc := ClientInstance(server.Client(), &server.URL, &testKey)
+	defer mc.Destroy()
+
+	p, err := mc.GetPortfolio("u1", PeriodWeekly)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Live.Balance != 100 || len(p.History) != 2 {
+		t.Errorf("unexpected portfolio %+v", p)
+	}
+
+	// the summary metric for d is replaced by its answers
+	var keys []string
+	for _, pos := range p.Positions {
+		keys = append(keys, pos.Key())
+	}
+	if len(keys) != 3 || keys[0] != "a" || keys[1] != "d/x" || keys[2] != "d/y" {
+		t.Errorf("unexpected positions %v", keys)
+	}
+}
+
+func TestPortfolioAtRisk(t *testing.T) {
+	p := testPortfolio()
+
+	top := p.AtRisk(2)
+	if len(top) != 2 || top[0].Key() != "a" || top[1].Key() != "b" {
+		t.Fatalf("unexpected positions %+v", top)
+	}
+	if top[0].AtRisk() != 30 || top[1].AtRisk() != 20 {
+		t.Errorf("expected M30 and M20 at risk, got %v and %v", top[0].AtRisk(), top[1].AtRisk())
+	}
+	if top[0].PayoutIf(1) != 45 || top[0].PayoutIf(0) != 0 {
+		t.Errorf("expected the loan to be repaid from the payout, got %v", top[0].PayoutIf(1))
+	}
+
+	if n := len(p.AtRisk(0)); n != 4 {
+		t.Errorf("expected every open position, got %d", n)
+	}
+}
+
+func TestPortfolioExposureByGroup(t *testing.T) {
+	want := []GroupExposure{
+		{Slug: "politics", Positions: 2, Invested: 45, Value: 50, AtRisk: 50, Profit: 5},
+		{Slug: "sports", Positions: 1, Invested: 25, Value: 20, AtRisk: 20, Profit: -5},
+		{Slug: "", Positions: 2, Invested: 11, Value: 12, AtRisk: 12, Profit: 1},
+	}
+
+	got := testPortfolio().ExposureByGroup()
+	if len(got) != len(want) {
+		t.Fatalf("unexpected exposure %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("group %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestPortfolioPnL(t *testing.T) {
+	p := testPortfolio()
+	// every share was sold, for a profit
+	p.Positions = append(p.Positions, Position{Market: FullMarket{Id: "e"}, Metric: ContractMetric{Profit: 4}})
+
+	want := PnL{Realized: 4, Unrealized: 6, Resolution: 5, Total: 15}
+	if got := p.PnL(); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,78 @@
+package mango
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Manifold allows 500 requests per minute per IP address.
+//
+// See [the Manifold API docs] for more details.
+//
+// [the Manifold API docs]: https://docs.manifold.markets/api#rate-limits
+const defaultRateLimit = 500
+const defaultRatePeriod = time.Minute
+
+// rateLimiter is a token bucket shared by every request a [Client] makes.
+// It starts full, so short bursts are sent immediately and only sustained
+// traffic is slowed down.
+type rateLimiter struct {
+	mu       sync.Mutex
+	tokens   float64
+	capacity float64
+	rate     float64 // tokens per second
+	last     time.Time
+}
+
+func newRateLimiter(requests int, per time.Duration) *rateLimiter {
+	if requests <= 0 || per <= 0 {
+		return nil
+	}
+
+	return &rateLimiter{
+		tokens:   float64(requests),
+		capacity: float64(requests),
+		rate:     float64(requests) / per.Seconds(),
+		last:     time.Now(),
+	}
+}
+
+// wait blocks until a token is available or ctx is done, and returns how long it waited.
+// If ctx is done first, the token is given back and ctx's error is returned.
+// A nil rateLimiter never blocks.
+func (rl *rateLimiter) wait(ctx context.Context) (time.Duration, error) {
+	if rl == nil {
+		return 0, nil
+	}
+
+	rl.mu.Lock()
+	now := time.Now()
+	rl.tokens += now.Sub(rl.last).Seconds() * rl.rate
+	if rl.tokens > rl.capacity {
+		rl.tokens = rl.capacity
+	}
+	rl.last = now
+
+	// take the token now, even if that leaves the bucket in debt, so that
+	// concurrent callers queue up behind each other rather than racing
+	rl.tokens--
+	var d time.Duration
+	if rl.tokens < 0 {
+		d = time.Duration(-rl.tokens / rl.rate * float64(time.Second))
+	}
+	rl.mu.Unlock()
+
+	if d > 0 {
+		select {
+		case <-ctx.Done():
+			rl.mu.Lock()
+			rl.tokens++
+			rl.mu.Unlock()
+			return 0, ctx.Err()
+		case <-time.After(d):
+		}
+	}
+
+	return d, nil
+}
@@ -1,9 +1,6 @@
 package mango
 
-import (
-	"log"
-	"net/url"
-)
+import "net/url"
 
 const base string = "https://api.manifold.markets"
 const version string = "v0/"
@@ -28,13 +25,16 @@ const postBet string = "bet/"
 const postCancellation string = "bet/cancel/"
 const postMarket string = "market/"
 const postComment string = "comment/"
+const postReact string = "react/"
 const postMultiBet string = "multi-bet/"
 const postManagram string = "managram/"
+const postFollowTopic string = "follow-topic/"
 
 const getUserPortfolio string = "get-user-portfolio/"
 const getUserPortfolioHistory string = "get-user-portfolio-history/"
 const getMarketProbs string = "market-probs/"
 const getLeagues string = "leagues/"
+const getLeaderboard string = "leaderboard/"
 const getTxns string = "txns/"
 const getUserContractMetrics string = "get-user-contract-metrics-with-contracts/"
 
@@ -43,6 +43,8 @@ const positionsSuffix = "/positions/"
 const liquiditySuffix = "/liquidity/"
 const closureSuffix = "/close/"
 const groupSuffix = "/group/"
+const groupsSuffix = "/groups/"
+const membersSuffix = "/members/"
 const resolutionSuffix = "/resolve/"
 const sellSuffix = "/sell/"
 const answerSuffix string = "/answer/"
@@ -51,21 +53,20 @@ const awardBountySuffix string = "/award-bounty/"
 const liteSuffix string = "/lite/"
 const probSuffix string = "/prob/"
 
-const manifoldConstantsUrl string = "https://github.com/manifoldmarkets/manifold/blob/main/common/src/envs/constants.ts"
-const manifoldLeaderboards string = "https://manifold.markets/leaderboards"
-
 // requestURL returns a fully-formed URL that HTTP requests can be sent to.
 // It includes the base domain, path, and any query parameters supplied.
+//
+// If the URL cannot be built then an empty string is returned, which
+// the client will refuse to send a request to.
 func requestURL(base, path, value, suffix string, params ...string) string {
 	// if query parameters are supplied, they must be in key:value pairs
 	if len(params)%2 != 0 {
-		log.Println("number of params passed to requestURL() must be divisible by 2")
 		return ""
 	}
 
 	query, err := url.Parse(base + "/" + version)
 	if err != nil {
-		log.Fatalf("error parsing base URL: %v", err)
+		return ""
 	}
 
 	query.Path += path
@@ -0,0 +1,340 @@
+package mango
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+)
+
+// ResolutionPreview represents what resolving a market a particular way would pay out to
+// each of its traders. Use [Client.PreviewResolution] to build one.
+type ResolutionPreview struct {
+	MarketId string               `json:"marketId"`
+	Question string               `json:"question"`
+	Request  ResolveMarketRequest `json:"request"`
+	// Cancel is true if the market would be cancelled, which refunds everyone's investment.
+	Cancel bool `json:"cancel,omitempty"`
+	// Probabilities is what each answer would resolve to, keyed by answer id, or by the
+	// empty string for a binary market. YES shares pay out the probability and NO shares
+	// the rest of it.
+	Probabilities map[string]float64 `json:"probabilities,omitempty"`
+	// Payouts are what each trader would be paid, largest first.
+	Payouts []ResolutionPayout `json:"payouts"`
+
+	Invested float64 `json:"invested"`
+	Payout   float64 `json:"payout"`
+	// CreatorFees are the fees the market's creator has earned from trades in the market.
+	CreatorFees float64 `json:"creatorFees"`
+	// OpenOrders counts the unfilled limit orders that resolving would cancel, and Refunds
+	// is the unfilled mana they would return.
+	OpenOrders int     `json:"openOrders"`
+	Refunds    float64 `json:"refunds"`
+
+	answers map[string]string // answer id to text, for reports
+}
+
+// ResolutionPayout represents what one trader would be paid when a market resolves.
+type ResolutionPayout struct {
+	UserId   string    `json:"userId"`
+	Username string    `json:"username"`
+	Name     string    `json:"name"`
+	Holdings []Holding `json:"holdings"`
+	Invested float64   `json:"invested"`
+	// Payout is what the trader's shares would be worth. Any loan is repaid out of it, so
+	// their balance would rise by Payout less Loan.
+	Payout float64 `json:"payout"`
+	Loan   float64 `json:"loan,omitempty"`
+	Profit float64 `json:"profit"`
+}
+
+// Holding represents the shares of one outcome of a market, or of one of its answers,
+// and what they would pay out.
+type Holding struct {
+	AnswerId string  `json:"answerId,omitempty"`
+	Outcome  string  `json:"outcome"`
+	Shares   float64 `json:"shares"`
+	Payout   float64 `json:"payout"`
+}
+
+// PreviewResolution returns what resolving a market with the given request would pay out,
+// without resolving it. It takes the same [ResolveMarketRequest] as [Client.ResolveMarket],
+// and supports resolving binary markets YES, NO, to a probability with MKT, or CANCEL, and
+// multiple choice markets to a single answer, to several with [ResolveMarketRequest.Resolutions],
+// or CANCEL.
+//
+// Holdings are worked out from every bet in the market, so previewing a busy market can
+// take several requests.
+func (mc *Client) PreviewResolution(marketId string, rmr ResolveMarketRequest) (*ResolutionPreview, error) {
+	m, err := mc.GetMarketByID(marketId)
+	if err != nil {
+		return nil, err
+	}
+
+	positions, err := mc.GetMarketPositions(GetMarketPositionsRequest{MarketId: marketId})
+	if err != nil {
+		return nil, err
+	}
+
+	var bets []Bet
+	err = mc.PageBets(GetBetsRequest{ContractId: marketId}, func(page []Bet) error {
+		bets = append(bets, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return NewResolutionPreview(*m, rmr, bets, *positions)
+}
+
+// NewResolutionPreview works out what resolving a market with the given request would pay
+// out, from the market's bets and, for the loans taken against them, its positions.
+func NewResolutionPreview(m FullMarket, rmr ResolveMarketRequest, bets []Bet, positions []ContractMetric) (*ResolutionPreview, error) {
+	probs, cancel, err := resolutionProbabilities(m, rmr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &ResolutionPreview{
+		MarketId:      m.Id,
+		Question:      m.Question,
+		Request:       rmr,
+		Cancel:        cancel,
+		Probabilities: probs,
+		answers:       map[string]string{},
+	}
+	for _, a := range m.Answers {
+		p.answers[a.Id] = a.Text
+	}
+
+	type holdingKey struct{ answerId, outcome string }
+	users := map[string]*ResolutionPayout{}
+	shares := map[string]map[holdingKey]float64{}
+	var order []string
+
+	for _, b := range bets {
+		if b.ContractId != "" && b.ContractId != m.Id {
+			continue
+		}
+
+		p.CreatorFees += b.Fees.CreatorFee
+		if b.OrderAmount > 0 && !b.IsFilled && !b.IsCancelled {
+			p.OpenOrders++
+			p.Refunds += b.OrderAmount - b.Amount
+		}
+		if b.Amount == 0 && b.Shares == 0 {
+			continue
+		}
+
+		u, ok := users[b.UserId]
+		if !ok {
+			u = &ResolutionPayout{UserId: b.UserId, Username: b.UserUsername, Name: b.UserName}
+			users[b.UserId] = u
+			shares[b.UserId] = map[holdingKey]float64{}
+			order = append(order, b.UserId)
+		}
+		u.Invested += b.Amount
+		shares[b.UserId][holdingKey{b.AnswerId, b.Outcome}] += b.Shares
+	}
+
+	for _, cm := range positions {
+		if u, ok := users[cm.UserId]; ok {
+			u.Loan += cm.Loan
+			if u.Username == "" {
+				u.Username, u.Name = cm.UserUsername, cm.UserName
+			}
+		}
+	}
+
+	for _, id := range order {
+		u := users[id]
+
+		var keys []holdingKey
+		for k, s := range shares[id] {
+			if math.Abs(s) > 1e-9 {
+				keys = append(keys, k)
+			}
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].answerId != keys[j].answerId {
+				return keys[i].answerId < keys[j].answerId
+			}
+			return keys[i].outcome > keys[j].outcome
+		})
+
+		for _, k := range keys {
+			h := Holding{AnswerId: k.answerId, Outcome: k.outcome, Shares: shares[id][k]}
+			if !cancel {
+				prob := probs[k.answerId]
+				if k.outcome == "NO" {
+					prob = 1 - prob
+				}
+				h.Payout = h.Shares * prob
+				u.Payout += h.Payout
+			}
+			u.Holdings = append(u.Holdings, h)
+		}
+
+		if cancel {
+			u.Payout = u.Invested
+		}
+		u.Profit = u.Payout - u.Invested
+
+		p.Invested += u.Invested
+		p.Payout += u.Payout
+		p.Payouts = append(p.Payouts, *u)
+	}
+
+	sort.SliceStable(p.Payouts, func(i, j int) bool { return p.Payouts[i].Payout > p.Payouts[j].Payout })
+
+	return p, nil
+}
+
+// resolutionProbabilities returns what each answer of a market, or the market itself, would
+// resolve to, or whether it would be cancelled.
+func resolutionProbabilities(m FullMarket, rmr ResolveMarketRequest) (map[string]float64, bool, error) {
+	if rmr.Outcome == "CANCEL" {
+		return nil, true, nil
+	}
+
+	if m.OutcomeType == Binary {
+		switch rmr.Outcome {
+		case "YES":
+			return map[string]float64{"": 1}, false, nil
+		case "NO":
+			return map[string]float64{"": 0}, false, nil
+		case "MKT":
+			prob := m.Probability
+			if rmr.ProbabilityInt != 0 {
+				if rmr.ProbabilityInt < 0 || rmr.ProbabilityInt > 100 {
+					return nil, false, fmt.Errorf("probabilityInt must be between 0 and 100, got %d", rmr.ProbabilityInt)
+				}
+				prob = float64(rmr.ProbabilityInt) / 100
+			}
+			return map[string]float64{"": prob}, false, nil
+		default:
+			return nil, false, fmt.Errorf("binary markets resolve YES, NO, MKT or CANCEL, got %q", rmr.Outcome)
+		}
+	}
+
+	if len(m.Answers) == 0 {
+		return nil, false, fmt.Errorf("can't preview resolving %v markets", m.OutcomeType)
+	}
+
+	probs := map[string]float64{}
+	for _, a := range m.Answers {
+		probs[a.Id] = 0
+	}
+
+	if rmr.Outcome == "MKT" {
+		if len(rmr.Resolutions) == 0 {
+			for _, a := range m.Answers {
+				probs[a.Id] = a.Probability
+			}
+			return probs, false, nil
+		}
+
+		var total int64
+		for _, r := range rmr.Resolutions {
+			a, ok := answerByNumber(m, r.Answer)
+			if !ok {
+				return nil, false, fmt.Errorf("market %v has no answer %d", m.Id, r.Answer)
+			}
+			probs[a.Id] += float64(r.Pct) / 100
+			total += r.Pct
+		}
+		if total != 100 {
+			return nil, false, fmt.Errorf("resolutions must add up to 100%%, got %d%%", total)
+		}
+		return probs, false, nil
+	}
+
+	// otherwise the outcome is the answer that resolves YES, by id or by number
+	for _, a := range m.Answers {
+		if a.Id == rmr.Outcome || strconv.FormatInt(a.Number, 10) == rmr.Outcome {
+			probs[a.Id] = 1
+			return probs, false, nil
+		}
+	}
+
+	return nil, false, fmt.Errorf("market %v has no answer %q", m.Id, rmr.Outcome)
+}
+
+func answerByNumber(m FullMarket, number int64) (Answer, bool) {
+	for _, a := range m.Answers {
+		if a.Number == number {
+			return a, true
+		}
+	}
+	return Answer{}, false
+}
+
+// WriteText writes a report of the preview to w: what the market would resolve to, the
+// totals, and a table of what each trader would be paid.
+func (p *ResolutionPreview) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "market\t%v\n", p.Question)
+	switch {
+	case p.Cancel:
+		fmt.Fprintf(tw, "resolution\tCANCEL, refunding every trader\n")
+	case len(p.answers) == 0:
+		fmt.Fprintf(tw, "resolution\t%v (%.0f%% YES)\n", p.Request.Outcome, p.Probabilities[""]*100)
+	default:
+		fmt.Fprintf(tw, "resolution\t%v\n", p.Request.Outcome)
+		var ids []string
+		for id := range p.Probabilities {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			if p.Probabilities[ids[i]] != p.Probabilities[ids[j]] {
+				return p.Probabilities[ids[i]] > p.Probabilities[ids[j]]
+			}
+			return ids[i] < ids[j]
+		})
+		for _, id := range ids {
+			fmt.Fprintf(tw, "  %v\t%.0f%%\n", p.answerText(id), p.Probabilities[id]*100)
+		}
+	}
+	fmt.Fprintf(tw, "traders\t%d\n", len(p.Payouts))
+	fmt.Fprintf(tw, "invested\tM%.2f\n", p.Invested)
+	fmt.Fprintf(tw, "payout\tM%.2f\n", p.Payout)
+	fmt.Fprintf(tw, "creator fees\tM%.2f\n", p.CreatorFees)
+	if p.OpenOrders > 0 {
+		fmt.Fprintf(tw, "open orders\t%d, refunding M%.2f\n", p.OpenOrders, p.Refunds)
+	}
+
+	if len(p.Payouts) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "user\tholdings\tinvested\tpayout\tprofit")
+		for _, u := range p.Payouts {
+			fmt.Fprintf(tw, "%v\t%v\tM%.2f\tM%.2f\tM%.2f\n", u.Username, p.holdings(u.Holdings), u.Invested, u.Payout, u.Profit)
+		}
+	}
+
+	return tw.Flush()
+}
+
+func (p *ResolutionPreview) answerText(id string) string {
+	if text := p.answers[id]; text != "" {
+		return text
+	}
+	return id
+}
+
+func (p *ResolutionPreview) holdings(hs []Holding) string {
+	var out string
+	for i, h := range hs {
+		if i > 0 {
+			out += ", "
+		}
+		out += fmt.Sprintf("%.1f %v", h.Shares, h.Outcome)
+		if h.AnswerId != "" {
+			out += " of " + p.answerText(h.AnswerId)
+		}
+	}
+	return out
+}
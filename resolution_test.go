@@ -0,0 +1,155 @@
+package mango
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPreviewResolution(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v0/market/m1":
+			json.NewEncoder(w).Encode(FullMarket{Id: "m1", Question: "Will it rain?", OutcomeType: Binary, Probability: 0.6})
+		case "/v0/market/m1/positions/":
+			json.NewEncoder(w).Encode([]ContractMetric{{ContractId: "m1", UserId: "u1", Loan: 4}})
+		case "/v0/bets/":
+			if r.URL.Query().Get("contractId") != "m1" {
+				t.Errorf("unexpected request %v", r.URL)
+			}
+			json.NewEncoder(w).Encode([]Bet{
+				{Id: "b1", ContractId: "m1", UserId: "u1", UserUsername: "alice", Outcome: "YES", Amount: 10, Shares: 20, Fees: Fees{CreatorFee: 0.5}},
+				{Id: "b2", ContractId: "m1", UserId: "u2", UserUsername: "bob", Outcome: "NO", Amount: 15, Shares: 25, Fees: Fees{CreatorFee: 0.25}},
+				{Id: "b3", ContractId: "m1", UserId: "u1", UserUsername: "alice", Outcome: "YES", Amount: -6, Shares: -10},
+				{Id: "b4", ContractId: "m1", UserId: "u2", UserUsername: "bob", Outcome: "YES", OrderAmount: 10, Amount: 4, Shares: 8, LimitProb: 0.5},
+			})
+		default:
+			t.Errorf("unexpected path %v", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	mc := ClientInstance(server.Client(), &server.URL, &testKey)
+	defer mc.Destroy()
+
+	p, err := mc.PreviewResolution("m1", ResolveMarketRequest{Outcome: "YES"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Payouts) != 2 || p.Payouts[0].Username != "alice" {
+		t.Fatalf("expected alice to be paid the most, got %+v", p.Payouts)
+	}
+
+	alice, bob := p.Payouts[0], p.Payouts[1]
+	if alice.Payout != 10 || alice.Invested != 4 || alice.Profit != 6 || alice.Loan != 4 {
+		t.Errorf("unexpected payout for alice: %+v", alice)
+	}
+	if bob.Payout != 8 || bob.Invested != 19 || bob.Profit != -11 || len(bob.Holdings) != 2 {
+		t.Errorf("unexpected payout for bob: %+v", bob)
+	}
+	if p.CreatorFees != 0.75 || p.OpenOrders != 1 || p.Refunds != 6 || p.Payout != 18 {
+		t.Errorf("unexpected totals: %+v", p)
+	}
+
+	mkt, err := mc.PreviewResolution("m1", ResolveMarketRequest{Outcome: "MKT", ProbabilityInt: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range mkt.Payouts {
+		if u.Username == "bob" && math.Abs(u.Payout-(25*0.6+8*0.4)) > 1e-9 {
+			t.Errorf("unexpected payout for bob at 40%%: %+v", u)
+		}
+	}
+
+	cancel, err := mc.PreviewResolution("m1", ResolveMarketRequest{Outcome: "CANCEL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range cancel.Payouts {
+		if u.Payout != u.Invested || u.Profit != 0 {
+			t.Errorf("expected a refund, got %+v", u)
+		}
+	}
+
+	if _, err := mc.PreviewResolution("m1", ResolveMarketRequest{Outcome: "MAYBE"}); err == nil {
+		t.Error("expected an error for an invalid outcome")
+	}
+
+	var buf bytes.Buffer
+	if err := p.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Will it rain?", "YES (100% YES)", "creator fees  M0.75", "alice", "10.0 YES"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected report to contain %q, got:\n%v", want, buf.String())
+		}
+	}
+}
+
+func TestNewResolutionPreviewAnswers(t *testing.T) {
+	m := FullMarket{
+		Id:          "m1",
+		Question:    "Who will win?",
+		OutcomeType: "MULTIPLE_CHOICE",
+		Answers: []Answer{
+			{Id: "a1", Number: 1, Text: "Red", Probability: 0.5},
+			{Id: "a2", Number: 2, Text: "Blue", Probability: 0.3},
+			{Id: "a3", Number: 3, Text: "Green", Probability: 0.2},
+		},
+	}
+	bets := []Bet{
+		{UserId: "u1", UserUsername: "alice", AnswerId: "a1", Outcome: "YES", Amount: 10, Shares: 20},
+		{UserId: "u1", UserUsername: "alice", AnswerId: "a2", Outcome: "NO", Amount: 10, Shares: 14},
+		{UserId: "u2", UserUsername: "bob", AnswerId: "a2", Outcome: "YES", Amount: 10, Shares: 30},
+	}
+
+	tests := []struct {
+		rmr        ResolveMarketRequest
+		alice, bob float64
+	}{
+		{ResolveMarketRequest{Outcome: "a1"}, 34, 0},
+		{ResolveMarketRequest{Outcome: "2"}, 0, 30},
+		{ResolveMarketRequest{Outcome: "MKT", Resolutions: []Resolution{{Answer: 1, Pct: 50}, {Answer: 2, Pct: 50}}}, 17, 15},
+		{ResolveMarketRequest{Outcome: "MKT"}, 20*0.5 + 14*0.7, 30 * 0.3},
+	}
+
+	for _, tt := range tests {
+		p, err := NewResolutionPreview(m, tt.rmr, bets, nil)
+		if err != nil {
+			t.Fatalf("%+v: %v", tt.rmr, err)
+		}
+
+		got := map[string]float64{}
+		for _, u := range p.Payouts {
+			got[u.Username] = u.Payout
+		}
+		if math.Abs(got["alice"]-tt.alice) > 1e-9 || math.Abs(got["bob"]-tt.bob) > 1e-9 {
+			t.Errorf("%+v: expected alice %v and bob %v, got %v", tt.rmr, tt.alice, tt.bob, got)
+		}
+	}
+
+	invalid := []ResolveMarketRequest{
+		{Outcome: "a4"},
+		{Outcome: "MKT", Resolutions: []Resolution{{Answer: 1, Pct: 50}}},
+		{Outcome: "MKT", Resolutions: []Resolution{{Answer: 4, Pct: 100}}},
+	}
+	for _, rmr := range invalid {
+		if _, err := NewResolutionPreview(m, rmr, bets, nil); err == nil {
+			t.Errorf("expected an error for %+v", rmr)
+		}
+	}
+
+	p, _ := NewResolutionPreview(m, ResolveMarketRequest{Outcome: "a1"}, bets, nil)
+	var buf bytes.Buffer
+	if err := p.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Red") || !strings.Contains(buf.String(), "14.0 NO of Blue") {
+		t.Errorf("expected answers in the report, got:\n%v", buf.String())
+	}
+}
@@ -0,0 +1,174 @@
+// Package richtext builds documents in the TipTap JSON format Manifold uses for rich text,
+// such as in comments and market descriptions.
+//
+// A document is a tree of [Node] values, built with functions named after the node types:
+//
+//	doc := richtext.Doc(
+//		richtext.Paragraph(
+//			richtext.Text("Thanks "),
+//			richtext.Mention("abc123", "alice"),
+//			richtext.Text(", the market moved "),
+//			richtext.Text("10 points", richtext.Bold()),
+//			richtext.Text(" after your comment on "),
+//			richtext.MarketMention("xyz789", "will-it-rain-tomorrow"),
+//		),
+//	)
+//
+// Send it with [github.com/jonnyspicer/mango.PostCommentRequest.Doc].
+package richtext
+
+import "strings"
+
+// Node is a node of a TipTap document.
+type Node struct {
+	Type    string         `json:"type"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+	Content []Node         `json:"content,omitempty"`
+	// Text and Marks are only set for text nodes.
+	Text  string `json:"text,omitempty"`
+	Marks []Mark `json:"marks,omitempty"`
+}
+
+// Mark is formatting applied to a text node, such as bold or a link.
+type Mark struct {
+	Type  string         `json:"type"`
+	Attrs map[string]any `json:"attrs,omitempty"`
+}
+
+// Doc returns a document made of the given blocks, such as paragraphs and lists.
+func Doc(blocks ...Node) Node {
+	return Node{Type: "doc", Content: blocks}
+}
+
+// Paragraph returns a paragraph of inline nodes, such as text and mentions.
+func Paragraph(inline ...Node) Node {
+	return Node{Type: "paragraph", Content: inline}
+}
+
+// Heading returns a heading of the given level, from 1 to 6.
+func Heading(level int, inline ...Node) Node {
+	return Node{Type: "heading", Attrs: map[string]any{"level": level}, Content: inline}
+}
+
+// Blockquote returns a quote of the given blocks.
+func Blockquote(blocks ...Node) Node {
+	return Node{Type: "blockquote", Content: blocks}
+}
+
+// CodeBlock returns a block of preformatted code.
+func CodeBlock(code string) Node {
+	return Node{Type: "codeBlock", Content: []Node{Text(code)}}
+}
+
+// BulletList returns a bulleted list with an item for each of the given nodes. Inline
+// nodes are wrapped in a paragraph.
+func BulletList(items ...Node) Node {
+	return Node{Type: "bulletList", Content: listItems(items)}
+}
+
+// OrderedList returns a numbered list with an item for each of the given nodes. Inline
+// nodes are wrapped in a paragraph.
+func OrderedList(items ...Node) Node {
+	return Node{Type: "orderedList", Content: listItems(items)}
+}
+
+func listItems(items []Node) []Node {
+	out := make([]Node, len(items))
+	for i, n := range items {
+		if n.inline() {
+			n = Paragraph(n)
+		}
+		out[i] = Node{Type: "listItem", Content: []Node{n}}
+	}
+	return out
+}
+
+// Text returns a text node with the given formatting.
+func Text(s string, marks ...Mark) Node {
+	return Node{Type: "text", Text: s, Marks: marks}
+}
+
+// HardBreak returns a line break within a paragraph.
+func HardBreak() Node {
+	return Node{Type: "hardBreak"}
+}
+
+// Mention returns a mention of a user, which notifies them.
+func Mention(userId, username string) Node {
+	return Node{Type: "mention", Attrs: map[string]any{"id": userId, "label": username}}
+}
+
+// MarketMention returns a link to a market that Manifold shows with its question and
+// probability.
+func MarketMention(marketId, slug string) Node {
+	return Node{Type: "contract-mention", Attrs: map[string]any{"id": marketId, "label": slug}}
+}
+
+// Image returns an image.
+func Image(src, alt string) Node {
+	return Node{Type: "image", Attrs: map[string]any{"src": src, "alt": alt}}
+}
+
+// Bold returns a mark that makes text bold.
+func Bold() Mark {
+	return Mark{Type: "bold"}
+}
+
+// Italic returns a mark that makes text italic.
+func Italic() Mark {
+	return Mark{Type: "italic"}
+}
+
+// Code returns a mark that formats text as code.
+func Code() Mark {
+	return Mark{Type: "code"}
+}
+
+// Link returns a mark that links text to href.
+func Link(href string) Mark {
+	return Mark{Type: "link", Attrs: map[string]any{"href": href, "target": "_blank"}}
+}
+
+// inline reports whether a node goes inside a paragraph rather than being a block.
+func (n Node) inline() bool {
+	switch n.Type {
+	case "text", "hardBreak", "mention", "contract-mention", "image":
+		return true
+	}
+	return false
+}
+
+// PlainText returns the text of the document without its formatting. Blocks are separated
+// by blank lines, list items by newlines, and mentions are written as @label.
+func (n Node) PlainText() string {
+	var sb strings.Builder
+	n.writeText(&sb)
+	return strings.TrimSpace(sb.String())
+}
+
+func (n Node) writeText(sb *strings.Builder) {
+	switch n.Type {
+	case "text":
+		sb.WriteString(n.Text)
+		return
+	case "hardBreak":
+		sb.WriteString("\n")
+		return
+	case "mention", "contract-mention":
+		if label, ok := n.Attrs["label"].(string); ok {
+			sb.WriteString("@" + label)
+		}
+		return
+	}
+
+	for i, c := range n.Content {
+		if i > 0 && !c.inline() {
+			if n.Type == "bulletList" || n.Type == "orderedList" {
+				sb.WriteString("\n")
+			} else {
+				sb.WriteString("\n\n")
+			}
+		}
+		c.writeText(sb)
+	}
+}
@@ -0,0 +1,42 @@
+package richtext
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDoc(t *testing.T) {
+	doc := Doc(
+		Heading(2, Text("Update")),
+		Paragraph(Text("Thanks "), Mention("u1", "alice"), Text(", see "), Text("here", Link("https://manifold.markets"), Bold())),
+		BulletList(Text("one"), Paragraph(Text("two"), HardBreak(), Text("three"))),
+		Paragraph(MarketMention("m1", "will-it-rain")),
+	)
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var back Node
+	if err := json.Unmarshal(b, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Type != "doc" || len(back.Content) != 4 {
+		t.Fatalf("unexpected document %s", b)
+	}
+	if h := back.Content[0]; h.Type != "heading" || h.Attrs["level"] != float64(2) {
+		t.Errorf("unexpected heading %+v", h)
+	}
+	if link := back.Content[1].Content[3]; len(link.Marks) != 2 || link.Marks[0].Attrs["href"] != "https://manifold.markets" {
+		t.Errorf("unexpected link %+v", link)
+	}
+	if item := back.Content[2].Content[0]; item.Type != "listItem" || item.Content[0].Type != "paragraph" {
+		t.Errorf("expected list items to be wrapped in paragraphs, got %+v", item)
+	}
+
+	want := "Update\n\nThanks @alice, see here\n\none\ntwo\nthree\n\n@will-it-rain"
+	if got := doc.PlainText(); got != want {
+		t.Errorf("expected plain text %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,165 @@
+package mango
+
+import (
+	"fmt"
+	"sort"
+)
+
+// maxScenarioPositions is the most positions [Portfolio.Scenarios] will combine, which
+// gives 65536 scenarios.
+const maxScenarioPositions = 16
+
+// Scenario represents a set of outcomes for positions that are expected to resolve together,
+// such as several markets about the same election. Outcomes maps the key of each position,
+// as returned by [Position.Key], to the probability it resolves to: 1 for YES, 0 for NO, or
+// anything in between for MKT. Positions that are not in the scenario keep their current value.
+type Scenario struct {
+	Name     string             `json:"name,omitempty"`
+	Outcomes map[string]float64 `json:"outcomes"`
+}
+
+// ScenarioResult represents what a [Portfolio] would be worth under a [Scenario].
+type ScenarioResult struct {
+	Scenario Scenario `json:"scenario"`
+	// Balance is the user's balance once the positions in the scenario have paid out.
+	Balance float64 `json:"balance"`
+	// InvestmentValue is the current value, less loans, of the positions the scenario leaves open.
+	InvestmentValue float64 `json:"investmentValue"`
+	// NetWorth is the sum of Balance and InvestmentValue.
+	NetWorth float64 `json:"netWorth"`
+	// Change is the difference between NetWorth and the portfolio's current net worth.
+	Change float64 `json:"change"`
+}
+
+// NetWorth returns the user's balance plus the current value of their open positions, less
+// the loans taken against them.
+func (p *Portfolio) NetWorth() float64 {
+	worth := p.Live.Balance
+	for _, pos := range p.Open() {
+		worth += pos.Value() - pos.Metric.Loan
+	}
+	return worth
+}
+
+// Evaluate returns what the portfolio would be worth if the positions in s resolved as it says.
+func (p *Portfolio) Evaluate(s Scenario) ScenarioResult {
+	r := ScenarioResult{Scenario: s, Balance: p.Live.Balance}
+
+	for _, pos := range p.Open() {
+		if prob, ok := s.Outcomes[pos.Key()]; ok {
+			r.Balance += pos.PayoutIf(prob)
+		} else {
+			r.InvestmentValue += pos.Value() - pos.Metric.Loan
+		}
+	}
+
+	r.NetWorth = r.Balance + r.InvestmentValue
+	r.Change = r.NetWorth - p.NetWorth()
+
+	return r
+}
+
+// Outcomes returns what the portfolio would be worth if each open position resolved YES, and
+// if it resolved NO, with every other position keeping its current value. The scenarios are
+// named after the position's key and outcome, for example "abc123 YES".
+func (p *Portfolio) Outcomes() []ScenarioResult {
+	var out []ScenarioResult
+	for _, pos := range p.Open() {
+		for _, o := range []struct {
+			name string
+			prob float64
+		}{{"YES", 1}, {"NO", 0}} {
+			out = append(out, p.Evaluate(Scenario{
+				Name:     pos.Key() + " " + o.name,
+				Outcomes: map[string]float64{pos.Key(): o.prob},
+			}))
+		}
+	}
+	return out
+}
+
+// Scenarios returns what the portfolio would be worth under every combination of YES and NO
+// outcomes for the positions with the given keys, worst first. It is meant for positions in
+// correlated markets, where the worst combination can be much worse than any single outcome.
+//
+// Scenarios are named after the outcomes in the order the keys were given, for example
+// "YES NO YES". An error is returned if a key does not match an open position, or if more
+// than 16 keys are given.
+func (p *Portfolio) Scenarios(keys ...string) ([]ScenarioResult, error) {
+	if len(keys) > maxScenarioPositions {
+		return nil, fmt.Errorf("at most %d positions can be combined, got %d", maxScenarioPositions, len(keys))
+	}
+	if err := p.checkKeys(keys); err != nil {
+		return nil, err
+	}
+
+	out := make([]ScenarioResult, 0, 1<<len(keys))
+	for combo := 0; combo < 1<<len(keys); combo++ {
+		s := Scenario{Outcomes: map[string]float64{}}
+		for i, k := range keys {
+			name := "NO"
+			s.Outcomes[k] = 0
+			if combo&(1<<i) != 0 {
+				name = "YES"
+				s.Outcomes[k] = 1
+			}
+			if i > 0 {
+				s.Name += " "
+			}
+			s.Name += name
+		}
+		out = append(out, p.Evaluate(s))
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].NetWorth < out[j].NetWorth })
+
+	return out, nil
+}
+
+// AnswerScenarios returns what the portfolio would be worth if each answer of a multiple
+// choice market won and every other answer resolved NO, worst first. Each scenario is named
+// after the winning answer's id.
+func (p *Portfolio) AnswerScenarios(marketId string) ([]ScenarioResult, error) {
+	var market *FullMarket
+	for i := range p.Positions {
+		if p.Positions[i].Market.Id == marketId {
+			market = &p.Positions[i].Market
+			break
+		}
+	}
+	if market == nil {
+		return nil, fmt.Errorf("no position in market %v", marketId)
+	}
+	if len(market.Answers) == 0 {
+		return nil, fmt.Errorf("market %v has no answers", marketId)
+	}
+
+	out := make([]ScenarioResult, 0, len(market.Answers))
+	for _, winner := range market.Answers {
+		s := Scenario{Name: winner.Id, Outcomes: map[string]float64{}}
+		for _, a := range market.Answers {
+			s.Outcomes[marketId+"/"+a.Id] = 0
+		}
+		s.Outcomes[marketId+"/"+winner.Id] = 1
+		out = append(out, p.Evaluate(s))
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].NetWorth < out[j].NetWorth })
+
+	return out, nil
+}
+
+// checkKeys returns an error if any of keys doesn't match an open position.
+func (p *Portfolio) checkKeys(keys []string) error {
+	open := map[string]bool{}
+	for _, pos := range p.Open() {
+		open[pos.Key()] = true
+	}
+
+	for _, k := range keys {
+		if !open[k] {
+			return fmt.Errorf("no open position %v", k)
+		}
+	}
+	return nil
+}
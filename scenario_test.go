@@ -0,0 +1,66 @@
+package mango
+
+import "testing"
+
+func TestEvaluate(t *testing.T) {
+	p := testPortfolio()
+
+	if w := p.NetWorth(); w != 157 {
+		t.Fatalf("expected a net worth of M157, got %v", w)
+	}
+
+	r := p.Evaluate(Scenario{Outcomes: map[string]float64{"a": 1}})
+	if r.Balance != 145 || r.InvestmentValue != 32 || r.NetWorth != 177 || r.Change != 20 {
+		t.Errorf("unexpected result %+v", r)
+	}
+
+	if n := len(p.Outcomes()); n != 8 {
+		t.Errorf("expected two outcomes for each open position, got %d", n)
+	}
+}
+
+func TestScenarios(t *testing.T) {
+	p := testPortfolio()
+
+	rs, err := p.Scenarios("a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		name  string
+		worth float64
+	}{
+		{"NO YES", 112},
+		{"NO NO", 152},
+		{"YES YES", 157},
+		{"YES NO", 197},
+	}
+	if len(rs) != len(want) {
+		t.Fatalf("expected %d scenarios, got %+v", len(want), rs)
+	}
+	for i, w := range want {
+		if rs[i].Scenario.Name != w.name || rs[i].NetWorth != w.worth {
+			t.Errorf("scenario %d: got %v worth %v, want %v worth %v", i, rs[i].Scenario.Name, rs[i].NetWorth, w.name, w.worth)
+		}
+	}
+
+	if _, err := p.Scenarios("a", "c"); err == nil {
+		t.Error("expected an error for a resolved position")
+	}
+}
+
+func TestAnswerScenarios(t *testing.T) {
+	rs, err := testPortfolio().AnswerScenarios("d")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rs) != 2 || rs[0].Scenario.Name != "y" || rs[0].NetWorth != 145 || rs[1].Scenario.Name != "x" || rs[1].NetWorth != 175 {
+		t.Errorf("unexpected scenarios %+v", rs)
+	}
+
+	if _, err := testPortfolio().AnswerScenarios("a"); err == nil {
+		t.Error("expected an error for a market without answers")
+	}
+}
@@ -0,0 +1,146 @@
+package screener
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/jonnyspicer/mango"
+)
+
+type kind int
+
+const (
+	boolKind kind = iota
+	numberKind
+	stringKind
+	listKind
+)
+
+func (k kind) String() string {
+	switch k {
+	case boolKind:
+		return "bool"
+	case numberKind:
+		return "number"
+	case stringKind:
+		return "string"
+	default:
+		return "list"
+	}
+}
+
+// value is the result of evaluating a node. Only the field for the node's kind is set.
+type value struct {
+	b    bool
+	num  float64
+	str  string
+	list []string
+}
+
+// field is a market field that expressions can use.
+type field struct {
+	name string
+	kind kind
+}
+
+// structFields maps the lowercased names of a market type's fields to their indices.
+type structFields map[string]int
+
+var (
+	fields     = map[string]field{}
+	liteFields = structFieldsOf(reflect.TypeOf(mango.LiteMarket{}))
+	fullFields = structFieldsOf(reflect.TypeOf(mango.FullMarket{}))
+)
+
+// structFieldsOf finds the fields of a market type that can be used in expressions, which
+// are named like the Go field with a lowercase first letter, such as volume24Hours.
+func structFieldsOf(t reflect.Type) structFields {
+	out := structFields{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		k, ok := kindOf(f.Type)
+		if !ok {
+			continue
+		}
+
+		rs := []rune(f.Name)
+		rs[0] = unicode.ToLower(rs[0])
+		name := string(rs)
+		key := strings.ToLower(name)
+
+		if prev, ok := fields[key]; ok && prev.kind != k {
+			panic(fmt.Sprintf("screener: field %v is a %v and a %v", name, prev.kind, k))
+		}
+		fields[key] = field{name: name, kind: k}
+		out[key] = i
+	}
+	return out
+}
+
+func kindOf(t reflect.Type) (kind, bool) {
+	switch t.Kind() {
+	case reflect.Bool:
+		return boolKind, true
+	case reflect.String:
+		return stringKind, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return numberKind, true
+	case reflect.Slice:
+		if e := t.Elem().Kind(); e == reflect.String || e == reflect.Interface {
+			return listKind, true
+		}
+	}
+	return 0, false
+}
+
+// Fields returns the names of the market fields expressions can use, in alphabetical
+// order. Names are matched without regard to case.
+func Fields() []string {
+	out := make([]string, 0, len(fields))
+	for _, f := range fields {
+		out = append(out, f.name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// record is a market being evaluated.
+type record struct {
+	v      reflect.Value
+	fields structFields
+	now    float64
+}
+
+// get returns the value of a field, or the zero value of its kind if the market's type
+// doesn't have it.
+func (r *record) get(key string) value {
+	i, ok := r.fields[key]
+	if !ok {
+		return value{}
+	}
+
+	f := r.v.Field(i)
+	switch f.Kind() {
+	case reflect.Bool:
+		return value{b: f.Bool()}
+	case reflect.String:
+		return value{str: f.String()}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value{num: float64(f.Int())}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return value{num: float64(f.Uint())}
+	case reflect.Float32, reflect.Float64:
+		return value{num: f.Float()}
+	default:
+		list := make([]string, f.Len())
+		for j := range list {
+			list[j] = fmt.Sprint(f.Index(j).Interface())
+		}
+		return value{list: list}
+	}
+}
@@ -0,0 +1,136 @@
+package screener
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	typ  tokenType
+	text string
+	// num is the value of a number, in milliseconds if it had a duration unit.
+	num float64
+	// pos is the column the token starts at, counting from 1.
+	pos int
+}
+
+func (t token) String() string {
+	if t.typ == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// SyntaxError is returned by [Compile] for an expression that can't be parsed or doesn't
+// make sense, such as one comparing a number with a string.
+type SyntaxError struct {
+	// Pos is the column of the expression the error was found at, counting from 1.
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("column %d: %v", e.Pos, e.Msg)
+}
+
+func errorf(pos int, format string, args ...any) error {
+	return &SyntaxError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// units are the suffixes a number can have to make it a duration, in milliseconds.
+var units = map[string]float64{
+	"ms": 1,
+	"s":  1000,
+	"m":  60 * 1000,
+	"h":  60 * 60 * 1000,
+	"d":  24 * 60 * 60 * 1000,
+	"w":  7 * 24 * 60 * 60 * 1000,
+}
+
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "(", ")", "[", "]", ","}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	rs := []rune(src)
+
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+
+		case unicode.IsDigit(r) || r == '.' && i+1 < len(rs) && unicode.IsDigit(rs[i+1]):
+			for i < len(rs) && (unicode.IsDigit(rs[i]) || rs[i] == '.') {
+				i++
+			}
+			n, err := strconv.ParseFloat(string(rs[start:i]), 64)
+			if err != nil {
+				return nil, errorf(start+1, "invalid number %q", string(rs[start:i]))
+			}
+
+			unitStart := i
+			for i < len(rs) && unicode.IsLetter(rs[i]) {
+				i++
+			}
+			if unit := string(rs[unitStart:i]); unit != "" {
+				ms, ok := units[unit]
+				if !ok {
+					return nil, errorf(unitStart+1, "unknown duration unit %q, expected one of ms, s, m, h, d or w", unit)
+				}
+				n *= ms
+			}
+			toks = append(toks, token{typ: tokNumber, text: string(rs[start:i]), num: n, pos: start + 1})
+
+		case r == '"' || r == '\'':
+			i++
+			var sb strings.Builder
+			for ; i < len(rs) && rs[i] != r; i++ {
+				if rs[i] == '\\' && i+1 < len(rs) {
+					i++
+				}
+				sb.WriteRune(rs[i])
+			}
+			if i == len(rs) {
+				return nil, errorf(start+1, "string not closed")
+			}
+			i++
+			toks = append(toks, token{typ: tokString, text: sb.String(), pos: start + 1})
+
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(rs) && (unicode.IsLetter(rs[i]) || unicode.IsDigit(rs[i]) || rs[i] == '_') {
+				i++
+			}
+			toks = append(toks, token{typ: tokIdent, text: string(rs[start:i]), pos: start + 1})
+
+		default:
+			found := false
+			for _, op := range operators {
+				if strings.HasPrefix(string(rs[i:]), op) {
+					toks = append(toks, token{typ: tokOp, text: op, pos: start + 1})
+					i += len([]rune(op))
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, errorf(start+1, "unexpected character %q", r)
+			}
+		}
+	}
+
+	return append(toks, token{typ: tokEOF, pos: len(rs) + 1}), nil
+}
@@ -0,0 +1,166 @@
+package screener
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/jonnyspicer/mango"
+)
+
+// Screen is a named filter expression, with the search that finds the markets it filters.
+type Screen struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Expr        string `json:"expr"`
+	// Search narrows the markets the expression is run over on the server, which is much
+	// quicker than filtering every market. Its Offset is ignored.
+	Search mango.SearchMarketsRequest `json:"search"`
+}
+
+// Compile compiles the screen's expression.
+func (s Screen) Compile() (*Expr, error) {
+	e, err := Compile(s.Expr)
+	if err != nil {
+		return nil, fmt.Errorf("error compiling screen %v: %w", s.Name, err)
+	}
+	return e, nil
+}
+
+// Run calls fn with each market that matches the screen, as [Expr.Stream] does.
+func (s Screen) Run(mc *mango.Client, fn func(mango.FullMarket) error) error {
+	e, err := s.Compile()
+	if err != nil {
+		return err
+	}
+
+	req := s.Search
+	req.Offset = 0
+	return e.Stream(mc, req, fn)
+}
+
+// Library is a file of saved screens, kept as a JSON array sorted by name.
+type Library struct {
+	Path    string
+	Screens []Screen
+}
+
+// DefaultLibraryPath returns the path of the library used by the command-line tool, which
+// is `screens.json` in the mango directory of [os.UserConfigDir].
+func DefaultLibraryPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "mango", "screens.json"), nil
+}
+
+// Open reads the library at path. A library that doesn't exist yet has no screens.
+func Open(path string) (*Library, error) {
+	l := &Library{Path: path}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading screens: %v", err)
+	}
+
+	if err := json.Unmarshal(b, &l.Screens); err != nil {
+		return nil, fmt.Errorf("error parsing screens in %v: %v", path, err)
+	}
+
+	return l, nil
+}
+
+// Get returns the screen with the given name.
+func (l *Library) Get(name string) (Screen, bool) {
+	for _, s := range l.Screens {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Screen{}, false
+}
+
+// Save adds a screen to the library, replacing any screen with the same name, and writes
+// the library. The screen's expression must compile.
+func (l *Library) Save(s Screen) error {
+	if s.Name == "" {
+		return errors.New("screen must have a name")
+	}
+	if _, err := s.Compile(); err != nil {
+		return err
+	}
+
+	screens := []Screen{s}
+	for _, other := range l.Screens {
+		if other.Name != s.Name {
+			screens = append(screens, other)
+		}
+	}
+	sort.Slice(screens, func(i, j int) bool { return screens[i].Name < screens[j].Name })
+
+	if err := l.write(screens); err != nil {
+		return err
+	}
+	l.Screens = screens
+	return nil
+}
+
+// Delete removes the screen with the given name from the library and writes the library.
+// It reports whether there was such a screen.
+func (l *Library) Delete(name string) (bool, error) {
+	screens := []Screen{}
+	for _, s := range l.Screens {
+		if s.Name != name {
+			screens = append(screens, s)
+		}
+	}
+	if len(screens) == len(l.Screens) {
+		return false, nil
+	}
+
+	if err := l.write(screens); err != nil {
+		return false, err
+	}
+	l.Screens = screens
+	return true, nil
+}
+
+// write replaces the library's file with the screens, so a failed write doesn't lose the
+// screens already saved.
+func (l *Library) write(screens []Screen) error {
+	b, err := json.MarshalIndent(screens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding screens: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0o755); err != nil {
+		return fmt.Errorf("error writing screens: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(l.Path), ".screens-*")
+	if err != nil {
+		return fmt.Errorf("error writing screens: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing screens: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing screens: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), l.Path); err != nil {
+		return fmt.Errorf("error writing screens: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,486 @@
+package screener
+
+import (
+	"strings"
+)
+
+// node is a type-checked node of an expression.
+type node interface {
+	kind() kind
+	eval(r *record) value
+}
+
+type literal struct {
+	k kind
+	v value
+}
+
+func (n literal) kind() kind         { return n.k }
+func (n literal) eval(*record) value { return n.v }
+
+type fieldRef struct {
+	key string
+	k   kind
+}
+
+func (n fieldRef) kind() kind           { return n.k }
+func (n fieldRef) eval(r *record) value { return r.get(n.key) }
+
+type nowRef struct{}
+
+func (nowRef) kind() kind           { return numberKind }
+func (nowRef) eval(r *record) value { return value{num: r.now} }
+
+type not struct{ x node }
+
+func (n not) kind() kind           { return boolKind }
+func (n not) eval(r *record) value { return value{b: !n.x.eval(r).b} }
+
+type neg struct{ x node }
+
+func (n neg) kind() kind           { return numberKind }
+func (n neg) eval(r *record) value { return value{num: -n.x.eval(r).num} }
+
+type logic struct {
+	and  bool
+	x, y node
+}
+
+func (n logic) kind() kind { return boolKind }
+func (n logic) eval(r *record) value {
+	x := n.x.eval(r).b
+	if x != n.and {
+		return value{b: x}
+	}
+	return value{b: n.y.eval(r).b}
+}
+
+type arith struct {
+	op   string
+	x, y node
+}
+
+func (n arith) kind() kind { return numberKind }
+func (n arith) eval(r *record) value {
+	x, y := n.x.eval(r).num, n.y.eval(r).num
+	switch n.op {
+	case "+":
+		return value{num: x + y}
+	case "-":
+		return value{num: x - y}
+	case "*":
+		return value{num: x * y}
+	default:
+		return value{num: x / y}
+	}
+}
+
+type compare struct {
+	op   string
+	x, y node
+}
+
+func (n compare) kind() kind { return boolKind }
+func (n compare) eval(r *record) value {
+	x, y := n.x.eval(r), n.y.eval(r)
+
+	// c is negative, zero or positive as x is less than, equal to or greater than y
+	var c int
+	switch n.x.kind() {
+	case numberKind:
+		switch {
+		case x.num < y.num:
+			c = -1
+		case x.num > y.num:
+			c = 1
+		case x.num != y.num: // NaN
+			return value{b: n.op == "!="}
+		}
+	case stringKind:
+		c = strings.Compare(x.str, y.str)
+	case boolKind:
+		if x.b != y.b {
+			c = 1
+		}
+	}
+
+	switch n.op {
+	case "==":
+		return value{b: c == 0}
+	case "!=":
+		return value{b: c != 0}
+	case "<":
+		return value{b: c < 0}
+	case "<=":
+		return value{b: c <= 0}
+	case ">":
+		return value{b: c > 0}
+	default:
+		return value{b: c >= 0}
+	}
+}
+
+type between struct{ x, lo, hi node }
+
+func (n between) kind() kind { return boolKind }
+func (n between) eval(r *record) value {
+	x := n.x.eval(r).num
+	return value{b: x >= n.lo.eval(r).num && x <= n.hi.eval(r).num}
+}
+
+type in struct {
+	x     node
+	items []node
+}
+
+func (n in) kind() kind { return boolKind }
+func (n in) eval(r *record) value {
+	x := n.x.eval(r)
+	for _, item := range n.items {
+		y := item.eval(r)
+		if n.x.kind() == numberKind && x.num == y.num || n.x.kind() == stringKind && x.str == y.str {
+			return value{b: true}
+		}
+	}
+	return value{b: false}
+}
+
+// contains matches a substring of a string, or an element of a list, ignoring case.
+type contains struct{ x, y node }
+
+func (n contains) kind() kind { return boolKind }
+func (n contains) eval(r *record) value {
+	x, y := n.x.eval(r), n.y.eval(r)
+	if n.x.kind() == stringKind {
+		return value{b: strings.Contains(strings.ToLower(x.str), strings.ToLower(y.str))}
+	}
+	for _, s := range x.list {
+		if strings.EqualFold(s, y.str) {
+			return value{b: true}
+		}
+	}
+	return value{b: false}
+}
+
+// parser is a recursive descent parser for expressions, which checks the kinds of values
+// as it goes. From the lowest precedence to the highest, the grammar is:
+//
+//	or      = and { ("||" | "or") and }
+//	and     = unary { ("&&" | "and") unary }
+//	unary   = ("!" | "not") unary | test
+//	test    = sum [ op sum | "between" sum "and" sum | "in" "[" sum { "," sum } "]" | "contains" sum ]
+//	sum     = product { ("+" | "-") product }
+//	product = factor { ("*" | "/") factor }
+//	factor  = "-" factor | number | string | "true" | "false" | "now" | field | "(" or ")"
+type parser struct {
+	toks []token
+	i    int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.typ != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// is reports whether the next token is one of the given operators or keywords, which are
+// matched without regard to case.
+func (p *parser) is(words ...string) bool {
+	t := p.peek()
+	if t.typ != tokOp && t.typ != tokIdent {
+		return false
+	}
+	for _, w := range words {
+		if strings.EqualFold(t.text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) expect(word string) error {
+	if !p.is(word) {
+		t := p.peek()
+		return errorf(t.pos, "expected %q, found %v", word, t)
+	}
+	p.next()
+	return nil
+}
+
+func parse(src string) (node, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{toks: toks}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.typ != tokEOF {
+		return nil, errorf(t.pos, "unexpected %v", t)
+	}
+	if n.kind() != boolKind {
+		return nil, errorf(1, "expression is a %v, not true or false", n.kind())
+	}
+
+	return n, nil
+}
+
+// want returns an error if n, which starts at pos, isn't one of the given kinds.
+func want(n node, pos int, what string, kinds ...kind) error {
+	for _, k := range kinds {
+		if n.kind() == k {
+			return nil
+		}
+	}
+	return errorf(pos, "%v needs a %v, not a %v", what, kinds[0], n.kind())
+}
+
+func (p *parser) or() (node, error) {
+	return p.logic(false, p.and, "||", "or")
+}
+
+func (p *parser) and() (node, error) {
+	return p.logic(true, p.unary, "&&", "and")
+}
+
+func (p *parser) logic(and bool, operand func() (node, error), words ...string) (node, error) {
+	pos := p.peek().pos
+	x, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.is(words...) {
+		op := p.next()
+		if err := want(x, pos, op.text, boolKind); err != nil {
+			return nil, err
+		}
+
+		pos = p.peek().pos
+		y, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if err := want(y, pos, op.text, boolKind); err != nil {
+			return nil, err
+		}
+		x = logic{and: and, x: x, y: y}
+	}
+
+	return x, nil
+}
+
+func (p *parser) unary() (node, error) {
+	if !p.is("!", "not") {
+		return p.test()
+	}
+
+	p.next()
+	pos := p.peek().pos
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	if err := want(x, pos, "not", boolKind); err != nil {
+		return nil, err
+	}
+	return not{x}, nil
+}
+
+func (p *parser) test() (node, error) {
+	pos := p.peek().pos
+	x, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.is("==", "!=", "<", "<=", ">", ">="):
+		op := p.next().text
+		ypos := p.peek().pos
+		y, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if x.kind() == listKind {
+			return nil, errorf(pos, "lists can't be compared, use contains")
+		}
+		if y.kind() != x.kind() {
+			return nil, errorf(ypos, "can't compare a %v with a %v", x.kind(), y.kind())
+		}
+		if x.kind() == boolKind && op != "==" && op != "!=" {
+			return nil, errorf(pos, "true and false can only be compared with == and !=")
+		}
+		return compare{op: op, x: x, y: y}, nil
+
+	case p.is("between"):
+		p.next()
+		if err := want(x, pos, "between", numberKind); err != nil {
+			return nil, err
+		}
+		lo, err := p.number()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("and"); err != nil {
+			return nil, err
+		}
+		hi, err := p.number()
+		if err != nil {
+			return nil, err
+		}
+		return between{x, lo, hi}, nil
+
+	case p.is("in"):
+		p.next()
+		if err := want(x, pos, "in", stringKind, numberKind); err != nil {
+			return nil, err
+		}
+		if err := p.expect("["); err != nil {
+			return nil, err
+		}
+		var items []node
+		for {
+			ipos := p.peek().pos
+			item, err := p.sum()
+			if err != nil {
+				return nil, err
+			}
+			if item.kind() != x.kind() {
+				return nil, errorf(ipos, "can't look for a %v in a list of %vs", x.kind(), item.kind())
+			}
+			items = append(items, item)
+			if !p.is(",") {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return in{x, items}, nil
+
+	case p.is("contains"):
+		p.next()
+		if err := want(x, pos, "contains", stringKind, listKind); err != nil {
+			return nil, err
+		}
+		ypos := p.peek().pos
+		y, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if err := want(y, ypos, "contains", stringKind); err != nil {
+			return nil, err
+		}
+		return contains{x, y}, nil
+	}
+
+	return x, nil
+}
+
+// number parses a sum that must be a number.
+func (p *parser) number() (node, error) {
+	pos := p.peek().pos
+	n, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if err := want(n, pos, "between", numberKind); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (p *parser) sum() (node, error) {
+	return p.arith(p.product, "+", "-")
+}
+
+func (p *parser) product() (node, error) {
+	return p.arith(p.factor, "*", "/")
+}
+
+func (p *parser) arith(operand func() (node, error), ops ...string) (node, error) {
+	pos := p.peek().pos
+	x, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.is(ops...) {
+		op := p.next().text
+		if err := want(x, pos, op, numberKind); err != nil {
+			return nil, err
+		}
+
+		pos = p.peek().pos
+		y, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if err := want(y, pos, op, numberKind); err != nil {
+			return nil, err
+		}
+		x = arith{op: op, x: x, y: y}
+	}
+
+	return x, nil
+}
+
+func (p *parser) factor() (node, error) {
+	t := p.next()
+	switch t.typ {
+	case tokNumber:
+		return literal{numberKind, value{num: t.num}}, nil
+	case tokString:
+		return literal{stringKind, value{str: t.text}}, nil
+	case tokIdent:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return literal{boolKind, value{b: true}}, nil
+		case "false":
+			return literal{boolKind, value{b: false}}, nil
+		case "now":
+			return nowRef{}, nil
+		}
+		key := strings.ToLower(t.text)
+		f, ok := fields[key]
+		if !ok {
+			return nil, errorf(t.pos, "unknown field %q", t.text)
+		}
+		return fieldRef{key: key, k: f.kind}, nil
+	case tokOp:
+		switch t.text {
+		case "-":
+			pos := p.peek().pos
+			x, err := p.factor()
+			if err != nil {
+				return nil, err
+			}
+			if err := want(x, pos, "-", numberKind); err != nil {
+				return nil, err
+			}
+			return neg{x}, nil
+		case "(":
+			x, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		}
+	}
+
+	return nil, errorf(t.pos, "unexpected %v", t)
+}
@@ -0,0 +1,117 @@
+// Package screener finds markets with filter expressions, going further than the filters
+// Manifold's API offers:
+//
+//	e, err := screener.Compile(`outcomeType == "BINARY" && volume24Hours > 500 &&
+//		probability between 0.15 and 0.85 && closeTime < now + 7d && !isResolved`)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	err = e.Stream(mc, mango.SearchMarketsRequest{Filter: "open"}, func(m mango.FullMarket) error {
+//		fmt.Println(m.Question)
+//		return nil
+//	})
+//
+// Expressions can use the fields of [mango.FullMarket] and [mango.LiteMarket] that are
+// numbers, strings, booleans or lists of strings, named like the Go field with a lowercase
+// first letter, such as volume24Hours; [Fields] lists them. Fields a market's type doesn't
+// have are zero. Times are in milliseconds since the epoch, like the fields, and can be
+// compared with now plus or minus a duration such as 30m, 12h, 7d or 2w.
+//
+// The operators are:
+//
+//	||  or                        either side is true
+//	&&  and                       both sides are true
+//	!   not                       the operand is false
+//	==  !=  <  <=  >  >=          compare numbers, strings, or true and false
+//	x between lo and hi           lo <= x <= hi, for numbers
+//	x in [a, b, ...]              x is one of the values
+//	x contains s                  a string contains s, or a list has s, ignoring case
+//	+  -  *  /                    arithmetic on numbers
+//
+// Screens, which are named expressions with the search that feeds them, can be saved to a
+// [Library] and run again later.
+package screener
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+)
+
+// Expr is a compiled filter expression.
+type Expr struct {
+	src  string
+	root node
+	now  func() time.Time
+}
+
+// Compile parses a filter expression. If it can't be parsed, or uses fields or operators
+// in ways that don't make sense, the error is a [*SyntaxError].
+func Compile(src string) (*Expr, error) {
+	root, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return &Expr{src: src, root: root, now: time.Now}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+func (e *Expr) match(v any, fields structFields) bool {
+	r := &record{v: reflect.ValueOf(v), fields: fields, now: float64(e.now().UnixMilli())}
+	return e.root.eval(r).b
+}
+
+// MatchLite reports whether a market matches the expression.
+func (e *Expr) MatchLite(m mango.LiteMarket) bool {
+	return e.match(m, liteFields)
+}
+
+// MatchFull reports whether a market matches the expression.
+func (e *Expr) MatchFull(m mango.FullMarket) bool {
+	return e.match(m, fullFields)
+}
+
+// Stream calls fn with each market found by [mango.Client.PageSearchMarkets] for req that
+// matches the expression, until there are no more markets, fn returns an error, or a
+// request fails. fn can return [mango.ErrStopPagination] to stop early.
+//
+// Markets that move between pages as the search is paged through are only passed to fn
+// once.
+func (e *Expr) Stream(mc *mango.Client, req mango.SearchMarketsRequest, fn func(mango.FullMarket) error) error {
+	seen := map[string]bool{}
+	return mc.PageSearchMarkets(req, func(page []mango.FullMarket) error {
+		for _, m := range page {
+			if seen[m.Id] || !e.MatchFull(m) {
+				continue
+			}
+			seen[m.Id] = true
+			if err := fn(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// StreamMarkets calls fn with each market found by [mango.Client.PageMarkets] for req, newest
+// first, that matches the expression, until there are no more markets, fn returns an error,
+// or a request fails. fn can return [mango.ErrStopPagination] to stop early.
+func (e *Expr) StreamMarkets(mc *mango.Client, req mango.GetMarketsRequest, fn func(mango.LiteMarket) error) error {
+	return mc.PageMarkets(req, func(page []mango.LiteMarket) error {
+		for _, m := range page {
+			if !e.MatchLite(m) {
+				continue
+			}
+			if err := fn(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,191 @@
+package screener
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+	"github.com/jonnyspicer/mango/mangotest"
+)
+
+var now = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func compile(t *testing.T, src string) *Expr {
+	t.Helper()
+	e, err := Compile(src)
+	if err != nil {
+		t.Fatalf("error compiling %q: %v", src, err)
+	}
+	e.now = func() time.Time { return now }
+	return e
+}
+
+func TestMatch(t *testing.T) {
+	m := mango.FullMarket{
+		Question:      "Will AI pass the bar exam?",
+		OutcomeType:   mango.Binary,
+		Probability:   0.4,
+		Volume24Hours: 800,
+		CloseTime:     now.Add(3 * 24 * time.Hour).UnixMilli(),
+		GroupSlugs:    []string{"ai", "law"},
+		Tags:          []string{"tech"},
+	}
+
+	for _, tt := range []struct {
+		expr string
+		want bool
+	}{
+		{`outcomeType == "BINARY" && volume24Hours > 500 && probability between 0.15 and 0.85 && closeTime < now + 7d && !isResolved`, true},
+		{`closeTime < now + 2d`, false},
+		{`closeTime > now + 2d and closeTime <= now + 72h`, true},
+		{`probability between 0.5 and 0.85 or volume24Hours >= 800`, true},
+		{`not (probability > 0.3) || isResolved`, false},
+		{`isResolved == false`, true},
+		{`question contains "ai pass"`, true},
+		{`groupSlugs contains "LAW" && !(tags contains "sports")`, true},
+		{`outcomeType in ["MULTIPLE_CHOICE", "BINARY"]`, true},
+		{`volume24Hours in [1, 2, 3]`, false},
+		{`(1 - probability) * 100 == 60`, true},
+		{`-probability < -0.3`, true},
+		{`totalBounty / 0 > 1`, false},
+		{`Volume24hours > 1`, true},
+	} {
+		if got := compile(t, tt.expr).MatchFull(m); got != tt.want {
+			t.Errorf("%v: expected %v, got %v", tt.expr, tt.want, got)
+		}
+	}
+
+	// fields a lite market doesn't have are zero
+	lite := mango.LiteMarket{Probability: 0.4, Tags: []interface{}{"tech"}}
+	if !compile(t, `probability == 0.4 && tags contains "tech" && !(groupSlugs contains "x")`).MatchLite(lite) {
+		t.Errorf("expected the lite market to match")
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, tt := range []struct {
+		expr string
+		pos  int
+	}{
+		{`volume > `, 10},
+		{`volume > 5 &&`, 14},
+		{`bogus > 1`, 1},
+		{`volume > "a lot"`, 10},
+		{`question > 1`, 12},
+		{`volume`, 1},
+		{`isResolved < true`, 1},
+		{`probability between 0.1 or 0.2`, 25},
+		{`closeTime < now + 7y`, 20},
+		{`question contains "open`, 19},
+		{`tags == "a"`, 1},
+		{`volume > 1 volume`, 12},
+		{`volume # 1`, 8},
+		{`volume + "1" > 1`, 10},
+	} {
+		_, err := Compile(tt.expr)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Errorf("%v: expected a syntax error, got %v", tt.expr, err)
+			continue
+		}
+		if se.Pos != tt.pos {
+			t.Errorf("%v: expected an error at column %d, got %v", tt.expr, tt.pos, se)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	fs := Fields()
+	has := map[string]bool{}
+	for _, f := range fs {
+		has[f] = true
+	}
+	for _, f := range []string{"outcomeType", "volume24Hours", "closeTime", "groupSlugs", "tags", "isResolved"} {
+		if !has[f] {
+			t.Errorf("expected field %v in %v", f, fs)
+		}
+	}
+	if has["pool"] || has["answers"] {
+		t.Errorf("expected fields that aren't values to be left out, got %v", fs)
+	}
+}
+
+func TestStream(t *testing.T) {
+	s := mangotest.NewServer()
+	defer s.Close()
+
+	for i, v := range []float64{100, 900, 600, 50, 700} {
+		s.AddMarket(mango.FullMarket{Id: string(rune('a' + i)), Question: "Q", Pool: mango.Pool{"YES": 100, "NO": 100}, P: 0.5, Volume24Hours: v})
+	}
+	mc := s.Client()
+
+	e := compile(t, `volume24Hours > 500`)
+	var ids []string
+	err := e.Stream(mc, mango.SearchMarketsRequest{Limit: 2}, func(m mango.FullMarket) error {
+		ids = append(ids, m.Id)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 || ids[0] != "e" || ids[2] != "b" {
+		t.Errorf("unexpected matches %v", ids)
+	}
+
+	ids = nil
+	err = e.StreamMarkets(mc, mango.GetMarketsRequest{}, func(m mango.LiteMarket) error {
+		ids = append(ids, m.Id)
+		return mango.ErrStopPagination
+	})
+	if err != nil || len(ids) != 1 {
+		t.Errorf("expected to stop after the first match, got %v and %v", err, ids)
+	}
+}
+
+func TestLibrary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mango", "screens.json")
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Screens) != 0 {
+		t.Fatalf("expected an empty library, got %+v", l.Screens)
+	}
+
+	if err := l.Save(Screen{Name: "movers", Expr: `volume24Hours > 500`, Search: mango.SearchMarketsRequest{Filter: "open"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Save(Screen{Name: "closing", Expr: `closeTime < now + 1d`}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Save(Screen{Name: "movers", Expr: `volume24Hours > 1000`}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Save(Screen{Name: "broken", Expr: `volume >`}); err == nil {
+		t.Errorf("expected a screen that doesn't compile not to be saved")
+	}
+
+	l, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Screens) != 2 || l.Screens[0].Name != "closing" {
+		t.Fatalf("unexpected screens %+v", l.Screens)
+	}
+	if s, ok := l.Get("movers"); !ok || s.Expr != `volume24Hours > 1000` || s.Search.Filter != "" {
+		t.Errorf("expected the screen to be replaced, got %+v", s)
+	}
+
+	if ok, err := l.Delete("closing"); !ok || err != nil {
+		t.Errorf("expected to delete the screen, got %v and %v", ok, err)
+	}
+	if ok, _ := l.Delete("closing"); ok {
+		t.Errorf("expected deleting again to find nothing")
+	}
+	if l, _ = Open(path); len(l.Screens) != 1 {
+		t.Errorf("expected 1 screen to be left, got %+v", l.Screens)
+	}
+}
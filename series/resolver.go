@@ -0,0 +1,126 @@
+package series
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+)
+
+// Resolver decides how an instance should resolve. It is called once the instance's
+// time has passed, and returns nil if the outcome isn't known yet, in which case it
+// will be called again on the runner's next pass.
+type Resolver func(ctx context.Context, inst Instance) (*mango.ResolveMarketRequest, error)
+
+// ParseOutcome parses a binary market resolution: "YES", "NO", "CANCEL", or "MKT"
+// followed by a probability percentage, eg "MKT 65". An empty string returns nil.
+func ParseOutcome(s string) (*mango.ResolveMarketRequest, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+
+	switch s {
+	case "":
+		return nil, nil
+	case "YES", "NO", "CANCEL":
+		return &mango.ResolveMarketRequest{Outcome: s}, nil
+	}
+
+	if rest, ok := strings.CutPrefix(s, "MKT"); ok {
+		rest = strings.TrimLeft(rest, " :=")
+		p, err := strconv.ParseInt(rest, 10, 64)
+		if err != nil || p < 1 || p > 99 {
+			return nil, fmt.Errorf("invalid MKT probability %q, expected a percentage from 1 to 99", rest)
+		}
+		return &mango.ResolveMarketRequest{Outcome: "MKT", ProbabilityInt: p}, nil
+	}
+
+	return nil, fmt.Errorf("invalid outcome %q", s)
+}
+
+// CSVResolver resolves instances from a CSV file. Each row has the instance's date
+// (2006-01-02) or time (RFC 3339), its outcome, and optionally a probability for MKT
+// resolutions:
+//
+//	date,outcome,probability
+//	2024-06-07,YES,
+//	2024-06-14,MKT,40
+//
+// The file is read each time, so rows can be appended while the runner is running.
+// Instances without a row are left unresolved.
+func CSVResolver(path string) Resolver {
+	return func(_ context.Context, inst Instance) (*mango.ResolveMarketRequest, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		r := csv.NewReader(f)
+		r.FieldsPerRecord = -1
+		r.TrimLeadingSpace = true
+
+		for {
+			rec, err := r.Read()
+			if errors.Is(err, io.EOF) {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error reading %v: %v", path, err)
+			}
+			if len(rec) < 2 || !matches(rec[0], inst) {
+				continue
+			}
+
+			outcome := rec[1]
+			if len(rec) > 2 && strings.TrimSpace(rec[2]) != "" {
+				outcome += " " + rec[2]
+			}
+			return ParseOutcome(outcome)
+		}
+	}
+}
+
+func matches(key string, inst Instance) bool {
+	key = strings.TrimSpace(key)
+	if key == inst.Date() {
+		return true
+	}
+	t, err := time.Parse(time.RFC3339, key)
+	return err == nil && t.Equal(inst.Time)
+}
+
+// CommandResolver resolves instances by running a command and parsing the last line it
+// prints with [ParseOutcome]. Printing nothing leaves the instance unresolved. The command
+// is given the instance in the environment variables SERIES_NAME, SERIES_TIME,
+// SERIES_DATE, SERIES_QUESTION and SERIES_MARKET_ID.
+func CommandResolver(name string, args ...string) Resolver {
+	return func(ctx context.Context, inst Instance) (*mango.ResolveMarketRequest, error) {
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Env = append(os.Environ(),
+			"SERIES_NAME="+inst.Series,
+			"SERIES_TIME="+inst.Time.Format(time.RFC3339),
+			"SERIES_DATE="+inst.Date(),
+			"SERIES_QUESTION="+inst.Question,
+			"SERIES_MARKET_ID="+inst.MarketId,
+		)
+
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("error running %v: %v: %s", name, err, bytes.TrimSpace(stderr.Bytes()))
+		}
+
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		return ParseOutcome(lines[len(lines)-1])
+	}
+}
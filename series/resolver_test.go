@@ -0,0 +1,77 @@
+package series
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseOutcome(t *testing.T) {
+	for in, want := range map[string]string{"yes": "YES", " NO ": "NO", "Cancel": "CANCEL", "MKT 40": "MKT", "mkt:40": "MKT"} {
+		rmr, err := ParseOutcome(in)
+		if err != nil {
+			t.Errorf("%q: %v", in, err)
+			continue
+		}
+		if rmr.Outcome != want {
+			t.Errorf("%q: got %v, want %v", in, rmr.Outcome, want)
+		}
+		if want == "MKT" && rmr.ProbabilityInt != 40 {
+			t.Errorf("%q: got probability %v", in, rmr.ProbabilityInt)
+		}
+	}
+
+	if rmr, err := ParseOutcome(""); rmr != nil || err != nil {
+		t.Errorf("expected nil for an empty outcome, got %v, %v", rmr, err)
+	}
+	for _, in := range []string{"maybe", "MKT", "MKT 100"} {
+		if _, err := ParseOutcome(in); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
+
+func TestCSVResolver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outcomes.csv")
+	data := "date,outcome,probability\n2024-06-07,YES,\n2024-06-14T21:00:00Z,MKT,40\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	resolve := CSVResolver(path)
+
+	rmr, err := resolve(context.Background(), Instance{Time: time.Date(2024, 6, 7, 21, 0, 0, 0, time.UTC)})
+	if err != nil || rmr == nil || rmr.Outcome != "YES" {
+		t.Errorf("unexpected resolution %+v, %v", rmr, err)
+	}
+
+	rmr, err = resolve(context.Background(), Instance{Time: time.Date(2024, 6, 14, 21, 0, 0, 0, time.UTC)})
+	if err != nil || rmr == nil || rmr.Outcome != "MKT" || rmr.ProbabilityInt != 40 {
+		t.Errorf("unexpected resolution %+v, %v", rmr, err)
+	}
+
+	rmr, err = resolve(context.Background(), Instance{Time: time.Date(2024, 6, 21, 21, 0, 0, 0, time.UTC)})
+	if err != nil || rmr != nil {
+		t.Errorf("expected no resolution, got %+v, %v", rmr, err)
+	}
+}
+
+func TestCommandResolver(t *testing.T) {
+	resolve := CommandResolver("sh", "-c", `echo checking >&2; if [ "$SERIES_DATE" = 2024-06-07 ]; then echo YES; fi`)
+
+	rmr, err := resolve(context.Background(), Instance{Time: time.Date(2024, 6, 7, 21, 0, 0, 0, time.UTC)})
+	if err != nil || rmr == nil || rmr.Outcome != "YES" {
+		t.Errorf("unexpected resolution %+v, %v", rmr, err)
+	}
+
+	rmr, err = resolve(context.Background(), Instance{Time: time.Date(2024, 6, 14, 21, 0, 0, 0, time.UTC)})
+	if err != nil || rmr != nil {
+		t.Errorf("expected no resolution, got %+v, %v", rmr, err)
+	}
+
+	if _, err := CommandResolver("sh", "-c", "exit 3")(context.Background(), Instance{}); err == nil {
+		t.Error("expected an error when the command fails")
+	}
+}
@@ -0,0 +1,62 @@
+package series
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// a Wednesday
+	from := time.Date(2024, 6, 5, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, 6, 5, 10, 45, 0, 0, time.UTC)},
+		{"0 21 * * 5", time.Date(2024, 6, 7, 21, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 6, 6, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 9 1,15 * *", time.Date(2024, 6, 15, 9, 0, 0, 0, time.UTC)},
+		{"30 10 * * 3", time.Date(2024, 6, 12, 10, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 6, 9, 0, 0, 0, 0, time.UTC)},
+		{"0 12 29 2 *", time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC)},
+		// when both days are restricted either can match
+		{"0 0 13 * 5", time.Date(2024, 6, 7, 0, 0, 0, 0, time.UTC)},
+		{"0-10/5 11 * * 1-5", time.Date(2024, 6, 5, 11, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.expr, nil)
+		if err != nil {
+			t.Errorf("%q: %v", tt.expr, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleLocation(t *testing.T) {
+	loc := time.FixedZone("IST", 5*3600+1800)
+
+	s, err := ParseSchedule("0 9 * * *", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := s.Next(time.Date(2024, 6, 5, 10, 0, 0, 0, time.UTC))
+	want := time.Date(2024, 6, 6, 9, 0, 0, 0, loc)
+	if !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := ParseSchedule(expr, nil); err == nil {
+			t.Errorf("%q: expected an error", expr)
+		}
+	}
+}
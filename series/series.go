@@ -0,0 +1,474 @@
+// Package series creates recurring markets on a schedule, such as a market each
+// week asking whether a stock will close above a price, and resolves them once
+// the answer is known.
+//
+// A [Series] pairs a cron-style schedule with question and description templates.
+// Each time in the schedule is an instance of the series: a [Runner] creates the
+// market for the next instance ahead of time, adds it to the series' groups and,
+// once the instance's time has passed, asks the series' [Resolver] how it should
+// resolve. Everything the runner does is recorded in a state file, so it can be
+// restarted without creating duplicate markets:
+//
+//	r, err := series.NewRunner(mc, "series.json", []series.Series{{
+//		Name:     "spx-weekly",
+//		Schedule: "0 21 * * 5",
+//		Question: "Will the S&P 500 close above {{.Level}} on {{.Date}}?",
+//		Vars:     map[string]any{"Level": 5000},
+//		Groups:   []string{"stocks-group-id"},
+//		Resolver: series.CSVResolver("closes.csv"),
+//	}})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	r.Run(ctx, 10*time.Minute)
+package series
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+)
+
+// Series describes a recurring market.
+type Series struct {
+	// Name identifies the series in the state file, so it must be unique and shouldn't change.
+	Name string
+
+	// Schedule is a cron expression giving the time of each instance, see [ParseSchedule].
+	Schedule string
+	// Location is the time zone the schedule is interpreted in, UTC if nil.
+	Location *time.Location
+
+	// Question and Description are templates executed with the series' Vars and
+	// .Series, the name of the series; .Time, the time of the instance; .Date, the
+	// date of the instance as 2006-01-02; and .Index, which counts instances from 1.
+	// The description is Markdown.
+	Question    string
+	Description string
+	Vars        map[string]any
+
+	// Market is used as the basis for each market created. Its question, description
+	// and close time are replaced. If its outcome type is empty, markets are binary.
+	Market mango.PostMarketRequest
+	// Groups are the IDs of the groups each market is added to.
+	Groups []string
+
+	// Lead is how far ahead of time instances are created. The next instance
+	// is always created, so by default it is created as soon as the last one's time has passed.
+	Lead time.Duration
+	// CloseOffset is added to the instance's time to get the market's close time. A negative
+	// offset stops trading before the instance's time, a positive one keeps it open
+	// afterwards until it is resolved.
+	CloseOffset time.Duration
+
+	// Resolver decides how instances resolve. If nil, markets are left for you to resolve.
+	Resolver Resolver
+}
+
+// Instance is a single market in a series.
+type Instance struct {
+	Series    string    `json:"series"`
+	Time      time.Time `json:"time"`
+	Question  string    `json:"question"`
+	CloseTime time.Time `json:"closeTime"`
+	MarketId  string    `json:"marketId,omitempty"` // empty until the market has been created
+	Linked    bool      `json:"linked,omitempty"`   // true once the market has been added to its groups
+	Resolved  bool      `json:"resolved,omitempty"`
+	Outcome   string    `json:"outcome,omitempty"`
+}
+
+// Date returns the date of the instance as 2006-01-02.
+func (i Instance) Date() string {
+	return i.Time.Format("2006-01-02")
+}
+
+// Runner creates and resolves the markets in a set of series. A Runner isn't safe for concurrent use.
+type Runner struct {
+	mc     *mango.Client
+	path   string
+	series []Series
+	scheds map[string]*Schedule
+	state  *State
+	logger *slog.Logger
+	now    func() time.Time
+	me     string
+}
+
+// Option configures a [Runner].
+type Option func(*Runner)
+
+// WithLogger sets the logger the runner reports its progress to. By default nothing is logged.
+func WithLogger(l *slog.Logger) Option {
+	return func(r *Runner) {
+		r.logger = l
+	}
+}
+
+// WithClock replaces time.Now, which is useful for testing and backfilling.
+func WithClock(now func() time.Time) Option {
+	return func(r *Runner) {
+		r.now = now
+	}
+}
+
+// NewRunner returns a runner for the given series, keeping its state in the file at statePath.
+func NewRunner(mc *mango.Client, statePath string, series []Series, opts ...Option) (*Runner, error) {
+	r := &Runner{
+		mc:     mc,
+		path:   statePath,
+		series: series,
+		scheds: map[string]*Schedule{},
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		now:    time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	for _, s := range series {
+		if s.Name == "" {
+			return nil, fmt.Errorf("series must have a name")
+		}
+		if _, ok := r.scheds[s.Name]; ok {
+			return nil, fmt.Errorf("duplicate series %q", s.Name)
+		}
+		if s.Question == "" {
+			return nil, fmt.Errorf("series %q must have a question", s.Name)
+		}
+
+		sched, err := ParseSchedule(s.Schedule, s.Location)
+		if err != nil {
+			return nil, fmt.Errorf("series %q: %v", s.Name, err)
+		}
+		r.scheds[s.Name] = sched
+	}
+
+	state, err := LoadState(statePath)
+	if err != nil {
+		return nil, err
+	}
+	r.state = state
+
+	return r, nil
+}
+
+// Instances returns the instances of the named series that the runner knows about, oldest first.
+func (r *Runner) Instances(name string) []Instance {
+	var out []Instance
+	for _, inst := range r.state.Instances {
+		if inst.Series == name {
+			out = append(out, inst)
+		}
+	}
+	return out
+}
+
+// Run calls [Runner.RunOnce] every interval until ctx is cancelled. Errors are logged rather than returned.
+func (r *Runner) Run(ctx context.Context, interval time.Duration) error {
+	for {
+		if err := r.RunOnce(ctx); err != nil {
+			r.logger.Error("series run failed", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// RunOnce creates any instances that are due, adds them to their groups and resolves
+// those that have finished. Instances an earlier run stopped part way through are finished
+// first. It carries on past errors, returning them all at the end.
+func (r *Runner) RunOnce(ctx context.Context) error {
+	var errs []error
+
+	for _, s := range r.series {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := r.create(s); err != nil {
+			errs = append(errs, fmt.Errorf("series %q: %w", s.Name, err))
+		}
+		if err := r.resolve(ctx, s); err != nil {
+			errs = append(errs, fmt.Errorf("series %q: %w", s.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// index returns the position of the instance at t in its series, counting from 1.
+func (r *Runner) index(name string, t time.Time) int {
+	n := 1
+	for _, inst := range r.state.Instances {
+		if inst.Series == name && inst.Time.Before(t) {
+			n++
+		}
+	}
+	return n
+}
+
+// due returns the times of the instances that should exist now.
+func (r *Runner) due(s Series) []time.Time {
+	sched := r.scheds[s.Name]
+	now := r.now()
+
+	next := sched.Next(now)
+	if next.IsZero() {
+		return nil
+	}
+
+	out := []time.Time{next}
+	for len(out) < 100 {
+		next = sched.Next(next)
+		if next.IsZero() || next.After(now.Add(s.Lead)) {
+			break
+		}
+		out = append(out, next)
+	}
+
+	return out
+}
+
+func (r *Runner) create(s Series) error {
+	// finish instances left part way by an earlier run, even if their time has passed
+	for i := range r.state.Instances {
+		inst := &r.state.Instances[i]
+		if inst.Series != s.Name || inst.MarketId != "" && inst.Linked {
+			continue
+		}
+		if err := r.finish(s, inst); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range r.due(s) {
+		if r.state.find(s.Name, t) != nil {
+			continue
+		}
+
+		q, desc, err := s.render(t, r.index(s.Name, t))
+		if err != nil {
+			return err
+		}
+
+		// record the instance before creating its market, so if we stop part way we know to
+		// look for the market rather than creating another one
+		r.state.Instances = append(r.state.Instances, Instance{
+			Series:    s.Name,
+			Time:      t,
+			Question:  q,
+			CloseTime: t.Add(s.CloseOffset),
+		})
+		if err := r.state.Save(r.path); err != nil {
+			return err
+		}
+
+		inst := &r.state.Instances[len(r.state.Instances)-1]
+		if err := r.createMarket(s, inst, desc, false); err != nil {
+			return err
+		}
+		if err := r.link(s, inst); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// finish creates the market for an instance that was recorded without one, or finds it if
+// it was created but not recorded, and adds the market to its groups. A market whose close
+// time has passed can't be created any more, so the instance is left without one.
+func (r *Runner) finish(s Series, inst *Instance) error {
+	if inst.MarketId == "" {
+		if !r.now().Before(inst.CloseTime) {
+			id, err := r.findMarket(inst.Question)
+			if err != nil {
+				return err
+			}
+			if id == "" {
+				r.logger.Warn("series instance closed before its market was created", "series", s.Name, "time", inst.Time)
+				return nil
+			}
+			inst.MarketId = id
+			if err := r.state.Save(r.path); err != nil {
+				return err
+			}
+		} else {
+			_, desc, err := s.render(inst.Time, r.index(s.Name, inst.Time))
+			if err != nil {
+				return err
+			}
+			if err := r.createMarket(s, inst, desc, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	return r.link(s, inst)
+}
+
+// link adds an instance's market to the series' groups, if it hasn't been already.
+func (r *Runner) link(s Series, inst *Instance) error {
+	if inst.Linked {
+		return nil
+	}
+
+	for _, g := range s.Groups {
+		if err := r.mc.AddMarketToGroup(inst.MarketId, g); err != nil {
+			return fmt.Errorf("error adding %v to group %v: %v", inst.MarketId, g, err)
+		}
+	}
+	inst.Linked = true
+
+	return r.state.Save(r.path)
+}
+
+func (r *Runner) createMarket(s Series, inst *Instance, desc string, recovering bool) error {
+	if recovering {
+		id, err := r.findMarket(inst.Question)
+		if err != nil {
+			return err
+		}
+		if id != "" {
+			r.logger.Info("found market for series instance", "series", s.Name, "time", inst.Time, "market", id)
+			inst.MarketId = id
+			return r.state.Save(r.path)
+		}
+	}
+
+	req := s.Market
+	req.Question = inst.Question
+	req.DescriptionMarkdown = desc
+	req.CloseTime = inst.CloseTime.UnixMilli()
+	if req.OutcomeType == "" {
+		req.OutcomeType = mango.Binary
+	}
+
+	id, err := r.mc.CreateMarket(req)
+	if err != nil {
+		return fmt.Errorf("error creating market for %v: %v", inst.Time.Format(time.RFC3339), err)
+	}
+
+	r.logger.Info("created market for series instance", "series", s.Name, "time", inst.Time, "market", *id)
+	inst.MarketId = *id
+
+	return r.state.Save(r.path)
+}
+
+// findMarket looks for a market the authenticated user created with the given question.
+func (r *Runner) findMarket(question string) (string, error) {
+	if r.me == "" {
+		u, err := r.mc.GetAuthenticatedUser()
+		if err != nil {
+			return "", fmt.Errorf("error getting authenticated user: %v", err)
+		}
+		r.me = u.Id
+	}
+
+	found, err := r.mc.SearchMarkets(mango.SearchMarketsRequest{Term: question, Filter: "all", CreatorId: r.me})
+	if err != nil {
+		return "", fmt.Errorf("error searching for %q: %v", question, err)
+	}
+
+	for _, m := range *found {
+		if m.Question == question {
+			return m.Id, nil
+		}
+	}
+
+	return "", nil
+}
+
+func (r *Runner) resolve(ctx context.Context, s Series) error {
+	if s.Resolver == nil {
+		return nil
+	}
+
+	now := r.now()
+	for i := range r.state.Instances {
+		inst := &r.state.Instances[i]
+		if inst.Series != s.Name || inst.MarketId == "" || inst.Resolved || now.Before(inst.Time) {
+			continue
+		}
+
+		rmr, err := s.Resolver(ctx, *inst)
+		if err != nil {
+			return fmt.Errorf("error resolving %v: %v", inst.MarketId, err)
+		}
+		if rmr == nil {
+			r.logger.Debug("series instance not ready to resolve", "series", s.Name, "time", inst.Time, "market", inst.MarketId)
+			continue
+		}
+
+		// stop trading on the known outcome if the market would otherwise stay open
+		if now.Before(inst.CloseTime) {
+			if err := r.mc.CloseMarket(inst.MarketId, nil); err != nil {
+				return fmt.Errorf("error closing %v: %v", inst.MarketId, err)
+			}
+		}
+
+		if err := r.mc.ResolveMarket(inst.MarketId, *rmr); err != nil {
+			return fmt.Errorf("error resolving %v: %v", inst.MarketId, err)
+		}
+
+		r.logger.Info("resolved series instance", "series", s.Name, "time", inst.Time, "market", inst.MarketId, "outcome", rmr.Outcome)
+		inst.Resolved = true
+		inst.Outcome = rmr.Outcome
+		if err := r.state.Save(r.path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// render executes the question and description templates for the instance at t.
+func (s Series) render(t time.Time, index int) (string, string, error) {
+	data := map[string]any{}
+	for k, v := range s.Vars {
+		data[k] = v
+	}
+	data["Series"] = s.Name
+	data["Time"] = t
+	data["Date"] = t.Format("2006-01-02")
+	data["Index"] = index
+
+	q, err := execute(s.Question, data)
+	if err != nil {
+		return "", "", err
+	}
+
+	desc, err := execute(s.Description, data)
+	if err != nil {
+		return "", "", err
+	}
+
+	return q, desc, nil
+}
+
+func execute(s string, data map[string]any) (string, error) {
+	t, err := template.New("").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("error parsing template %q: %v", s, err)
+	}
+
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("error executing template %q: %v", s, err)
+	}
+
+	return strings.TrimSpace(b.String()), nil
+}
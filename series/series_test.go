@@ -0,0 +1,225 @@
+package series
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+	"github.com/jonnyspicer/mango/mangotest"
+)
+
+func TestRunner(t *testing.T) {
+	s := mangotest.NewServer()
+	defer s.Close()
+
+	mc := s.Client()
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	// a Wednesday, the instance closes on Friday evening
+	now := time.Date(2024, 6, 5, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	resolutions := map[string]string{}
+	defs := []Series{{
+		Name:        "spx",
+		Schedule:    "0 21 * * 5",
+		Question:    "Will the S&P 500 close above {{.Level}} on {{.Date}}?",
+		Description: "Instance {{.Index}} of {{.Series}}.",
+		Vars:        map[string]any{"Level": 5000},
+		Market:      mango.PostMarketRequest{InitialProb: 40},
+		Groups:      []string{"stocks"},
+		CloseOffset: -time.Hour,
+		Resolver: func(_ context.Context, inst Instance) (*mango.ResolveMarketRequest, error) {
+			return ParseOutcome(resolutions[inst.Date()])
+		},
+	}}
+
+	r, err := NewRunner(mc, path, defs, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	insts := r.Instances("spx")
+	if len(insts) != 1 {
+		t.Fatalf("expected 1 instance, got %d", len(insts))
+	}
+	first := insts[0]
+	if first.Question != "Will the S&P 500 close above 5000 on 2024-06-07?" {
+		t.Errorf("unexpected question %q", first.Question)
+	}
+
+	m, ok := s.Market(first.MarketId)
+	if !ok {
+		t.Fatalf("market %v was not created", first.MarketId)
+	}
+	if m.CloseTime != time.Date(2024, 6, 7, 20, 0, 0, 0, time.UTC).UnixMilli() || m.Probability != 0.4 {
+		t.Errorf("unexpected market %+v", m)
+	}
+	if m.TextDescription != "Instance 1 of spx." {
+		t.Errorf("unexpected description %q", m.TextDescription)
+	}
+	if g := s.MarketGroups(first.MarketId); len(g) != 1 || g[0] != "stocks" {
+		t.Errorf("unexpected groups %v", g)
+	}
+
+	// a restarted runner shouldn't create the same instance again
+	r, err = NewRunner(mc, path, defs, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(r.Instances("spx")); n != 1 {
+		t.Fatalf("expected 1 instance after restarting, got %d", n)
+	}
+
+	// after the first instance, the next is created but the first can't be resolved yet
+	now = time.Date(2024, 6, 7, 22, 0, 0, 0, time.UTC)
+	if err := r.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	insts = r.Instances("spx")
+	if len(insts) != 2 || insts[0].Resolved {
+		t.Fatalf("unexpected instances %+v", insts)
+	}
+
+	resolutions["2024-06-07"] = "YES"
+	if err := r.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	insts = r.Instances("spx")
+	if !insts[0].Resolved || insts[0].Outcome != "YES" || insts[1].Resolved {
+		t.Errorf("unexpected instances %+v", insts)
+	}
+	if m, _ := s.Market(first.MarketId); !m.IsResolved || m.Resolution != "YES" {
+		t.Errorf("unexpected market %+v", m)
+	}
+}
+
+func TestRunnerRecoversPendingInstance(t *testing.T) {
+	s := mangotest.NewServer()
+	defer s.Close()
+
+	mc := s.Client()
+	path := filepath.Join(t.TempDir(), "state.json")
+	now := time.Date(2024, 6, 5, 12, 0, 0, 0, time.UTC)
+
+	// simulate stopping after creating the market but before recording its ID
+	id, err := mc.CreateMarket(mango.PostMarketRequest{OutcomeType: mango.Binary, Question: "Will it rain on 2024-06-06?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := &State{Instances: []Instance{{
+		Series:   "rain",
+		Time:     time.Date(2024, 6, 6, 0, 0, 0, 0, time.UTC),
+		Question: "Will it rain on 2024-06-06?",
+	}}}
+	if err := state.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewRunner(mc, path, []Series{{Name: "rain", Schedule: "@daily", Question: "Will it rain on {{.Date}}?"}},
+		WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	insts := r.Instances("rain")
+	if len(insts) != 1 || insts[0].MarketId != *id {
+		t.Errorf("expected the existing market to be recorded, got %+v", insts)
+	}
+
+	found, _ := mc.SearchMarkets(mango.SearchMarketsRequest{Term: "rain"})
+	if len(*found) != 1 {
+		t.Errorf("expected no duplicate market, got %d markets", len(*found))
+	}
+}
+
+func TestRunnerFinishesPastInstances(t *testing.T) {
+	s := mangotest.NewServer()
+	defer s.Close()
+
+	mc := s.Client()
+	path := filepath.Join(t.TempDir(), "state.json")
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	// simulate earlier runs that stopped before adding a market to its group, before
+	// recording a market's ID, and before creating a market at all
+	linked, err := mc.CreateMarket(mango.PostMarketRequest{OutcomeType: mango.Binary, Question: "Will it rain on 2024-06-06?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := mc.CreateMarket(mango.PostMarketRequest{OutcomeType: mango.Binary, Question: "Will it rain on 2024-06-07?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := &State{Instances: []Instance{
+		{Series: "rain", Time: time.Date(2024, 6, 6, 0, 0, 0, 0, time.UTC), Question: "Will it rain on 2024-06-06?", MarketId: *linked},
+		{Series: "rain", Time: time.Date(2024, 6, 7, 0, 0, 0, 0, time.UTC), Question: "Will it rain on 2024-06-07?"},
+		{Series: "rain", Time: time.Date(2024, 6, 8, 0, 0, 0, 0, time.UTC), Question: "Will it rain on 2024-06-08?"},
+	}}
+	for i := range state.Instances {
+		state.Instances[i].CloseTime = state.Instances[i].Time
+	}
+	if err := state.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewRunner(mc, path, []Series{{Name: "rain", Schedule: "@daily", Question: "Will it rain on {{.Date}}?", Groups: []string{"weather"}}},
+		WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	insts := r.Instances("rain")
+	if len(insts) != 4 {
+		t.Fatalf("expected the 3 past instances and the next one, got %+v", insts)
+	}
+	if !insts[0].Linked || !insts[1].Linked || insts[1].MarketId != *recorded {
+		t.Errorf("expected the past instances to be finished, got %+v", insts[:2])
+	}
+	for _, id := range []string{*linked, *recorded} {
+		if g := s.MarketGroups(id); len(g) != 1 || g[0] != "weather" {
+			t.Errorf("expected %v to be added to its group, got %v", id, g)
+		}
+	}
+	if insts[2].MarketId != "" {
+		t.Errorf("expected no market to be created after the instance closed, got %+v", insts[2])
+	}
+
+	found, _ := mc.SearchMarkets(mango.SearchMarketsRequest{Term: "rain"})
+	if len(*found) != 3 {
+		t.Errorf("expected only the next instance's market to be created, got %d markets", len(*found))
+	}
+}
+
+func TestNewRunnerValidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	bad := [][]Series{
+		{{Schedule: "@daily", Question: "q"}},
+		{{Name: "a", Schedule: "@daily"}},
+		{{Name: "a", Schedule: "never", Question: "q"}},
+		{{Name: "a", Schedule: "@daily", Question: "q"}, {Name: "a", Schedule: "@daily", Question: "q"}},
+	}
+
+	for i, defs := range bad {
+		if _, err := NewRunner(nil, path, defs); err == nil {
+			t.Errorf("case %d: expected an error", i)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package series
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// State is the record of every instance a [Runner] has created, kept in a JSON file.
+type State struct {
+	Instances []Instance `json:"instances"`
+}
+
+// LoadState reads the state file at path. A missing file is treated as empty state.
+func LoadState(path string) (*State, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &State{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading series state: %v", err)
+	}
+
+	var s State
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("error parsing series state %v: %v", path, err)
+	}
+
+	return &s, nil
+}
+
+// Save writes the state to path. The file is replaced atomically, so a crash
+// while saving leaves the previous state intact.
+func (s *State) Save(path string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding series state: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("error saving series state: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error saving series state: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error saving series state: %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error saving series state: %v", err)
+	}
+
+	return nil
+}
+
+func (s *State) find(series string, t time.Time) *Instance {
+	for i := range s.Instances {
+		if s.Instances[i].Series == series && s.Instances[i].Time.Equal(t) {
+			return &s.Instances[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package mango
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/jonnyspicer/mango/internal/optimize"
+)
+
+// minTrade is the smallest bet or sale, in mana or shares, that [SizeTrade] will suggest.
+const minTrade = 0.01
+
+// SizeRequest represents the parameters for sizing a trade with [SizeTrade].
+type SizeRequest struct {
+	// Market is the market to trade in. It must be a CPMM market, so its Pool and P are set.
+	Market FullMarket
+	// AnswerId is the answer to trade in a multiple choice market. The answer's PoolYes and
+	// PoolNo must be set.
+	AnswerId string
+	// Prob is your probability that the market, or answer, resolves YES.
+	Prob float64
+	// Bankroll is the mana available to bet.
+	Bankroll float64
+	// KellyFraction scales how aggressively to bet, between 0 and 1. 1 is full Kelly.
+	KellyFraction float64
+	// Shares is the position already held in the market, or answer, keyed by outcome
+	// as in [ContractMetric.TotalShares].
+	Shares map[string]float64
+}
+
+// BetSize represents the trade suggested by [SizeTrade].
+type BetSize struct {
+	// Outcome is the outcome the trade bets on. It is empty if no trade is worth making.
+	Outcome string `json:"outcome,omitempty"`
+	// Sale is the sale of shares of the other outcome, if some are held. Selling them comes
+	// before any bet.
+	Sale *BetSimulation `json:"sale,omitempty"`
+	// Bet is the bet on Outcome, if one is worth making after the sale.
+	Bet *BetSimulation `json:"bet,omitempty"`
+	// Amount and Shares are the mana to bet and the shares it is expected to buy.
+	Amount float64 `json:"amount"`
+	Shares float64 `json:"shares"`
+	// ProbBefore and ProbAfter are the market's probability before and after the trade.
+	ProbBefore float64 `json:"probBefore"`
+	ProbAfter  float64 `json:"probAfter"`
+	// ExpectedProfit is the profit you expect from the trade at your probability.
+	ExpectedProfit float64 `json:"expectedProfit"`
+}
+
+// SizeBet returns the bet that maximises the expected log of your bankroll in a binary CPMM
+// market, given your probability that it resolves YES.
+//
+// Unlike [KellyBet], it accounts for the price moving against you as you bet, and for fees, so
+// it never bets so much that the market moves past your probability. See [SizeTrade] for how
+// kellyFraction is applied, and to size trades in multiple choice markets or with an existing
+// position.
+func SizeBet(market FullMarket, myProb, bankroll, kellyFraction float64) (*BetSize, error) {
+	return SizeTrade(SizeRequest{Market: market, Prob: myProb, Bankroll: bankroll, KellyFraction: kellyFraction})
+}
+
+// SizeTrade returns the trade that maximises the expected log of your wealth, which is your
+// bankroll plus the payout of any shares you hold, by simulating trades against the market's
+// CPMM pool.
+//
+// If you hold shares of the outcome you think is overpriced, selling them is considered
+// first, and a bet is only suggested once they would all be sold.
+//
+// Rather than scaling the full Kelly amount, KellyFraction moves your probability towards the
+// market's: a fraction of 0.5 sizes the trade as if your probability were halfway between the
+// market's and yours. For small edges this is the same as betting that fraction of full Kelly,
+// and it stays consistent when a trade is split between a sale and a bet.
+//
+// Each answer of a multiple choice market is treated as a binary market with its own pool
+// and a P of 0.5, so any effect on the other answers is ignored.
+func SizeTrade(req SizeRequest) (*BetSize, error) {
+	pool, p, err := req.pool()
+	if err != nil {
+		return nil, err
+	}
+	if req.Prob <= 0 || req.Prob >= 1 {
+		return nil, fmt.Errorf("prob must be between 0 and 1, got %v", req.Prob)
+	}
+	if req.Bankroll < 0 {
+		return nil, fmt.Errorf("bankroll must not be negative, got %v", req.Bankroll)
+	}
+	if req.KellyFraction <= 0 || req.KellyFraction > 1 {
+		return nil, fmt.Errorf("kellyFraction must be between 0 and 1, got %v", req.KellyFraction)
+	}
+
+	market := CPMMProbability(pool, p)
+	size := &BetSize{ProbBefore: market, ProbAfter: market}
+
+	target := market + req.KellyFraction*(req.Prob-market)
+	if math.Abs(target-market) < 1e-9 {
+		return size, nil
+	}
+
+	size.Outcome = "YES"
+	other := "NO"
+	if target < market {
+		size.Outcome, other = "NO", "YES"
+	}
+
+	w := wealth{cash: req.Bankroll, yes: req.Shares["YES"], no: req.Shares["NO"]}
+	before := w
+
+	// win is the probability, as far as the sizing is concerned, that Outcome pays out
+	win := target
+	if size.Outcome == "NO" {
+		win = 1 - target
+	}
+
+	if held := req.Shares[other]; held > minTrade {
+		sale := func(s float64) (*BetSimulation, wealth) {
+			sim, _ := SimulateSale(pool, p, other, s)
+			return sim, w.sell(other, s, sim.Amount)
+		}
+
+		s := optimize.Maximise(0, held, func(s float64) float64 {
+			_, after := sale(s)
+			return after.growth(size.Outcome, win)
+		})
+		if s > held-minTrade {
+			s = held
+		}
+
+		if s >= minTrade {
+			size.Sale, w = sale(s)
+			pool = size.Sale.Pool
+			size.ProbAfter = size.Sale.ProbAfter
+		}
+		if s < held {
+			size.ExpectedProfit = w.value(req.Prob) - before.value(req.Prob)
+			return size, nil
+		}
+	}
+
+	bet := func(a float64) (*BetSimulation, wealth) {
+		sim, _ := SimulateBet(pool, p, size.Outcome, a)
+		return sim, w.buy(size.Outcome, a, sim.Shares)
+	}
+
+	a := optimize.Maximise(0, w.cash, func(a float64) float64 {
+		_, after := bet(a)
+		return after.growth(size.Outcome, win)
+	})
+	if a >= minTrade {
+		size.Bet, w = bet(a)
+		size.Amount = size.Bet.Amount
+		size.Shares = size.Bet.Shares
+		size.ProbAfter = size.Bet.ProbAfter
+	}
+
+	if size.Sale == nil && size.Bet == nil {
+		size.Outcome = ""
+	}
+	size.ExpectedProfit = w.value(req.Prob) - before.value(req.Prob)
+
+	return size, nil
+}
+
+// pool returns the CPMM pool and P parameter that the request trades against.
+func (req SizeRequest) pool() (Pool, float64, error) {
+	if req.AnswerId == "" {
+		return req.Market.Pool, req.Market.P, validateCPMM(req.Market.Pool, req.Market.P, "YES")
+	}
+
+	for _, a := range req.Market.Answers {
+		if a.Id == req.AnswerId {
+			pool := Pool{"YES": a.PoolYes, "NO": a.PoolNo}
+			return pool, 0.5, validateCPMM(pool, 0.5, "YES")
+		}
+	}
+
+	return nil, 0, fmt.Errorf("market %v has no answer %v", req.Market.Id, req.AnswerId)
+}
+
+// wealth represents mana and the shares held in a single market.
+type wealth struct {
+	cash, yes, no float64
+}
+
+func (w wealth) buy(outcome string, amount, shares float64) wealth {
+	w.cash -= amount
+	if outcome == "YES" {
+		w.yes += shares
+	} else {
+		w.no += shares
+	}
+	return w
+}
+
+func (w wealth) sell(outcome string, shares, amount float64) wealth {
+	w.cash += amount
+	if outcome == "YES" {
+		w.yes -= shares
+	} else {
+		w.no -= shares
+	}
+	return w
+}
+
+// growth returns the expected log of wealth, if outcome pays out with probability win.
+func (w wealth) growth(outcome string, win float64) float64 {
+	ifYes, ifNo := w.cash+w.yes, w.cash+w.no
+	if outcome == "NO" {
+		ifYes, ifNo = ifNo, ifYes
+	}
+	return win*math.Log(ifYes) + (1-win)*math.Log(ifNo)
+}
+
+// value returns the expected value of wealth, if the market resolves YES with probability prob.
+func (w wealth) value(prob float64) float64 {
+	return w.cash + prob*w.yes + (1-prob)*w.no
+}
@@ -0,0 +1,127 @@
+package mango
+
+import (
+	"math"
+	"testing"
+)
+
+func binaryMarket(liquidity float64) FullMarket {
+	return FullMarket{Id: "m1", Pool: Pool{"YES": liquidity, "NO": liquidity}, P: 0.5}
+}
+
+func TestSizeBet(t *testing.T) {
+	// with plenty of liquidity the price barely moves, so the bet is close to plain Kelly
+	deep, err := SizeBet(binaryMarket(1e8), 0.7, 1000, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	naive := KellyBet(0.7, 2) * 1000
+	if deep.Outcome != "YES" || deep.Amount > naive || deep.Amount < naive*0.9 {
+		t.Errorf("expected a bet a little under M%v, got %+v", naive, deep)
+	}
+
+	// in a thin market the same edge is worth much less
+	thin, err := SizeBet(binaryMarket(100), 0.7, 1000, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thin.Amount <= 0 || thin.Amount >= deep.Amount/2 {
+		t.Errorf("expected a much smaller bet, got %+v", thin)
+	}
+	if thin.ProbAfter <= 0.5 || thin.ProbAfter >= 0.7 {
+		t.Errorf("expected the bet to move the market towards but not past 0.7, got %v", thin.ProbAfter)
+	}
+	if thin.ExpectedProfit <= 0 {
+		t.Errorf("expected a positive expected profit, got %v", thin.ExpectedProfit)
+	}
+
+	half, err := SizeBet(binaryMarket(1e8), 0.7, 1000, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(half.Amount-deep.Amount/2) > deep.Amount*0.05 {
+		t.Errorf("expected about half of %v, got %v", deep.Amount, half.Amount)
+	}
+
+	no, err := SizeBet(binaryMarket(100), 0.3, 1000, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if no.Outcome != "NO" || math.Abs(no.Amount-thin.Amount) > 1e-3 || no.ProbAfter <= 0.3 {
+		t.Errorf("expected a NO bet mirroring the YES bet, got %+v", no)
+	}
+
+	none, err := SizeBet(binaryMarket(100), 0.5, 1000, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if none.Outcome != "" || none.Bet != nil || none.Amount != 0 {
+		t.Errorf("expected no bet without an edge, got %+v", none)
+	}
+}
+
+func TestSizeTradeSells(t *testing.T) {
+	// with plenty of NO shares, the best move is to sell some of them
+	size, err := SizeTrade(SizeRequest{
+		Market:        binaryMarket(100),
+		Prob:          0.7,
+		Bankroll:      1000,
+		KellyFraction: 1,
+		Shares:        map[string]float64{"NO": 500},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size.Outcome != "YES" || size.Sale == nil || size.Bet != nil {
+		t.Fatalf("expected only a sale, got %+v", size)
+	}
+	if size.Sale.Outcome != "NO" || size.Sale.Shares <= 0 || size.Sale.Shares >= 500 {
+		t.Errorf("expected to sell some of the NO shares, got %+v", size.Sale)
+	}
+
+	// with only a few, they are all sold and then YES is bought
+	size, err = SizeTrade(SizeRequest{
+		Market:        binaryMarket(100),
+		Prob:          0.7,
+		Bankroll:      1000,
+		KellyFraction: 1,
+		Shares:        map[string]float64{"NO": 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size.Sale == nil || size.Sale.Shares != 2 || size.Bet == nil || size.Amount <= 0 {
+		t.Errorf("expected a sale of every NO share and a YES bet, got %+v", size)
+	}
+	if size.ProbAfter != size.Bet.ProbAfter {
+		t.Errorf("expected the probability after the bet, got %v", size.ProbAfter)
+	}
+}
+
+func TestSizeTradeAnswer(t *testing.T) {
+	m := FullMarket{Id: "m1", Answers: []Answer{
+		{Id: "a", PoolYes: 100, PoolNo: 100},
+		{Id: "b"},
+	}}
+
+	size, err := SizeTrade(SizeRequest{Market: m, AnswerId: "a", Prob: 0.7, Bankroll: 1000, KellyFraction: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	thin, _ := SizeBet(binaryMarket(100), 0.7, 1000, 1)
+	if size.Outcome != "YES" || math.Abs(size.Amount-thin.Amount) > 1e-3 {
+		t.Errorf("expected the answer to be sized like a binary market, got %+v", size)
+	}
+
+	for _, req := range []SizeRequest{
+		{Market: m, AnswerId: "b", Prob: 0.7, Bankroll: 1000, KellyFraction: 1},
+		{Market: m, AnswerId: "c", Prob: 0.7, Bankroll: 1000, KellyFraction: 1},
+		{Market: binaryMarket(100), Prob: 1, Bankroll: 1000, KellyFraction: 1},
+		{Market: binaryMarket(100), Prob: 0.7, Bankroll: 1000, KellyFraction: 0},
+		{Market: binaryMarket(100), Prob: 0.7, Bankroll: -1, KellyFraction: 1},
+	} {
+		if _, err := SizeTrade(req); err == nil {
+			t.Errorf("expected an error for %+v", req)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+)
+
+// ErrNotFound is returned when a record isn't in the store.
+var ErrNotFound = errors.New("not found in store")
+
+// MarketQuery filters markets. Empty fields don't filter.
+type MarketQuery struct {
+	CreatorId string
+	Resolved  *bool
+	From      time.Time // created at or after
+	To        time.Time // created before
+	Limit     int
+}
+
+// BetQuery filters bets. Empty fields don't filter. Cancelled bets are left out unless IncludeCancelled is set.
+type BetQuery struct {
+	UserId           string
+	MarketId         string
+	From             time.Time // created at or after
+	To               time.Time // created before
+	IncludeCancelled bool
+	Limit            int
+}
+
+// TxnQuery filters transactions. Empty fields don't filter.
+type TxnQuery struct {
+	UserId   string // the sender or recipient
+	Category string
+	From     time.Time // created at or after
+	To       time.Time // created before
+	Limit    int
+}
+
+// where builds up a WHERE clause from optional conditions.
+type where struct {
+	conds []string
+	args  []any
+}
+
+func (w *where) add(cond string, args ...any) {
+	w.conds = append(w.conds, cond)
+	w.args = append(w.args, args...)
+}
+
+func (w *where) timeRange(col string, from, to time.Time) {
+	if !from.IsZero() {
+		w.add(col+" >= ?", from.UnixMilli())
+	}
+	if !to.IsZero() {
+		w.add(col+" < ?", to.UnixMilli())
+	}
+}
+
+func (w *where) String() string {
+	if len(w.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.conds, " AND ")
+}
+
+func limit(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" LIMIT %d", n)
+}
+
+// queryJSON runs a query selecting a single JSON column and decodes each row into a T.
+func queryJSON[T any](ctx context.Context, db *sql.DB, query string, args ...any) ([]T, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying store: %v", err)
+	}
+	defer rows.Close()
+
+	var out []T
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("error querying store: %v", err)
+		}
+
+		var v T
+		if err := json.Unmarshal([]byte(data), &v); err != nil {
+			return nil, fmt.Errorf("error decoding stored record: %v", err)
+		}
+		out = append(out, v)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying store: %v", err)
+	}
+	return out, nil
+}
+
+func queryOne[T any](ctx context.Context, db *sql.DB, query string, args ...any) (*T, error) {
+	out, err := queryJSON[T](ctx, db, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, ErrNotFound
+	}
+	return &out[0], nil
+}
+
+// Market returns the stored market with the given ID.
+func (s *Store) Market(ctx context.Context, id string) (*mango.LiteMarket, error) {
+	return queryOne[mango.LiteMarket](ctx, s.db, "SELECT data FROM markets WHERE id = ?", id)
+}
+
+// Markets returns the stored markets matching q, newest first.
+func (s *Store) Markets(ctx context.Context, q MarketQuery) ([]mango.LiteMarket, error) {
+	var w where
+	if q.CreatorId != "" {
+		w.add("creator_id = ?", q.CreatorId)
+	}
+	if q.Resolved != nil {
+		w.add("is_resolved = ?", *q.Resolved)
+	}
+	w.timeRange("created_time", q.From, q.To)
+
+	return queryJSON[mango.LiteMarket](ctx, s.db, "SELECT data FROM markets"+w.String()+" ORDER BY created_time DESC"+limit(q.Limit), w.args...)
+}
+
+// Bets returns the stored bets matching q, oldest first.
+func (s *Store) Bets(ctx context.Context, q BetQuery) ([]mango.Bet, error) {
+	w := q.where("")
+	return queryJSON[mango.Bet](ctx, s.db, "SELECT data FROM bets"+w.String()+" ORDER BY created_time, id"+limit(q.Limit), w.args...)
+}
+
+// where returns the conditions for the query, with the bets table's columns qualified by prefix.
+func (q BetQuery) where(prefix string) *where {
+	w := &where{}
+	if q.UserId != "" {
+		w.add(prefix+"user_id = ?", q.UserId)
+	}
+	if q.MarketId != "" {
+		w.add(prefix+"contract_id = ?", q.MarketId)
+	}
+	if !q.IncludeCancelled {
+		w.add(prefix + "is_cancelled = 0")
+	}
+	w.timeRange(prefix+"created_time", q.From, q.To)
+	return w
+}
+
+// Comments returns the stored comments on a market, oldest first.
+func (s *Store) Comments(ctx context.Context, marketId string) ([]mango.Comment, error) {
+	return queryJSON[mango.Comment](ctx, s.db, "SELECT data FROM comments WHERE contract_id = ? ORDER BY created_time, id", marketId)
+}
+
+// User returns the stored user with the given ID.
+func (s *Store) User(ctx context.Context, id string) (*mango.User, error) {
+	return queryOne[mango.User](ctx, s.db, "SELECT data FROM users WHERE id = ?", id)
+}
+
+// UserByUsername returns the stored user with the given username.
+func (s *Store) UserByUsername(ctx context.Context, username string) (*mango.User, error) {
+	return queryOne[mango.User](ctx, s.db, "SELECT data FROM users WHERE username = ?", username)
+}
+
+// Groups returns every stored group, largest first.
+func (s *Store) Groups(ctx context.Context) ([]mango.Group, error) {
+	return queryJSON[mango.Group](ctx, s.db, "SELECT data FROM groups ORDER BY total_members DESC, slug")
+}
+
+// Txns returns the stored transactions matching q, oldest first.
+func (s *Store) Txns(ctx context.Context, q TxnQuery) ([]mango.Txn, error) {
+	var w where
+	if q.UserId != "" {
+		w.add("(from_id = ? OR to_id = ?)", q.UserId, q.UserId)
+	}
+	if q.Category != "" {
+		w.add("category = ?", q.Category)
+	}
+	w.timeRange("created_time", q.From, q.To)
+
+	return queryJSON[mango.Txn](ctx, s.db, "SELECT data FROM txns"+w.String()+" ORDER BY created_time, id"+limit(q.Limit), w.args...)
+}
+
+// MarketVolume summarises the trading on a market.
+type MarketVolume struct {
+	MarketId string  `json:"marketId"`
+	Question string  `json:"question"`
+	Bets     int     `json:"bets"`
+	Traders  int     `json:"traders"`
+	Volume   float64 `json:"volume"`
+}
+
+// TopMarketsByVolume returns the markets with the most mana traded on them by the stored
+// bets matching q, busiest first. The question is empty if the market isn't stored.
+func (s *Store) TopMarketsByVolume(ctx context.Context, q BetQuery) ([]MarketVolume, error) {
+	w := q.where("b.")
+
+	rows, err := s.db.QueryContext(ctx, `SELECT b.contract_id, COALESCE(m.question, ''), COUNT(*), COUNT(DISTINCT b.user_id), SUM(ABS(b.amount))
+		FROM bets b LEFT JOIN markets m ON m.id = b.contract_id`+w.String()+`
+		GROUP BY b.contract_id ORDER BY SUM(ABS(b.amount)) DESC, b.contract_id`+limit(q.Limit), w.args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying store: %v", err)
+	}
+	defer rows.Close()
+
+	var out []MarketVolume
+	for rows.Next() {
+		var mv MarketVolume
+		if err := rows.Scan(&mv.MarketId, &mv.Question, &mv.Bets, &mv.Traders, &mv.Volume); err != nil {
+			return nil, fmt.Errorf("error querying store: %v", err)
+		}
+		out = append(out, mv)
+	}
+
+	return out, rows.Err()
+}
+
+// DailyVolume is the trading on a single UTC day.
+type DailyVolume struct {
+	Day    string  `json:"day"` // 2006-01-02
+	Bets   int     `json:"bets"`
+	Volume float64 `json:"volume"`
+}
+
+// DailyVolumes returns the number of bets and mana traded each day by the stored bets matching q, oldest first.
+// Days without bets are left out.
+func (s *Store) DailyVolumes(ctx context.Context, q BetQuery) ([]DailyVolume, error) {
+	w := q.where("")
+
+	rows, err := s.db.QueryContext(ctx, `SELECT date(created_time / 1000, 'unixepoch') AS day, COUNT(*), SUM(ABS(amount))
+		FROM bets`+w.String()+` GROUP BY day ORDER BY day`+limit(q.Limit), w.args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying store: %v", err)
+	}
+	defer rows.Close()
+
+	var out []DailyVolume
+	for rows.Next() {
+		var dv DailyVolume
+		if err := rows.Scan(&dv.Day, &dv.Bets, &dv.Volume); err != nil {
+			return nil, fmt.Errorf("error querying store: %v", err)
+		}
+		out = append(out, dv)
+	}
+
+	return out, rows.Err()
+}
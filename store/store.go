@@ -0,0 +1,235 @@
+// Package store mirrors Manifold data into a local SQLite database, so that analysis
+// can be run over it repeatedly without downloading the same records again.
+//
+// [Store.Sync] fetches only what has changed since the last sync: new bets, comments
+// and transactions are fetched until the newest one already stored is reached, and
+// markets are fetched in order of when they were last updated. What is mirrored can be
+// limited to particular users and markets, which is much quicker than mirroring everything:
+//
+//	s, err := store.Open("manifold.db", mc, store.WithUsers("abc123"))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer s.Close()
+//
+//	if _, err := s.Sync(ctx); err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	bets, err := s.Bets(ctx, store.BetQuery{UserId: "abc123"})
+//
+// Every table keeps the full JSON of each record alongside the columns used for
+// querying, and [Store.DB] gives direct access for queries the helpers don't cover.
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+	_ "modernc.org/sqlite"
+)
+
+// Store is a local mirror of Manifold data.
+type Store struct {
+	db      *sql.DB
+	mc      *mango.Client
+	logger  *slog.Logger
+	users   []string
+	markets []string
+	since   time.Time
+}
+
+// Option configures a [Store].
+type Option func(*Store)
+
+// WithUsers limits the bets, transactions and positions that are mirrored to those of the given users,
+// and keeps the users' profiles up to date on every sync.
+func WithUsers(ids ...string) Option {
+	return func(s *Store) {
+		s.users = append(s.users, ids...)
+	}
+}
+
+// WithMarkets limits the bets that are mirrored to those on the given markets, and
+// mirrors the markets' comments, which can only be fetched one market at a time.
+func WithMarkets(ids ...string) Option {
+	return func(s *Store) {
+		s.markets = append(s.markets, ids...)
+	}
+}
+
+// WithSince stops the first sync from fetching bets, markets, users and transactions created before t.
+// Later syncs only fetch what is new anyway.
+func WithSince(t time.Time) Option {
+	return func(s *Store) {
+		s.since = t
+	}
+}
+
+// WithLogger sets the logger sync progress is reported to. By default nothing is logged.
+func WithLogger(l *slog.Logger) Option {
+	return func(s *Store) {
+		s.logger = l
+	}
+}
+
+// Open opens the SQLite database at path, creating it if needed, and brings its schema up to date.
+// The client is used to sync; it may be nil if the store will only be queried.
+func Open(path string, mc *mango.Client, opts ...Option) (*Store, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, fmt.Errorf("error opening store: %v", err)
+	}
+
+	// SQLite only allows one writer, so share a single connection rather than waiting on locks
+	db.SetMaxOpenConns(1)
+
+	s := &Store{
+		db:     db,
+		mc:     mc,
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// DB returns the underlying database, for queries the helpers don't cover.
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// migrations are applied in order, and the number applied is kept in SQLite's user_version.
+var migrations = []string{
+	`CREATE TABLE markets (
+		id TEXT PRIMARY KEY,
+		question TEXT NOT NULL,
+		creator_id TEXT NOT NULL,
+		created_time INTEGER NOT NULL,
+		close_time INTEGER NOT NULL,
+		last_updated_time INTEGER NOT NULL,
+		outcome_type TEXT NOT NULL,
+		probability REAL NOT NULL,
+		volume REAL NOT NULL,
+		is_resolved INTEGER NOT NULL,
+		resolution TEXT NOT NULL,
+		data TEXT NOT NULL
+	);
+	CREATE INDEX markets_creator ON markets (creator_id, created_time);
+	CREATE INDEX markets_updated ON markets (last_updated_time);
+
+	CREATE TABLE bets (
+		id TEXT PRIMARY KEY,
+		contract_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		created_time INTEGER NOT NULL,
+		outcome TEXT NOT NULL,
+		amount REAL NOT NULL,
+		shares REAL NOT NULL,
+		prob_before REAL NOT NULL,
+		prob_after REAL NOT NULL,
+		limit_prob REAL NOT NULL,
+		is_cancelled INTEGER NOT NULL,
+		is_filled INTEGER NOT NULL,
+		data TEXT NOT NULL
+	);
+	CREATE INDEX bets_contract ON bets (contract_id, created_time);
+	CREATE INDEX bets_user ON bets (user_id, created_time);
+	CREATE INDEX bets_time ON bets (created_time);
+
+	CREATE TABLE comments (
+		id TEXT PRIMARY KEY,
+		contract_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		created_time INTEGER NOT NULL,
+		text TEXT NOT NULL,
+		data TEXT NOT NULL
+	);
+	CREATE INDEX comments_contract ON comments (contract_id, created_time);
+	CREATE INDEX comments_user ON comments (user_id, created_time);
+
+	CREATE TABLE users (
+		id TEXT PRIMARY KEY,
+		username TEXT NOT NULL,
+		name TEXT NOT NULL,
+		created_time INTEGER NOT NULL,
+		balance REAL NOT NULL,
+		data TEXT NOT NULL
+	);
+	CREATE INDEX users_username ON users (username);
+
+	CREATE TABLE groups (
+		id TEXT PRIMARY KEY,
+		slug TEXT NOT NULL,
+		name TEXT NOT NULL,
+		total_members INTEGER NOT NULL,
+		total_contracts INTEGER NOT NULL,
+		data TEXT NOT NULL
+	);
+	CREATE INDEX groups_slug ON groups (slug);
+
+	CREATE TABLE txns (
+		id TEXT PRIMARY KEY,
+		created_time INTEGER NOT NULL,
+		from_id TEXT NOT NULL,
+		to_id TEXT NOT NULL,
+		amount REAL NOT NULL,
+		token TEXT NOT NULL,
+		category TEXT NOT NULL,
+		data TEXT NOT NULL
+	);
+	CREATE INDEX txns_from ON txns (from_id, created_time);
+	CREATE INDEX txns_to ON txns (to_id, created_time);
+
+	CREATE TABLE cursors (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);`,
+}
+
+func (s *Store) migrate() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("error reading store version: %v", err)
+	}
+
+	if version > len(migrations) {
+		return fmt.Errorf("store was created by a newer version of mango (schema %d, expected at most %d)", version, len(migrations))
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return fmt.Errorf("error migrating store: %v", err)
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error migrating store to schema %d: %v", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error migrating store to schema %d: %v", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("error migrating store to schema %d: %v", i+1, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+	"github.com/jonnyspicer/mango/mangotest"
+)
+
+func newTestStore(t *testing.T, mc *mango.Client, opts ...Option) *Store {
+	t.Helper()
+
+	s, err := Open(filepath.Join(t.TempDir(), "test.db"), mc, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func TestSync(t *testing.T) {
+	srv := mangotest.NewServer()
+	defer srv.Close()
+
+	srv.AddUser(mango.User{Id: "u1", Username: "alice"})
+	srv.AddGroup(mango.Group{Id: "g1", Slug: "weather", Name: "Weather", TotalMembers: 3})
+	srv.AddMarket(mango.FullMarket{Id: "m1", Question: "Will it rain?", CreatorId: "u1", Pool: mango.Pool{"YES": 100, "NO": 100}, P: 0.5})
+	srv.AddMarket(mango.FullMarket{Id: "m2", Question: "Will it snow?", CreatorId: "u1", Pool: mango.Pool{"YES": 100, "NO": 100}, P: 0.5})
+	srv.AddTxn(mango.Txn{FromId: "u1", ToId: srv.Me().Id, Amount: 50, Category: "MANA_PAYMENT"})
+
+	mc := srv.Client()
+	for _, id := range []string{"m1", "m1", "m2"} {
+		if _, err := mc.PostBet(mango.PostBetRequest{ContractId: id, Outcome: "YES", Amount: 10}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := newTestStore(t, mc)
+	ctx := context.Background()
+
+	stats, err := s.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SyncStats{Markets: 2, Bets: 3, Users: 2, Groups: 1, Txns: 1}
+	if stats != want {
+		t.Errorf("got stats %+v, want %+v", stats, want)
+	}
+
+	// a second sync should only fetch what changed
+	if _, err := mc.PostBet(mango.PostBetRequest{ContractId: "m2", Outcome: "NO", Amount: 5}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err = s.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Bets != 1 || stats.Markets != 1 || stats.Users != 0 {
+		t.Errorf("expected only the new bet and its market, got %+v", stats)
+	}
+
+	m, err := s.Market(ctx, "m2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	live, _ := srv.Market("m2")
+	if m.Probability != live.Probability || m.Volume != 15 {
+		t.Errorf("expected the market to be updated, got %+v", m)
+	}
+
+	bets, err := s.Bets(ctx, BetQuery{MarketId: "m1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bets) != 2 || bets[0].CreatedTime > bets[1].CreatedTime {
+		t.Errorf("unexpected bets %+v", bets)
+	}
+
+	top, err := s.TopMarketsByVolume(ctx, BetQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 2 || top[0].MarketId != "m1" || top[0].Volume != 20 || top[0].Question != "Will it rain?" || top[1].Volume != 15 {
+		t.Errorf("unexpected volumes %+v", top)
+	}
+
+	days, err := s.DailyVolumes(ctx, BetQuery{UserId: srv.Me().Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 1 || days[0].Bets != 4 || days[0].Day != time.Now().UTC().Format("2006-01-02") {
+		t.Errorf("unexpected daily volumes %+v", days)
+	}
+
+	if u, err := s.UserByUsername(ctx, "alice"); err != nil || u.Id != "u1" {
+		t.Errorf("unexpected user %+v, %v", u, err)
+	}
+	if _, err := s.User(ctx, "nobody"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if g, err := s.Groups(ctx); err != nil || len(g) != 1 || g[0].Slug != "weather" {
+		t.Errorf("unexpected groups %+v, %v", g, err)
+	}
+	if txns, err := s.Txns(ctx, TxnQuery{UserId: "u1"}); err != nil || len(txns) != 1 {
+		t.Errorf("unexpected txns %+v, %v", txns, err)
+	}
+	if ms, err := s.Markets(ctx, MarketQuery{CreatorId: "u1", Limit: 1}); err != nil || len(ms) != 1 || ms[0].Id != "m2" {
+		t.Errorf("unexpected markets %+v, %v", ms, err)
+	}
+}
+
+func TestSyncScoped(t *testing.T) {
+	srv := mangotest.NewServer()
+	defer srv.Close()
+
+	srv.AddUser(mango.User{Id: "u1", Username: "alice"})
+	srv.AddMarket(mango.FullMarket{Id: "m1", Question: "Will it rain?", Pool: mango.Pool{"YES": 100, "NO": 100}, P: 0.5})
+	srv.AddMarket(mango.FullMarket{Id: "m2", Question: "Will it snow?", Pool: mango.Pool{"YES": 100, "NO": 100}, P: 0.5})
+	srv.AddMarket(mango.FullMarket{Id: "m3", Question: "Will it hail?"})
+	srv.AddBet(mango.Bet{ContractId: "m2", UserId: "u1", Amount: 10})
+	srv.AddBet(mango.Bet{ContractId: "m3", UserId: "u2", Amount: 10})
+	srv.AddComment(mango.Comment{ContractId: "m1", UserId: "u2", Text: "hi"})
+	srv.AddTxn(mango.Txn{FromId: "u2", ToId: "u3", Amount: 10})
+
+	s := newTestStore(t, srv.Client(), WithUsers("u1"), WithMarkets("m1"))
+
+	stats, err := s.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// m1 is in scope and m2 has a bet by u1, but m3 is neither
+	want := SyncStats{Markets: 2, Bets: 1, Comments: 1, Users: 1}
+	if stats != want {
+		t.Errorf("got stats %+v, want %+v", stats, want)
+	}
+	if _, err := s.Market(context.Background(), "m3"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected m3 not to be stored, got %v", err)
+	}
+	if c, err := s.Comments(context.Background(), "m1"); err != nil || len(c) != 1 {
+		t.Errorf("unexpected comments %+v, %v", c, err)
+	}
+}
+
+func TestSyncSince(t *testing.T) {
+	srv := mangotest.NewServer()
+	defer srv.Close()
+
+	srv.AddBet(mango.Bet{Id: "old", ContractId: "m1", CreatedTime: time.Now().Add(-48 * time.Hour).UnixMilli()})
+	srv.AddBet(mango.Bet{Id: "new", ContractId: "m1"})
+
+	s := newTestStore(t, srv.Client(), WithSince(time.Now().Add(-time.Hour)))
+
+	if _, err := s.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	bets, err := s.Bets(context.Background(), BetQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bets) != 1 || bets[0].Id != "new" {
+		t.Errorf("expected only the new bet, got %+v", bets)
+	}
+}
+
+func TestOpenExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	s, err := Open(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DB().Exec("INSERT INTO cursors (key, value) VALUES ('k', 'v')"); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = Open(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if v, err := s.cursor("k"); err != nil || v != "v" {
+		t.Errorf("expected the data to survive reopening, got %q, %v", v, err)
+	}
+	if _, err := s.Sync(context.Background()); err == nil {
+		t.Error("expected an error syncing without a client")
+	}
+}
@@ -0,0 +1,564 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jonnyspicer/mango"
+)
+
+// SyncStats counts the records fetched and stored by a sync.
+type SyncStats struct {
+	Markets  int `json:"markets"`
+	Bets     int `json:"bets"`
+	Comments int `json:"comments"`
+	Users    int `json:"users"`
+	Groups   int `json:"groups"`
+	Txns     int `json:"txns"`
+}
+
+// Sync fetches everything that has changed since the last sync and stores it. It carries on
+// past errors, returning them together at the end, and can be safely interrupted: the next
+// sync picks up anything that was missed.
+//
+// Without [WithUsers] or [WithMarkets], every market, bet, user and transaction on Manifold is
+// mirrored. Otherwise only the bets, transactions and comments of the given users and markets
+// are, along with the markets they were on.
+//
+// Bets are only fetched once, so later changes to limit orders, such as fills and cancellations,
+// are not picked up.
+func (s *Store) Sync(ctx context.Context) (SyncStats, error) {
+	var stats SyncStats
+	if s.mc == nil {
+		return stats, fmt.Errorf("store was opened without a client")
+	}
+
+	var errs []error
+	step := func(name string, fn func(context.Context, *SyncStats) error) {
+		if err := ctx.Err(); err != nil {
+			return
+		}
+		if err := fn(ctx, &stats); err != nil {
+			errs = append(errs, fmt.Errorf("error syncing %v: %w", name, err))
+		}
+	}
+
+	touched := map[string]bool{}
+	step("bets", func(ctx context.Context, st *SyncStats) error { return s.syncBets(ctx, st, touched) })
+	step("markets", func(ctx context.Context, st *SyncStats) error { return s.syncMarkets(ctx, st, touched) })
+	step("comments", s.syncComments)
+	step("users", s.syncUsers)
+	step("groups", s.syncGroups)
+	step("transactions", s.syncTxns)
+
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	s.logger.Info("store synced", "markets", stats.Markets, "bets", stats.Bets, "comments", stats.Comments,
+		"users", stats.Users, "groups", stats.Groups, "txns", stats.Txns)
+
+	return stats, errors.Join(errs...)
+}
+
+func (s *Store) scoped() bool {
+	return len(s.users) > 0 || len(s.markets) > 0
+}
+
+func (s *Store) syncBets(ctx context.Context, stats *SyncStats, touched map[string]bool) error {
+	type target struct {
+		key string
+		req mango.GetBetsRequest
+	}
+
+	var targets []target
+	for _, id := range s.users {
+		targets = append(targets, target{"bets:user:" + id, mango.GetBetsRequest{UserId: id}})
+	}
+	for _, id := range s.markets {
+		targets = append(targets, target{"bets:market:" + id, mango.GetBetsRequest{ContractId: id}})
+	}
+	if !s.scoped() {
+		targets = append(targets, target{"bets", mango.GetBetsRequest{}})
+	}
+
+	for _, t := range targets {
+		// the cursor is the newest bet already stored, as "id|createdTime"
+		cur, err := s.cursor(t.key)
+		if err != nil {
+			return err
+		}
+		headId, headTimeStr, _ := strings.Cut(cur, "|")
+		headTime, _ := strconv.ParseInt(headTimeStr, 10, 64)
+		if headTime == 0 && !s.since.IsZero() {
+			headTime = s.since.UnixMilli()
+		}
+
+		var newHead string
+		err = s.mc.PageBets(t.req, func(page []mango.Bet) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if newHead == "" {
+				newHead = page[0].Id + "|" + strconv.FormatInt(page[0].CreatedTime, 10)
+			}
+
+			stop := false
+			var fresh []mango.Bet
+			for _, b := range page {
+				if b.Id == headId || b.CreatedTime < headTime {
+					stop = true
+					break
+				}
+				fresh = append(fresh, b)
+			}
+
+			err := s.inTx(ctx, func(tx *sql.Tx) error {
+				for _, b := range fresh {
+					if err := upsertBet(tx, b); err != nil {
+						return err
+					}
+					touched[b.ContractId] = true
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			stats.Bets += len(fresh)
+
+			if stop {
+				return mango.ErrStopPagination
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// only move the cursor once everything newer than it has been stored
+		if newHead != "" {
+			if err := s.setCursor(t.key, newHead); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) syncMarkets(ctx context.Context, stats *SyncStats, touched map[string]bool) error {
+	if s.scoped() {
+		return s.syncMarketsByID(ctx, stats, touched)
+	}
+
+	const key = "markets:updated"
+
+	cur, err := s.cursor(key)
+	if err != nil {
+		return err
+	}
+	since, _ := strconv.ParseInt(cur, 10, 64)
+	if since == 0 && !s.since.IsZero() {
+		since = s.since.UnixMilli()
+	}
+
+	var newest int64
+	err = s.mc.PageMarkets(mango.GetMarketsRequest{Sort: "updated-time"}, func(page []mango.LiteMarket) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		stop := false
+		var fresh []mango.LiteMarket
+		for _, m := range page {
+			// markets updated at the same time as the cursor are fetched again, in case some were missed
+			if m.LastUpdatedTime < since {
+				stop = true
+				break
+			}
+			if m.LastUpdatedTime > newest {
+				newest = m.LastUpdatedTime
+			}
+			fresh = append(fresh, m)
+		}
+
+		err := s.inTx(ctx, func(tx *sql.Tx) error {
+			for _, m := range fresh {
+				if err := upsertMarket(tx, m); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		stats.Markets += len(fresh)
+
+		if stop {
+			return mango.ErrStopPagination
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if newest > 0 {
+		return s.setCursor(key, strconv.FormatInt(newest, 10))
+	}
+	return nil
+}
+
+// syncMarketsByID fetches the markets in scope, and those that new bets were made on.
+func (s *Store) syncMarketsByID(ctx context.Context, stats *SyncStats, touched map[string]bool) error {
+	ids := append([]string(nil), s.markets...)
+	for id := range touched {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	markets, errs := s.mc.GetMarketsByIDs(ids, nil)
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, m := range markets {
+			if err := upsertFullMarket(tx, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	stats.Markets += len(markets)
+
+	var all []error
+	for id, err := range errs {
+		all = append(all, fmt.Errorf("market %v: %w", id, err))
+	}
+	return errors.Join(all...)
+}
+
+// syncComments fetches the comments on the markets in scope. Comments can't be fetched
+// incrementally, so all of them are fetched each time.
+func (s *Store) syncComments(ctx context.Context, stats *SyncStats) error {
+	for _, id := range s.markets {
+		comments, err := s.mc.GetComments(mango.GetCommentsRequest{ContractId: id})
+		if err != nil {
+			return fmt.Errorf("market %v: %w", id, err)
+		}
+
+		err = s.inTx(ctx, func(tx *sql.Tx) error {
+			for _, c := range *comments {
+				if err := upsertComment(tx, c); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		stats.Comments += len(*comments)
+	}
+
+	return nil
+}
+
+func (s *Store) syncUsers(ctx context.Context, stats *SyncStats) error {
+	if s.scoped() {
+		users, errs := s.mc.GetUsersByIDs(s.users, nil)
+
+		err := s.inTx(ctx, func(tx *sql.Tx) error {
+			for _, u := range users {
+				if err := upsertUser(tx, u); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		stats.Users += len(users)
+
+		var all []error
+		for id, err := range errs {
+			all = append(all, fmt.Errorf("user %v: %w", id, err))
+		}
+		return errors.Join(all...)
+	}
+
+	const key = "users"
+
+	headId, err := s.cursor(key)
+	if err != nil {
+		return err
+	}
+
+	var newHead string
+	err = s.mc.PageUsers(mango.GetUsersRequest{}, func(page []mango.User) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if newHead == "" {
+			newHead = page[0].Id
+		}
+
+		stop := false
+		var fresh []mango.User
+		for _, u := range page {
+			if u.Id == headId || (!s.since.IsZero() && u.CreatedTime < s.since.UnixMilli()) {
+				stop = true
+				break
+			}
+			fresh = append(fresh, u)
+		}
+
+		err := s.inTx(ctx, func(tx *sql.Tx) error {
+			for _, u := range fresh {
+				if err := upsertUser(tx, u); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		stats.Users += len(fresh)
+
+		if stop {
+			return mango.ErrStopPagination
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if newHead != "" {
+		return s.setCursor(key, newHead)
+	}
+	return nil
+}
+
+func (s *Store) syncGroups(ctx context.Context, stats *SyncStats) error {
+	groups, err := s.mc.GetGroups(nil)
+	if err != nil {
+		return err
+	}
+
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, g := range *groups {
+			if err := upsertGroup(tx, g); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	stats.Groups += len(*groups)
+
+	return nil
+}
+
+func (s *Store) syncTxns(ctx context.Context, stats *SyncStats) error {
+	type target struct {
+		key string
+		req mango.GetTransactionsRequest
+	}
+
+	var targets []target
+	for _, id := range s.users {
+		targets = append(targets,
+			target{"txns:from:" + id, mango.GetTransactionsRequest{FromId: id}},
+			target{"txns:to:" + id, mango.GetTransactionsRequest{ToId: id}},
+		)
+	}
+	if !s.scoped() {
+		targets = append(targets, target{"txns", mango.GetTransactionsRequest{}})
+	}
+
+	for _, t := range targets {
+		// the cursor is the time of the newest transaction already stored
+		cur, err := s.cursor(t.key)
+		if err != nil {
+			return err
+		}
+		after, _ := strconv.ParseInt(cur, 10, 64)
+		if after == 0 && !s.since.IsZero() {
+			after = s.since.UnixMilli()
+		}
+
+		req := t.req
+		if after > 0 {
+			// transactions at the same time as the cursor are fetched again, in case some were missed
+			req.After = after - 1
+		}
+
+		var newest int64
+		err = s.mc.PageTransactions(req, func(page []mango.Txn) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			err := s.inTx(ctx, func(tx *sql.Tx) error {
+				for _, txn := range page {
+					if err := upsertTxn(tx, txn); err != nil {
+						return err
+					}
+					if txn.CreatedTime > newest {
+						newest = txn.CreatedTime
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			stats.Txns += len(page)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if newest > 0 {
+			if err := s.setCursor(t.key, strconv.FormatInt(newest, 10)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) inTx(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) cursor(key string) (string, error) {
+	var v string
+	err := s.db.QueryRow("SELECT value FROM cursors WHERE key = ?", key).Scan(&v)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return v, err
+}
+
+func (s *Store) setCursor(key, value string) error {
+	_, err := s.db.Exec("INSERT OR REPLACE INTO cursors (key, value) VALUES (?, ?)", key, value)
+	return err
+}
+
+func upsertMarket(tx *sql.Tx, m mango.LiteMarket) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO markets
+		(id, question, creator_id, created_time, close_time, last_updated_time, outcome_type, probability, volume, is_resolved, resolution, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		m.Id, m.Question, m.CreatorId, m.CreatedTime, m.CloseTime, m.LastUpdatedTime, string(m.OutcomeType),
+		m.Probability, m.Volume, m.IsResolved, m.Resolution, string(data))
+	return err
+}
+
+func upsertFullMarket(tx *sql.Tx, m mango.FullMarket) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO markets
+		(id, question, creator_id, created_time, close_time, last_updated_time, outcome_type, probability, volume, is_resolved, resolution, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		m.Id, m.Question, m.CreatorId, m.CreatedTime, m.CloseTime, m.LastUpdatedTime, string(m.OutcomeType),
+		m.Probability, m.Volume, m.IsResolved, m.Resolution, string(data))
+	return err
+}
+
+func upsertBet(tx *sql.Tx, b mango.Bet) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO bets
+		(id, contract_id, user_id, created_time, outcome, amount, shares, prob_before, prob_after, limit_prob, is_cancelled, is_filled, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		b.Id, b.ContractId, b.UserId, b.CreatedTime, b.Outcome, b.Amount, b.Shares, b.ProbBefore, b.ProbAfter,
+		b.LimitProb, b.IsCancelled, b.IsFilled, string(data))
+	return err
+}
+
+func upsertComment(tx *sql.Tx, c mango.Comment) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO comments (id, contract_id, user_id, created_time, text, data)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		c.Id, c.ContractId, c.UserId, c.CreatedTime, c.Text, string(data))
+	return err
+}
+
+func upsertUser(tx *sql.Tx, u mango.User) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO users (id, username, name, created_time, balance, data)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		u.Id, u.Username, u.Name, u.CreatedTime, u.Balance, string(data))
+	return err
+}
+
+func upsertGroup(tx *sql.Tx, g mango.Group) error {
+	data, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO groups (id, slug, name, total_members, total_contracts, data)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		g.Id, g.Slug, g.Name, g.TotalMembers, g.TotalContracts, string(data))
+	return err
+}
+
+func upsertTxn(tx *sql.Tx, t mango.Txn) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO txns (id, created_time, from_id, to_id, amount, token, category, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		t.Id, t.CreatedTime, t.FromId, t.ToId, t.Amount, t.Token, t.Category, string(data))
+	return err
+}
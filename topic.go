@@ -0,0 +1,128 @@
+package mango
+
+import "fmt"
+
+// TopicNode is a topic in a [TopicTree], with the topics below it.
+type TopicNode struct {
+	Group    Group        `json:"group"`
+	Children []*TopicNode `json:"children,omitempty"`
+}
+
+// Walk calls fn with the node's group and then each of the groups below it, depth first,
+// with the depth of each below the node.
+func (n *TopicNode) Walk(fn func(g Group, depth int)) {
+	n.walk(fn, 0)
+}
+
+func (n *TopicNode) walk(fn func(g Group, depth int), depth int) {
+	fn(n.Group, depth)
+	for _, c := range n.Children {
+		c.walk(fn, depth+1)
+	}
+}
+
+// Len returns the number of topics in the tree below and including the node.
+func (n *TopicNode) Len() int {
+	count := 0
+	n.Walk(func(Group, int) { count++ })
+	return count
+}
+
+// Find returns the node for the group with the given ID in the tree below and including
+// the node, or nil if there isn't one.
+func (n *TopicNode) Find(id string) *TopicNode {
+	if n.Group.Id == id {
+		return n
+	}
+	for _, c := range n.Children {
+		if found := c.Find(id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// TopicTree is Manifold's topic hierarchy around one topic: the topics above it, and the
+// tree of topics below it.
+//
+// Manifold's topics form a graph rather than a tree, since a topic can be below several
+// others. Each topic appears once, under the first topic it was found below, nearest the
+// root first.
+type TopicTree struct {
+	Root *TopicNode `json:"root"`
+	// Above are the topics above the root, nearest first.
+	Above []Group `json:"above"`
+}
+
+// TopicTree resolves the hierarchy of topics around the group with the given ID, following
+// the topics above and below it with [Client.GetRelatedGroups] up to depth levels away. A
+// depth of 0 follows the hierarchy as far as it goes.
+//
+// If there is an error making a request, then nil and an error will be returned.
+func (mc *Client) TopicTree(id string, depth int) (*TopicTree, error) {
+	g, err := mc.GetGroupById(id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting group %v: %w", id, err)
+	}
+
+	t := &TopicTree{Root: &TopicNode{Group: *g}, Above: []Group{}}
+	related := map[string]*RelatedGroups{}
+	get := func(id string) (*RelatedGroups, error) {
+		if r, ok := related[id]; ok {
+			return r, nil
+		}
+		r, err := mc.GetRelatedGroups(id)
+		if err != nil {
+			return nil, fmt.Errorf("error getting groups related to %v: %w", id, err)
+		}
+		related[id] = r
+		return r, nil
+	}
+
+	// follow the topics below, a level at a time
+	seen := map[string]bool{id: true}
+	level := []*TopicNode{t.Root}
+	for d := 1; len(level) > 0 && (depth == 0 || d <= depth); d++ {
+		var next []*TopicNode
+		for _, n := range level {
+			r, err := get(n.Group.Id)
+			if err != nil {
+				return nil, err
+			}
+			for _, below := range r.Below {
+				if seen[below.Id] {
+					continue
+				}
+				seen[below.Id] = true
+				c := &TopicNode{Group: below}
+				n.Children = append(n.Children, c)
+				next = append(next, c)
+			}
+		}
+		level = next
+	}
+
+	// and then the topics above
+	seen = map[string]bool{id: true}
+	ids := []string{id}
+	for d := 1; len(ids) > 0 && (depth == 0 || d <= depth); d++ {
+		var next []string
+		for _, id := range ids {
+			r, err := get(id)
+			if err != nil {
+				return nil, err
+			}
+			for _, above := range r.Above {
+				if seen[above.Id] {
+					continue
+				}
+				seen[above.Id] = true
+				t.Above = append(t.Above, above)
+				next = append(next, above.Id)
+			}
+		}
+		ids = next
+	}
+
+	return t, nil
+}
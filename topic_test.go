@@ -0,0 +1,85 @@
+package mango
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTopicTree(t *testing.T) {
+	// science is above physics and astronomy, and physics is also above astronomy, which
+	// is above science, making a cycle
+	groups := map[string]Group{
+		"g0": {Id: "g0", Slug: "everything"},
+		"g1": {Id: "g1", Slug: "science"},
+		"g2": {Id: "g2", Slug: "physics"},
+		"g3": {Id: "g3", Slug: "astronomy"},
+		"g4": {Id: "g4", Slug: "exoplanets"},
+	}
+	below := map[string][]string{"g0": {"g1"}, "g1": {"g2", "g3"}, "g2": {"g3"}, "g3": {"g4", "g1"}}
+
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v0/group/by-id/"), "/"), "/")
+		requests[r.URL.Path]++
+		if len(path) == 1 {
+			json.NewEncoder(w).Encode(groups[path[0]])
+			return
+		}
+
+		id := path[0]
+		related := RelatedGroups{Above: []Group{}, Below: []Group{}}
+		for _, above := range []string{"g0", "g1", "g2", "g3"} {
+			for _, b := range below[above] {
+				if b == id {
+					related.Above = append(related.Above, groups[above])
+				}
+			}
+		}
+		for _, b := range below[id] {
+			related.Below = append(related.Below, groups[b])
+		}
+		json.NewEncoder(w).Encode(related)
+	}))
+	defer server.Close()
+
+	mc := ClientInstance(server.Client(), &server.URL, &testKey)
+	defer mc.Destroy()
+
+	tree, err := mc.TopicTree("g1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var walked []string
+	tree.Root.Walk(func(g Group, depth int) {
+		walked = append(walked, fmt.Sprintf("%v%d", g.Slug, depth))
+	})
+	if got := fmt.Sprint(walked); got != "[science0 physics1 astronomy1 exoplanets2]" {
+		t.Errorf("unexpected tree %v", got)
+	}
+
+	var above []string
+	for _, g := range tree.Above {
+		above = append(above, g.Slug)
+	}
+	if got := fmt.Sprint(above); got != "[everything astronomy physics]" {
+		t.Errorf("expected the topics above, nearest first, got %v", above)
+	}
+	for path, n := range requests {
+		if n > 1 {
+			t.Errorf("expected %v to be requested once, got %d", path, n)
+		}
+	}
+
+	tree, err = mc.TopicTree("g1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Root.Len() != 3 || tree.Root.Find("g4") != nil || len(tree.Above) != 2 {
+		t.Errorf("expected depth 1 to stop at the nearest topics, got %+v", tree)
+	}
+}
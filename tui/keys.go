@@ -0,0 +1,64 @@
+package tui
+
+import "io"
+
+// Key represents a key press. Printable keys are represented by the character they produce.
+type Key string
+
+// Special keys.
+const (
+	KeyUp        Key = "up"
+	KeyDown      Key = "down"
+	KeyEnter     Key = "enter"
+	KeyEsc       Key = "esc"
+	KeyBackspace Key = "backspace"
+	KeyCtrlC     Key = "ctrl+c"
+)
+
+// parseKeys converts the bytes read from a raw terminal into key presses.
+func parseKeys(b []byte) []Key {
+	var keys []Key
+
+	for i := 0; i < len(b); i++ {
+		switch c := b[i]; {
+		case c == 0x1b && i+2 < len(b) && b[i+1] == '[':
+			switch b[i+2] {
+			case 'A':
+				keys = append(keys, KeyUp)
+			case 'B':
+				keys = append(keys, KeyDown)
+			}
+			i += 2
+		case c == 0x1b:
+			keys = append(keys, KeyEsc)
+		case c == '\r' || c == '\n':
+			keys = append(keys, KeyEnter)
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, KeyBackspace)
+		case c == 0x03:
+			keys = append(keys, KeyCtrlC)
+		case c >= 0x20 && c < 0x7f:
+			keys = append(keys, Key(string(rune(c))))
+		}
+	}
+
+	return keys
+}
+
+// readKeys sends the key presses read from r to keys, closing it when r is exhausted.
+func readKeys(r io.Reader, keys chan<- []Key) {
+	defer close(keys)
+
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if ks := parseKeys(buf[:n]); len(ks) > 0 {
+				keys <- ks
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,462 @@
+// Package tui implements an interactive terminal interface for watching markets and trading on them.
+//
+// The interface shows a watchlist of markets with their live probabilities and a sparkline of
+// their recent history, the authenticated user's positions, and a bet ticket that previews the
+// cost and price impact of a bet before it is placed.
+//
+// All state changes go through [App.HandleKey] and [App.Poll], and [App.View] renders the
+// current state as a string, so the interface can be driven without a terminal in tests.
+package tui
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonnyspicer/mango"
+)
+
+const defaultInterval = 5 * time.Second
+const sparklineWidth = 30
+
+// outcomeKeys maps the keys used to choose an outcome to the outcome they choose.
+var outcomeKeys = map[Key]string{"y": "YES", "n": "NO"}
+
+// Config represents the options used to create an [App].
+type Config struct {
+	// Markets is the watchlist, given as market slugs or IDs.
+	Markets []string
+	// Interval is how often probabilities and positions are refreshed. Defaults to 5 seconds.
+	Interval time.Duration
+	// DryRun simulates bets against the market's pool instead of placing them.
+	DryRun bool
+}
+
+// App is the state of the terminal interface.
+type App struct {
+	mc  *mango.Client
+	cfg Config
+
+	me        *mango.User
+	markets   []*watched
+	positions []position
+	selected  int
+	ticket    *ticket
+	status    string
+	quit      bool
+}
+
+type watched struct {
+	market  mango.FullMarket
+	history []point
+}
+
+type point struct {
+	time int64
+	prob float64
+}
+
+type position struct {
+	question string
+	metric   mango.ContractMetric
+}
+
+// ticket is a bet that is being entered but has not been placed yet.
+type ticket struct {
+	outcome string
+	amount  string
+	preview *mango.BetSimulation
+	err     error
+}
+
+// New returns an [App] that uses the given client. Call [App.Load] before using it.
+func New(mc *mango.Client, cfg Config) *App {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+
+	return &App{mc: mc, cfg: cfg}
+}
+
+// Load fetches the watched markets, their history, and the authenticated user's positions.
+func (a *App) Load() error {
+	me, err := a.mc.GetAuthenticatedUser()
+	if err != nil {
+		return fmt.Errorf("error getting authenticated user: %v", err)
+	}
+	a.me = me
+
+	a.markets = nil
+	for _, s := range a.cfg.Markets {
+		m, err := a.mc.GetMarketBySlug(s)
+		if err != nil {
+			if m, err = a.mc.GetMarketByID(s); err != nil {
+				return fmt.Errorf("error getting market %v: %v", s, err)
+			}
+		}
+
+		w := &watched{market: *m}
+		if err := a.loadHistory(w); err != nil {
+			return err
+		}
+		a.markets = append(a.markets, w)
+	}
+
+	return a.loadPositions()
+}
+
+func (a *App) loadHistory(w *watched) error {
+	bets, err := a.mc.GetBets(mango.GetBetsRequest{ContractId: w.market.Id})
+	if err != nil {
+		return fmt.Errorf("error getting bets for market %v: %v", w.market.Id, err)
+	}
+
+	w.history = w.history[:0]
+	for _, b := range *bets {
+		if b.IsCancelled || b.Amount == 0 {
+			continue
+		}
+		w.history = append(w.history, point{b.CreatedTime, b.ProbAfter})
+	}
+
+	// bets are returned newest first
+	sort.Slice(w.history, func(i, j int) bool { return w.history[i].time < w.history[j].time })
+
+	return nil
+}
+
+func (a *App) loadPositions() error {
+	resp, err := a.mc.GetUserContractMetricsWithContracts(mango.GetUserContractMetricsRequest{UserId: a.me.Id})
+	if err != nil {
+		return fmt.Errorf("error getting positions: %v", err)
+	}
+
+	questions := map[string]string{}
+	for _, c := range resp.Contracts {
+		questions[c.Id] = c.Question
+	}
+
+	a.positions = a.positions[:0]
+	for id, cms := range resp.MetricsByContract {
+		for _, cm := range cms {
+			if cm.HasShares {
+				a.positions = append(a.positions, position{questions[id], cm})
+			}
+		}
+	}
+	sort.Slice(a.positions, func(i, j int) bool { return a.positions[i].metric.Payout > a.positions[j].metric.Payout })
+
+	return nil
+}
+
+// Poll refreshes the probabilities of the watched markets and the user's positions.
+func (a *App) Poll() error {
+	if len(a.markets) > 0 {
+		ids := make([]string, len(a.markets))
+		for i, w := range a.markets {
+			ids[i] = w.market.Id
+		}
+
+		probs, err := a.mc.GetMarketProbs(ids)
+		if err != nil {
+			return fmt.Errorf("error getting probabilities: %v", err)
+		}
+
+		now := time.Now().UnixMilli()
+		for _, w := range a.markets {
+			if p, ok := (*probs)[w.market.Id]; ok && len(w.market.Answers) == 0 {
+				w.market.Probability = p.Prob
+				w.history = append(w.history, point{now, p.Prob})
+			}
+		}
+	}
+
+	return a.loadPositions()
+}
+
+// Done reports whether the user has asked to quit.
+func (a *App) Done() bool {
+	return a.quit
+}
+
+// HandleKey updates the state of the interface in response to a key press.
+func (a *App) HandleKey(k Key) {
+	if a.ticket != nil {
+		a.handleTicketKey(k)
+		return
+	}
+
+	switch k {
+	case "q", KeyCtrlC:
+		a.quit = true
+	case "k", KeyUp:
+		if a.selected > 0 {
+			a.selected--
+		}
+	case "j", KeyDown:
+		if a.selected < len(a.markets)-1 {
+			a.selected++
+		}
+	case "r":
+		if err := a.Poll(); err != nil {
+			a.status = err.Error()
+		} else {
+			a.status = "refreshed"
+		}
+	case "y", "n":
+		a.openTicket(outcomeKeys[k])
+	}
+}
+
+func (a *App) openTicket(outcome string) {
+	if len(a.markets) == 0 {
+		return
+	}
+
+	// the probabilities from polling don't include the pool, which the preview needs
+	// bets are previewed and placed against a single pool, which multiple choice markets don't have
+	w := a.markets[a.selected]
+	if len(w.market.Answers) > 0 {
+		a.status = "betting is only supported on binary markets"
+		return
+	}
+	if m, err := a.mc.GetMarketByID(w.market.Id); err == nil {
+		w.market = *m
+	}
+
+	a.ticket = &ticket{outcome: outcome}
+	a.status = ""
+}
+
+func (a *App) handleTicketKey(k Key) {
+	t := a.ticket
+
+	switch {
+	case k == KeyEsc || k == KeyCtrlC:
+		a.ticket = nil
+		return
+	case k == KeyEnter:
+		a.placeBet()
+		return
+	case k == KeyBackspace:
+		if len(t.amount) > 0 {
+			t.amount = t.amount[:len(t.amount)-1]
+		}
+	case k == "y" || k == "n":
+		t.outcome = outcomeKeys[k]
+	case len(k) == 1 && (k[0] >= '0' && k[0] <= '9' || k[0] == '.'):
+		t.amount += string(k)
+	default:
+		return
+	}
+
+	a.updatePreview()
+}
+
+func (a *App) updatePreview() {
+	t := a.ticket
+	t.preview, t.err = nil, nil
+
+	amount, err := strconv.ParseFloat(t.amount, 64)
+	if err != nil || amount <= 0 {
+		return
+	}
+
+	m := a.markets[a.selected].market
+	t.preview, t.err = mango.SimulateBet(m.Pool, m.P, t.outcome, amount)
+}
+
+func (a *App) placeBet() {
+	t := a.ticket
+
+	amount, err := strconv.ParseFloat(t.amount, 64)
+	if err != nil || amount <= 0 {
+		a.status = "enter an amount to bet"
+		return
+	}
+
+	w := a.markets[a.selected]
+	if a.cfg.DryRun {
+		a.ticket = nil
+		sim, err := mango.SimulateBet(w.market.Pool, w.market.P, t.outcome, amount)
+		if err != nil {
+			a.status = fmt.Sprintf("error simulating bet: %v", err)
+			return
+		}
+		a.status = fmt.Sprintf("dry run: would buy %.2f %v shares for M%.2f", sim.Shares, sim.Outcome, sim.Amount)
+		return
+	}
+
+	bet, err := a.mc.PostBet(mango.PostBetRequest{
+		Amount:     amount,
+		ContractId: w.market.Id,
+		Outcome:    t.outcome,
+	})
+	a.ticket = nil
+	if err != nil {
+		a.status = fmt.Sprintf("error placing bet: %v", err)
+		return
+	}
+
+	a.status = fmt.Sprintf("bought %.2f %v shares for M%.2f", bet.Shares, bet.Outcome, bet.Amount)
+
+	w.market.Probability = bet.ProbAfter
+	w.history = append(w.history, point{bet.CreatedTime, bet.ProbAfter})
+	if me, err := a.mc.GetAuthenticatedUser(); err == nil {
+		a.me = me
+	}
+	if err := a.loadPositions(); err != nil {
+		a.status = err.Error()
+	}
+}
+
+// View renders the current state of the interface.
+func (a *App) View() string {
+	var b strings.Builder
+
+	if a.me != nil {
+		fmt.Fprintf(&b, "mango  @%v  balance M%.2f", a.me.Username, a.me.Balance)
+		if a.cfg.DryRun {
+			b.WriteString("  (dry run)")
+		}
+		b.WriteString("\n\n")
+	}
+
+	fmt.Fprintf(&b, "  %-50v %7v  %v\n", "MARKET", "PROB", "HISTORY")
+	for i, w := range a.markets {
+		cursor := " "
+		if i == a.selected {
+			cursor = ">"
+		}
+		prob := formatProb(w.market.Probability)
+		if len(w.market.Answers) > 0 {
+			prob = "multi"
+		}
+		fmt.Fprintf(&b, "%v %-50v %7v  %v\n", cursor, truncate(w.market.Question, 50), prob, sparkline(w.history, sparklineWidth))
+	}
+
+	b.WriteString("\nPOSITIONS\n")
+	if len(a.positions) == 0 {
+		b.WriteString("  none\n")
+	}
+	for _, p := range a.positions {
+		cm := p.metric
+		fmt.Fprintf(&b, "  %-50v %v %.2f  invested M%.2f  value M%.2f  profit M%.2f\n",
+			truncate(p.question, 50), cm.MaxShares, cm.TotalShares[cm.MaxShares], cm.Invested, cm.Payout, cm.Profit)
+	}
+
+	if t := a.ticket; t != nil {
+		m := a.markets[a.selected].market
+		fmt.Fprintf(&b, "\nBET %v on %q\n  amount: M%v_\n", t.outcome, truncate(m.Question, 50), t.amount)
+		switch {
+		case t.err != nil:
+			fmt.Fprintf(&b, "  preview unavailable: %v\n", t.err)
+		case t.preview != nil:
+			p := t.preview
+			fees := p.Fees.CreatorFee + p.Fees.PlatformFee + p.Fees.LiquidityFee
+			fmt.Fprintf(&b, "  shares %.2f  fees M%.2f  avg price %v  payout if %v M%.2f\n",
+				p.Shares, fees, formatProb(p.Amount/p.Shares), p.Outcome, p.Shares)
+			fmt.Fprintf(&b, "  prob %v → %v (%+.1f)\n", formatProb(p.ProbBefore), formatProb(p.ProbAfter), (p.ProbAfter-p.ProbBefore)*100)
+		}
+		b.WriteString("\n[0-9] amount  [y/n] outcome  [enter] place bet  [esc] cancel\n")
+	} else {
+		b.WriteString("\n[y] buy YES  [n] buy NO  [↑/↓] select  [r] refresh  [q] quit\n")
+	}
+
+	if a.status != "" {
+		b.WriteString(a.status + "\n")
+	}
+
+	return b.String()
+}
+
+// Run loads the interface and then redraws it on out in response to key presses read
+// from in, until the user quits or in is closed. in should be a terminal in raw mode.
+func (a *App) Run(in io.Reader, out io.Writer) error {
+	if err := a.Load(); err != nil {
+		return err
+	}
+
+	keys := make(chan []Key, 1)
+	go readKeys(in, keys)
+
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+
+	a.render(out)
+	for !a.quit {
+		select {
+		case ks, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			for _, k := range ks {
+				a.HandleKey(k)
+			}
+		case <-ticker.C:
+			if a.ticket != nil {
+				continue
+			}
+			if err := a.Poll(); err != nil {
+				a.status = err.Error()
+			}
+		}
+		a.render(out)
+	}
+
+	return nil
+}
+
+func (a *App) render(out io.Writer) {
+	// clear the screen, and use CRLF line endings since the terminal is in raw mode
+	fmt.Fprint(out, "\x1b[H\x1b[2J"+strings.ReplaceAll(a.View(), "\n", "\r\n"))
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline renders the probability history as a line of the given width, with
+// each character showing the last probability within an equal slice of time.
+func sparkline(history []point, width int) string {
+	if len(history) == 0 {
+		return ""
+	}
+
+	start, end := history[0].time, history[len(history)-1].time
+	span := end - start + 1
+
+	out := make([]rune, width)
+	j := 0
+	prob := history[0].prob
+	for i := 0; i < width; i++ {
+		limit := start + span*int64(i+1)/int64(width)
+		for j < len(history) && history[j].time < limit {
+			prob = history[j].prob
+			j++
+		}
+		level := int(prob * float64(len(sparks)))
+		if level >= len(sparks) {
+			level = len(sparks) - 1
+		}
+		if level < 0 {
+			level = 0
+		}
+		out[i] = sparks[level]
+	}
+
+	return string(out)
+}
+
+func formatProb(p float64) string {
+	return fmt.Sprintf("%.1f%%", p*100)
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,186 @@
+package tui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jonnyspicer/mango"
+	"github.com/jonnyspicer/mango/mangotest"
+)
+
+func newTestApp(t *testing.T) (*App, *mangotest.Server) {
+	t.Helper()
+
+	s := mangotest.NewServer()
+	t.Cleanup(s.Close)
+
+	s.AddMarket(mango.FullMarket{
+		Id:       "m1",
+		Question: "Will it rain?",
+		Url:      "https://manifold.markets/someone/will-it-rain",
+		Pool:     mango.Pool{"YES": 100, "NO": 100},
+		P:        0.5,
+	})
+	s.AddMarket(mango.FullMarket{
+		Id:       "m2",
+		Question: "Will it snow?",
+		Url:      "https://manifold.markets/someone/will-it-snow",
+		Pool:     mango.Pool{"YES": 300, "NO": 100},
+		P:        0.5,
+	})
+	s.AddBet(mango.Bet{ContractId: "m1", Outcome: "YES", Amount: 10, ProbBefore: 0.4, ProbAfter: 0.5})
+
+	a := New(s.Client(), Config{Markets: []string{"will-it-rain", "m2"}})
+	if err := a.Load(); err != nil {
+		t.Fatalf("error loading app: %v", err)
+	}
+
+	return a, s
+}
+
+func TestWatchlist(t *testing.T) {
+	a, _ := newTestApp(t)
+
+	view := a.View()
+	for _, want := range []string{"Will it rain?", "50.0%", "Will it snow?", "25.0%", "@me", "none"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("expected view to contain %q, got:\n%v", want, view)
+		}
+	}
+
+	a.HandleKey(KeyDown)
+	if !strings.Contains(a.View(), "> Will it snow?") {
+		t.Errorf("expected second market to be selected, got:\n%v", a.View())
+	}
+}
+
+func TestBetTicket(t *testing.T) {
+	a, s := newTestApp(t)
+
+	for _, k := range []Key{"y", "2", "0"} {
+		a.HandleKey(k)
+	}
+
+	view := a.View()
+	if !strings.Contains(view, "BET YES") || !strings.Contains(view, "M20_") || !strings.Contains(view, "50.0% →") {
+		t.Fatalf("expected bet ticket with preview, got:\n%v", view)
+	}
+
+	preview := a.ticket.preview
+	a.HandleKey(KeyEnter)
+
+	bets := s.Bets()
+	if len(bets) != 2 || bets[1].Amount != 20 || bets[1].Outcome != "YES" {
+		t.Fatalf("expected a M20 YES bet to be placed, got %+v", bets)
+	}
+	if bets[1].Shares != preview.Shares {
+		t.Errorf("expected preview shares %v to match placed bet %v", preview.Shares, bets[1].Shares)
+	}
+
+	view = a.View()
+	if !strings.Contains(view, "bought") || strings.Contains(view, "none") {
+		t.Errorf("expected status and new position, got:\n%v", view)
+	}
+	if !strings.Contains(view, "balance M980.00") {
+		t.Errorf("expected updated balance, got:\n%v", view)
+	}
+}
+
+func TestCancelTicket(t *testing.T) {
+	a, s := newTestApp(t)
+
+	for _, k := range []Key{"n", "5", KeyBackspace, "3", KeyEsc} {
+		a.HandleKey(k)
+	}
+
+	if a.ticket != nil {
+		t.Error("expected ticket to be closed")
+	}
+	if len(s.Bets()) != 1 {
+		t.Errorf("expected no bet to be placed, got %+v", s.Bets())
+	}
+}
+
+func TestDryRunBet(t *testing.T) {
+	a, s := newTestApp(t)
+	a.cfg.DryRun = true
+
+	for _, k := range []Key{"y", "2", "0", KeyEnter} {
+		a.HandleKey(k)
+	}
+
+	if len(s.Bets()) != 1 {
+		t.Errorf("expected no bet to be placed, got %+v", s.Bets())
+	}
+	if view := a.View(); !strings.Contains(view, "dry run: would buy") || !strings.Contains(view, "balance M1000.00  (dry run)") {
+		t.Errorf("expected simulated bet, got:\n%v", view)
+	}
+}
+
+func TestMultipleChoiceTicket(t *testing.T) {
+	a, s := newTestApp(t)
+	s.AddMarket(mango.FullMarket{
+		Id:       "m3",
+		Question: "Who will win?",
+		Answers:  []mango.Answer{{Id: "a1", Text: "Alice", PoolYes: 100, PoolNo: 100}, {Id: "a2", Text: "Bob", PoolYes: 100, PoolNo: 100}},
+	})
+	a.cfg.Markets = append(a.cfg.Markets, "m3")
+	if err := a.Load(); err != nil {
+		t.Fatalf("error loading app: %v", err)
+	}
+
+	for _, k := range []Key{KeyDown, KeyDown, "y", "1", "0", KeyEnter} {
+		a.HandleKey(k)
+	}
+
+	if a.ticket != nil {
+		t.Error("expected no ticket to be opened")
+	}
+	if len(s.Bets()) != 1 {
+		t.Errorf("expected no bet to be placed, got %+v", s.Bets())
+	}
+	if !strings.Contains(a.View(), "only supported on binary markets") {
+		t.Errorf("expected status explaining why, got:\n%v", a.View())
+	}
+}
+
+func TestRun(t *testing.T) {
+	a, s := newTestApp(t)
+
+	var out bytes.Buffer
+	if err := a.Run(strings.NewReader("y10\rq"), &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !a.Done() {
+		t.Error("expected app to have quit")
+	}
+	if len(s.Bets()) != 2 {
+		t.Errorf("expected a bet to be placed, got %+v", s.Bets())
+	}
+	if !strings.Contains(out.String(), "\r\n") {
+		t.Error("expected output to use CRLF line endings")
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	got := parseKeys([]byte("a\x1b[A\x1b[B\r\x7f\x1b"))
+	want := []Key{"a", KeyUp, KeyDown, KeyEnter, KeyBackspace, KeyEsc}
+
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("key %d: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	s := sparkline([]point{{0, 0}, {50, 0.5}, {100, 1}}, 3)
+	if s != "▁▅█" {
+		t.Errorf("unexpected sparkline %q", s)
+	}
+}
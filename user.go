@@ -35,6 +35,11 @@ type User struct {
 	Website       string       `json:"website,omitempty"`
 	TwitterHandle string       `json:"twitterHandle,omitempty"`
 	DiscordHandle string       `json:"discordHandle,omitempty"`
+	// IsBot, IsAdmin and IsTrustworthy are set for Manifold's bots, its staff, and the
+	// users it labels trustworthy.
+	IsBot         bool `json:"isBot,omitempty"`
+	IsAdmin       bool `json:"isAdmin,omitempty"`
+	IsTrustworthy bool `json:"isTrustworthy,omitempty"`
 }
 
 // DisplayUser represents a lightweight user object with only display information.
@@ -1,6 +1,14 @@
 package mango
 
-import "testing"
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
 
 func TestKellyBet(t *testing.T) {
 	tests := []struct {
@@ -27,54 +35,56 @@ func TestKellyBet(t *testing.T) {
 	}
 }
 
-func TestGetUsernames(t *testing.T) {
-	text := `export const BOT_USERNAMES = [
-		'pos',
-		'v',
-		'acc',
-		'jerk',
-	]
-	export const CORE_USERNAMES = [
-		'Austin',
-		'JamesGrugett',
-		'SG',
-	]
-	export const CHECK_USERNAMES = [
-		'EliezerYudkowsky',
-		'memestiny',
-		'ScottAlexander',
-	]`
-
-	expectedBotUsernames := []string{"pos", "v", "acc", "jerk"}
-	expectedCoreUsernames := []string{"Austin", "JamesGrugett", "SG"}
-	expectedCheckUsernames := []string{"EliezerYudkowsky", "memestiny", "ScottAlexander"}
-
-	botUsernames := getUsernames(Bot, text)
-	coreUsernames := getUsernames(Core, text)
-	checkUsernames := getUsernames(Check, text)
-
-	if !stringSliceEqual(botUsernames, expectedBotUsernames) {
-		t.Errorf("Expected BOT_USERNAMES %v, but got %v", expectedBotUsernames, botUsernames)
+func TestGetUsersOfType(t *testing.T) {
+	users := []User{
+		{Id: "u5", Username: "acc", IsBot: true},
+		{Id: "u4", Username: "Austin", IsAdmin: true},
+		{Id: "u3", Username: "alice"},
+		{Id: "u2", Username: "pos", IsBot: true, IsTrustworthy: true},
+		{Id: "u1", Username: "ScottAlexander", IsTrustworthy: true},
 	}
 
-	if !stringSliceEqual(coreUsernames, expectedCoreUsernames) {
-		t.Errorf("Expected CORE_USERNAMES %v, but got %v", expectedCoreUsernames, coreUsernames)
-	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.TrimSuffix(r.URL.Path, "/") != "/v0/users" {
+			http.NotFound(w, r)
+			return
+		}
+		start := 0
+		for i, u := range users {
+			if u.Id == r.URL.Query().Get("before") {
+				start = i + 1
+			}
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		json.NewEncoder(w).Encode(users[start:min(start+limit, len(users))])
+	}))
+	defer server.Close()
 
-	if !stringSliceEqual(checkUsernames, expectedCheckUsernames) {
-		t.Errorf("Expected CHECK_USERNAMES %v, but got %v", expectedCheckUsernames, checkUsernames)
-	}
-}
+	mc := ClientInstance(server.Client(), &server.URL, &testKey)
+	defer mc.Destroy()
 
-// Helper function to compare string slices
-func stringSliceEqual(a, b []string) bool {
-	if len(a) != len(b) {
-		return false
-	}
-	for i := range a {
-		if a[i] != b[i] {
-			return false
+	for _, tt := range []struct {
+		t    UsernameType
+		want string
+	}{
+		{Bot, "[acc pos]"},
+		{Core, "[Austin]"},
+		{Check, "[pos ScottAlexander]"},
+	} {
+		got, err := mc.GetUsersOfType(tt.t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, u := range *got {
+			names = append(names, u.Username)
 		}
+		if fmt.Sprint(names) != tt.want {
+			t.Errorf("%v: expected %v, got %v", tt.t, tt.want, names)
+		}
+	}
+
+	if _, err := mc.GetUsersOfType("MOD"); err == nil {
+		t.Errorf("expected an error for an unknown type")
 	}
-	return true
 }